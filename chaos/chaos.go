@@ -0,0 +1,240 @@
+package chaos
+
+import (
+    "fmt"
+    "math/rand"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/fault"
+    "github.com/TestInABox/gostackinabox/latency"
+    "github.com/TestInABox/gostackinabox/redact"
+)
+
+/*
+    Chaos disrupts a share of the requests the router hands to its
+    registered services, e.g
+
+        c := chaos.New(42).Rate(0.1).Services("https://api.example")
+        r.Chaos = c
+        c.ReportOnFailure(t)
+
+    Each disrupted request gets one of the configured disruptions, picked
+    at random with equal weight:
+
+        Statuses  a reply with the status instead of calling the service;
+                  429 and 503 replies carry a Retry-After header
+        Latency   a delay sampled from the profile before calling the service
+        Faults    a transport failure from the fault package
+
+    Without any configured it replies 500, 502, 503 and 429 or refuses the
+    connection. Requests no service handles are never disrupted, and
+    without Services every registered service is.
+
+    Every decision comes from the seed, so the same seed disrupts the same
+    requests the same way provided the requests are sent in the same order.
+    The seed and each disruption are logged, and ReportOnFailure adds them
+    to the output of a failed test so it can be reproduced.
+ */
+
+type Chaos struct {
+    seed     int64
+    rate     float64
+    services map[string]bool
+    actions  []action
+
+    lock     sync.Mutex
+    rnd      *rand.Rand
+    requests int
+    injected []Injection
+}
+
+// Injection records a disrupted request
+type Injection struct {
+    // Request is the position of the request, from 1, among those sent to the selected services
+    Request int
+    Method  string
+    URL     string
+    Service string
+    Fault   string
+}
+
+func (i Injection) String() string {
+    return fmt.Sprintf("#%d %s %s (%s): %s", i.Request, i.Method, i.URL, i.Service, i.Fault)
+}
+
+type action struct {
+    name    string
+    status  common.HttpStatusCode
+    profile latency.Profile
+    fault   *fault.Fault
+}
+
+func New(seed int64) *Chaos {
+    log.Printf("Chaos seed: %d", seed)
+    return &Chaos{
+        seed: seed,
+        rate: 0.1,
+        rnd: rand.New(rand.NewSource(seed)),
+    }
+}
+
+// Seed returns the seed to pass to New to reproduce the disruptions
+func (c *Chaos) Seed() int64 {
+    return c.seed
+}
+
+// Rate sets the share, between 0 and 1, of the requests to disrupt; it defaults to 0.1
+func (c *Chaos) Rate(rate float64) *Chaos {
+    c.rate = rate
+    return c
+}
+
+// Services limits the disruption to the services, named as they were registered with the router
+func (c *Chaos) Services(names ...string) *Chaos {
+    if c.services == nil {
+        c.services = make(map[string]bool)
+    }
+    for _, name := range names {
+        c.services[name] = true
+    }
+    return c
+}
+
+// Statuses adds replies with each of the statuses to the disruptions
+func (c *Chaos) Statuses(statuses ...common.HttpStatusCode) *Chaos {
+    for _, status := range statuses {
+        c.actions = append(c.actions, action{
+            name: fmt.Sprintf("%d %s", status, http.StatusText(int(status))),
+            status: status,
+        })
+    }
+    return c
+}
+
+// Latency adds delays sampled from the profile to the disruptions
+func (c *Chaos) Latency(profile latency.Profile) *Chaos {
+    c.actions = append(c.actions, action{profile: profile})
+    return c
+}
+
+// Faults adds each of the transport failures to the disruptions
+func (c *Chaos) Faults(faults ...*fault.Fault) *Chaos {
+    for _, f := range faults {
+        c.actions = append(c.actions, action{name: f.String(), fault: f})
+    }
+    return c
+}
+
+func (c *Chaos) defaultActions() []action {
+    if len(c.actions) > 0 {
+        return c.actions
+    }
+    defaults := &Chaos{}
+    return defaults.Statuses(500, 502, 503, 429).Faults(fault.ConnectionRefused()).actions
+}
+
+// choose decides whether to disrupt the request sent to the service; rd scrubs the URL that is logged
+func (c *Chaos) choose(request *http.Request, service string, rd *redact.Redactor) (chosen action, delay time.Duration, ok bool) {
+    if len(service) == 0 || (len(c.services) > 0 && !c.services[service]) {
+        return
+    }
+    actions := c.defaultActions()
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    c.requests++
+    if c.rnd.Float64() >= c.rate {
+        return
+    }
+    chosen, ok = actions[c.rnd.Intn(len(actions))], true
+    name := chosen.name
+    if chosen.profile != nil {
+        delay = chosen.profile.Sample(c.rnd)
+        name = fmt.Sprintf("latency of %v", delay)
+    }
+    injection := Injection{
+        Request: c.requests,
+        Method: request.Method,
+        URL: rd.URL(request.URL).String(),
+        Service: service,
+        Fault: name,
+    }
+    c.injected = append(c.injected, injection)
+    log.Printf("Chaos (seed %d) injecting %s", c.seed, injection)
+    return
+}
+
+// BuildFunc turns a reply into the response the client receives, e.g Router.BuildResponse
+type BuildFunc func(reply *common.HttpReply, request *http.Request) (*http.Response, error)
+
+// Apply disrupts the request the service would handle, or sends it with next; injected
+// replies are turned into responses with build and rd scrubs the URLs that are logged
+func (c *Chaos) Apply(
+    request *http.Request,
+    service string,
+    rd *redact.Redactor,
+    build BuildFunc,
+    next func(*http.Request) (*http.Response, error),
+) (response *http.Response, err error) {
+    chosen, delay, ok := c.choose(request, service, rd)
+    switch {
+    case !ok:
+        return next(request)
+    case chosen.fault != nil:
+        return chosen.fault.Apply(request, next)
+    case chosen.profile != nil:
+        if err = common.Sleep(&common.HttpCall{Request: request}, delay); err != nil {
+            return
+        }
+        return next(request)
+    }
+    return build(reply(chosen.status), request)
+}
+
+func reply(status common.HttpStatusCode) *common.HttpReply {
+    builder := common.NewReply(status)
+    if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+        builder.WithHeader("Retry-After", "1")
+    }
+    return builder.Text(fmt.Sprintf("gostackinabox: chaos injected %d", status))
+}
+
+// Injected returns the disruptions so far, in the order they happened
+func (c *Chaos) Injected() []Injection {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    return append([]Injection{}, c.injected...)
+}
+
+// Summary describes the seed and every disruption
+func (c *Chaos) Summary() string {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+    var builder strings.Builder
+    fmt.Fprintf(&builder, "chaos seed %d disrupted %d of %d requests", c.seed, len(c.injected), c.requests)
+    for _, injection := range c.injected {
+        fmt.Fprintf(&builder, "\n    %s", injection)
+    }
+    return builder.String()
+}
+
+// TestReporter is the subset of testing.TB ReportOnFailure uses
+type TestReporter interface {
+    Cleanup(func())
+    Failed() bool
+    Logf(format string, args ...interface{})
+}
+
+// ReportOnFailure logs the Summary to the test once it has finished, if it failed
+func (c *Chaos) ReportOnFailure(t TestReporter) {
+    t.Cleanup(func() {
+        if t.Failed() {
+            t.Logf("%s", c.Summary())
+        }
+    })
+}
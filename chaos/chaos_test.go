@@ -0,0 +1,219 @@
+package chaos_test
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "syscall"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/chaos"
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/fault"
+    "github.com/TestInABox/gostackinabox/latency"
+    "github.com/TestInABox/gostackinabox/redact"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+func newClient(t *testing.T, c *chaos.Chaos) *http.Client {
+    r := router.New()
+    stubs := stub.New(r)
+    stubs.On("GET", "https://api.example/data").Reply(200).Body("data")
+    stubs.On("GET", "https://other.example/data").Reply(200).Body("data")
+    if err := stubs.Err(); err != nil {
+        t.Fatalf("Unexpected configuration error: %v", err)
+    }
+    r.Chaos = c
+    return &http.Client{Transport: r}
+}
+
+// outcomes sends the requests and describes the result of each
+func outcomes(client *http.Client, urls ...string) (result []string) {
+    for _, url := range urls {
+        response, err := client.Get(url)
+        switch {
+        case err != nil:
+            result = append(result, "error")
+        default:
+            result = append(result, fmt.Sprint(response.StatusCode))
+            response.Body.Close()
+        }
+    }
+    return
+}
+
+func repeat(url string, count int) (result []string) {
+    for index := 0; index < count; index++ {
+        result = append(result, url)
+    }
+    return
+}
+
+func Test_Chaos_Reproducible(t *testing.T) {
+    urls := repeat("https://api.example/data", 100)
+    first := chaos.New(42).Rate(0.3)
+    second := chaos.New(42).Rate(0.3)
+    firstOutcomes := outcomes(newClient(t, first), urls...)
+    secondOutcomes := outcomes(newClient(t, second), urls...)
+    if strings.Join(firstOutcomes, ",") != strings.Join(secondOutcomes, ",") {
+        t.Errorf("The same seed gave different outcomes:\n%v\n%v", firstOutcomes, secondOutcomes)
+    }
+    if first.Summary() != second.Summary() {
+        t.Errorf("The same seed gave different summaries:\n%s\n%s", first.Summary(), second.Summary())
+    }
+
+    injected := first.Injected()
+    if len(injected) < 15 || len(injected) > 45 {
+        t.Errorf("Unexpected number of disruptions for a rate of 0.3: %d", len(injected))
+    }
+    seen := map[string]bool{}
+    for _, injection := range injected {
+        seen[firstOutcomes[injection.Request-1]] = true
+        if injection.Service != "https://api.example" {
+            t.Errorf("Unexpected service: %s", injection)
+        }
+    }
+    for _, outcome := range []string{"500", "502", "503", "429", "error"} {
+        if !seen[outcome] {
+            t.Errorf("The default disruptions never produced %s: %v", outcome, firstOutcomes)
+        }
+    }
+    if summary := first.Summary(); !strings.HasPrefix(summary, fmt.Sprintf("chaos seed 42 disrupted %d of 100 requests", len(injected))) {
+        t.Errorf("Unexpected summary: %s", summary)
+    }
+}
+
+func Test_Chaos_Selection(t *testing.T) {
+    c := chaos.New(1).Rate(1).Services("https://api.example").Statuses(503).Faults(fault.ConnectionReset(0))
+    client := newClient(t, c)
+
+    for _, outcome := range outcomes(client, repeat("https://other.example/data", 10)...) {
+        if outcome != "200" {
+            t.Errorf("Unselected service was disrupted: %s", outcome)
+        }
+    }
+    if outcome := outcomes(client, "https://unhandled.example/")[0]; outcome != "595" {
+        t.Errorf("Unhandled request was disrupted: %s", outcome)
+    }
+
+    statuses, resets := 0, 0
+    for index := 0; index < 20; index++ {
+        response, err := client.Get("https://api.example/data")
+        if err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        switch {
+        case response.StatusCode == 503:
+            statuses++
+            if response.Header.Get("Retry-After") != "1" {
+                t.Errorf("Expected a Retry-After header: %v", response.Header)
+            }
+        default:
+            buffer := make([]byte, 10)
+            if _, err := response.Body.Read(buffer); !errors.Is(err, syscall.ECONNRESET) {
+                t.Errorf("Expected a connection reset but found %v", err)
+            }
+            resets++
+        }
+        response.Body.Close()
+    }
+    if statuses == 0 || resets == 0 || len(c.Injected()) != 20 {
+        t.Errorf("Unexpected disruptions: %d statuses, %d resets, %d injected", statuses, resets, len(c.Injected()))
+    }
+}
+
+func Test_Chaos_Latency(t *testing.T) {
+    c := chaos.New(1).Rate(1).Latency(latency.Fixed(20 * time.Millisecond))
+    client := newClient(t, c)
+    started := time.Now()
+    if outcome := outcomes(client, "https://api.example/data")[0]; outcome != "200" || time.Since(started) < 20*time.Millisecond {
+        t.Errorf("Unexpected outcome: %s after %v", outcome, time.Since(started))
+    }
+    if injected := c.Injected(); len(injected) != 1 || injected[0].Fault != "latency of 20ms" {
+        t.Errorf("Unexpected injections: %v", injected)
+    }
+}
+
+type reporter struct {
+    failed  bool
+    cleanup func()
+    logged  string
+}
+
+func (r *reporter) Cleanup(fn func()) {
+    r.cleanup = fn
+}
+
+func (r *reporter) Failed() bool {
+    return r.failed
+}
+
+func (r *reporter) Logf(format string, args ...interface{}) {
+    r.logged = fmt.Sprintf(format, args...)
+}
+
+func Test_Chaos_ReportOnFailure(t *testing.T) {
+    c := chaos.New(7).Rate(1).Statuses(common.HttpStatusCode(500))
+    outcomes(newClient(t, c), "https://api.example/data")
+
+    passed := &reporter{}
+    c.ReportOnFailure(passed)
+    passed.cleanup()
+    if len(passed.logged) > 0 {
+        t.Errorf("Unexpected report for a passing test: %s", passed.logged)
+    }
+
+    failed := &reporter{failed: true}
+    c.ReportOnFailure(failed)
+    failed.cleanup()
+    expected := "chaos seed 7 disrupted 1 of 1 requests\n    #1 GET https://api.example/data (https://api.example): 500 Internal Server Error"
+    if failed.logged != expected {
+        t.Errorf("Unexpected report: %q", failed.logged)
+    }
+}
+
+func Test_Chaos_Router(t *testing.T) {
+    r := router.New()
+    r.ProtoMajor, r.ProtoMinor = 2, 0
+    r.Redactor = &redact.Redactor{Query: []redact.Rule{{Name: "page"}}}
+    for _, name := range []string{"first", "second", "third"} {
+        handler := &service.ServiceHandler{
+            Matcher: &common.BasicServerURI{
+                Protocol: "https",
+                Host: "api.example",
+            },
+            FuncHandler: func(hc *common.HttpCall) (*common.HttpReply, error) {
+                return common.NewReply(200).Text("data"), nil
+            },
+        }
+        if err := r.RegisterService(name, handler); err != nil {
+            t.Fatalf("Unexpected error registering service: %v", err)
+        }
+    }
+    c := chaos.New(1).Rate(1).Statuses(500)
+    r.Chaos = c
+    client := &http.Client{Transport: r}
+
+    for index := 0; index < 20; index++ {
+        response, err := client.Get("https://api.example/data?page=2")
+        if err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        response.Body.Close()
+        if response.StatusCode != 500 || response.Proto != "HTTP/2.0" {
+            t.Errorf("Injected reply not built by the router: %d %s", response.StatusCode, response.Proto)
+        }
+    }
+    for _, injection := range c.Injected() {
+        if injection.Service != "first" {
+            t.Errorf("Overlapping services not blamed in registration order: %s", injection)
+        }
+        if !strings.Contains(injection.URL, "page="+redact.Placeholder("page")) {
+            t.Errorf("Router Redactor not applied: %s", injection.URL)
+        }
+    }
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+    "bytes"
+    "compress/flate"
+    "compress/gzip"
+    "compress/zlib"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "mime"
+    "mime/multipart"
+    "net/url"
+    "strings"
+)
+
+/*
+    The body accessors read the request body once, undoing any gzip or
+    deflate Content-Encoding, and keep it on the HttpCall so every matcher,
+    middleware and handler sees the same data. The original bytes are put
+    back on Request.Body so code reading the *http.Request directly, such as
+    loggers, still finds them.
+ */
+
+var (
+    ErrUnsupportedContentEncoding error = errors.New("HttpCall: Unsupported Content-Encoding")
+    ErrUnexpectedContentType error = errors.New("HttpCall: Unexpected Content-Type")
+)
+
+// Bytes returns the decoded request body; a request without a body returns nil
+func (hc *HttpCall) Bytes() ([]byte, error) {
+    if !hc.bodyRead {
+        hc.body, hc.bodyErr = hc.readBody()
+        hc.bodyRead = true
+    }
+    return hc.body, hc.bodyErr
+}
+
+// String returns the decoded request body as text
+func (hc *HttpCall) String() (string, error) {
+    data, err := hc.Bytes()
+    return string(data), err
+}
+
+// DecodeJSON unmarshals the request body into v
+func (hc *HttpCall) DecodeJSON(v interface{}) error {
+    data, err := hc.Bytes()
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(data, v)
+}
+
+// DecodeXML unmarshals the request body into v
+func (hc *HttpCall) DecodeXML(v interface{}) error {
+    data, err := hc.Bytes()
+    if err != nil {
+        return err
+    }
+    return xml.Unmarshal(data, v)
+}
+
+// Form parses an application/x-www-form-urlencoded body; unlike http.Request.Form it
+// doesn't include the query parameters
+func (hc *HttpCall) Form() (form url.Values, err error) {
+    if _, err = hc.mediaType("application/x-www-form-urlencoded"); err != nil {
+        return
+    }
+    data, err := hc.Bytes()
+    if err != nil {
+        return
+    }
+    return url.ParseQuery(string(data))
+}
+
+// MultipartForm parses a multipart/form-data body, keeping up to maxMemory bytes of file
+// parts in memory and the rest in temporary files; call RemoveAll on the form to remove them
+func (hc *HttpCall) MultipartForm(maxMemory int64) (form *multipart.Form, err error) {
+    params, err := hc.mediaType("multipart/form-data")
+    if err != nil {
+        return
+    }
+    data, err := hc.Bytes()
+    if err != nil {
+        return
+    }
+    return multipart.NewReader(bytes.NewReader(data), params["boundary"]).ReadForm(maxMemory)
+}
+
+func (hc *HttpCall) mediaType(expected string) (params map[string]string, err error) {
+    contentType := hc.Headers.Get("Content-Type")
+    mediaType, params, _ := mime.ParseMediaType(contentType)
+    if mediaType != expected {
+        err = fmt.Errorf("%w: expected %s but found %q", ErrUnexpectedContentType, expected, contentType)
+    }
+    return
+}
+
+func (hc *HttpCall) readBody() (data []byte, err error) {
+    if hc.Request == nil || hc.Request.Body == nil {
+        return
+    }
+    raw, err := ioutil.ReadAll(hc.Request.Body)
+    hc.Request.Body.Close()
+    hc.Request.Body = ioutil.NopCloser(bytes.NewReader(raw))
+    if err != nil {
+        return
+    }
+    return decodeContent(raw, hc.Headers.Get("Content-Encoding"))
+}
+
+// decodeContent undoes the encodings in the reverse of the order they were applied
+func decodeContent(data []byte, contentEncoding string) ([]byte, error) {
+    if len(contentEncoding) == 0 || len(data) == 0 {
+        return data, nil
+    }
+    encodings := strings.Split(contentEncoding, ",")
+    for index := len(encodings) - 1; index >= 0; index-- {
+        var reader io.ReadCloser
+        switch encoding := strings.ToLower(strings.TrimSpace(encodings[index])); encoding {
+        case "", "identity":
+            continue
+        case "gzip", "x-gzip":
+            gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+            if err != nil {
+                return nil, fmt.Errorf("unable to decode the gzip body: %w", err)
+            }
+            reader = gzipReader
+        case "deflate":
+            // deflate is zlib wrapped however some servers send the raw stream
+            zlibReader, err := zlib.NewReader(bytes.NewReader(data))
+            if err != nil {
+                reader = flate.NewReader(bytes.NewReader(data))
+                break
+            }
+            reader = zlibReader
+        default:
+            return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, encoding)
+        }
+        decoded, err := ioutil.ReadAll(reader)
+        reader.Close()
+        if err != nil {
+            return nil, fmt.Errorf("unable to decode the %s body: %w", strings.TrimSpace(encodings[index]), err)
+        }
+        data = decoded
+    }
+    return data, nil
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+    "context"
     "net/http"
     "net/url"
 )
@@ -12,4 +13,21 @@ type HttpCall struct {
     Url     *url.URL
     Headers http.Header
     Request *http.Request
+    // PathParams holds any named segments captured by a templated path
+    // (e.g `{id}` in `/users/{id}`); only set by matchers that support them
+    PathParams map[string]string
+
+    // the body is cached by Bytes; see body.go
+    body     []byte
+    bodyErr  error
+    bodyRead bool
+}
+
+// Context is the context of the request; the router stops waiting for the handler, and the
+// client sees context.Canceled or context.DeadlineExceeded, once it's done
+func (hc *HttpCall) Context() context.Context {
+    if hc.Request == nil {
+        return context.Background()
+    }
+    return hc.Request.Context()
 }
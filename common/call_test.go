@@ -1,6 +1,13 @@
 package common_test
 
 import (
+    "bytes"
+    "compress/flate"
+    "compress/gzip"
+    "compress/zlib"
+    "errors"
+    "io/ioutil"
+    "mime/multipart"
     "net/http"
     "net/url"
     "testing"
@@ -19,3 +26,104 @@ func Test_Common_HttpCall(t *testing.T) {
         t.Errorf("Failed to set method")
     }
 }
+
+func newCall(t *testing.T, contentType string, contentEncoding string, body []byte) *common.HttpCall {
+    request, err := http.NewRequest("POST", "https://api.example/upload", bytes.NewReader(body))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    request.Header.Set("Content-Type", contentType)
+    if len(contentEncoding) > 0 {
+        request.Header.Set("Content-Encoding", contentEncoding)
+    }
+    return &common.HttpCall{
+        Method: common.HttpVerb_Post,
+        Url: request.URL,
+        Headers: request.Header,
+        Request: request,
+    }
+}
+
+func Test_Common_HttpCall_Body(t *testing.T) {
+    var compressed bytes.Buffer
+    writer := gzip.NewWriter(&compressed)
+    writer.Write([]byte(`{"name": "bob"}`))
+    writer.Close()
+
+    call := newCall(t, "application/json", "gzip", compressed.Bytes())
+    var decoded struct {
+        Name string `json:"name"`
+    }
+    if err := call.DecodeJSON(&decoded); err != nil || decoded.Name != "bob" {
+        t.Errorf("Unexpected JSON: %#v %v", decoded, err)
+    }
+    if text, err := call.String(); err != nil || text != `{"name": "bob"}` {
+        t.Errorf("Unexpected text: %q %v", text, err)
+    }
+    // the original bytes are still on the request for anything reading it directly
+    raw, _ := ioutil.ReadAll(call.Request.Body)
+    if !bytes.Equal(raw, compressed.Bytes()) {
+        t.Errorf("Unexpected raw body: %q", raw)
+    }
+    if data, err := call.Bytes(); err != nil || string(data) != `{"name": "bob"}` {
+        t.Errorf("Unexpected cached body: %q %v", data, err)
+    }
+
+    xmlCall := newCall(t, "application/xml", "", []byte(`<user name="alice"/>`))
+    var user struct {
+        Name string `xml:"name,attr"`
+    }
+    if err := xmlCall.DecodeXML(&user); err != nil || user.Name != "alice" {
+        t.Errorf("Unexpected XML: %#v %v", user, err)
+    }
+
+    formCall := newCall(t, "application/x-www-form-urlencoded", "", []byte("a=1&a=2&b=3"))
+    if form, err := formCall.Form(); err != nil || form.Get("b") != "3" || len(form["a"]) != 2 {
+        t.Errorf("Unexpected form: %v %v", form, err)
+    }
+    if _, err := xmlCall.Form(); !errors.Is(err, common.ErrUnexpectedContentType) {
+        t.Errorf("Expected ErrUnexpectedContentType but found %v", err)
+    }
+
+    var multipartBody bytes.Buffer
+    multipartWriter := multipart.NewWriter(&multipartBody)
+    multipartWriter.WriteField("title", "report")
+    file, _ := multipartWriter.CreateFormFile("upload", "report.txt")
+    file.Write([]byte("content"))
+    multipartWriter.Close()
+    multipartCall := newCall(t, multipartWriter.FormDataContentType(), "", multipartBody.Bytes())
+    form, err := multipartCall.MultipartForm(1 << 20)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer form.RemoveAll()
+    if form.Value["title"][0] != "report" || form.File["upload"][0].Filename != "report.txt" {
+        t.Errorf("Unexpected multipart form: %v %v", form.Value, form.File)
+    }
+
+    // deflate is zlib wrapped but the raw stream some servers send is accepted too
+    var zlibBody bytes.Buffer
+    zlibWriter := zlib.NewWriter(&zlibBody)
+    zlibWriter.Write([]byte("zlib"))
+    zlibWriter.Close()
+    if text, err := newCall(t, "text/plain", "deflate", zlibBody.Bytes()).String(); err != nil || text != "zlib" {
+        t.Errorf("Unexpected zlib deflate body: %q %v", text, err)
+    }
+    var rawBody bytes.Buffer
+    flateWriter, _ := flate.NewWriter(&rawBody, flate.DefaultCompression)
+    flateWriter.Write([]byte("raw"))
+    flateWriter.Close()
+    if text, err := newCall(t, "text/plain", "deflate", rawBody.Bytes()).String(); err != nil || text != "raw" {
+        t.Errorf("Unexpected raw deflate body: %q %v", text, err)
+    }
+
+    unsupported := newCall(t, "text/plain", "br", []byte("data"))
+    if _, err := unsupported.Bytes(); !errors.Is(err, common.ErrUnsupportedContentEncoding) {
+        t.Errorf("Expected ErrUnsupportedContentEncoding but found %v", err)
+    }
+
+    empty := &common.HttpCall{Headers: http.Header{}}
+    if data, err := empty.Bytes(); data != nil || err != nil {
+        t.Errorf("Unexpected body without a request: %q %v", data, err)
+    }
+}
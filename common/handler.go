@@ -2,3 +2,14 @@ package common
 
 type HttpHandler func(*HttpCall) (*HttpReply, error)
 type HttpHandlerMap map[HttpVerb]HttpHandler
+
+// Middleware wraps a handler to inspect or change the request and reply
+type Middleware func(next HttpHandler) HttpHandler
+
+// Chain wraps the handler in the middleware; the first middleware listed is the outermost
+func Chain(handler HttpHandler, middleware ...Middleware) HttpHandler {
+    for index := len(middleware) - 1; index >= 0; index-- {
+        handler = middleware[index](handler)
+    }
+    return handler
+}
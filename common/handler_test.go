@@ -25,3 +25,31 @@ func Test_Common_Handler(t *testing.T) {
         t.Errorf("Unexpectedly did not find the key (%s) in the map (%v)", key, hhm)
     }
 }
+
+func Test_Common_Chain(t *testing.T) {
+    order := []string{}
+    tag := func(name string) common.Middleware {
+        return func(next common.HttpHandler) common.HttpHandler {
+            return func(hc *common.HttpCall) (*common.HttpReply, error) {
+                order = append(order, name)
+                return next(hc)
+            }
+        }
+    }
+    handler := common.Chain(
+        func(hc *common.HttpCall) (*common.HttpReply, error) {
+            order = append(order, "handler")
+            return &common.HttpReply{Status: 200}, nil
+        },
+        tag("outer"),
+        tag("inner"),
+    )
+
+    reply, err := handler(&common.HttpCall{})
+    if err != nil || reply.Status != 200 {
+        t.Errorf("Unexpected result: %v %v", reply, err)
+    }
+    if len(order) != 3 || order[0] != "outer" || order[1] != "inner" || order[2] != "handler" {
+        t.Errorf("Unexpected order: %v", order)
+    }
+}
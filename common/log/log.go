@@ -3,6 +3,9 @@ package log
 import (
     "fmt"
     golog "log"
+    "sync"
+
+    "github.com/TestInABox/gostackinabox/redact"
 )
 
 /*
@@ -37,18 +40,38 @@ var (
     doPrintln standardFn = golog.Println
 )
 
+// all messages are scrubbed so URLs with credentials and the like don't leak into test output;
+// only the secret rules apply so ids and timestamps can still be correlated
+var (
+    redactorLock sync.RWMutex
+    redactor *redact.Redactor = redact.Default().Secrets()
+)
+
+// SetRedactor replaces the redactor applied to all messages; nil disables redaction
+func SetRedactor(rd *redact.Redactor) {
+    redactorLock.Lock()
+    defer redactorLock.Unlock()
+    redactor = rd.Secrets()
+}
+
+func scrub(message string) string {
+    redactorLock.RLock()
+    defer redactorLock.RUnlock()
+    return redactor.Text(message)
+}
+
 func makeLogStringf(format string, v ...interface{}) string {
-    coreLogString := fmt.Sprintf(format, v...)
+    coreLogString := scrub(fmt.Sprintf(format, v...))
     return fmt.Sprintf(logFmtString, logName, coreLogString)
 }
 
 func makeLogString(v ...interface{}) string {
-    coreLogString := fmt.Sprint(v...)
+    coreLogString := scrub(fmt.Sprint(v...))
     return fmt.Sprintf(logFmtString, logName, coreLogString)
 }
 
 func makeLogStringln(v ...interface{}) string {
-    coreLogString := fmt.Sprintln(v...)
+    coreLogString := scrub(fmt.Sprintln(v...))
     return fmt.Sprintf(logFmtString, logName, coreLogString)
 }
 
@@ -3,11 +3,59 @@ package log
 
 import (
     "fmt"
+    "net/http"
+    "strings"
+    "sync"
     "testing"
 
+    "github.com/TestInABox/gostackinabox/redact"
+
     //"github.com/TestInABox/gostackinabox/common/log"
 )
 
+func Test_Redaction(t *testing.T) {
+    result := makeLogStringf("GET %s", "https://example.com/?access_token=abc")
+    if strings.Contains(result, "abc") {
+        t.Errorf("Log message not redacted: %s", result)
+    }
+
+    id := "0b0d7f6c-7e0a-4c35-9b53-4b6e1b1f8e2c"
+    result = makeLogStringf("request %s at 2023-01-02T03:04:05Z", id)
+    if !strings.Contains(result, id) || !strings.Contains(result, "2023-01-02T03:04:05Z") {
+        t.Errorf("Log message normalized: %s", result)
+    }
+
+    header := http.Header{"X-Request-Id": {id}, "Authorization": {"Bearer abc"}}
+    result = makeLogStringf("Headers: %v", redact.Default().Secrets().Header(header))
+    if !strings.Contains(result, id) || strings.Contains(result, "abc") {
+        t.Errorf("Log message redacted the request id or kept the secret: %s", result)
+    }
+
+    SetRedactor(nil)
+    defer SetRedactor(redact.Default())
+    result = makeLogStringf("GET %s", "https://example.com/?access_token=abc")
+    if !strings.Contains(result, "abc") {
+        t.Errorf("Log message unexpectedly redacted: %s", result)
+    }
+}
+
+func Test_SetRedactor_Concurrent(t *testing.T) {
+    defer SetRedactor(redact.Default())
+    var wg sync.WaitGroup
+    for index := 0; index < 4; index++ {
+        wg.Add(2)
+        go func() {
+            defer wg.Done()
+            SetRedactor(redact.Default())
+        }()
+        go func() {
+            defer wg.Done()
+            makeLogStringf("GET %s", "https://example.com/?access_token=abc")
+        }()
+    }
+    wg.Wait()
+}
+
 func Test_MakeLogString(t *testing.T) {
     t.Run(
         "format",
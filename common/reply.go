@@ -1,14 +1,173 @@
 package common
 
 import (
-    "net/http"
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
     "io"
+    "io/ioutil"
+    "mime"
+    "net/http"
+    "path/filepath"
 )
 
 type HttpReply struct {
     Status       HttpStatusCode
     Headers      http.Header
+    // Trailers are sent after the body; see ReplyBuilder.Chunked for setting them while streaming
     Trailers     http.Header
     ResponseData io.ReadCloser
+    // Length is the size of ResponseData in bytes, or -1 when it isn't known up front, in which
+    // case the router sends the body chunked
     Length       int64
 }
+
+/*
+    ReplyBuilder creates replies with the Content-Type and Length already
+    matching the body, e.g
+
+        return common.NewReply(200).WithHeader("ETag", `"1"`).JSON(user)
+
+    Text, JSON, XML and File only set the Content-Type when WithHeader or
+    WithContentType hasn't already done so.
+ */
+type ReplyBuilder struct {
+    status   HttpStatusCode
+    headers  http.Header
+    trailers http.Header
+}
+
+func NewReply(status HttpStatusCode) *ReplyBuilder {
+    return &ReplyBuilder{
+        status: status,
+        headers: make(http.Header),
+    }
+}
+
+// WithHeader adds a value to the header
+func (rb *ReplyBuilder) WithHeader(name string, value string) *ReplyBuilder {
+    rb.headers.Add(name, value)
+    return rb
+}
+
+// WithHeaders adds every value of the headers
+func (rb *ReplyBuilder) WithHeaders(headers http.Header) *ReplyBuilder {
+    for name, values := range headers {
+        for _, value := range values {
+            rb.headers.Add(name, value)
+        }
+    }
+    return rb
+}
+
+func (rb *ReplyBuilder) WithContentType(contentType string) *ReplyBuilder {
+    rb.headers.Set("Content-Type", contentType)
+    return rb
+}
+
+// WithTrailer adds a value to the trailer sent after the body
+func (rb *ReplyBuilder) WithTrailer(name string, value string) *ReplyBuilder {
+    if rb.trailers == nil {
+        rb.trailers = make(http.Header)
+    }
+    rb.trailers.Add(name, value)
+    return rb
+}
+
+// Empty replies without a body
+func (rb *ReplyBuilder) Empty() *HttpReply {
+    return rb.build(BytesBody(nil), 0)
+}
+
+// Text replies with the text as text/plain
+func (rb *ReplyBuilder) Text(text string) *HttpReply {
+    rb.defaultContentType("text/plain; charset=utf-8")
+    return rb.Bytes([]byte(text))
+}
+
+// Bytes replies with the data as-is; unlike the other body methods it leaves the Content-Type unset
+func (rb *ReplyBuilder) Bytes(data []byte) *HttpReply {
+    return rb.build(BytesBody(data), int64(len(data)))
+}
+
+// JSON replies with the encoding of the value
+func (rb *ReplyBuilder) JSON(v interface{}) (reply *HttpReply, err error) {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return
+    }
+    rb.defaultContentType("application/json")
+    reply = rb.Bytes(data)
+    return
+}
+
+// XML replies with the encoding of the value, preceded by the XML declaration
+func (rb *ReplyBuilder) XML(v interface{}) (reply *HttpReply, err error) {
+    data, err := xml.Marshal(v)
+    if err != nil {
+        return
+    }
+    rb.defaultContentType("application/xml; charset=utf-8")
+    reply = rb.Bytes(append([]byte(xml.Header), data...))
+    return
+}
+
+// File replies with the content of the file; the Content-Type comes from the extension, or
+// is sniffed from the content when the extension isn't known
+func (rb *ReplyBuilder) File(path string) (reply *HttpReply, err error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return
+    }
+    contentType := mime.TypeByExtension(filepath.Ext(path))
+    if len(contentType) == 0 {
+        contentType = http.DetectContentType(data)
+    }
+    rb.defaultContentType(contentType)
+    reply = rb.Bytes(data)
+    return
+}
+
+// Stream replies with whatever the reader produces; length is the number of bytes it will
+// produce, or -1 if that isn't known. The reader is closed afterwards if it's an io.Closer.
+// Like Bytes it leaves the Content-Type unset.
+func (rb *ReplyBuilder) Stream(reader io.Reader, length int64) *HttpReply {
+    body, ok := reader.(io.ReadCloser)
+    if !ok {
+        body = ioutil.NopCloser(reader)
+    }
+    if length < 0 {
+        length = -1
+    }
+    return rb.build(body, length)
+}
+
+func (rb *ReplyBuilder) defaultContentType(contentType string) {
+    if len(rb.headers.Get("Content-Type")) == 0 {
+        rb.headers.Set("Content-Type", contentType)
+    }
+}
+
+func (rb *ReplyBuilder) build(body io.ReadCloser, length int64) *HttpReply {
+    return &HttpReply{
+        Status: rb.status,
+        Headers: rb.headers,
+        Trailers: rb.trailers,
+        ResponseData: body,
+        Length: length,
+    }
+}
+
+// bytesBody is an in-memory body; Len lets the router check the reply Length before sending it
+type bytesBody struct {
+    *bytes.Reader
+}
+
+func (bb *bytesBody) Close() error {
+    return nil
+}
+
+// BytesBody makes the data a reply body
+func BytesBody(data []byte) io.ReadCloser {
+    return &bytesBody{Reader: bytes.NewReader(data)}
+}
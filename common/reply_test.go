@@ -1,7 +1,10 @@
 package common_test
 
 import (
+    "io/ioutil"
     "net/http"
+    "path/filepath"
+    "strings"
     "testing"
 
     "github.com/TestInABox/gostackinabox/common"
@@ -16,3 +19,62 @@ func Test_Common_HttpReply(t *testing.T) {
         t.Errorf("Failed to set Status")
     }
 }
+
+func Test_Common_ReplyBuilder(t *testing.T) {
+    read := func(reply *common.HttpReply) string {
+        data, _ := ioutil.ReadAll(reply.ResponseData)
+        if int64(len(data)) != reply.Length {
+            t.Errorf("Length %d doesn't match the body %q", reply.Length, data)
+        }
+        return string(data)
+    }
+
+    text := common.NewReply(201).WithHeader("ETag", `"1"`).Text("hello")
+    if text.Status != 201 || text.Headers.Get("Content-Type") != "text/plain; charset=utf-8" || text.Headers.Get("ETag") != `"1"` {
+        t.Errorf("Unexpected text reply: %d %v", text.Status, text.Headers)
+    }
+    if body := read(text); body != "hello" {
+        t.Errorf("Unexpected text body: %q", body)
+    }
+
+    json, err := common.NewReply(200).JSON(map[string]int{"id": 7})
+    if err != nil || json.Headers.Get("Content-Type") != "application/json" || read(json) != `{"id":7}` {
+        t.Errorf("Unexpected JSON reply: %v %v", json.Headers, err)
+    }
+    if _, err := common.NewReply(200).JSON(func() {}); err == nil {
+        t.Errorf("Expected a value that can't be encoded to fail")
+    }
+
+    type item struct {
+        Name string `xml:"name,attr"`
+    }
+    xml, err := common.NewReply(200).WithContentType("text/xml").XML(&item{Name: "a"})
+    if err != nil || xml.Headers.Get("Content-Type") != "text/xml" || read(xml) != `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<item name="a"></item>` {
+        t.Errorf("Unexpected XML reply: %v %v", xml.Headers, err)
+    }
+
+    raw := common.NewReply(200).Bytes([]byte{1, 2, 3})
+    if len(raw.Headers.Get("Content-Type")) > 0 || read(raw) != "\x01\x02\x03" {
+        t.Errorf("Unexpected bytes reply: %v", raw.Headers)
+    }
+
+    empty := common.NewReply(204).WithTrailer("X-Checksum", "abc").Empty()
+    if read(empty) != "" || empty.Trailers.Get("X-Checksum") != "abc" {
+        t.Errorf("Unexpected empty reply: %v", empty.Trailers)
+    }
+
+    stream := common.NewReply(200).Stream(strings.NewReader("streamed"), -5)
+    if stream.Length != -1 {
+        t.Errorf("Unexpected stream length: %d", stream.Length)
+    }
+
+    path := filepath.Join(t.TempDir(), "page.html")
+    ioutil.WriteFile(path, []byte("<html></html>"), 0600)
+    file, err := common.NewReply(200).File(path)
+    if err != nil || !strings.HasPrefix(file.Headers.Get("Content-Type"), "text/html") || read(file) != "<html></html>" {
+        t.Errorf("Unexpected file reply: %v %v", file, err)
+    }
+    if _, err := common.NewReply(200).File(filepath.Join(t.TempDir(), "missing")); err == nil {
+        t.Errorf("Expected a missing file to fail")
+    }
+}
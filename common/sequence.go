@@ -0,0 +1,151 @@
+package common
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    Sequence is an HttpHandler that returns a scripted series of replies,
+    one step per call, e.g 503, 503 then 200 for testing retry logic.
+
+    Register it anywhere an HttpHandler is accepted using `seq.Handle`.
+ */
+
+type SequenceStep struct {
+    Status  HttpStatusCode
+    Headers http.Header
+    Body    []byte
+    // Delay is applied before the reply is returned
+    Delay time.Duration
+    // Err, if set, is returned instead of a reply; wrap it in a TransportError
+    // to have the router return it from RoundTrip
+    Err error
+}
+
+type SequenceExhaustedBehavior int
+
+const (
+    // keep returning the last step
+    SequenceRepeatLast SequenceExhaustedBehavior = iota
+    // start again from the first step
+    SequenceCycle
+    // report the extra call to the Reporter and return ErrSequenceExhausted
+    SequenceFail
+)
+
+// TestReporter is the subset of testing.TB used to fail the test
+type TestReporter interface {
+    Errorf(format string, args ...interface{})
+}
+
+type Sequence struct {
+    Steps     []SequenceStep
+    Exhausted SequenceExhaustedBehavior
+    Reporter  TestReporter
+
+    lock  sync.Mutex
+    calls int
+}
+
+var (
+    ErrSequenceExhausted error = errors.New("Sequence: No more steps")
+    ErrSequenceEmpty error = errors.New("Sequence: No steps configured")
+)
+
+func NewSequence(steps ...SequenceStep) *Sequence {
+    return &Sequence{
+        Steps: steps,
+    }
+}
+
+// Calls returns the number of times the sequence has been called
+func (seq *Sequence) Calls() int {
+    seq.lock.Lock()
+    defer seq.lock.Unlock()
+    return seq.calls
+}
+
+func (seq *Sequence) Reset() {
+    seq.lock.Lock()
+    defer seq.lock.Unlock()
+    seq.calls = 0
+}
+
+func (seq *Sequence) nextStep() (step SequenceStep, err error) {
+    seq.lock.Lock()
+    defer seq.lock.Unlock()
+
+    if len(seq.Steps) == 0 {
+        err = ErrSequenceEmpty
+        return
+    }
+
+    index := seq.calls
+    seq.calls++
+    if index >= len(seq.Steps) {
+        switch seq.Exhausted {
+        case SequenceCycle:
+            index = index % len(seq.Steps)
+        case SequenceFail:
+            err = fmt.Errorf("%w: call %d of %d steps", ErrSequenceExhausted, index+1, len(seq.Steps))
+            return
+        default:
+            index = len(seq.Steps) - 1
+        }
+    }
+    log.Printf("Sequence serving step %d of %d", index+1, len(seq.Steps))
+    step = seq.Steps[index]
+    return
+}
+
+func (seq *Sequence) Handle(request *HttpCall) (result *HttpReply, err error) {
+    step, err := seq.nextStep()
+    if err != nil {
+        if seq.Reporter != nil {
+            seq.Reporter.Errorf("gostackinabox: %s %s: %v", request.Method, request.Url, err)
+        }
+        return
+    }
+
+    if step.Delay > 0 {
+        if err = Sleep(request, step.Delay); err != nil {
+            return
+        }
+    }
+
+    if step.Err != nil {
+        err = step.Err
+        return
+    }
+
+    result = NewReply(step.Status).WithHeaders(step.Headers).Bytes(step.Body)
+    return
+}
+
+// Sleep waits for the duration unless the request is cancelled first, in which
+// case it returns a TransportError so the client sees the cancellation
+func Sleep(request *HttpCall, d time.Duration) (err error) {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+
+    if request == nil {
+        <-timer.C
+        return
+    }
+
+    ctx := request.Context()
+    select {
+    case <-timer.C:
+    case <-ctx.Done():
+        err = &TransportError{Err: ctx.Err()}
+    }
+    return
+}
+
+var _ HttpHandler = (&Sequence{}).Handle
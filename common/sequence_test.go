@@ -0,0 +1,153 @@
+package common_test
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+)
+
+type fakeReporter struct {
+    messages []string
+}
+
+func (fr *fakeReporter) Errorf(format string, args ...interface{}) {
+    fr.messages = append(fr.messages, fmt.Sprintf(format, args...))
+}
+
+func Test_Common_Sequence(t *testing.T) {
+    newCall := func() *common.HttpCall {
+        u, _ := url.Parse("http://example.com/retry")
+        return &common.HttpCall{
+            Method: common.HttpVerb_Get,
+            Url: u,
+            Request: &http.Request{URL: u},
+        }
+    }
+    steps := []common.SequenceStep{
+        {Status: 503},
+        {Status: 503},
+        {Status: 200, Body: []byte("ok"), Headers: http.Header{"X-Step": []string{"3"}}},
+    }
+
+    type TestScenario struct {
+        name      string
+        exhausted common.SequenceExhaustedBehavior
+        expected  []common.HttpStatusCode
+        failures  int
+    }
+
+    var TestScenarios = []TestScenario{
+        {
+            name: "repeat last",
+            exhausted: common.SequenceRepeatLast,
+            expected: []common.HttpStatusCode{503, 503, 200, 200, 200},
+        },
+        {
+            name: "cycle",
+            exhausted: common.SequenceCycle,
+            expected: []common.HttpStatusCode{503, 503, 200, 503, 503},
+        },
+        {
+            name: "fail",
+            exhausted: common.SequenceFail,
+            expected: []common.HttpStatusCode{503, 503, 200, 0, 0},
+            failures: 2,
+        },
+    }
+
+    for _, scenario := range TestScenarios {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                reporter := &fakeReporter{}
+                seq := common.NewSequence(steps...)
+                seq.Exhausted = scenario.exhausted
+                seq.Reporter = reporter
+
+                for index, expected := range scenario.expected {
+                    reply, err := seq.Handle(newCall())
+                    if expected == 0 {
+                        if !errors.Is(err, common.ErrSequenceExhausted) {
+                            t.Errorf("Call %d: unexpected error: %v", index, err)
+                        }
+                        continue
+                    }
+                    if err != nil {
+                        t.Fatalf("Call %d: unexpected error: %v", index, err)
+                    }
+                    if reply.Status != expected {
+                        t.Errorf("Call %d: unexpected status: %d != %d", index, reply.Status, expected)
+                    }
+                    if expected == 200 {
+                        body, _ := ioutil.ReadAll(reply.ResponseData)
+                        if string(body) != "ok" || reply.Length != 2 || reply.Headers.Get("X-Step") != "3" {
+                            t.Errorf("Call %d: unexpected reply: %s %d %v", index, body, reply.Length, reply.Headers)
+                        }
+                    }
+                }
+                if len(reporter.messages) != scenario.failures {
+                    t.Errorf("Unexpected failures reported: %v", reporter.messages)
+                }
+                if seq.Calls() != len(scenario.expected) {
+                    t.Errorf("Unexpected call count: %d", seq.Calls())
+                }
+            },
+        )
+    }
+
+    t.Run(
+        "empty",
+        func(t *testing.T) {
+            _, err := common.NewSequence().Handle(newCall())
+            if !errors.Is(err, common.ErrSequenceEmpty) {
+                t.Errorf("Unexpected error: %v", err)
+            }
+        },
+    )
+    t.Run(
+        "step error",
+        func(t *testing.T) {
+            stepErr := &common.TransportError{Err: errors.New("connection reset")}
+            seq := common.NewSequence(common.SequenceStep{Err: stepErr})
+            _, err := seq.Handle(newCall())
+            var transportErr *common.TransportError
+            if !errors.As(err, &transportErr) {
+                t.Errorf("Unexpected error: %v", err)
+            }
+        },
+    )
+    t.Run(
+        "delay honors cancellation",
+        func(t *testing.T) {
+            seq := common.NewSequence(common.SequenceStep{Status: 200, Delay: time.Hour})
+            call := newCall()
+            ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+            defer cancel()
+            call.Request = call.Request.WithContext(ctx)
+
+            _, err := seq.Handle(call)
+            if !errors.Is(err, context.DeadlineExceeded) {
+                t.Errorf("Unexpected error: %v", err)
+            }
+        },
+    )
+    t.Run(
+        "reset",
+        func(t *testing.T) {
+            seq := common.NewSequence(steps...)
+            seq.Handle(newCall())
+            seq.Reset()
+            reply, _ := seq.Handle(newCall())
+            if reply.Status != 503 || seq.Calls() != 1 {
+                t.Errorf("Reset did not restart the sequence: %d %d", reply.Status, seq.Calls())
+            }
+        },
+    )
+}
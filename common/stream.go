@@ -0,0 +1,114 @@
+package common
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "sync"
+)
+
+/*
+    Chunked replies are produced while the client reads them, with the
+    length unknown up front as with `Transfer-Encoding: chunked`, e.g
+
+        return common.NewReply(200).WithContentType("text/plain").Chunked(func(w *common.ChunkWriter) error {
+            for _, line := range lines {
+                if _, err := fmt.Fprintln(w, line); err != nil {
+                    return err
+                }
+                time.Sleep(10 * time.Millisecond)
+            }
+            w.SetTrailer("X-Checksum", checksum(lines))
+            return nil
+        }), nil
+
+    The function runs once the client starts reading the body, and each
+    Write blocks until the client has read it. Like http.Response.Trailer
+    the trailers set on the writer only appear in the reply Trailers once
+    the body has been read to its end. An error returned by the function,
+    or a panic, fails the client's read; writes fail once the client closes
+    the body, which the router does when the request context ends.
+ */
+
+// ChunkWriter sends the body of a chunked reply
+type ChunkWriter struct {
+    pipe     *io.PipeWriter
+    lock     sync.Mutex
+    trailers http.Header
+}
+
+// Write sends the data as the next chunk
+func (cw *ChunkWriter) Write(p []byte) (int, error) {
+    return cw.pipe.Write(p)
+}
+
+// SetTrailer sets a value of the trailer sent after the body
+func (cw *ChunkWriter) SetTrailer(name string, value string) {
+    cw.lock.Lock()
+    defer cw.lock.Unlock()
+    cw.trailers.Set(name, value)
+}
+
+// AddTrailer adds a value to the trailer sent after the body
+func (cw *ChunkWriter) AddTrailer(name string, value string) {
+    cw.lock.Lock()
+    defer cw.lock.Unlock()
+    cw.trailers.Add(name, value)
+}
+
+// Chunked replies with what the function writes; the Length is -1 and, like Bytes, the
+// Content-Type is left unset
+func (rb *ReplyBuilder) Chunked(produce func(w *ChunkWriter) error) *HttpReply {
+    if rb.trailers == nil {
+        rb.trailers = make(http.Header)
+    }
+    reader, writer := io.Pipe()
+    body := &chunkedBody{
+        pipe: reader,
+        writer: &ChunkWriter{pipe: writer, trailers: make(http.Header)},
+        produce: produce,
+        trailers: rb.trailers,
+    }
+    return rb.build(body, -1)
+}
+
+type chunkedBody struct {
+    pipe     *io.PipeReader
+    writer   *ChunkWriter
+    produce  func(w *ChunkWriter) error
+    // trailers is the reply's, filled in once the body is read to its end
+    trailers http.Header
+    start    sync.Once
+    finish   sync.Once
+}
+
+func (cb *chunkedBody) run() {
+    defer func() {
+        if recovered := recover(); recovered != nil {
+            cb.writer.pipe.CloseWithError(fmt.Errorf("chunked reply panicked: %v", recovered))
+        }
+    }()
+    // CloseWithError(nil) ends the body with io.EOF
+    cb.writer.pipe.CloseWithError(cb.produce(cb.writer))
+}
+
+func (cb *chunkedBody) Read(p []byte) (n int, err error) {
+    cb.start.Do(func() {
+        go cb.run()
+    })
+    n, err = cb.pipe.Read(p)
+    if err == io.EOF {
+        cb.finish.Do(func() {
+            cb.writer.lock.Lock()
+            defer cb.writer.lock.Unlock()
+            for name, values := range cb.writer.trailers {
+                cb.trailers[name] = append(cb.trailers[name], values...)
+            }
+        })
+    }
+    return
+}
+
+func (cb *chunkedBody) Close() error {
+    return cb.pipe.Close()
+}
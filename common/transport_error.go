@@ -0,0 +1,22 @@
+package common
+
+/*
+    Normally an error returned by a handler is reported to the client as a
+    596 (Service Error) reply. Wrapping the error in a TransportError instead
+    causes the router to return the wrapped error from RoundTrip, just like
+    a real transport failing to complete the request would.
+ */
+type TransportError struct {
+    Err error
+}
+
+func (te *TransportError) Error() string {
+    if te.Err == nil {
+        return "transport error"
+    }
+    return te.Err.Error()
+}
+
+func (te *TransportError) Unwrap() error {
+    return te.Err
+}
@@ -12,4 +12,5 @@ type URI interface {
 var (
     ErrServerURIMisconfigured error = errors.New("Misconfigured ServerURI")
     ErrPathURIMisconfigured error = errors.New("Misconfigured PathURI")
+    ErrTemplateURIMisconfigured error = errors.New("Misconfigured TemplateURI")
 )
@@ -0,0 +1,101 @@
+package common
+
+import (
+    "fmt"
+    "net/url"
+    "regexp"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    TemplateURI matches the complete path against a template such as
+    `/v1/users/{id}/posts/{postId}` where each `{name}` matches a single path
+    segment. The captured segments are available via Params.
+
+    Unlike PathURI the template always matches the entire path so it is
+    intended for leaf services (those without sub-services of their own).
+ */
+type TemplateURI struct {
+    Template string
+
+    regex *regexp.Regexp
+    names []string
+}
+
+var templateParamRegex = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_.\-]*)\}`)
+
+func NewTemplateURI(template string) (tu *TemplateURI, err error) {
+    tu = &TemplateURI{
+        Template: template,
+    }
+    if err = tu.compile(); err != nil {
+        tu = nil
+    }
+    return
+}
+
+func (tu *TemplateURI) compile() (err error) {
+    if tu.regex != nil {
+        return
+    }
+    if !strings.HasPrefix(tu.Template, "/") {
+        err = fmt.Errorf("%w: template %q must start with /", ErrTemplateURIMisconfigured, tu.Template)
+        return
+    }
+
+    var builder strings.Builder
+    builder.WriteString("^")
+    names := []string{}
+    last := 0
+    for _, loc := range templateParamRegex.FindAllStringSubmatchIndex(tu.Template, -1) {
+        builder.WriteString(regexp.QuoteMeta(tu.Template[last:loc[0]]))
+        builder.WriteString("([^/]+)")
+        names = append(names, tu.Template[loc[2]:loc[3]])
+        last = loc[1]
+    }
+    remainder := tu.Template[last:]
+    if strings.ContainsAny(remainder, "{}") {
+        err = fmt.Errorf("%w: template %q has an invalid parameter", ErrTemplateURIMisconfigured, tu.Template)
+        return
+    }
+    builder.WriteString(regexp.QuoteMeta(remainder))
+    builder.WriteString("$")
+
+    tu.regex, err = regexp.Compile(builder.String())
+    if err != nil {
+        err = fmt.Errorf("%w: %v", ErrTemplateURIMisconfigured, err)
+        return
+    }
+    tu.names = names
+    return
+}
+
+func (tu *TemplateURI) IsMatch(u url.URL) (result bool, err error) {
+    if err = tu.compile(); err != nil {
+        log.Printf("Unable to use template %s: %v", tu.Template, err)
+        return
+    }
+    result = tu.regex.MatchString(u.Path)
+    log.Printf("Attempting to match %s against template %s... match: %t", u.Path, tu.Template, result)
+    return
+}
+
+// Params extracts the named segments from the URL; nil if the URL doesn't match
+func (tu *TemplateURI) Params(u url.URL) (params map[string]string) {
+    if tu.compile() != nil {
+        return
+    }
+    matches := tu.regex.FindStringSubmatch(u.Path)
+    if matches == nil {
+        return
+    }
+    params = make(map[string]string, len(tu.names))
+    for index, name := range tu.names {
+        params[name] = matches[index+1]
+    }
+    return
+}
+
+var _ URI = &TemplateURI{}
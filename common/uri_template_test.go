@@ -0,0 +1,98 @@
+package common_test
+
+import (
+    "errors"
+    "net/url"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+)
+
+func Test_Common_TemplateURI(t *testing.T) {
+    type TestScenario struct {
+        name     string
+        template string
+        path     string
+        result   bool
+        params   map[string]string
+    }
+
+    var TestScenarios = []TestScenario{
+        {
+            name: "literal",
+            template: "/v1/users",
+            path: "/v1/users",
+            result: true,
+            params: map[string]string{},
+        },
+        {
+            name: "literal mismatch",
+            template: "/v1/users",
+            path: "/v1/users/1",
+            result: false,
+        },
+        {
+            name: "parameters",
+            template: "/v1/users/{id}/posts/{post_id}",
+            path: "/v1/users/42/posts/7",
+            result: true,
+            params: map[string]string{"id": "42", "post_id": "7"},
+        },
+        {
+            name: "parameter is a single segment",
+            template: "/v1/users/{id}",
+            path: "/v1/users/42/posts",
+            result: false,
+        },
+        {
+            name: "regex characters are literal",
+            template: "/v1/users.json",
+            path: "/v1/usersxjson",
+            result: false,
+        },
+    }
+
+    for _, scenario := range TestScenarios {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                tu, err := common.NewTemplateURI(scenario.template)
+                if err != nil {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+                u := url.URL{Scheme: "http", Host: "example.com", Path: scenario.path}
+                result, err := tu.IsMatch(u)
+                if err != nil {
+                    t.Errorf("Unexpected error: %v", err)
+                }
+                if result != scenario.result {
+                    t.Errorf("Unexpected match result: %t != %t", result, scenario.result)
+                }
+                params := tu.Params(u)
+                if len(params) != len(scenario.params) {
+                    t.Errorf("Unexpected params: %v != %v", params, scenario.params)
+                }
+                for name, value := range scenario.params {
+                    if params[name] != value {
+                        t.Errorf("Unexpected param %s: %s != %s", name, params[name], value)
+                    }
+                }
+            },
+        )
+    }
+
+    t.Run(
+        "invalid templates",
+        func(t *testing.T) {
+            for _, template := range []string{"v1/users", "/v1/{id", "/v1/{}"} {
+                if _, err := common.NewTemplateURI(template); !errors.Is(err, common.ErrTemplateURIMisconfigured) {
+                    t.Errorf("Template %q: unexpected error: %v", template, err)
+                }
+            }
+            tu := &common.TemplateURI{Template: "missing-slash"}
+            if _, err := tu.IsMatch(url.URL{}); !errors.Is(err, common.ErrTemplateURIMisconfigured) {
+                t.Errorf("Unexpected error: %v", err)
+            }
+        },
+    )
+}
@@ -6,7 +6,6 @@ import (
     "github.com/TestInABox/gostackinabox/common"
     "github.com/TestInABox/gostackinabox/common/log"
     "github.com/TestInABox/gostackinabox/service"
-    "github.com/TestInABox/gostackinabox/util"
 )
 
 
@@ -53,13 +52,7 @@ func NewHelloWorldService() (s service.Service, err error) {
 
 func (hw *HelloWorldService) MyGetHandler(request *common.HttpCall) (result *common.HttpReply, err error) {
     log.Printf("Hello World Service GET Handler")
-    msg := "hello world!"
-    expectedLength := int64(len(msg))
-    result = &common.HttpReply{
-        Status: common.GetHttpStatus(200),
-        ResponseData: util.StringToResponseBody(msg),
-        Length: expectedLength,
-    }
+    result = common.NewReply(200).Text("hello world!")
     return
 }
 
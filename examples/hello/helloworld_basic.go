@@ -4,7 +4,6 @@ import (
     "github.com/TestInABox/gostackinabox/common"
     "github.com/TestInABox/gostackinabox/common/log"
     "github.com/TestInABox/gostackinabox/service"
-    "github.com/TestInABox/gostackinabox/util"
 )
 
 
@@ -38,13 +37,7 @@ func NewHelloWorldBasicService() (s service.Service, err error) {
 
 func (hwb *HelloWorldBasicService) HelloWorldHandler(request *common.HttpCall) (result *common.HttpReply, err error) {
     log.Printf("Hello World Basic Service FuncHandler")
-    msg := "basic hello world!"
-    expectedLength := int64(len(msg))
-    result = &common.HttpReply{
-        Status: common.GetHttpStatus(200),
-        ResponseData: util.StringToResponseBody(msg),
-        Length: expectedLength,
-    }
+    result = common.NewReply(200).Text("basic hello world!")
     return
 }
 
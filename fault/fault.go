@@ -0,0 +1,213 @@
+package fault
+
+import (
+    "crypto/x509"
+    "io"
+    "net"
+    "net/http"
+    "os"
+    "syscall"
+
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    Faults reproduce the errors a real http.Transport returns when the
+    network misbehaves, with the same types so code checking them with
+    errors.Is and errors.As can be tested:
+
+        ConnectionRefused    *net.OpError wrapping syscall.ECONNREFUSED
+        DNSFailure           *net.OpError wrapping a *net.DNSError
+        TLSHandshake         x509.UnknownAuthorityError
+        ConnectionReset      the body fails with a *net.OpError wrapping syscall.ECONNRESET
+        UnexpectedEOF        the body fails with io.ErrUnexpectedEOF before its Content-Length
+        TruncatedChunked     the body is chunked and fails with io.ErrUnexpectedEOF
+
+    The first three fail the request before it reaches any service; the rest
+    let the service reply and fail the body after the given number of bytes.
+ */
+
+type Fault struct {
+    name string
+    // fail returns the error failing the request, if this fault fails requests
+    fail func(request *http.Request) error
+    // alter changes the response, if this fault fails responses
+    alter func(response *http.Response)
+}
+
+func (f *Fault) String() string {
+    return f.name
+}
+
+// Apply injects the fault into a single request, sending it with next unless the fault fails it
+func (f *Fault) Apply(request *http.Request, next func(*http.Request) (*http.Response, error)) (response *http.Response, err error) {
+    log.Printf("Injecting %s into %s %s", f, request.Method, request.URL)
+    if f.fail != nil {
+        err = f.fail(request)
+        return
+    }
+    response, err = next(request)
+    // an upgraded connection's body has to stay writable so it is never altered
+    if err == nil && response != nil && f.alter != nil && response.StatusCode != http.StatusSwitchingProtocols {
+        if response.Request == nil {
+            response.Request = request
+        }
+        f.alter(response)
+    }
+    return
+}
+
+// remoteAddr is the address a real transport would have dialled
+type remoteAddr string
+
+func (ra remoteAddr) Network() string {
+    return "tcp"
+}
+
+func (ra remoteAddr) String() string {
+    return string(ra)
+}
+
+func addressOf(request *http.Request) remoteAddr {
+    host := request.URL.Hostname()
+    port := request.URL.Port()
+    if len(port) == 0 {
+        port = "80"
+        if request.URL.Scheme == "https" {
+            port = "443"
+        }
+    }
+    return remoteAddr(net.JoinHostPort(host, port))
+}
+
+// ConnectionRefused fails the request as if nothing listens on the port,
+// e.g `dial tcp api.example:443: connect: connection refused`
+func ConnectionRefused() *Fault {
+    return &Fault{
+        name: "connection refused",
+        fail: func(request *http.Request) error {
+            return &net.OpError{
+                Op: "dial",
+                Net: "tcp",
+                Addr: addressOf(request),
+                Err: os.NewSyscallError("connect", syscall.ECONNREFUSED),
+            }
+        },
+    }
+}
+
+// DNSFailure fails the request as if the host doesn't exist, e.g `dial tcp: lookup api.example: no such host`
+func DNSFailure() *Fault {
+    return &Fault{
+        name: "DNS failure",
+        fail: func(request *http.Request) error {
+            return &net.OpError{
+                Op: "dial",
+                Net: "tcp",
+                Err: &net.DNSError{
+                    Err: "no such host",
+                    Name: request.URL.Hostname(),
+                    IsNotFound: true,
+                },
+            }
+        },
+    }
+}
+
+// TLSHandshake fails the request as if the server's certificate isn't trusted
+func TLSHandshake() *Fault {
+    return &Fault{
+        name: "TLS handshake failure",
+        fail: func(request *http.Request) error {
+            // a real handshake failure always carries the untrusted certificate
+            return x509.UnknownAuthorityError{Cert: &x509.Certificate{}}
+        },
+    }
+}
+
+// Err fails the request with the error
+func Err(err error) *Fault {
+    return &Fault{
+        name: err.Error(),
+        fail: func(request *http.Request) error {
+            return err
+        },
+    }
+}
+
+// ConnectionReset fails reading the body after the bytes, e.g `read tcp api.example:443: read: connection reset by peer`
+func ConnectionReset(after int64) *Fault {
+    return &Fault{
+        name: "connection reset",
+        alter: func(response *http.Response) {
+            err := &net.OpError{
+                Op: "read",
+                Net: "tcp",
+                Addr: addressOf(response.Request),
+                Err: os.NewSyscallError("read", syscall.ECONNRESET),
+            }
+            breakBody(response, after, err)
+        },
+    }
+}
+
+// UnexpectedEOF ends the body after the bytes, before its Content-Length is reached
+func UnexpectedEOF(after int64) *Fault {
+    return &Fault{
+        name: "unexpected EOF",
+        alter: func(response *http.Response) {
+            breakBody(response, after, io.ErrUnexpectedEOF)
+        },
+    }
+}
+
+// TruncatedChunked sends the body chunked and ends it after the bytes without the final chunk
+func TruncatedChunked(after int64) *Fault {
+    return &Fault{
+        name: "truncated chunked body",
+        alter: func(response *http.Response) {
+            response.ContentLength = -1
+            response.TransferEncoding = []string{"chunked"}
+            response.Header.Del("Content-Length")
+            breakBody(response, after, io.ErrUnexpectedEOF)
+        },
+    }
+}
+
+func breakBody(response *http.Response, after int64, err error) {
+    body := response.Body
+    if body == nil {
+        body = http.NoBody
+    }
+    response.Body = &brokenBody{body: body, remaining: after, err: err}
+}
+
+// brokenBody passes through the remaining bytes, then fails every read with the error
+type brokenBody struct {
+    body      io.ReadCloser
+    remaining int64
+    err       error
+}
+
+func (bb *brokenBody) Read(p []byte) (n int, err error) {
+    if bb.remaining <= 0 {
+        return 0, bb.err
+    }
+    if int64(len(p)) > bb.remaining {
+        p = p[:bb.remaining]
+    }
+    n, err = bb.body.Read(p)
+    bb.remaining -= int64(n)
+    if err == io.EOF {
+        // the body ended before the fault
+        return
+    }
+    if bb.remaining <= 0 && err == nil {
+        err = bb.err
+    }
+    return
+}
+
+func (bb *brokenBody) Close() error {
+    return bb.body.Close()
+}
@@ -0,0 +1,128 @@
+package fault_test
+
+import (
+    "errors"
+    "crypto/x509"
+    "io"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "regexp"
+    "strings"
+    "syscall"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/fault"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+func newClient(t *testing.T) (*http.Client, *router.Router) {
+    r := router.New()
+    stubs := stub.New(r)
+    stubs.On("GET", "https://api.example/data").Reply(200).Body(strings.Repeat("x", 100))
+    stubs.On("GET", "https://api.example/other").Reply(200).Body("other")
+    if err := stubs.Err(); err != nil {
+        t.Fatalf("Unexpected configuration error: %v", err)
+    }
+    return &http.Client{Transport: r}, r
+}
+
+func Test_Fault_RequestErrors(t *testing.T) {
+    client, r := newClient(t)
+    faults := fault.NewInjector(1)
+    faults.Add(fault.ConnectionRefused()).ForHost("db.example")
+    faults.Add(fault.DNSFailure()).ForHost("missing.example")
+    faults.Add(fault.TLSHandshake()).ForHost("api.example").ForPath(regexp.MustCompile(`^/other`))
+    r.Faults = faults
+
+    _, err := client.Get("http://db.example/rows")
+    var opErr *net.OpError
+    if !errors.As(err, &opErr) || !errors.Is(err, syscall.ECONNREFUSED) || opErr.Op != "dial" || opErr.Addr.String() != "db.example:80" {
+        t.Errorf("Expected a connection refused *net.OpError but found %v", err)
+    }
+
+    _, err = client.Get("https://missing.example/")
+    var dnsErr *net.DNSError
+    if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound || dnsErr.Name != "missing.example" {
+        t.Errorf("Expected a *net.DNSError but found %v", err)
+    }
+
+    _, err = client.Get("https://api.example/other")
+    var tlsErr x509.UnknownAuthorityError
+    if !errors.As(err, &tlsErr) {
+        t.Errorf("Expected an x509.UnknownAuthorityError but found %v", err)
+    } else if !strings.Contains(tlsErr.Error(), "unknown authority") {
+        t.Errorf("Unexpected TLS error message: %s", tlsErr.Error())
+    }
+
+    // the other path of the host isn't affected
+    response, err := client.Get("https://api.example/data")
+    if err != nil || response.StatusCode != 200 {
+        t.Errorf("Unexpected result: %v %v", response, err)
+    }
+}
+
+func Test_Fault_BodyErrors(t *testing.T) {
+    for _, scenario := range []struct {
+        fault   *fault.Fault
+        target  error
+        chunked bool
+    }{
+        {fault.ConnectionReset(10), syscall.ECONNRESET, false},
+        {fault.UnexpectedEOF(10), io.ErrUnexpectedEOF, false},
+        {fault.TruncatedChunked(10), io.ErrUnexpectedEOF, true},
+    } {
+        client, r := newClient(t)
+        r.Faults = fault.NewInjector(1)
+        r.Faults.Add(scenario.fault)
+
+        response, err := client.Get("https://api.example/data")
+        if err != nil {
+            t.Fatalf("%s: unexpected error: %v", scenario.fault, err)
+        }
+        if chunked := response.ContentLength < 0 && len(response.TransferEncoding) > 0; chunked != scenario.chunked {
+            t.Errorf("%s: unexpected framing: %d %v", scenario.fault, response.ContentLength, response.TransferEncoding)
+        }
+        data, err := ioutil.ReadAll(response.Body)
+        if len(data) != 10 || !errors.Is(err, scenario.target) {
+            t.Errorf("%s: expected 10 bytes and %v but found %d and %v", scenario.fault, scenario.target, len(data), err)
+        }
+        response.Body.Close()
+    }
+}
+
+func Test_Fault_Probability(t *testing.T) {
+    failures := func(seed int64) (result []bool) {
+        client, r := newClient(t)
+        r.Faults = fault.NewInjector(seed)
+        rule := r.Faults.Add(fault.ConnectionRefused()).WithProbability(0.3)
+        for index := 0; index < 200; index++ {
+            _, err := client.Get("https://api.example/data")
+            result = append(result, err != nil)
+        }
+        if rule.Injected() < 40 || rule.Injected() > 80 {
+            t.Errorf("Unexpected number of faults for a probability of 0.3: %d", rule.Injected())
+        }
+        return
+    }
+    first, second := failures(5), failures(5)
+    for index := range first {
+        if first[index] != second[index] {
+            t.Fatalf("The same seed injected different faults at request %d", index)
+        }
+    }
+}
+
+func Test_Fault_Transport(t *testing.T) {
+    _, r := newClient(t)
+    faults := fault.NewInjector(1)
+    faults.Add(fault.Err(errors.New("boom"))).ForHost("api.example:443")
+    client := &http.Client{Transport: faults.Transport(r)}
+    if _, err := client.Get("https://api.example:443/data"); err == nil || !strings.Contains(err.Error(), "boom") {
+        t.Errorf("Expected the custom error but found %v", err)
+    }
+    if response, err := client.Get("https://api.example/data"); err != nil || response.StatusCode != 200 {
+        t.Errorf("Unexpected result: %v %v", response, err)
+    }
+}
@@ -0,0 +1,136 @@
+package fault
+
+import (
+    "fmt"
+    "math/rand"
+    "net/http"
+    "regexp"
+    "sync"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/predicate"
+)
+
+/*
+    An Injector decides which requests get a fault, e.g
+
+        faults := fault.NewInjector(42)
+        faults.Add(fault.ConnectionRefused()).ForHost("db.example")
+        faults.Add(fault.ConnectionReset(512)).ForHost("api.example").ForPath(regexp.MustCompile(`^/v1/export`)).WithProbability(0.25)
+        r.Faults = faults
+
+    Rules are checked in the order they were added and the first rule that
+    matches the request and wins its roll of the dice applies. The seed makes
+    the rolls repeatable between test runs. A router with Faults applies them
+    to every request, including those no service handles; Transport applies
+    them to any other http.RoundTripper.
+ */
+
+type Injector struct {
+    lock  sync.Mutex
+    rnd   *rand.Rand
+    rules []*Rule
+}
+
+type Rule struct {
+    injector    *Injector
+    fault       *Fault
+    predicates  []predicate.Predicate
+    probability float64
+    injected    int
+}
+
+func NewInjector(seed int64) *Injector {
+    return &Injector{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// Add injects the fault into every request until the rule is narrowed
+func (in *Injector) Add(f *Fault) *Rule {
+    in.lock.Lock()
+    defer in.lock.Unlock()
+    rule := &Rule{injector: in, fault: f, probability: 1}
+    in.rules = append(in.rules, rule)
+    return rule
+}
+
+// ForHost limits the rule to the host, given with or without the port
+func (r *Rule) ForHost(host string) *Rule {
+    return r.When(predicate.New(
+        fmt.Sprintf("host is %q", host),
+        func(request *common.HttpCall) string {
+            if request.Url.Host == host || request.Url.Hostname() == host {
+                return ""
+            }
+            return fmt.Sprintf("found %q", request.Url.Host)
+        },
+    ))
+}
+
+// ForPath limits the rule to paths matching the regular expression
+func (r *Rule) ForPath(pattern *regexp.Regexp) *Rule {
+    return r.When(predicate.Path(predicate.Regex(pattern)))
+}
+
+// When limits the rule to requests satisfying the predicates; only the method, URL and headers are set
+func (r *Rule) When(predicates ...predicate.Predicate) *Rule {
+    r.predicates = append(r.predicates, predicates...)
+    return r
+}
+
+// WithProbability injects the fault into the share, between 0 and 1, of the matching requests
+func (r *Rule) WithProbability(probability float64) *Rule {
+    r.probability = probability
+    return r
+}
+
+// Injected returns the number of requests the rule's fault was injected into
+func (r *Rule) Injected() int {
+    r.injector.lock.Lock()
+    defer r.injector.lock.Unlock()
+    return r.injected
+}
+
+// choose picks the fault for the request, if any
+func (in *Injector) choose(request *http.Request) *Fault {
+    call := &common.HttpCall{
+        Method: common.HttpVerb(request.Method),
+        Url: request.URL,
+        Headers: request.Header,
+    }
+    in.lock.Lock()
+    defer in.lock.Unlock()
+    for _, rule := range in.rules {
+        if !predicate.IsMatch(call, rule.predicates...) {
+            continue
+        }
+        if rule.probability < 1 && in.rnd.Float64() >= rule.probability {
+            continue
+        }
+        rule.injected++
+        return rule.fault
+    }
+    return nil
+}
+
+// Apply sends the request with next unless a fault fails it, then lets any fault alter the response
+func (in *Injector) Apply(request *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+    f := in.choose(request)
+    if f == nil {
+        return next(request)
+    }
+    return f.Apply(request, next)
+}
+
+type transport struct {
+    injector *Injector
+    next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(request *http.Request) (*http.Response, error) {
+    return t.injector.Apply(request, t.next.RoundTrip)
+}
+
+// Transport injects the faults into the requests sent with next
+func (in *Injector) Transport(next http.RoundTripper) http.RoundTripper {
+    return &transport{injector: in, next: next}
+}
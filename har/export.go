@@ -0,0 +1,169 @@
+package har
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "io"
+    "net/http"
+    "os"
+    "sort"
+    "time"
+    "unicode/utf8"
+
+    "github.com/TestInABox/gostackinabox/router"
+)
+
+// FromExchanges converts the router's recorded traffic into an archive
+func FromExchanges(exchanges []router.Exchange) *HAR {
+    h := &HAR{
+        Log: Log{
+            Version: Version,
+            Creator: Creator{
+                Name: creatorName,
+                Version: creatorVersion,
+            },
+            Entries: make([]Entry, 0, len(exchanges)),
+        },
+    }
+    for _, exchange := range exchanges {
+        h.Log.Entries = append(h.Log.Entries, entryFromExchange(exchange))
+    }
+    return h
+}
+
+// Export writes the recorded traffic to the writer as HAR formatted JSON
+func Export(w io.Writer, exchanges []router.Exchange) error {
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(FromExchanges(exchanges))
+}
+
+func WriteFile(path string, exchanges []router.Exchange) (err error) {
+    f, err := os.Create(path)
+    if err != nil {
+        return
+    }
+    defer func() {
+        if closeErr := f.Close(); err == nil {
+            err = closeErr
+        }
+    }()
+    err = Export(f, exchanges)
+    return
+}
+
+func entryFromExchange(exchange router.Exchange) (entry Entry) {
+    wait := milliseconds(exchange.Wait)
+    receive := milliseconds(exchange.Receive)
+
+    entry = Entry{
+        StartedDateTime: exchange.Started.Format(time.RFC3339Nano),
+        Time: wait + receive,
+        Request: Request{
+            Method: exchange.Method,
+            HTTPVersion: protoOrDefault(exchange.Proto),
+            Cookies: []NameValue{},
+            Headers: nameValues(exchange.RequestHeaders),
+            QueryString: []NameValue{},
+            HeadersSize: -1,
+            BodySize: int64(len(exchange.RequestBody)),
+        },
+        Response: Response{
+            Status: exchange.Status,
+            StatusText: http.StatusText(exchange.Status),
+            HTTPVersion: protoOrDefault(exchange.ResponseProto),
+            Cookies: []NameValue{},
+            Headers: nameValues(exchange.ResponseHeaders),
+            Content: Content{
+                Size: int64(len(exchange.ResponseBody)),
+                MimeType: exchange.ResponseHeaders.Get("Content-Type"),
+            },
+            HeadersSize: -1,
+            BodySize: int64(len(exchange.ResponseBody)),
+        },
+        Cache: Cache{},
+        Timings: Timings{
+            Blocked: -1,
+            DNS: -1,
+            Connect: -1,
+            SSL: -1,
+            Send: 0,
+            Wait: wait,
+            Receive: receive,
+        },
+    }
+
+    if exchange.URL != nil {
+        entry.Request.URL = exchange.URL.String()
+        entry.Request.QueryString = nameValues(exchange.URL.Query())
+    }
+
+    if len(exchange.RequestBody) > 0 {
+        text, encoding := encodeBody(exchange.RequestBody)
+        entry.Request.PostData = &PostData{
+            MimeType: exchange.RequestHeaders.Get("Content-Type"),
+            Text: text,
+            Encoding: encoding,
+        }
+    }
+
+    if len(exchange.ResponseBody) > 0 {
+        entry.Response.Content.Text, entry.Response.Content.Encoding = encodeBody(exchange.ResponseBody)
+    }
+
+    if location := exchange.ResponseHeaders.Get("Location"); len(location) > 0 {
+        entry.Response.RedirectURL = location
+    }
+
+    if exchange.Err != nil {
+        entry.Response.Error = exchange.Err.Error()
+    }
+    return
+}
+
+func milliseconds(d time.Duration) float64 {
+    return float64(d) / float64(time.Millisecond)
+}
+
+func protoOrDefault(proto string) string {
+    if len(proto) == 0 {
+        return "HTTP/1.1"
+    }
+    return proto
+}
+
+// encodeBody keeps text bodies readable and base64 encodes anything binary
+func encodeBody(body []byte) (text string, encoding string) {
+    if utf8.Valid(body) && !containsControl(body) {
+        text = string(body)
+        return
+    }
+    text = base64.StdEncoding.EncodeToString(body)
+    encoding = EncodingBase64
+    return
+}
+
+func containsControl(body []byte) bool {
+    for _, b := range body {
+        if b < 0x20 && b != '\n' && b != '\r' && b != '\t' {
+            return true
+        }
+    }
+    return false
+}
+
+// nameValues flattens the header/query map in a stable order
+func nameValues(values map[string][]string) (result []NameValue) {
+    result = []NameValue{}
+    names := make([]string, 0, len(values))
+    for name := range values {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        for _, value := range values[name] {
+            result = append(result, NameValue{Name: name, Value: value})
+        }
+    }
+    return
+}
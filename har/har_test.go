@@ -0,0 +1,192 @@
+package har_test
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/har"
+    "github.com/TestInABox/gostackinabox/redact"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/util"
+)
+
+func recordTraffic(t *testing.T) []router.Exchange {
+    irt := router.New()
+    irt.Recorder = router.NewRecorder()
+
+    binary := []byte{0x89, 0x50, 0x4e, 0x47, 0x00, 0x01}
+    serviceHandler := &service.ServiceHandler{
+        Matcher: &common.BasicServerURI{
+            Protocol: "https",
+            Host: "api.example.com",
+        },
+        FuncHandler: func(hc *common.HttpCall) (hr *common.HttpReply, err error) {
+            time.Sleep(5 * time.Millisecond)
+            if hc.Url.Path == "/logo.png" {
+                hr = &common.HttpReply{
+                    Status: 200,
+                    Headers: http.Header{"Content-Type": []string{"image/png"}},
+                    ResponseData: util.StringToResponseBody(string(binary)),
+                    Length: int64(len(binary)),
+                }
+                return
+            }
+            msg := `{"id":1}`
+            hr = &common.HttpReply{
+                Status: 200,
+                Headers: http.Header{"Content-Type": []string{"application/json"}},
+                ResponseData: util.StringToResponseBody(msg),
+                Length: int64(len(msg)),
+            }
+            return
+        },
+    }
+    if err := irt.RegisterService("https://api.example.com", serviceHandler); err != nil {
+        t.Fatalf("Unexpected error registering service: %v", err)
+    }
+
+    client := &http.Client{Transport: irt}
+    for _, target := range []string{"https://api.example.com/users?access_token=abc", "https://api.example.com/logo.png"} {
+        response, err := client.Get(target)
+        if err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        ioutil.ReadAll(response.Body)
+        response.Body.Close()
+    }
+    return irt.Recorder.Exchanges()
+}
+
+func Test_HAR_Export(t *testing.T) {
+    exchanges := recordTraffic(t)
+
+    var buffer bytes.Buffer
+    if err := har.Export(&buffer, exchanges); err != nil {
+        t.Fatalf("Unexpected error exporting: %v", err)
+    }
+
+    var decoded har.HAR
+    if err := json.Unmarshal(buffer.Bytes(), &decoded); err != nil {
+        t.Fatalf("Export generated invalid JSON: %v", err)
+    }
+    if decoded.Log.Version != har.Version || len(decoded.Log.Entries) != 2 {
+        t.Fatalf("Unexpected archive: %#v", decoded.Log)
+    }
+
+    jsonEntry := decoded.Log.Entries[0]
+    if strings.Contains(jsonEntry.Request.URL, "abc") {
+        t.Errorf("Exported URL not redacted: %s", jsonEntry.Request.URL)
+    }
+    if jsonEntry.Response.Content.Text != `{"id":1}` || len(jsonEntry.Response.Content.Encoding) != 0 {
+        t.Errorf("Unexpected text content: %#v", jsonEntry.Response.Content)
+    }
+    if jsonEntry.Timings.Wait < 5 || jsonEntry.Time < jsonEntry.Timings.Wait {
+        t.Errorf("Timings do not reflect the service latency: %#v (time: %f)", jsonEntry.Timings, jsonEntry.Time)
+    }
+    if len(jsonEntry.Request.QueryString) != 1 || jsonEntry.Request.QueryString[0].Value != redact.Placeholder("access_token") {
+        t.Errorf("Unexpected query string: %#v", jsonEntry.Request.QueryString)
+    }
+
+    binaryEntry := decoded.Log.Entries[1]
+    if binaryEntry.Response.Content.Encoding != har.EncodingBase64 {
+        t.Errorf("Binary content not base64 encoded: %#v", binaryEntry.Response.Content)
+    }
+}
+
+func Test_HAR_Replay(t *testing.T) {
+    var buffer bytes.Buffer
+    if err := har.Export(&buffer, recordTraffic(t)); err != nil {
+        t.Fatalf("Unexpected error exporting: %v", err)
+    }
+    archive, err := har.Load(&buffer)
+    if err != nil {
+        t.Fatalf("Unexpected error loading: %v", err)
+    }
+
+    irt := router.New()
+    if err := har.Register(irt, archive); err != nil {
+        t.Fatalf("Unexpected error registering: %v", err)
+    }
+    client := &http.Client{Transport: irt}
+
+    t.Run(
+        "placeholder matches any value",
+        func(t *testing.T) {
+            response, err := client.Get("https://api.example.com/users?access_token=different")
+            if err != nil {
+                t.Fatalf("Unexpected error: %v", err)
+            }
+            body, _ := ioutil.ReadAll(response.Body)
+            if response.StatusCode != 200 || string(body) != `{"id":1}` {
+                t.Errorf("Unexpected replay: %d %s", response.StatusCode, body)
+            }
+        },
+    )
+    t.Run(
+        "binary body",
+        func(t *testing.T) {
+            response, err := client.Get("https://api.example.com/logo.png")
+            if err != nil {
+                t.Fatalf("Unexpected error: %v", err)
+            }
+            body, _ := ioutil.ReadAll(response.Body)
+            if !bytes.Equal(body, []byte{0x89, 0x50, 0x4e, 0x47, 0x00, 0x01}) {
+                t.Errorf("Unexpected binary body: %v", body)
+            }
+        },
+    )
+    t.Run(
+        "unrecorded route",
+        func(t *testing.T) {
+            response, err := client.Get("https://api.example.com/other")
+            if err != nil {
+                t.Fatalf("Unexpected error: %v", err)
+            }
+            if response.StatusCode != int(common.HttpStatus_ServiceSubRouteError) {
+                t.Errorf("Unexpected status: %d", response.StatusCode)
+            }
+        },
+    )
+}
+
+func Test_HAR_Replay_TransportError(t *testing.T) {
+    archive, err := har.Load(strings.NewReader(`{"log":{"entries":[{"request":{"method":"GET","url":"https://api.example.com/down"},"response":{"status":0,"_error":"connection refused"}}]}}`))
+    if err != nil {
+        t.Fatalf("Unexpected error loading: %v", err)
+    }
+
+    irt := router.New()
+    if err := har.Register(irt, archive); err != nil {
+        t.Fatalf("Unexpected error registering: %v", err)
+    }
+    client := &http.Client{Transport: irt}
+
+    response, err := client.Get("https://api.example.com/down")
+    if err == nil {
+        t.Fatalf("Unexpected replay of a transport error: %d", response.StatusCode)
+    }
+    if !strings.Contains(err.Error(), "connection refused") {
+        t.Errorf("Unexpected error: %v", err)
+    }
+}
+
+func Test_HAR_Load(t *testing.T) {
+    _, err := har.Load(strings.NewReader("{not json"))
+    if !errors.Is(err, har.ErrInvalidHAR) {
+        t.Errorf("Unexpected error: %v != %v", err, har.ErrInvalidHAR)
+    }
+
+    archive, _ := har.Load(strings.NewReader(`{"log":{"entries":[{"request":{"method":"GET","url":"/relative"}}]}}`))
+    _, err = har.NewReplayServices(archive)
+    if !errors.Is(err, har.ErrInvalidEntry) {
+        t.Errorf("Unexpected error: %v != %v", err, har.ErrInvalidEntry)
+    }
+}
@@ -0,0 +1,201 @@
+package har
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "sync"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/redact"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/util"
+)
+
+/*
+    ReplayService serves the entries of a HAR file recorded for a single host.
+    Requests are matched on method, path and query string with any redaction
+    placeholders acting as wildcards. When several entries match the same
+    request they are served in the order recorded, the last one repeating
+    once they are used up.
+ */
+
+func Load(r io.Reader) (h *HAR, err error) {
+    h = &HAR{}
+    if decodeErr := json.NewDecoder(r).Decode(h); decodeErr != nil {
+        err = fmt.Errorf("%w: %v", ErrInvalidHAR, decodeErr)
+        h = nil
+        return
+    }
+    return
+}
+
+func ReadFile(path string) (h *HAR, err error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return
+    }
+    defer f.Close()
+    return Load(f)
+}
+
+type replayEntry struct {
+    entry   Entry
+    url     *url.URL
+    served  int
+}
+
+type ReplayService struct {
+    service.ServiceHandler
+
+    lock    sync.Mutex
+    entries []*replayEntry
+}
+
+// NewReplayServices generates a service per host (scheme://host:port) found in the archive
+func NewReplayServices(h *HAR) (services []*ReplayService, err error) {
+    if h == nil {
+        err = fmt.Errorf("%w: missing archive", ErrInvalidHAR)
+        return
+    }
+
+    byHost := make(map[string]*ReplayService)
+    for index, entry := range h.Log.Entries {
+        entryUrl, parseErr := url.Parse(entry.Request.URL)
+        if parseErr != nil || len(entryUrl.Host) == 0 {
+            err = fmt.Errorf("%w: entry %d has an invalid URL %q", ErrInvalidEntry, index, entry.Request.URL)
+            return
+        }
+
+        serviceName := util.GetUrlBaseResource(entryUrl)
+        rs, ok := byHost[serviceName]
+        if !ok {
+            rs = &ReplayService{}
+            initErr := rs.Init(
+                serviceName,
+                &common.BasicServerURI{
+                    Protocol: entryUrl.Scheme,
+                    Host: entryUrl.Hostname(),
+                    Port: entryUrl.Port(),
+                },
+            )
+            if initErr != nil {
+                err = initErr
+                return
+            }
+            rs.FuncHandler = rs.Replay
+            byHost[serviceName] = rs
+            services = append(services, rs)
+        }
+        rs.entries = append(rs.entries, &replayEntry{entry: entry, url: entryUrl})
+    }
+    return
+}
+
+// Register adds replay services for every host in the archive to the router
+func Register(r *router.Router, h *HAR) (err error) {
+    services, err := NewReplayServices(h)
+    if err != nil {
+        return
+    }
+    for _, rs := range services {
+        if err = r.RegisterService(rs.GetName(), rs); err != nil {
+            return
+        }
+    }
+    return
+}
+
+func (rs *ReplayService) Replay(request *common.HttpCall) (result *common.HttpReply, err error) {
+    rs.lock.Lock()
+    defer rs.lock.Unlock()
+
+    var candidate *replayEntry
+    for _, entry := range rs.entries {
+        if !entry.isMatch(request) {
+            continue
+        }
+        if entry.served == 0 {
+            candidate = entry
+            break
+        }
+        // all matching entries have been served, repeat the last one
+        candidate = entry
+    }
+
+    if candidate == nil {
+        log.Printf("No HAR entry recorded for %s %s", request.Method, request.Url.String())
+        msg := fmt.Sprintf("gostackinabox: no HAR entry recorded for %s %s", request.Method, request.Url.String())
+        result = common.NewReply(common.HttpStatus_ServiceSubRouteError).Text(msg)
+        return
+    }
+
+    candidate.served++
+    return candidate.reply()
+}
+
+func (re *replayEntry) isMatch(request *common.HttpCall) bool {
+    if string(request.Method) != re.entry.Request.Method {
+        return false
+    }
+    if !redact.Matches(re.url.Path, request.Url.Path) {
+        return false
+    }
+
+    recorded := re.url.Query()
+    actual := request.Url.Query()
+    if len(recorded) != len(actual) {
+        return false
+    }
+    for name, values := range recorded {
+        actualValues, ok := actual[name]
+        if !ok || len(actualValues) != len(values) {
+            return false
+        }
+        for index, value := range values {
+            if !redact.Matches(value, actualValues[index]) {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+func (re *replayEntry) reply() (result *common.HttpReply, err error) {
+    // the request failed at the transport level when it was recorded so fail it the same way
+    if re.entry.Response.Status == 0 && len(re.entry.Response.Error) > 0 {
+        err = &common.TransportError{Err: errors.New(re.entry.Response.Error)}
+        return
+    }
+
+    content := re.entry.Response.Content
+    body := []byte(content.Text)
+    if content.Encoding == EncodingBase64 {
+        body, err = base64.StdEncoding.DecodeString(content.Text)
+        if err != nil {
+            err = fmt.Errorf("%w: unable to decode the response body for %s: %v", ErrInvalidEntry, re.entry.Request.URL, err)
+            return
+        }
+    }
+
+    headers := make(http.Header)
+    for _, header := range re.entry.Response.Headers {
+        headers.Add(header.Name, header.Value)
+    }
+    // the body is served as-is so the recorded framing no longer applies
+    headers.Del("Content-Length")
+    headers.Del("Transfer-Encoding")
+    headers.Del("Content-Encoding")
+
+    result = common.NewReply(common.GetHttpStatus(re.entry.Response.Status)).WithHeaders(headers).Bytes(body)
+    return
+}
+
+var _ service.Service = &ReplayService{}
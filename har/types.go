@@ -0,0 +1,113 @@
+package har
+
+import (
+    "errors"
+)
+
+/*
+    Types for the HTTP Archive (HAR) 1.2 format as described at
+    http://www.softwareishard.com/blog/har-12-spec/
+
+    Only the fields needed to faithfully capture the router's traffic are
+    populated on export; import ignores anything it does not need to replay
+    the entries.
+ */
+
+const (
+    Version = "1.2"
+    creatorName = "gostackinabox"
+    creatorVersion = "1.0"
+
+    // Content.Encoding value for binary bodies
+    EncodingBase64 = "base64"
+)
+
+type HAR struct {
+    Log Log `json:"log"`
+}
+
+type Log struct {
+    Version string  `json:"version"`
+    Creator Creator `json:"creator"`
+    Entries []Entry `json:"entries"`
+}
+
+type Creator struct {
+    Name    string `json:"name"`
+    Version string `json:"version"`
+}
+
+type Entry struct {
+    StartedDateTime string   `json:"startedDateTime"`
+    // Time is the total elapsed time of the request in milliseconds
+    Time     float64  `json:"time"`
+    Request  Request  `json:"request"`
+    Response Response `json:"response"`
+    Cache    Cache    `json:"cache"`
+    Timings  Timings  `json:"timings"`
+    Comment  string   `json:"comment,omitempty"`
+}
+
+type NameValue struct {
+    Name  string `json:"name"`
+    Value string `json:"value"`
+}
+
+type Request struct {
+    Method      string      `json:"method"`
+    URL         string      `json:"url"`
+    HTTPVersion string      `json:"httpVersion"`
+    Cookies     []NameValue `json:"cookies"`
+    Headers     []NameValue `json:"headers"`
+    QueryString []NameValue `json:"queryString"`
+    PostData    *PostData   `json:"postData,omitempty"`
+    HeadersSize int64       `json:"headersSize"`
+    BodySize    int64       `json:"bodySize"`
+}
+
+type PostData struct {
+    MimeType string `json:"mimeType"`
+    Text     string `json:"text"`
+    // HAR 1.2 has no encoding for request bodies so binary data uses a custom field
+    Encoding string `json:"_encoding,omitempty"`
+}
+
+type Response struct {
+    Status      int         `json:"status"`
+    StatusText  string      `json:"statusText"`
+    HTTPVersion string      `json:"httpVersion"`
+    Cookies     []NameValue `json:"cookies"`
+    Headers     []NameValue `json:"headers"`
+    Content     Content     `json:"content"`
+    RedirectURL string      `json:"redirectURL"`
+    HeadersSize int64       `json:"headersSize"`
+    BodySize    int64       `json:"bodySize"`
+    // transport errors have no HAR equivalent; recorded for diagnostics only
+    Error string `json:"_error,omitempty"`
+}
+
+type Content struct {
+    Size     int64  `json:"size"`
+    MimeType string `json:"mimeType"`
+    Text     string `json:"text,omitempty"`
+    Encoding string `json:"encoding,omitempty"`
+}
+
+type Cache struct {
+}
+
+// Timings are in milliseconds; -1 means the value does not apply
+type Timings struct {
+    Blocked float64 `json:"blocked"`
+    DNS     float64 `json:"dns"`
+    Connect float64 `json:"connect"`
+    Send    float64 `json:"send"`
+    Wait    float64 `json:"wait"`
+    Receive float64 `json:"receive"`
+    SSL     float64 `json:"ssl"`
+}
+
+var (
+    ErrInvalidHAR error = errors.New("HAR: Invalid archive")
+    ErrInvalidEntry error = errors.New("HAR: Invalid entry")
+)
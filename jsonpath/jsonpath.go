@@ -0,0 +1,182 @@
+package jsonpath
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+/*
+    The jsonpath package implements the subset of JSONPath that stub mappings
+    and templates use in practice: `$`, `.name`, `['name']`, `[index]`, `*`
+    wildcards and `..name` recursive descent. Filters, slices and unions are
+    reported as unsupported. Documents are the values produced by decoding JSON
+    into an interface{}.
+ */
+
+var (
+    ErrUnsupported error = errors.New("JSONPath: Unsupported expression")
+)
+
+type stepKind int
+
+const (
+    stepField stepKind = iota
+    stepIndex
+    stepWildcard
+    stepDescend
+)
+
+type pathStep struct {
+    kind  stepKind
+    name  string
+    index int
+}
+
+type Path struct {
+    expression string
+    steps      []pathStep
+}
+
+// Compile parses the expression, failing with ErrUnsupported for anything outside the subset
+func Compile(expression string) (jp *Path, err error) {
+    if !strings.HasPrefix(expression, "$") {
+        err = fmt.Errorf("%w: %q must start with $", ErrUnsupported, expression)
+        return
+    }
+
+    jp = &Path{expression: expression}
+    rest := expression[1:]
+    for len(rest) > 0 {
+        switch {
+        case strings.HasPrefix(rest, ".."):
+            var name string
+            name, rest = readName(rest[2:])
+            if len(name) == 0 {
+                err = fmt.Errorf("%w: %q is missing a field name after ..", ErrUnsupported, expression)
+                return
+            }
+            jp.steps = append(jp.steps, pathStep{kind: stepDescend, name: name})
+        case rest[0] == '.':
+            var name string
+            name, rest = readName(rest[1:])
+            switch name {
+            case "":
+                err = fmt.Errorf("%w: %q is missing a field name after .", ErrUnsupported, expression)
+                return
+            case "*":
+                jp.steps = append(jp.steps, pathStep{kind: stepWildcard})
+            default:
+                jp.steps = append(jp.steps, pathStep{kind: stepField, name: name})
+            }
+        case rest[0] == '[':
+            end := strings.Index(rest, "]")
+            if end < 0 {
+                err = fmt.Errorf("%w: %q has an unterminated [", ErrUnsupported, expression)
+                return
+            }
+            var step pathStep
+            if step, err = parseBracket(expression, rest[1:end]); err != nil {
+                return
+            }
+            jp.steps = append(jp.steps, step)
+            rest = rest[end+1:]
+        default:
+            err = fmt.Errorf("%w: %q has unexpected %q", ErrUnsupported, expression, rest)
+            return
+        }
+    }
+    return
+}
+
+func readName(s string) (name string, rest string) {
+    end := strings.IndexAny(s, ".[")
+    if end < 0 {
+        end = len(s)
+    }
+    return s[:end], s[end:]
+}
+
+func parseBracket(expression string, content string) (step pathStep, err error) {
+    content = strings.TrimSpace(content)
+    switch {
+    case content == "*":
+        step.kind = stepWildcard
+    case len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0]:
+        step.kind = stepField
+        step.name = content[1 : len(content)-1]
+    default:
+        index, convErr := strconv.Atoi(content)
+        if convErr != nil {
+            err = fmt.Errorf("%w: %q uses [%s]; only names, indexes and * are supported", ErrUnsupported, expression, content)
+            return
+        }
+        step.kind = stepIndex
+        step.index = index
+    }
+    return
+}
+
+func (jp *Path) String() string {
+    return jp.expression
+}
+
+// Evaluate returns every value in the document the path selects
+func (jp *Path) Evaluate(document interface{}) []interface{} {
+    current := []interface{}{document}
+    for _, step := range jp.steps {
+        next := []interface{}{}
+        for _, value := range current {
+            next = append(next, step.apply(value)...)
+        }
+        current = next
+    }
+    return current
+}
+
+func (step pathStep) apply(value interface{}) (result []interface{}) {
+    switch step.kind {
+    case stepField:
+        if object, ok := value.(map[string]interface{}); ok {
+            if child, ok := object[step.name]; ok {
+                result = append(result, child)
+            }
+        }
+    case stepIndex:
+        if array, ok := value.([]interface{}); ok {
+            index := step.index
+            if index < 0 {
+                index += len(array)
+            }
+            if index >= 0 && index < len(array) {
+                result = append(result, array[index])
+            }
+        }
+    case stepWildcard:
+        result = children(value)
+    case stepDescend:
+        for _, child := range children(value) {
+            if step.name == "*" {
+                result = append(result, child)
+            }
+            result = append(result, pathStep{kind: stepDescend, name: step.name}.apply(child)...)
+        }
+        if step.name != "*" {
+            result = append(pathStep{kind: stepField, name: step.name}.apply(value), result...)
+        }
+    }
+    return
+}
+
+func children(value interface{}) (result []interface{}) {
+    switch typed := value.(type) {
+    case map[string]interface{}:
+        for _, child := range typed {
+            result = append(result, child)
+        }
+    case []interface{}:
+        result = append(result, typed...)
+    }
+    return
+}
@@ -0,0 +1,50 @@
+package jsonpath_test
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "reflect"
+    "sort"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/jsonpath"
+)
+
+func Test_JSONPath_Evaluate(t *testing.T) {
+    var document interface{}
+    json.Unmarshal(
+        []byte(`{"store": {"book": [{"title": "a", "price": 1}, {"title": "b", "price": 2}], "name": "shop"}, "title": "top"}`),
+        &document,
+    )
+
+    scenarios := map[string][]interface{}{
+        "$": {document},
+        "$.store.name": {"shop"},
+        "$['store']['name']": {"shop"},
+        "$.store.book[1].title": {"b"},
+        "$.store.book[-1].price": {float64(2)},
+        "$.store.book[*].title": {"a", "b"},
+        "$..title": {"a", "b", "top"},
+        "$.missing": {},
+        "$.store.book[5]": {},
+    }
+    for expression, expected := range scenarios {
+        path, err := jsonpath.Compile(expression)
+        if err != nil {
+            t.Errorf("%s: unexpected error: %v", expression, err)
+            continue
+        }
+        result := path.Evaluate(document)
+        sort.Slice(result, func(i, j int) bool { return fmt.Sprint(result[i]) < fmt.Sprint(result[j]) })
+        if len(result) != len(expected) || (len(expected) > 0 && !reflect.DeepEqual(result, expected)) {
+            t.Errorf("%s: unexpected result: %#v != %#v", expression, result, expected)
+        }
+    }
+
+    for _, expression := range []string{"store.name", "$.book[?(@.price > 1)]", "$.book[0:2]", "$.book[", "$.."} {
+        if _, err := jsonpath.Compile(expression); !errors.Is(err, jsonpath.ErrUnsupported) {
+            t.Errorf("%s: unexpected error: %v", expression, err)
+        }
+    }
+}
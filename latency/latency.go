@@ -0,0 +1,147 @@
+package latency
+
+import (
+    "context"
+    "io"
+    "math/rand"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    The latency package delays replies to test client timeouts and hedging.
+    An Injector has separate profiles for the time to the first byte (the
+    wait before the reply is returned) and for delivering the body, e.g
+
+        slow := latency.NewInjector(42).
+            WithFirstByte(latency.LogNormal(80*time.Millisecond, 0.5)).
+            WithBody(latency.Uniform(10*time.Millisecond, 50*time.Millisecond))
+        r.Use(slow.Middleware())
+
+    Its Middleware can be used at the router, service or method level (see
+    service.ServiceHandler for the order they run in). The body delay is
+    the total time taken to read the body, spread across it in proportion
+    to the bytes read; when the reply Length isn't known the whole delay is
+    taken before the first read. All waits end early, failing with the
+    context error, when the request context ends.
+ */
+
+type Injector struct {
+    firstByte Profile
+    body      Profile
+
+    lock sync.Mutex
+    rnd  *rand.Rand
+}
+
+// NewInjector creates an Injector without delays; the seed makes the sampled delays repeatable
+func NewInjector(seed int64) *Injector {
+    return &Injector{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// WithFirstByte sets the delay before the reply is returned
+func (in *Injector) WithFirstByte(p Profile) *Injector {
+    in.firstByte = p
+    return in
+}
+
+// WithBody sets the total delay taken to deliver the body
+func (in *Injector) WithBody(p Profile) *Injector {
+    in.body = p
+    return in
+}
+
+func (in *Injector) sample(p Profile) time.Duration {
+    if p == nil {
+        return 0
+    }
+    in.lock.Lock()
+    defer in.lock.Unlock()
+    return p.Sample(in.rnd)
+}
+
+func (in *Injector) Middleware() common.Middleware {
+    return func(next common.HttpHandler) common.HttpHandler {
+        return func(request *common.HttpCall) (result *common.HttpReply, err error) {
+            firstByte := in.sample(in.firstByte)
+            bodyDelay := in.sample(in.body)
+            if firstByte > 0 {
+                log.Printf("Delaying the reply to %s %s by %v", request.Method, request.Url, firstByte)
+                if err = common.Sleep(request, firstByte); err != nil {
+                    return
+                }
+            }
+
+            result, err = next(request)
+            // an upgraded connection's body has to stay writable so it is never wrapped
+            if err != nil || result == nil || result.ResponseData == nil || bodyDelay <= 0 ||
+                result.Status == http.StatusSwitchingProtocols {
+                return
+            }
+            result.ResponseData = &slowBody{
+                body: result.ResponseData,
+                ctx: request.Context(),
+                delay: bodyDelay,
+                length: result.Length,
+            }
+            return
+        }
+    }
+}
+
+// slowBody spreads the delay over reading the body
+type slowBody struct {
+    body   io.ReadCloser
+    ctx    context.Context
+    delay  time.Duration
+    length int64
+    read   int64
+    // waited is the part of the delay already taken
+    waited time.Duration
+}
+
+func (sb *slowBody) Read(p []byte) (n int, err error) {
+    if sb.length <= 0 {
+        // the share of each read can't be known, so take the delay up front
+        if err = sb.wait(sb.delay); err != nil {
+            return
+        }
+        return sb.body.Read(p)
+    }
+
+    n, err = sb.body.Read(p)
+    sb.read += int64(n)
+    progress := sb.read
+    if progress > sb.length {
+        progress = sb.length
+    }
+    due := time.Duration(float64(sb.delay) * float64(progress) / float64(sb.length))
+    if waitErr := sb.wait(due); waitErr != nil {
+        return 0, waitErr
+    }
+    return
+}
+
+// wait sleeps until the given part of the delay has been taken
+func (sb *slowBody) wait(due time.Duration) error {
+    if due <= sb.waited {
+        return nil
+    }
+    timer := time.NewTimer(due - sb.waited)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        sb.waited = due
+        return nil
+    case <-sb.ctx.Done():
+        return sb.ctx.Err()
+    }
+}
+
+func (sb *slowBody) Close() error {
+    return sb.body.Close()
+}
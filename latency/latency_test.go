@@ -0,0 +1,129 @@
+package latency_test
+
+import (
+    "context"
+    "errors"
+    "io/ioutil"
+    "math/rand"
+    "net/http"
+    "sort"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/latency"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+func percentileOf(p latency.Profile, seed int64, rank int) time.Duration {
+    rnd := rand.New(rand.NewSource(seed))
+    samples := make([]time.Duration, 1000)
+    for index := range samples {
+        samples[index] = p.Sample(rnd)
+    }
+    sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+    return samples[rank*len(samples)/100-1]
+}
+
+func Test_Latency_Profiles(t *testing.T) {
+    table, err := latency.Percentiles(map[float64]time.Duration{50: 20 * time.Millisecond, 99: 200 * time.Millisecond})
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    for _, scenario := range []struct {
+        profile latency.Profile
+        rank    int
+        low     time.Duration
+        high    time.Duration
+    }{
+        {latency.Fixed(time.Second), 50, time.Second, time.Second},
+        {latency.Uniform(10*time.Millisecond, 20*time.Millisecond), 100, 19 * time.Millisecond, 20 * time.Millisecond},
+        {latency.Jitter(100*time.Millisecond, 10*time.Millisecond), 1, 90 * time.Millisecond, 91 * time.Millisecond},
+        {latency.Normal(100*time.Millisecond, 10*time.Millisecond), 50, 95 * time.Millisecond, 105 * time.Millisecond},
+        {latency.Normal(0, 10*time.Millisecond), 10, 0, 0},
+        {latency.LogNormal(50*time.Millisecond, 0.5), 50, 45 * time.Millisecond, 55 * time.Millisecond},
+        {latency.LogNormal(50*time.Millisecond, 0.5), 99, 120 * time.Millisecond, 200 * time.Millisecond},
+        {table, 50, 18 * time.Millisecond, 22 * time.Millisecond},
+        {table, 90, 150 * time.Millisecond, 185 * time.Millisecond},
+        {table, 10, 20 * time.Millisecond, 20 * time.Millisecond},
+    } {
+        if sample := percentileOf(scenario.profile, 7, scenario.rank); sample < scenario.low || sample > scenario.high {
+            t.Errorf("%s: p%d %v is not in [%v, %v]", scenario.profile, scenario.rank, sample, scenario.low, scenario.high)
+        }
+        if percentileOf(scenario.profile, 7, scenario.rank) != percentileOf(scenario.profile, 7, scenario.rank) {
+            t.Errorf("%s: the same seed gave different samples", scenario.profile)
+        }
+    }
+
+    for _, invalid := range []map[float64]time.Duration{
+        {},
+        {0: time.Second},
+        {101: time.Second},
+        {50: time.Second, 99: time.Millisecond},
+    } {
+        if _, err := latency.Percentiles(invalid); !errors.Is(err, latency.ErrInvalidProfile) {
+            t.Errorf("%v: expected ErrInvalidProfile but found %v", invalid, err)
+        }
+    }
+}
+
+func Test_Latency_Injector(t *testing.T) {
+    r := router.New()
+    client := &http.Client{Transport: r}
+    stubs := stub.New(r)
+    stubs.On("GET", "https://api.example/slow").Reply(200).Body(strings.Repeat("x", 1000))
+    if err := stubs.Err(); err != nil {
+        t.Fatalf("Unexpected configuration error: %v", err)
+    }
+    injector := latency.NewInjector(1).
+        WithFirstByte(latency.Fixed(30 * time.Millisecond)).
+        WithBody(latency.Fixed(40 * time.Millisecond))
+    r.Use(injector.Middleware())
+
+    started := time.Now()
+    response, err := client.Get("https://api.example/slow")
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    firstByte := time.Since(started)
+    body, err := ioutil.ReadAll(response.Body)
+    delivery := time.Since(started) - firstByte
+    if err != nil || len(body) != 1000 {
+        t.Fatalf("Unexpected body: %d %v", len(body), err)
+    }
+    if firstByte < 30*time.Millisecond || delivery < 40*time.Millisecond {
+        t.Errorf("Unexpected delays: first byte %v, body %v", firstByte, delivery)
+    }
+
+    // a timeout shorter than the first byte delay
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    request, _ := http.NewRequestWithContext(ctx, "GET", "https://api.example/slow", nil)
+    if _, err := client.Do(request); !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("Expected context.DeadlineExceeded but found %v", err)
+    }
+
+    // a timeout during the body delivery
+    ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+    request, _ = http.NewRequestWithContext(ctx, "GET", "https://api.example/slow", nil)
+    response, err = client.Do(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if _, err := ioutil.ReadAll(response.Body); !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("Expected the body read to fail with context.DeadlineExceeded but found %v", err)
+    }
+
+    // unknown lengths take the body delay before the first read
+    call := &common.HttpCall{Method: "GET"}
+    streamed, _ := injector.Middleware()(func(*common.HttpCall) (*common.HttpReply, error) {
+        return common.NewReply(200).Stream(strings.NewReader("data"), -1), nil
+    })(call)
+    started = time.Now()
+    if data, err := ioutil.ReadAll(streamed.ResponseData); err != nil || string(data) != "data" || time.Since(started) < 40*time.Millisecond {
+        t.Errorf("Unexpected streamed body: %q %v after %v", data, err, time.Since(started))
+    }
+}
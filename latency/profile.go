@@ -0,0 +1,150 @@
+package latency
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "math/rand"
+    "sort"
+    "time"
+)
+
+var (
+    ErrInvalidProfile error = errors.New("Latency: Invalid profile")
+)
+
+// Profile picks a delay; the random source is the Injector's so seeded injectors are repeatable
+type Profile interface {
+    Sample(rnd *rand.Rand) time.Duration
+    // String describes the profile, e.g `normal(100ms, 20ms)`
+    String() string
+}
+
+type profile struct {
+    description string
+    sample      func(rnd *rand.Rand) time.Duration
+}
+
+func (p *profile) Sample(rnd *rand.Rand) time.Duration {
+    if d := p.sample(rnd); d > 0 {
+        return d
+    }
+    return 0
+}
+
+func (p *profile) String() string {
+    return p.description
+}
+
+// Fixed always delays by the duration
+func Fixed(d time.Duration) Profile {
+    return &profile{
+        description: fmt.Sprintf("fixed(%v)", d),
+        sample: func(*rand.Rand) time.Duration {
+            return d
+        },
+    }
+}
+
+// Uniform delays by a duration evenly spread between low and high
+func Uniform(low time.Duration, high time.Duration) Profile {
+    if high < low {
+        low, high = high, low
+    }
+    return &profile{
+        description: fmt.Sprintf("uniform(%v, %v)", low, high),
+        sample: func(rnd *rand.Rand) time.Duration {
+            return low + time.Duration(rnd.Int63n(int64(high-low)+1))
+        },
+    }
+}
+
+// Jitter delays by the base give or take up to the jitter
+func Jitter(base time.Duration, jitter time.Duration) Profile {
+    return Uniform(base-jitter, base+jitter)
+}
+
+// Normal delays by a normally distributed duration; negative samples are treated as 0
+func Normal(mean time.Duration, stddev time.Duration) Profile {
+    return &profile{
+        description: fmt.Sprintf("normal(%v, %v)", mean, stddev),
+        sample: func(rnd *rand.Rand) time.Duration {
+            return mean + time.Duration(rnd.NormFloat64()*float64(stddev))
+        },
+    }
+}
+
+// LogNormal delays by a log-normally distributed duration with the median; sigma, the standard
+// deviation of the logarithm, sets how long the tail is (e.g 0.5 puts p99 at about 3.2x the median)
+func LogNormal(median time.Duration, sigma float64) Profile {
+    return &profile{
+        description: fmt.Sprintf("lognormal(%v, %g)", median, sigma),
+        sample: func(rnd *rand.Rand) time.Duration {
+            return time.Duration(float64(median) * math.Exp(rnd.NormFloat64()*sigma))
+        },
+    }
+}
+
+type percentile struct {
+    rank  float64
+    delay time.Duration
+}
+
+/*
+    Percentiles delays according to a table of percentiles, e.g
+
+        latency.Percentiles(map[float64]time.Duration{50: 20 * time.Millisecond, 99: 250 * time.Millisecond})
+
+    Delays between the given percentiles are interpolated linearly; below the
+    lowest percentile the lowest delay is used and above the highest the
+    highest delay. Percentiles must be in (0, 100] and delays must not
+    decrease as the percentile increases.
+ */
+func Percentiles(table map[float64]time.Duration) (result Profile, err error) {
+    if len(table) == 0 {
+        err = fmt.Errorf("%w: the percentile table is empty", ErrInvalidProfile)
+        return
+    }
+    points := make([]percentile, 0, len(table))
+    for rank, delay := range table {
+        if rank <= 0 || rank > 100 {
+            err = fmt.Errorf("%w: percentile %g is not in (0, 100]", ErrInvalidProfile, rank)
+            return
+        }
+        points = append(points, percentile{rank: rank, delay: delay})
+    }
+    sort.Slice(points, func(i, j int) bool { return points[i].rank < points[j].rank })
+    description := "percentiles("
+    for index, point := range points {
+        if index > 0 {
+            if point.delay < points[index-1].delay {
+                err = fmt.Errorf("%w: p%g (%v) is less than p%g (%v)", ErrInvalidProfile, point.rank, point.delay, points[index-1].rank, points[index-1].delay)
+                return
+            }
+            description += ", "
+        }
+        description += fmt.Sprintf("p%g=%v", point.rank, point.delay)
+    }
+
+    result = &profile{
+        description: description + ")",
+        sample: func(rnd *rand.Rand) time.Duration {
+            return interpolate(points, rnd.Float64()*100)
+        },
+    }
+    return
+}
+
+func interpolate(points []percentile, rank float64) time.Duration {
+    if rank <= points[0].rank {
+        return points[0].delay
+    }
+    for index := 1; index < len(points); index++ {
+        lower, upper := points[index-1], points[index]
+        if rank <= upper.rank {
+            fraction := (rank - lower.rank) / (upper.rank - lower.rank)
+            return lower.delay + time.Duration(fraction*float64(upper.delay-lower.delay))
+        }
+    }
+    return points[len(points)-1].delay
+}
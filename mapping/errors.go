@@ -0,0 +1,44 @@
+package mapping
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+var (
+    ErrInvalidMapping error = errors.New("Mapping: Invalid mapping file")
+)
+
+// ValidationError identifies the problem with a mapping file by file and line
+type ValidationError struct {
+    File string
+    Line int
+    Msg  string
+}
+
+func (ve *ValidationError) Error() string {
+    if len(ve.File) > 0 {
+        return fmt.Sprintf("%s:%d: %s", ve.File, ve.Line, ve.Msg)
+    }
+    return fmt.Sprintf("line %d: %s", ve.Line, ve.Msg)
+}
+
+func (ve *ValidationError) Unwrap() error {
+    return ErrInvalidMapping
+}
+
+// ValidationErrors collects every problem found so they can all be fixed at once
+type ValidationErrors []*ValidationError
+
+func (ves ValidationErrors) Error() string {
+    messages := make([]string, 0, len(ves))
+    for _, ve := range ves {
+        messages = append(messages, ve.Error())
+    }
+    return fmt.Sprintf("%s:\n%s", ErrInvalidMapping, strings.Join(messages, "\n"))
+}
+
+func (ves ValidationErrors) Is(target error) bool {
+    return target == ErrInvalidMapping
+}
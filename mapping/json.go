@@ -0,0 +1,131 @@
+package mapping
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "sort"
+)
+
+/*
+    ParseJSON decodes the document into the same Node tree as ParseYAML so
+    the loader can report line numbers for JSON mapping files too.
+ */
+
+type jsonParser struct {
+    decoder    *json.Decoder
+    lineStarts []int
+}
+
+func ParseJSON(data []byte) (root *Node, err error) {
+    p := &jsonParser{
+        decoder: json.NewDecoder(bytes.NewReader(data)),
+        lineStarts: []int{0},
+    }
+    p.decoder.UseNumber()
+    for index, b := range data {
+        if b == '\n' {
+            p.lineStarts = append(p.lineStarts, index+1)
+        }
+    }
+
+    root, err = p.parseValue()
+    if err != nil {
+        return
+    }
+    if _, extraErr := p.decoder.Token(); !errors.Is(extraErr, io.EOF) {
+        err = &ValidationError{Line: p.line(), Msg: "unexpected content after the document"}
+    }
+    return
+}
+
+// line returns the line the decoder is currently on
+func (p *jsonParser) line() int {
+    offset := int(p.decoder.InputOffset())
+    return sort.Search(len(p.lineStarts), func(i int) bool { return p.lineStarts[i] > offset })
+}
+
+func (p *jsonParser) wrap(err error) error {
+    line := p.line()
+    var syntaxErr *json.SyntaxError
+    if errors.As(err, &syntaxErr) {
+        offset := int(syntaxErr.Offset)
+        line = sort.Search(len(p.lineStarts), func(i int) bool { return p.lineStarts[i] > offset })
+    }
+    if errors.Is(err, io.EOF) {
+        err = io.ErrUnexpectedEOF
+    }
+    return &ValidationError{Line: line, Msg: err.Error()}
+}
+
+func (p *jsonParser) parseValue() (node *Node, err error) {
+    token, tokenErr := p.decoder.Token()
+    if tokenErr != nil {
+        err = p.wrap(tokenErr)
+        return
+    }
+    line := p.line()
+
+    switch value := token.(type) {
+    case json.Delim:
+        switch value {
+        case '{':
+            return p.parseObject(line)
+        case '[':
+            return p.parseArray(line)
+        }
+        err = &ValidationError{Line: line, Msg: fmt.Sprintf("unexpected %s", value)}
+    case bool:
+        node = &Node{Kind: KindBool, Line: line, Value: fmt.Sprintf("%t", value)}
+    case json.Number:
+        node = &Node{Kind: KindNumber, Line: line, Value: value.String()}
+    case string:
+        node = &Node{Kind: KindString, Line: line, Value: value}
+    case nil:
+        node = &Node{Kind: KindNull, Line: line}
+    }
+    return
+}
+
+func (p *jsonParser) parseObject(line int) (node *Node, err error) {
+    node = newMap(line)
+    for p.decoder.More() {
+        token, tokenErr := p.decoder.Token()
+        if tokenErr != nil {
+            err = p.wrap(tokenErr)
+            return
+        }
+        key, _ := token.(string)
+        if _, exists := node.Map[key]; exists {
+            err = &ValidationError{Line: p.line(), Msg: fmt.Sprintf("duplicate key %q", key)}
+            return
+        }
+        var value *Node
+        if value, err = p.parseValue(); err != nil {
+            return
+        }
+        node.set(key, value)
+    }
+    // consume the closing delimiter
+    if _, tokenErr := p.decoder.Token(); tokenErr != nil {
+        err = p.wrap(tokenErr)
+    }
+    return
+}
+
+func (p *jsonParser) parseArray(line int) (node *Node, err error) {
+    node = &Node{Kind: KindSeq, Line: line}
+    for p.decoder.More() {
+        var item *Node
+        if item, err = p.parseValue(); err != nil {
+            return
+        }
+        node.Items = append(node.Items, item)
+    }
+    if _, tokenErr := p.decoder.Token(); tokenErr != nil {
+        err = p.wrap(tokenErr)
+    }
+    return
+}
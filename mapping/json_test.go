@@ -0,0 +1,50 @@
+package mapping_test
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/mapping"
+)
+
+func Test_Mapping_ParseJSON(t *testing.T) {
+    document := "{\n  \"a\": [1, true, null],\n  \"b\": {\n    \"c\": \"d\"\n  }\n}"
+    node, err := mapping.ParseJSON([]byte(document))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    expected := map[string]interface{}{
+        "a": []interface{}{float64(1), true, nil},
+        "b": map[string]interface{}{"c": "d"},
+    }
+    if !reflect.DeepEqual(node.Interface(), expected) {
+        t.Errorf("Unexpected result: %#v", node.Interface())
+    }
+    if line := node.Get("b").Get("c").Line; line != 4 {
+        t.Errorf("Unexpected line for b.c: %d", line)
+    }
+    if keys := node.Keys; !reflect.DeepEqual(keys, []string{"a", "b"}) {
+        t.Errorf("Key order not preserved: %v", keys)
+    }
+}
+
+func Test_Mapping_ParseJSON_Errors(t *testing.T) {
+    scenarios := map[string]int{
+        "{\n  \"a\": 1,\n  \"a\": 2\n}": 3,
+        "{\n  \"a\": [1,\n": 3,
+        "{}\n{}": 2,
+    }
+    for document, line := range scenarios {
+        _, err := mapping.ParseJSON([]byte(document))
+        var ve *mapping.ValidationError
+        if !errors.As(err, &ve) {
+            t.Errorf("%q: unexpected error: %v", document, err)
+            continue
+        }
+        if ve.Line != line {
+            t.Errorf("%q: unexpected line: %d != %d (%v)", document, ve.Line, line, err)
+        }
+    }
+}
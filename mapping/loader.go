@@ -0,0 +1,439 @@
+package mapping
+
+import (
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+/*
+    The loader reads mapping files and registers each mapping as a stub. A
+    file contains a single mapping, a list of mappings, or a document with a
+    `mappings` list:
+
+        mappings:
+          - request:
+              method: GET
+              url: https://api.example/v1/users/{id}    # or `host` and `path`
+              query:
+                verbose: "true"                        # exact value
+              headers:
+                Accept: {matches: "application/.*json"}  # or equals/contains
+              body:
+                json: {"name": "bob"}                  # or equals/contains/matches
+            response:
+              status: 200
+              headers:
+                Content-Type: application/json
+              body: '{"id": 1}'                        # or `json` or `bodyFile`
+
+    `bodyFile` is relative to the directory of the mapping file. Every file is
+    validated, including the path templates and any conflict with services the
+    router already has, before anything is registered so a bad mapping never
+    results in a partially configured router.
+ */
+
+type valueMatch struct {
+    name    string
+    op      string
+    value   string
+    pattern *regexp.Regexp
+}
+
+type bodyMatch struct {
+    op      string
+    value   string
+    pattern *regexp.Regexp
+    json    interface{}
+}
+
+type mappingSpec struct {
+    file string
+    line int
+
+    method  string
+    url     string
+    query   []valueMatch
+    headers []valueMatch
+    body    []bodyMatch
+
+    status          int
+    responseHeaders [][2]string
+    responseBody    []byte
+    responseJSON    interface{}
+    isJSON          bool
+}
+
+var mappingExtensions = map[string]bool{
+    ".yaml": true,
+    ".yml": true,
+    ".json": true,
+}
+
+// LoadDir loads every .yaml, .yml and .json file under the directory (recursively, in name order)
+func LoadDir(stubs *stub.Registry, dir string) (err error) {
+    files := []string{}
+    err = filepath.Walk(
+        dir,
+        func(path string, info os.FileInfo, walkErr error) error {
+            if walkErr != nil {
+                return walkErr
+            }
+            if !info.IsDir() && mappingExtensions[strings.ToLower(filepath.Ext(path))] {
+                files = append(files, path)
+            }
+            return nil
+        },
+    )
+    if err != nil {
+        return
+    }
+    sort.Strings(files)
+    return LoadFiles(stubs, files...)
+}
+
+func LoadFiles(stubs *stub.Registry, files ...string) (err error) {
+    var problems ValidationErrors
+    specs := []*mappingSpec{}
+    for _, file := range files {
+        fileSpecs, fileProblems := parseFile(file)
+        specs = append(specs, fileSpecs...)
+        problems = append(problems, fileProblems...)
+    }
+    for _, spec := range specs {
+        if checkErr := stubs.Check(spec.url); checkErr != nil {
+            problems = append(problems, &ValidationError{File: spec.file, Line: spec.line, Msg: checkErr.Error()})
+        }
+    }
+    if len(problems) > 0 {
+        err = problems
+        return
+    }
+
+    for _, spec := range specs {
+        log.Printf("Registering mapping %s:%d: %s %s", spec.file, spec.line, spec.method, spec.url)
+        if registerErr := spec.register(stubs); registerErr != nil {
+            err = fmt.Errorf("%s:%d: %w", spec.file, spec.line, registerErr)
+            return
+        }
+    }
+    return
+}
+
+func parseFile(file string) (specs []*mappingSpec, problems ValidationErrors) {
+    data, readErr := ioutil.ReadFile(file)
+    if readErr != nil {
+        problems = append(problems, &ValidationError{File: file, Msg: readErr.Error()})
+        return
+    }
+
+    var root *Node
+    var parseErr error
+    if strings.ToLower(filepath.Ext(file)) == ".json" {
+        root, parseErr = ParseJSON(data)
+    } else {
+        root, parseErr = ParseYAML(data)
+    }
+    if parseErr != nil {
+        if ve, ok := parseErr.(*ValidationError); ok {
+            ve.File = file
+            problems = append(problems, ve)
+        } else {
+            problems = append(problems, &ValidationError{File: file, Msg: parseErr.Error()})
+        }
+        return
+    }
+
+    v := &validator{file: file, dir: filepath.Dir(file)}
+    entries := []*Node{}
+    switch {
+    case root.Kind == KindSeq:
+        entries = root.Items
+    case root.Get("mappings") != nil:
+        v.keys(root, "mappings")
+        if mappings := root.Get("mappings"); v.kind(mappings, "mappings", KindSeq) {
+            entries = mappings.Items
+        }
+    case root.Kind == KindMap:
+        entries = []*Node{root}
+    default:
+        v.fail(root, "expected a mapping, a list of mappings or a `mappings` list but found a %s", root.Kind)
+    }
+
+    for _, entry := range entries {
+        if spec := v.mapping(entry); spec != nil {
+            specs = append(specs, spec)
+        }
+    }
+    problems = v.problems
+    return
+}
+
+type validator struct {
+    file     string
+    dir      string
+    problems ValidationErrors
+}
+
+func (v *validator) fail(n *Node, format string, args ...interface{}) {
+    v.problems = append(v.problems, &ValidationError{File: v.file, Line: n.Line, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) kind(n *Node, field string, kind Kind) bool {
+    if n.Kind != kind {
+        v.fail(n, "%s must be a %s but found a %s", field, kind, n.Kind)
+        return false
+    }
+    return true
+}
+
+// keys reports any keys that aren't in the allowed set
+func (v *validator) keys(n *Node, allowed ...string) {
+    for _, key := range n.Keys {
+        known := false
+        for _, name := range allowed {
+            if key == name {
+                known = true
+                break
+            }
+        }
+        if !known {
+            v.fail(n.Map[key], "unknown field %q (expected one of: %s)", key, strings.Join(allowed, ", "))
+        }
+    }
+}
+
+func (v *validator) text(n *Node, field string) (value string) {
+    value, err := n.Text()
+    if err != nil {
+        v.fail(n, "%s: %v", field, err)
+    }
+    return
+}
+
+func (v *validator) mapping(entry *Node) (spec *mappingSpec) {
+    if !v.kind(entry, "mapping", KindMap) {
+        return
+    }
+    before := len(v.problems)
+    v.keys(entry, "request", "response")
+
+    spec = &mappingSpec{file: v.file, line: entry.Line, status: 200}
+    request := entry.Get("request")
+    if request == nil {
+        v.fail(entry, "missing required field \"request\"")
+    } else if v.kind(request, "request", KindMap) {
+        v.request(request, spec)
+    }
+    if response := entry.Get("response"); response != nil && v.kind(response, "response", KindMap) {
+        v.response(response, spec)
+    }
+
+    if len(v.problems) > before {
+        spec = nil
+    }
+    return
+}
+
+func (v *validator) request(request *Node, spec *mappingSpec) {
+    v.keys(request, "method", "url", "host", "path", "query", "headers", "body")
+
+    if method := request.Get("method"); method == nil {
+        v.fail(request, "missing required field \"method\"")
+    } else {
+        spec.method = strings.ToUpper(v.text(method, "method"))
+    }
+
+    rawUrl, host, path := request.Get("url"), request.Get("host"), request.Get("path")
+    switch {
+    case rawUrl != nil && (host != nil || path != nil):
+        v.fail(rawUrl, "use either \"url\" or \"host\" and \"path\", not both")
+    case rawUrl != nil:
+        spec.url = v.text(rawUrl, "url")
+    case host != nil:
+        spec.url = strings.TrimRight(v.text(host, "host"), "/")
+        if path != nil {
+            spec.url += v.text(path, "path")
+        }
+    default:
+        v.fail(request, "missing required field \"url\" (or \"host\")")
+    }
+    if len(spec.url) > 0 && !strings.Contains(spec.url, "://") {
+        location := rawUrl
+        if location == nil {
+            location = host
+        }
+        v.fail(location, "url %q must be absolute, e.g https://api.example/path", spec.url)
+    }
+
+    if query := request.Get("query"); query != nil {
+        spec.query = v.valueMatches(query, "query")
+    }
+    if headers := request.Get("headers"); headers != nil {
+        spec.headers = v.valueMatches(headers, "headers")
+    }
+    if body := request.Get("body"); body != nil {
+        spec.body = v.bodyMatches(body)
+    }
+}
+
+func (v *validator) valueMatches(n *Node, field string) (matches []valueMatch) {
+    if !v.kind(n, field, KindMap) {
+        return
+    }
+    for _, name := range n.Keys {
+        value := n.Map[name]
+        if value.Kind != KindMap {
+            matches = append(matches, valueMatch{name: name, op: "equals", value: v.text(value, field+"."+name)})
+            continue
+        }
+        v.keys(value, "equals", "contains", "matches")
+        for _, op := range value.Keys {
+            match := valueMatch{name: name, op: op, value: v.text(value.Map[op], field+"."+name+"."+op)}
+            if op == "matches" {
+                match.pattern = v.regex(value.Map[op], match.value)
+            }
+            matches = append(matches, match)
+        }
+    }
+    return
+}
+
+func (v *validator) bodyMatches(n *Node) (matches []bodyMatch) {
+    if n.Kind != KindMap {
+        return []bodyMatch{{op: "equals", value: v.text(n, "body")}}
+    }
+    v.keys(n, "equals", "contains", "matches", "json")
+    for _, op := range n.Keys {
+        value := n.Map[op]
+        match := bodyMatch{op: op}
+        switch op {
+        case "json":
+            match.json = value.Interface()
+        case "matches":
+            match.value = v.text(value, "body.matches")
+            match.pattern = v.regex(value, match.value)
+        default:
+            match.value = v.text(value, "body."+op)
+        }
+        matches = append(matches, match)
+    }
+    return
+}
+
+func (v *validator) regex(n *Node, value string) *regexp.Regexp {
+    pattern, err := regexp.Compile(value)
+    if err != nil {
+        v.fail(n, "invalid regular expression %q: %v", value, err)
+    }
+    return pattern
+}
+
+func (v *validator) response(response *Node, spec *mappingSpec) {
+    v.keys(response, "status", "headers", "body", "json", "bodyFile")
+
+    if status := response.Get("status"); status != nil {
+        value, err := status.Int()
+        if err != nil || value < 100 || value > 999 {
+            v.fail(status, "status must be a number between 100 and 999 but found %q", status.Value)
+        }
+        spec.status = value
+    }
+
+    if headers := response.Get("headers"); headers != nil && v.kind(headers, "headers", KindMap) {
+        for _, name := range headers.Keys {
+            value := headers.Map[name]
+            if value.Kind == KindSeq {
+                for _, item := range value.Items {
+                    spec.responseHeaders = append(spec.responseHeaders, [2]string{name, v.text(item, "headers."+name)})
+                }
+                continue
+            }
+            spec.responseHeaders = append(spec.responseHeaders, [2]string{name, v.text(value, "headers."+name)})
+        }
+    }
+
+    bodies := []*Node{}
+    for _, field := range []string{"body", "json", "bodyFile"} {
+        if value := response.Get(field); value != nil {
+            bodies = append(bodies, value)
+        }
+    }
+    if len(bodies) > 1 {
+        v.fail(bodies[1], "only one of \"body\", \"json\" or \"bodyFile\" may be used")
+        return
+    }
+
+    if body := response.Get("body"); body != nil {
+        spec.responseBody = []byte(v.text(body, "body"))
+    }
+    if value := response.Get("json"); value != nil {
+        spec.responseJSON = value.Interface()
+        spec.isJSON = true
+    }
+    if bodyFile := response.Get("bodyFile"); bodyFile != nil {
+        path := v.text(bodyFile, "bodyFile")
+        if !filepath.IsAbs(path) {
+            path = filepath.Join(v.dir, path)
+        }
+        data, err := ioutil.ReadFile(path)
+        if err != nil {
+            v.fail(bodyFile, "unable to read bodyFile: %v", err)
+        }
+        spec.responseBody = data
+    }
+}
+
+func (spec *mappingSpec) register(stubs *stub.Registry) error {
+    s := stubs.On(spec.method, spec.url)
+    for _, match := range spec.query {
+        switch match.op {
+        case "equals":
+            s.WithQuery(match.name, match.value)
+        case "contains":
+            s.WithQueryMatching(match.name, regexp.MustCompile(regexp.QuoteMeta(match.value)))
+        case "matches":
+            s.WithQueryMatching(match.name, match.pattern)
+        }
+    }
+    for _, match := range spec.headers {
+        switch match.op {
+        case "equals":
+            s.WithHeader(match.name, match.value)
+        case "contains":
+            s.WithHeaderMatching(match.name, regexp.MustCompile(regexp.QuoteMeta(match.value)))
+        case "matches":
+            s.WithHeaderMatching(match.name, match.pattern)
+        }
+    }
+    for _, match := range spec.body {
+        switch match.op {
+        case "equals":
+            s.WithBody(match.value)
+        case "contains":
+            s.WithBodyContaining(match.value)
+        case "matches":
+            s.WithBodyMatching(match.pattern)
+        case "json":
+            s.WithJSONBody(match.json)
+        }
+    }
+
+    s.Reply(spec.status)
+    for _, header := range spec.responseHeaders {
+        s.Header(header[0], header[1])
+    }
+    if spec.isJSON {
+        s.JSON(spec.responseJSON)
+    } else {
+        s.Bytes(spec.responseBody)
+    }
+    return s.Err()
+}
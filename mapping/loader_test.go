@@ -0,0 +1,231 @@
+package mapping_test
+
+import (
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/mapping"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+    dir := t.TempDir()
+    for name, content := range files {
+        path := filepath.Join(dir, name)
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatalf("Unable to create directory: %v", err)
+        }
+        if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatalf("Unable to write %s: %v", name, err)
+        }
+    }
+    return dir
+}
+
+func Test_Mapping_LoadDir(t *testing.T) {
+    dir := writeFiles(
+        t,
+        map[string]string{
+            "users.yaml": `
+mappings:
+  - request:
+      method: get
+      url: https://api.example/v1/users/{id}
+      headers:
+        Accept: {contains: json}
+    response:
+      status: 200
+      json: {id: 1, name: bob}
+  - request:
+      method: POST
+      host: https://api.example
+      path: /v1/users
+      body:
+        json: {name: bob}
+    response:
+      status: 201
+      headers:
+        Location: /v1/users/1
+`,
+            "nested/files.json": `{
+  "request": {"method": "GET", "url": "https://files.example/logo.txt", "query": {"size": {"matches": "^[0-9]+$"}}},
+  "response": {"bodyFile": "logo.txt"}
+}`,
+            "nested/logo.txt": "LOGO",
+            "README.md": "ignored",
+        },
+    )
+
+    r := router.New()
+    if err := mapping.LoadDir(stub.New(r), dir); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    client := &http.Client{Transport: r}
+
+    type TestScenario struct {
+        name    string
+        method  string
+        target  string
+        headers map[string]string
+        body    string
+        status  int
+        reply   string
+    }
+
+    var TestScenarios = []TestScenario{
+        {
+            name: "header predicate and json reply",
+            method: "GET",
+            target: "https://api.example/v1/users/1",
+            headers: map[string]string{"Accept": "application/json"},
+            status: 200,
+            reply: `{"id":1,"name":"bob"}`,
+        },
+        {
+            name: "header predicate mismatch",
+            method: "GET",
+            target: "https://api.example/v1/users/1",
+            headers: map[string]string{"Accept": "text/html"},
+            status: 597,
+        },
+        {
+            name: "json body predicate",
+            method: "POST",
+            target: "https://api.example/v1/users",
+            body: `{ "name" : "bob" }`,
+            status: 201,
+        },
+        {
+            name: "body file and query regex",
+            method: "GET",
+            target: "https://files.example/logo.txt?size=10",
+            status: 200,
+            reply: "LOGO",
+        },
+    }
+
+    for _, scenario := range TestScenarios {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                request, _ := http.NewRequest(scenario.method, scenario.target, strings.NewReader(scenario.body))
+                for name, value := range scenario.headers {
+                    request.Header.Set(name, value)
+                }
+                response, err := client.Do(request)
+                if err != nil {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+                body, _ := ioutil.ReadAll(response.Body)
+                if response.StatusCode != scenario.status {
+                    t.Errorf("Unexpected status: %d != %d (%s)", response.StatusCode, scenario.status, body)
+                }
+                if len(scenario.reply) > 0 && string(body) != scenario.reply {
+                    t.Errorf("Unexpected body: %s != %s", body, scenario.reply)
+                }
+            },
+        )
+    }
+}
+
+func Test_Mapping_LoadDir_Validation(t *testing.T) {
+    dir := writeFiles(
+        t,
+        map[string]string{
+            "bad.yaml": `- request:
+    method: GET
+    url: /relative
+    bogus: 1
+  response:
+    status: abc
+- request:
+    url: https://api.example/
+  response:
+    body: x
+    json: {}
+`,
+            "broken.json": "{\n  \"request\": \n}",
+        },
+    )
+
+    r := router.New()
+    err := mapping.LoadDir(stub.New(r), dir)
+    if !errors.Is(err, mapping.ErrInvalidMapping) {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    var problems mapping.ValidationErrors
+    if !errors.As(err, &problems) {
+        t.Fatalf("Error is not a list of problems: %v", err)
+    }
+
+    expected := []string{
+        "bad.yaml:3: url \"/relative\" must be absolute",
+        "bad.yaml:4: unknown field \"bogus\"",
+        "bad.yaml:6: status must be a number",
+        "bad.yaml:8: missing required field \"method\"",
+        "bad.yaml:11: only one of",
+        "broken.json:3:",
+    }
+    message := err.Error()
+    for _, fragment := range expected {
+        if !strings.Contains(message, fragment) {
+            t.Errorf("Error is missing %q:\n%s", fragment, message)
+        }
+    }
+    if len(r.RequestHandlers) != 0 {
+        t.Errorf("Services were registered despite validation errors: %v", r.RequestHandlers)
+    }
+}
+
+func Test_Mapping_LoadDir_RegistrationChecks(t *testing.T) {
+    dir := writeFiles(
+        t,
+        map[string]string{
+            "a.yaml": `- request:
+    method: GET
+    url: https://api.example/v1/users
+- request:
+    method: GET
+    url: https://api.example/v1/users/{id
+`,
+            "b.yaml": `request:
+  method: GET
+  url: https://taken.example/
+`,
+        },
+    )
+
+    r := router.New()
+    taken := &service.ServiceHandler{
+        Matcher: &common.BasicServerURI{
+            Protocol: "https",
+            Host: "taken.example",
+        },
+    }
+    if err := r.RegisterService("https://taken.example", taken); err != nil {
+        t.Fatalf("Unexpected error registering service: %v", err)
+    }
+
+    err := mapping.LoadDir(stub.New(r), dir)
+    if !errors.Is(err, mapping.ErrInvalidMapping) {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    message := err.Error()
+    for _, fragment := range []string{"a.yaml:4:", "b.yaml:1:", stub.ErrServiceConflict.Error()} {
+        if !strings.Contains(message, fragment) {
+            t.Errorf("Error is missing %q:\n%s", fragment, message)
+        }
+    }
+    if len(r.RequestHandlers) != 1 {
+        t.Errorf("Services were registered despite validation errors: %v", r.RequestHandlers)
+    }
+}
@@ -0,0 +1,130 @@
+package mapping
+
+import (
+    "fmt"
+    "strconv"
+)
+
+/*
+    Node is the document tree produced by both the YAML and JSON parsers. Every
+    node remembers the line it started on so that validation errors can point
+    the author of the mapping file at the offending entry.
+ */
+
+type Kind int
+
+const (
+    KindNull Kind = iota
+    KindBool
+    KindNumber
+    KindString
+    KindMap
+    KindSeq
+)
+
+func (k Kind) String() string {
+    switch k {
+    case KindNull:
+        return "null"
+    case KindBool:
+        return "boolean"
+    case KindNumber:
+        return "number"
+    case KindString:
+        return "string"
+    case KindMap:
+        return "mapping"
+    case KindSeq:
+        return "list"
+    }
+    return "unknown"
+}
+
+type Node struct {
+    Kind Kind
+    Line int
+    // Value is the text of scalar nodes
+    Value string
+    // Keys retains the order the mapping keys were defined in
+    Keys  []string
+    Map   map[string]*Node
+    Items []*Node
+}
+
+func newMap(line int) *Node {
+    return &Node{
+        Kind: KindMap,
+        Line: line,
+        Map: make(map[string]*Node),
+    }
+}
+
+func (n *Node) set(key string, value *Node) {
+    if _, ok := n.Map[key]; !ok {
+        n.Keys = append(n.Keys, key)
+    }
+    n.Map[key] = value
+}
+
+// Get returns the value of the key on a mapping node; nil if not present
+func (n *Node) Get(key string) *Node {
+    if n == nil || n.Kind != KindMap {
+        return nil
+    }
+    return n.Map[key]
+}
+
+// Interface converts the node into the same values encoding/json would produce
+func (n *Node) Interface() interface{} {
+    if n == nil {
+        return nil
+    }
+    switch n.Kind {
+    case KindBool:
+        return n.Value == "true"
+    case KindNumber:
+        if f, err := strconv.ParseFloat(n.Value, 64); err == nil {
+            return f
+        }
+        return n.Value
+    case KindString:
+        return n.Value
+    case KindMap:
+        result := make(map[string]interface{}, len(n.Map))
+        for key, value := range n.Map {
+            result[key] = value.Interface()
+        }
+        return result
+    case KindSeq:
+        result := make([]interface{}, 0, len(n.Items))
+        for _, item := range n.Items {
+            result = append(result, item.Interface())
+        }
+        return result
+    }
+    return nil
+}
+
+// Int converts a numeric scalar
+func (n *Node) Int() (value int, err error) {
+    if n.Kind != KindNumber && n.Kind != KindString {
+        err = fmt.Errorf("expected a number but found a %s", n.Kind)
+        return
+    }
+    value, err = strconv.Atoi(n.Value)
+    if err != nil {
+        err = fmt.Errorf("expected a whole number but found %q", n.Value)
+    }
+    return
+}
+
+// Text returns the text of any scalar
+func (n *Node) Text() (value string, err error) {
+    switch n.Kind {
+    case KindString, KindNumber, KindBool:
+        value = n.Value
+    default:
+        err = fmt.Errorf("expected a string but found a %s", n.Kind)
+    }
+    return
+}
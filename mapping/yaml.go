@@ -0,0 +1,565 @@
+package mapping
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+/*
+    ParseYAML implements the subset of YAML needed for mapping files without
+    pulling in a third party dependency:
+
+        - block mappings and block sequences (including `- key: value` items)
+        - plain, 'single' and "double" quoted scalars
+        - literal (|) and folded (>) block scalars with chomping indicators
+        - single line flow collections, e.g `[a, b]` and `{a: 1, b: [2]}`
+        - comments and a leading `---` document marker
+
+    Anchors, aliases, tags and multiple documents are not supported and are
+    reported as errors rather than being misinterpreted.
+ */
+
+type yamlLine struct {
+    number  int
+    indent  int
+    content string
+}
+
+type yamlParser struct {
+    raw   []string
+    lines []yamlLine
+    pos   int
+}
+
+var yamlNumberRegex = regexp.MustCompile(`^[-+]?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][-+]?[0-9]+)?$`)
+
+func ParseYAML(data []byte) (root *Node, err error) {
+    p := &yamlParser{
+        raw: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"),
+    }
+    first := firstContentLine(p.raw)
+    for index, raw := range p.raw {
+        content := stripComment(raw)
+        trimmed := strings.TrimLeft(content, " ")
+        if len(strings.TrimSpace(trimmed)) == 0 {
+            continue
+        }
+        if trimmed[0] == '\t' {
+            err = &ValidationError{Line: index + 1, Msg: "tabs cannot be used for indentation"}
+            return
+        }
+        if index == first && strings.TrimSpace(trimmed) == "---" {
+            continue
+        }
+        p.lines = append(p.lines, yamlLine{
+            number: index + 1,
+            indent: len(content) - len(trimmed),
+            content: strings.TrimRight(trimmed, " "),
+        })
+    }
+
+    if len(p.lines) == 0 {
+        root = &Node{Kind: KindNull, Line: 1}
+        return
+    }
+
+    root, err = p.parseBlock(p.lines[0].indent)
+    if err == nil && p.pos < len(p.lines) {
+        line := p.lines[p.pos]
+        err = &ValidationError{Line: line.number, Msg: fmt.Sprintf("unexpected content %q", line.content)}
+    }
+    return
+}
+
+func firstContentLine(raw []string) int {
+    for index, line := range raw {
+        if len(strings.TrimSpace(stripComment(line))) > 0 {
+            return index
+        }
+    }
+    return -1
+}
+
+// stripComment removes a trailing comment that isn't inside a quoted string.
+// A quote only starts a quoted scalar where a scalar can start, i.e at the start of
+// the content or after `: `, `- `, `[`, `{` or `,`; elsewhere it's part of a plain
+// scalar such as `it's here`.
+func stripComment(line string) string {
+    inSingle, inDouble := false, false
+    for index := 0; index < len(line); index++ {
+        switch {
+        case inSingle:
+            if line[index] == '\'' {
+                if index+1 < len(line) && line[index+1] == '\'' {
+                    // '' is an escaped quote
+                    index++
+                } else {
+                    inSingle = false
+                }
+            }
+        case inDouble:
+            if line[index] == '\\' {
+                // skip the escaped character, which may itself be a backslash
+                index++
+            } else if line[index] == '"' {
+                inDouble = false
+            }
+        case line[index] == '\'' || line[index] == '"':
+            if opensScalar(line[:index]) {
+                inSingle = line[index] == '\''
+                inDouble = line[index] == '"'
+            }
+        case line[index] == '#':
+            if index == 0 || line[index-1] == ' ' {
+                return line[:index]
+            }
+        }
+    }
+    return line
+}
+
+// opensScalar reports whether a scalar can start straight after prefix
+func opensScalar(prefix string) bool {
+    trimmed := strings.TrimRight(prefix, " ")
+    if len(trimmed) == 0 {
+        return true
+    }
+    switch trimmed[len(trimmed)-1] {
+    case '[', '{', ',':
+        return true
+    case ':', '-':
+        // the indicator must be followed by a space, e.g `key: 'x'` not `a:'x'`
+        return len(trimmed) < len(prefix)
+    }
+    return false
+}
+
+// isComplexKey reports whether content uses an explicit `? key` entry
+func isComplexKey(content string) bool {
+    return content == "?" || strings.HasPrefix(content, "? ")
+}
+
+func isSequenceItem(content string) bool {
+    return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+func (p *yamlParser) parseBlock(indent int) (node *Node, err error) {
+    line := p.lines[p.pos]
+    if isComplexKey(line.content) {
+        err = &ValidationError{Line: line.number, Msg: "complex keys are not supported"}
+        return
+    }
+    if isSequenceItem(line.content) {
+        return p.parseSequence(indent)
+    }
+    if _, _, isKey := splitKey(line.content); isKey {
+        return p.parseMapping(indent)
+    }
+    // a lone scalar
+    p.pos++
+    return parseInline(line.content, line.number)
+}
+
+func (p *yamlParser) parseMapping(indent int) (node *Node, err error) {
+    node = newMap(p.lines[p.pos].number)
+    for p.pos < len(p.lines) {
+        line := p.lines[p.pos]
+        if line.indent < indent {
+            break
+        }
+        if line.indent > indent {
+            err = &ValidationError{Line: line.number, Msg: "unexpected indentation"}
+            return
+        }
+        if isSequenceItem(line.content) {
+            break
+        }
+        if isComplexKey(line.content) {
+            err = &ValidationError{Line: line.number, Msg: "complex keys are not supported"}
+            return
+        }
+
+        key, rest, isKey := splitKey(line.content)
+        if !isKey {
+            err = &ValidationError{Line: line.number, Msg: fmt.Sprintf("expected `key: value` but found %q", line.content)}
+            return
+        }
+        if _, exists := node.Map[key]; exists {
+            err = &ValidationError{Line: line.number, Msg: fmt.Sprintf("duplicate key %q", key)}
+            return
+        }
+        p.pos++
+
+        var value *Node
+        value, err = p.parseValue(indent, rest, line.number)
+        if err != nil {
+            return
+        }
+        node.set(key, value)
+    }
+    return
+}
+
+func (p *yamlParser) parseSequence(indent int) (node *Node, err error) {
+    node = &Node{Kind: KindSeq, Line: p.lines[p.pos].number}
+    for p.pos < len(p.lines) {
+        line := p.lines[p.pos]
+        if line.indent != indent || !isSequenceItem(line.content) {
+            if line.indent > indent {
+                err = &ValidationError{Line: line.number, Msg: "unexpected indentation"}
+            }
+            break
+        }
+
+        rest := strings.TrimLeft(strings.TrimPrefix(line.content, "-"), " ")
+        var item *Node
+        if len(rest) == 0 {
+            p.pos++
+            item, err = p.parseNested(indent, line.number)
+        } else if _, _, isKey := splitKey(rest); isKey || isSequenceItem(rest) {
+            // compact nested collection, e.g `- key: value`; re-parse the remainder
+            // of the line as though it started on its own at the deeper indentation
+            offset := len(line.content) - len(rest)
+            p.lines[p.pos] = yamlLine{number: line.number, indent: indent + offset, content: rest}
+            item, err = p.parseBlock(indent + offset)
+        } else {
+            p.pos++
+            item, err = p.parseValue(indent, rest, line.number)
+        }
+        if err != nil {
+            return
+        }
+        node.Items = append(node.Items, item)
+    }
+    return
+}
+
+// parseNested parses the block that follows a `key:` or `-` with nothing after it
+func (p *yamlParser) parseNested(indent int, lineNumber int) (node *Node, err error) {
+    if p.pos < len(p.lines) {
+        next := p.lines[p.pos]
+        if next.indent > indent {
+            return p.parseBlock(next.indent)
+        }
+        // YAML permits a sequence at the same indentation as its parent key
+        if next.indent == indent && isSequenceItem(next.content) {
+            return p.parseSequence(indent)
+        }
+    }
+    node = &Node{Kind: KindNull, Line: lineNumber}
+    return
+}
+
+func (p *yamlParser) parseValue(indent int, rest string, lineNumber int) (node *Node, err error) {
+    if len(rest) == 0 {
+        return p.parseNested(indent, lineNumber)
+    }
+    if rest[0] == '|' || rest[0] == '>' {
+        return p.parseBlockScalar(indent, rest, lineNumber)
+    }
+    if rest[0] == '&' || rest[0] == '*' || rest[0] == '!' {
+        err = &ValidationError{Line: lineNumber, Msg: "anchors, aliases and tags are not supported"}
+        return
+    }
+    if isComplexKey(rest) {
+        err = &ValidationError{Line: lineNumber, Msg: "complex keys are not supported"}
+        return
+    }
+    return parseInline(rest, lineNumber)
+}
+
+func (p *yamlParser) parseBlockScalar(indent int, header string, lineNumber int) (node *Node, err error) {
+    style := header[0]
+    chomp := strings.TrimSpace(header[1:])
+    if chomp != "" && chomp != "-" && chomp != "+" {
+        err = &ValidationError{Line: lineNumber, Msg: fmt.Sprintf("unsupported block scalar header %q", header)}
+        return
+    }
+
+    // block scalars are taken from the raw text as comments and blank lines are content
+    collected := []string{}
+    blockIndent := -1
+    rawIndex := lineNumber
+    for ; rawIndex < len(p.raw); rawIndex++ {
+        raw := p.raw[rawIndex]
+        trimmed := strings.TrimLeft(raw, " ")
+        if len(strings.TrimSpace(raw)) == 0 {
+            collected = append(collected, "")
+            continue
+        }
+        lineIndent := len(raw) - len(trimmed)
+        if lineIndent <= indent {
+            break
+        }
+        if blockIndent < 0 {
+            blockIndent = lineIndent
+        }
+        if lineIndent < blockIndent {
+            break
+        }
+        collected = append(collected, raw[blockIndent:])
+    }
+
+    // skip the parsed lines that belonged to the block
+    for p.pos < len(p.lines) && p.lines[p.pos].number <= rawIndex {
+        p.pos++
+    }
+
+    // trailing blank lines are subject to chomping
+    trailing := 0
+    for len(collected) > 0 && collected[len(collected)-1] == "" {
+        collected = collected[:len(collected)-1]
+        trailing++
+    }
+
+    var text string
+    if style == '|' {
+        text = strings.Join(collected, "\n")
+    } else {
+        text = foldLines(collected)
+    }
+
+    switch chomp {
+    case "-":
+    case "+":
+        text += strings.Repeat("\n", trailing+1)
+    default:
+        if len(collected) > 0 {
+            text += "\n"
+        }
+    }
+    node = &Node{Kind: KindString, Line: lineNumber, Value: text}
+    return
+}
+
+func foldLines(lines []string) string {
+    var builder strings.Builder
+    for index, line := range lines {
+        if index > 0 {
+            if line == "" || lines[index-1] == "" {
+                builder.WriteString("\n")
+            } else {
+                builder.WriteString(" ")
+            }
+        }
+        builder.WriteString(line)
+    }
+    return builder.String()
+}
+
+// splitKey splits `key: value` outside of any quotes or flow collections
+func splitKey(content string) (key string, rest string, ok bool) {
+    if len(content) == 0 || content[0] == '[' || content[0] == '{' {
+        return
+    }
+
+    if content[0] == '"' || content[0] == '\'' {
+        end := closingQuote(content)
+        if end < 0 || end+1 >= len(content) || content[end+1] != ':' {
+            return
+        }
+        if end+2 < len(content) && content[end+2] != ' ' {
+            return
+        }
+        unquoted, err := unquote(content[:end+1])
+        if err != nil {
+            return
+        }
+        return unquoted, strings.TrimSpace(content[end+2:]), true
+    }
+
+    for index := 0; index < len(content); index++ {
+        if content[index] != ':' {
+            continue
+        }
+        if index+1 == len(content) || content[index+1] == ' ' {
+            key = strings.TrimSpace(content[:index])
+            rest = strings.TrimSpace(content[index+1:])
+            ok = len(key) > 0
+            return
+        }
+    }
+    return
+}
+
+func closingQuote(content string) int {
+    quote := content[0]
+    for index := 1; index < len(content); index++ {
+        switch {
+        case quote == '"' && content[index] == '\\':
+            index++
+        case content[index] == quote:
+            if quote == '\'' && index+1 < len(content) && content[index+1] == '\'' {
+                index++
+                continue
+            }
+            return index
+        }
+    }
+    return -1
+}
+
+func unquote(value string) (string, error) {
+    if value[0] == '\'' {
+        return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+    }
+    return strconv.Unquote(value)
+}
+
+// parseInline parses a single line value: a scalar or a flow collection
+func parseInline(content string, lineNumber int) (node *Node, err error) {
+    fp := &flowParser{text: content, line: lineNumber}
+    node, err = fp.parse(false)
+    if err != nil {
+        return
+    }
+    fp.skipSpaces()
+    if fp.pos < len(fp.text) {
+        err = &ValidationError{Line: lineNumber, Msg: fmt.Sprintf("unexpected content %q", fp.text[fp.pos:])}
+    }
+    return
+}
+
+type flowParser struct {
+    text string
+    pos  int
+    line int
+}
+
+func (fp *flowParser) skipSpaces() {
+    for fp.pos < len(fp.text) && fp.text[fp.pos] == ' ' {
+        fp.pos++
+    }
+}
+
+func (fp *flowParser) parse(inFlow bool) (node *Node, err error) {
+    fp.skipSpaces()
+    if fp.pos >= len(fp.text) {
+        node = &Node{Kind: KindNull, Line: fp.line}
+        return
+    }
+
+    switch fp.text[fp.pos] {
+    case '[':
+        return fp.parseSeq()
+    case '{':
+        return fp.parseMap()
+    case '"', '\'':
+        end := closingQuote(fp.text[fp.pos:])
+        if end < 0 {
+            err = &ValidationError{Line: fp.line, Msg: "unterminated quoted string"}
+            return
+        }
+        var value string
+        value, err = unquote(fp.text[fp.pos : fp.pos+end+1])
+        if err != nil {
+            err = &ValidationError{Line: fp.line, Msg: fmt.Sprintf("invalid quoted string: %v", err)}
+            return
+        }
+        fp.pos += end + 1
+        node = &Node{Kind: KindString, Line: fp.line, Value: value}
+        return
+    }
+
+    start := fp.pos
+    for fp.pos < len(fp.text) {
+        c := fp.text[fp.pos]
+        if inFlow && (c == ',' || c == ']' || c == '}') {
+            break
+        }
+        if inFlow && c == ':' && (fp.pos+1 == len(fp.text) || fp.text[fp.pos+1] == ' ') {
+            break
+        }
+        fp.pos++
+    }
+    node = plainScalar(strings.TrimSpace(fp.text[start:fp.pos]), fp.line)
+    return
+}
+
+func (fp *flowParser) parseSeq() (node *Node, err error) {
+    node = &Node{Kind: KindSeq, Line: fp.line}
+    fp.pos++
+    for {
+        fp.skipSpaces()
+        if fp.pos >= len(fp.text) {
+            err = &ValidationError{Line: fp.line, Msg: "unterminated flow sequence"}
+            return
+        }
+        if fp.text[fp.pos] == ']' {
+            fp.pos++
+            return
+        }
+        var item *Node
+        if item, err = fp.parse(true); err != nil {
+            return
+        }
+        node.Items = append(node.Items, item)
+        if err = fp.separator(']'); err != nil {
+            return
+        }
+    }
+}
+
+func (fp *flowParser) parseMap() (node *Node, err error) {
+    node = newMap(fp.line)
+    fp.pos++
+    for {
+        fp.skipSpaces()
+        if fp.pos >= len(fp.text) {
+            err = &ValidationError{Line: fp.line, Msg: "unterminated flow mapping"}
+            return
+        }
+        if fp.text[fp.pos] == '}' {
+            fp.pos++
+            return
+        }
+        var key *Node
+        if key, err = fp.parse(true); err != nil {
+            return
+        }
+        fp.skipSpaces()
+        if fp.pos >= len(fp.text) || fp.text[fp.pos] != ':' {
+            err = &ValidationError{Line: fp.line, Msg: "expected : in flow mapping"}
+            return
+        }
+        fp.pos++
+        var value *Node
+        if value, err = fp.parse(true); err != nil {
+            return
+        }
+        node.set(key.Value, value)
+        if err = fp.separator('}'); err != nil {
+            return
+        }
+    }
+}
+
+func (fp *flowParser) separator(closing byte) (err error) {
+    fp.skipSpaces()
+    if fp.pos < len(fp.text) {
+        switch fp.text[fp.pos] {
+        case ',':
+            fp.pos++
+            return
+        case closing:
+            return
+        }
+    }
+    err = &ValidationError{Line: fp.line, Msg: fmt.Sprintf("expected , or %c", closing)}
+    return
+}
+
+func plainScalar(value string, lineNumber int) *Node {
+    switch value {
+    case "", "~", "null", "Null", "NULL":
+        return &Node{Kind: KindNull, Line: lineNumber}
+    case "true", "True", "TRUE", "false", "False", "FALSE":
+        return &Node{Kind: KindBool, Line: lineNumber, Value: strings.ToLower(value)}
+    }
+    if yamlNumberRegex.MatchString(value) {
+        return &Node{Kind: KindNumber, Line: lineNumber, Value: value}
+    }
+    return &Node{Kind: KindString, Line: lineNumber, Value: value}
+}
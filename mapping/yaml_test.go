@@ -0,0 +1,186 @@
+package mapping_test
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/mapping"
+)
+
+func Test_Mapping_ParseYAML(t *testing.T) {
+    type TestScenario struct {
+        name     string
+        document string
+        expected interface{}
+    }
+
+    var TestScenarios = []TestScenario{
+        {
+            name: "nested mappings and scalars",
+            document: `
+---
+# leading comment
+request:
+  method: GET   # trailing comment
+  count: 3
+  enabled: true
+  missing: ~
+  quoted: "a # not a comment"
+  single: 'it''s'
+  apostrophe: it's here # comment
+  escaped: "x\\" # c
+  doubled: 'a '' # not a comment'
+  flow: ['a # b', "c"] # comment
+`,
+            expected: map[string]interface{}{
+                "request": map[string]interface{}{
+                    "method": "GET",
+                    "count": float64(3),
+                    "enabled": true,
+                    "missing": nil,
+                    "quoted": "a # not a comment",
+                    "single": "it's",
+                    "apostrophe": "it's here",
+                    "escaped": "x\\",
+                    "doubled": "a ' # not a comment",
+                    "flow": []interface{}{"a # b", "c"},
+                },
+            },
+        },
+        {
+            name: "sequences",
+            document: `
+items:
+- a
+- b: 1
+  c: 2
+-
+  - nested
+other:
+  - "x"
+`,
+            expected: map[string]interface{}{
+                "items": []interface{}{
+                    "a",
+                    map[string]interface{}{"b": float64(1), "c": float64(2)},
+                    []interface{}{"nested"},
+                },
+                "other": []interface{}{"x"},
+            },
+        },
+        {
+            name: "flow collections",
+            document: `value: {a: [1, "two", {b: null}], c: https://x.example/y}`,
+            expected: map[string]interface{}{
+                "value": map[string]interface{}{
+                    "a": []interface{}{float64(1), "two", map[string]interface{}{"b": nil}},
+                    "c": "https://x.example/y",
+                },
+            },
+        },
+        {
+            name: "block scalars",
+            document: `
+literal: |
+  line one
+    # indented, not a comment
+
+  line three
+stripped: |-
+  text
+folded: >
+  a
+  b
+after: done
+`,
+            expected: map[string]interface{}{
+                "literal": "line one\n  # indented, not a comment\n\nline three\n",
+                "stripped": "text",
+                "folded": "a b\n",
+                "after": "done",
+            },
+        },
+    }
+
+    for _, scenario := range TestScenarios {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                node, err := mapping.ParseYAML([]byte(scenario.document))
+                if err != nil {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+                result := node.Interface()
+                if !reflect.DeepEqual(result, scenario.expected) {
+                    t.Errorf("Unexpected result:\n%#v\n!=\n%#v", result, scenario.expected)
+                }
+            },
+        )
+    }
+}
+
+func Test_Mapping_ParseYAML_Errors(t *testing.T) {
+    type TestScenario struct {
+        name     string
+        document string
+        line     int
+    }
+
+    var TestScenarios = []TestScenario{
+        {
+            name: "bad indentation",
+            document: "a:\n  b: 1\n    c: 2\n",
+            line: 3,
+        },
+        {
+            name: "duplicate key",
+            document: "a: 1\nb: 2\na: 3\n",
+            line: 3,
+        },
+        {
+            name: "unterminated flow",
+            document: "a: 1\nb: [1, 2\n",
+            line: 2,
+        },
+        {
+            name: "alias",
+            document: "a: *ref\n",
+            line: 1,
+        },
+        {
+            name: "complex key",
+            document: "a: 1\n? b\n: 2\n",
+            line: 2,
+        },
+        {
+            name: "complex key in a sequence",
+            document: "a:\n  - ? b\n",
+            line: 2,
+        },
+        {
+            name: "tab indentation",
+            document: "a:\n\tb: 1\n",
+            line: 2,
+        },
+    }
+
+    for _, scenario := range TestScenarios {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                _, err := mapping.ParseYAML([]byte(scenario.document))
+                var ve *mapping.ValidationError
+                if !errors.As(err, &ve) {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+                if ve.Line != scenario.line {
+                    t.Errorf("Unexpected line: %d != %d (%v)", ve.Line, scenario.line, err)
+                }
+                if !errors.Is(err, mapping.ErrInvalidMapping) {
+                    t.Errorf("Error does not wrap ErrInvalidMapping: %v", err)
+                }
+            },
+        )
+    }
+}
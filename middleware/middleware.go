@@ -0,0 +1,129 @@
+package middleware
+
+import (
+    "crypto/subtle"
+    "encoding/base64"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    The middleware package holds ready made common.Middleware for the
+    router (router.Router.Use), a service (service.ServiceHandler.Use) or a
+    single method (service.ServiceHandler.UseMethod), e.g
+
+        r.Use(middleware.Logging(), middleware.DefaultHeaders(http.Header{"Server": {"mock"}}))
+        users.UseMethod(common.HttpVerb_Post, middleware.BearerToken("secret"))
+
+    See service.ServiceHandler for the order the levels run in.
+ */
+
+// Logging logs each request with the status of its reply and the time taken to handle it
+func Logging() common.Middleware {
+    return func(next common.HttpHandler) common.HttpHandler {
+        return func(request *common.HttpCall) (result *common.HttpReply, err error) {
+            start := time.Now()
+            result, err = next(request)
+            elapsed := time.Since(start)
+            switch {
+            case err != nil:
+                log.Printf("%s %s failed after %v: %v", request.Method, request.Url, elapsed, err)
+            case result != nil:
+                log.Printf("%s %s replied %d after %v", request.Method, request.Url, result.Status, elapsed)
+            }
+            return
+        }
+    }
+}
+
+// DefaultHeaders adds the headers to replies that don't already set them
+func DefaultHeaders(headers http.Header) common.Middleware {
+    return func(next common.HttpHandler) common.HttpHandler {
+        return func(request *common.HttpCall) (result *common.HttpReply, err error) {
+            result, err = next(request)
+            if err != nil || result == nil {
+                return
+            }
+            if result.Headers == nil {
+                result.Headers = make(http.Header)
+            }
+            for name, values := range headers {
+                if _, ok := result.Headers[http.CanonicalHeaderKey(name)]; !ok {
+                    result.Headers[http.CanonicalHeaderKey(name)] = append([]string{}, values...)
+                }
+            }
+            return
+        }
+    }
+}
+
+// Authorize replies 401 to requests the check rejects; the challenge, if any, is sent as the
+// WWW-Authenticate header
+func Authorize(check func(request *common.HttpCall) bool, challenge string) common.Middleware {
+    return func(next common.HttpHandler) common.HttpHandler {
+        return func(request *common.HttpCall) (*common.HttpReply, error) {
+            if !check(request) {
+                log.Printf("Rejecting unauthorized request %s %s", request.Method, request.Url)
+                reply := common.NewReply(http.StatusUnauthorized)
+                if len(challenge) > 0 {
+                    reply.WithHeader("WWW-Authenticate", challenge)
+                }
+                return reply.Text("unauthorized"), nil
+            }
+            return next(request)
+        }
+    }
+}
+
+// BasicAuth requires the HTTP Basic credentials
+func BasicAuth(realm string, username string, password string) common.Middleware {
+    expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+    return Authorize(
+        func(request *common.HttpCall) bool {
+            return equal(request.Headers.Get("Authorization"), expected)
+        },
+        fmt.Sprintf("Basic realm=%q", realm),
+    )
+}
+
+// BearerToken requires one of the tokens as an `Authorization: Bearer` header
+func BearerToken(tokens ...string) common.Middleware {
+    return Authorize(
+        func(request *common.HttpCall) bool {
+            authorization := request.Headers.Get("Authorization")
+            if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+                return false
+            }
+            for _, token := range tokens {
+                if equal(authorization[7:], token) {
+                    return true
+                }
+            }
+            return false
+        },
+        "Bearer",
+    )
+}
+
+func equal(actual string, expected string) bool {
+    return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
+
+// Latency delays every request by the duration before handling it; a request cancelled while
+// waiting fails at the transport level (see common.Sleep). The latency package has random
+// and distribution-based delays.
+func Latency(d time.Duration) common.Middleware {
+    return func(next common.HttpHandler) common.HttpHandler {
+        return func(request *common.HttpCall) (*common.HttpReply, error) {
+            if err := common.Sleep(request, d); err != nil {
+                return nil, err
+            }
+            return next(request)
+        }
+    }
+}
@@ -0,0 +1,136 @@
+package middleware_test
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/middleware"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+)
+
+// trace records the order the middleware and handlers run in
+type trace struct {
+    steps []string
+}
+
+func (tr *trace) tag(name string) common.Middleware {
+    return func(next common.HttpHandler) common.HttpHandler {
+        return func(request *common.HttpCall) (*common.HttpReply, error) {
+            tr.steps = append(tr.steps, name)
+            return next(request)
+        }
+    }
+}
+
+func (tr *trace) handler(name string) common.HttpHandler {
+    return func(request *common.HttpCall) (*common.HttpReply, error) {
+        tr.steps = append(tr.steps, name)
+        return common.NewReply(200).Text(name), nil
+    }
+}
+
+func newRouter(t *testing.T, tr *trace) (*router.Router, *service.ServiceHandler) {
+    r := router.New()
+    api := &service.ServiceHandler{}
+    api.Init("api", &common.BasicServerURI{Protocol: "https", Host: "api.example"})
+    api.RegisterMethodHandler(common.HttpVerb_Get, tr.handler("root"))
+
+    users := &service.ServiceHandler{}
+    users.Init("users", &common.PathURI{Path: regexp.MustCompile(`^/users`)})
+    users.RegisterMethodHandler(common.HttpVerb_Get, tr.handler("list"))
+    users.RegisterMethodHandler(common.HttpVerb_Post, tr.handler("create"))
+    users.Use(tr.tag("users"))
+    users.UseMethod(common.HttpVerb_Post, tr.tag("post 1"), tr.tag("post 2"))
+    if err := api.RegisterHandler(users); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    api.Use(tr.tag("api 1"), tr.tag("api 2"))
+    r.Use(tr.tag("router"))
+    if err := r.RegisterService("api", api); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    return r, api
+}
+
+func Test_Middleware_Order(t *testing.T) {
+    tr := &trace{}
+    r, _ := newRouter(t, tr)
+    client := &http.Client{Transport: r}
+
+    for _, scenario := range []struct {
+        method   string
+        target   string
+        expected string
+    }{
+        {"POST", "https://api.example/users", "router,api 1,api 2,users,post 1,post 2,create"},
+        {"GET", "https://api.example/users", "router,api 1,api 2,users,list"},
+        {"GET", "https://api.example/", "router,api 1,api 2,root"},
+    } {
+        tr.steps = nil
+        request, _ := http.NewRequest(scenario.method, scenario.target, nil)
+        if _, err := client.Do(request); err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        if order := strings.Join(tr.steps, ","); order != scenario.expected {
+            t.Errorf("%s %s: unexpected order: %s", scenario.method, scenario.target, order)
+        }
+    }
+}
+
+func Test_Middleware_Builtin(t *testing.T) {
+    tr := &trace{}
+    r, api := newRouter(t, tr)
+    client := &http.Client{Transport: r}
+    api.Use(
+        middleware.Logging(),
+        middleware.DefaultHeaders(http.Header{"server": {"mock"}, "Content-Type": {"application/json"}}),
+    )
+    api.UseMethod(common.HttpVerb_Get, middleware.BasicAuth("api", "user", "pass"))
+
+    request, _ := http.NewRequest("GET", "https://api.example/", nil)
+    response, err := client.Do(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if response.StatusCode != 401 || response.Header.Get("WWW-Authenticate") != `Basic realm="api"` {
+        t.Errorf("Unexpected unauthorized reply: %d %v", response.StatusCode, response.Header)
+    }
+    request, _ = http.NewRequest("GET", "https://api.example/", nil)
+    request.SetBasicAuth("user", "pass")
+    response, err = client.Do(request)
+    if err != nil || response.StatusCode != 200 {
+        t.Fatalf("Unexpected reply: %v %v", response, err)
+    }
+    if response.Header.Get("Server") != "mock" || response.Header.Get("Content-Type") != "text/plain; charset=utf-8" {
+        t.Errorf("Unexpected default headers: %v", response.Header)
+    }
+
+    bearer := middleware.BearerToken("a", "b")(tr.handler("bearer"))
+    for token, status := range map[string]common.HttpStatusCode{"Bearer b": 200, "bearer a": 200, "Bearer c": 401, "": 401} {
+        call := &common.HttpCall{Headers: http.Header{"Authorization": {token}}}
+        if reply, _ := bearer(call); reply.Status != status {
+            t.Errorf("Token %q: unexpected status: %d != %d", token, reply.Status, status)
+        }
+    }
+
+    slow := middleware.Latency(20 * time.Millisecond)(tr.handler("slow"))
+    start := time.Now()
+    if _, err := slow(&common.HttpCall{}); err != nil || time.Since(start) < 20*time.Millisecond {
+        t.Errorf("Latency did not delay the request: %v", err)
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    cancelled, _ := http.NewRequestWithContext(ctx, "GET", "https://api.example/", nil)
+    var transportErr *common.TransportError
+    if _, err := slow(&common.HttpCall{Request: cancelled}); !errors.As(err, &transportErr) {
+        t.Errorf("Expected a TransportError for a cancelled request but found %v", err)
+    }
+}
@@ -0,0 +1,302 @@
+package openapi
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "net"
+    "reflect"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// Violation is a single difference between the traffic and the document
+type Violation struct {
+    // Location names the offending part, e.g `request.query.limit` or `response.body.pets[0].id`
+    Location string
+    Msg      string
+}
+
+func (v Violation) String() string {
+    return v.Location + ": " + v.Msg
+}
+
+type direction int
+
+const (
+    directionRequest direction = iota
+    directionResponse
+)
+
+// checker validates values against schemas, collecting every violation
+type checker struct {
+    doc        *Document
+    direction  direction
+    violations []Violation
+}
+
+func (c *checker) fail(location string, format string, args ...interface{}) {
+    c.violations = append(c.violations, Violation{Location: location, Msg: fmt.Sprintf(format, args...)})
+}
+
+// passes reports whether the value satisfies the schema without recording anything
+func (c *checker) passes(schema *Schema, value interface{}, location string) bool {
+    sub := &checker{doc: c.doc, direction: c.direction}
+    sub.value(schema, value, location)
+    return len(sub.violations) == 0
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func jsonType(value interface{}) string {
+    switch typed := value.(type) {
+    case nil:
+        return "null"
+    case bool:
+        return "boolean"
+    case float64:
+        if typed == math.Trunc(typed) {
+            return "integer"
+        }
+        return "number"
+    case string:
+        return "string"
+    case []interface{}:
+        return "array"
+    case map[string]interface{}:
+        return "object"
+    }
+    return fmt.Sprintf("%T", value)
+}
+
+func isType(name string, value interface{}) bool {
+    actual := jsonType(value)
+    return actual == name || (name == "number" && actual == "integer")
+}
+
+func (c *checker) value(schema *Schema, value interface{}, location string) {
+    schema, err := c.doc.ResolveSchema(schema)
+    if err != nil {
+        c.fail(location, "%v", err)
+        return
+    }
+    if schema == nil {
+        return
+    }
+
+    for _, part := range schema.AllOf {
+        c.value(part, value, location)
+    }
+    if len(schema.AnyOf) > 0 {
+        matched := false
+        for _, part := range schema.AnyOf {
+            if c.passes(part, value, location) {
+                matched = true
+                break
+            }
+        }
+        if !matched {
+            c.fail(location, "does not match any of the anyOf schemas")
+        }
+    }
+    if len(schema.OneOf) > 0 {
+        matched := 0
+        for _, part := range schema.OneOf {
+            if c.passes(part, value, location) {
+                matched++
+            }
+        }
+        if matched != 1 {
+            c.fail(location, "matches %d of the oneOf schemas instead of exactly one", matched)
+        }
+    }
+    if schema.Not != nil && c.passes(schema.Not, value, location) {
+        c.fail(location, "must not match the `not` schema")
+    }
+
+    if value == nil {
+        if len(schema.Type) > 0 && !schema.IsNullable() {
+            c.fail(location, "must not be null")
+        }
+        return
+    }
+
+    if len(schema.Enum) > 0 {
+        found := false
+        for _, allowed := range schema.Enum {
+            if reflect.DeepEqual(allowed, value) {
+                found = true
+                break
+            }
+        }
+        if !found {
+            c.fail(location, "%s is not one of %s", jsonText(value), jsonText(schema.Enum))
+        }
+    }
+
+    if len(schema.Type) > 0 {
+        matched := false
+        for _, name := range schema.Type {
+            if isType(name, value) {
+                matched = true
+                break
+            }
+        }
+        if !matched {
+            c.fail(location, "expected %s but found %s", strings.Join(schema.Type, " or "), jsonType(value))
+            return
+        }
+    }
+
+    switch typed := value.(type) {
+    case string:
+        c.text(schema, typed, location)
+    case float64:
+        if schema.Minimum != nil && typed < *schema.Minimum {
+            c.fail(location, "%v is less than the minimum %v", typed, *schema.Minimum)
+        }
+        if schema.Maximum != nil && typed > *schema.Maximum {
+            c.fail(location, "%v is greater than the maximum %v", typed, *schema.Maximum)
+        }
+    case []interface{}:
+        if schema.MinItems != nil && len(typed) < *schema.MinItems {
+            c.fail(location, "has %d items but needs at least %d", len(typed), *schema.MinItems)
+        }
+        if schema.MaxItems != nil && len(typed) > *schema.MaxItems {
+            c.fail(location, "has %d items but allows at most %d", len(typed), *schema.MaxItems)
+        }
+        if schema.Items != nil {
+            for index, item := range typed {
+                c.value(schema.Items, item, fmt.Sprintf("%s[%d]", location, index))
+            }
+        }
+    case map[string]interface{}:
+        c.object(schema, typed, location)
+    }
+}
+
+func (c *checker) text(schema *Schema, value string, location string) {
+    length := utf8.RuneCountInString(value)
+    if schema.MinLength != nil && length < *schema.MinLength {
+        c.fail(location, "is %d characters but needs at least %d", length, *schema.MinLength)
+    }
+    if schema.MaxLength != nil && length > *schema.MaxLength {
+        c.fail(location, "is %d characters but allows at most %d", length, *schema.MaxLength)
+    }
+    if len(schema.Pattern) > 0 {
+        pattern, err := regexp.Compile(schema.Pattern)
+        if err != nil {
+            c.fail(location, "the document's pattern %q is not supported: %v", schema.Pattern, err)
+        } else if !pattern.MatchString(value) {
+            c.fail(location, "%q does not match the pattern %q", value, schema.Pattern)
+        }
+    }
+
+    valid := true
+    switch schema.Format {
+    case "date-time":
+        _, err := time.Parse(time.RFC3339, value)
+        valid = err == nil
+    case "date":
+        _, err := time.Parse("2006-01-02", value)
+        valid = err == nil
+    case "uuid":
+        valid = uuidPattern.MatchString(value)
+    case "email":
+        valid = strings.Contains(value, "@") && !strings.ContainsAny(value, " \t\r\n")
+    case "ipv4":
+        ip := net.ParseIP(value)
+        valid = ip != nil && ip.To4() != nil
+    case "ipv6":
+        ip := net.ParseIP(value)
+        valid = ip != nil && ip.To4() == nil
+    }
+    if !valid {
+        c.fail(location, "%q is not a valid %s", value, schema.Format)
+    }
+}
+
+func (c *checker) object(schema *Schema, value map[string]interface{}, location string) {
+    for _, name := range schema.Required {
+        if _, ok := value[name]; ok {
+            continue
+        }
+        // read-only properties aren't sent in requests and write-only ones aren't returned
+        if property, err := c.doc.ResolveSchema(schema.Properties[name]); err == nil && property != nil {
+            if (c.direction == directionRequest && property.ReadOnly) || (c.direction == directionResponse && property.WriteOnly) {
+                continue
+            }
+        }
+        c.fail(location+"."+name, "is required")
+    }
+
+    names := make([]string, 0, len(value))
+    for name := range value {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        if property, ok := schema.Properties[name]; ok {
+            c.value(property, value[name], location+"."+name)
+            continue
+        }
+        if schema.AdditionalProperties == nil {
+            continue
+        }
+        if !schema.AdditionalProperties.Allowed {
+            c.fail(location+"."+name, "is not a documented property")
+        } else if schema.AdditionalProperties.Schema != nil {
+            c.value(schema.AdditionalProperties.Schema, value[name], location+"."+name)
+        }
+    }
+}
+
+// coerce converts parameter and header text into the type the schema expects so it can be checked
+func (doc *Document) coerce(schema *Schema, values []string) interface{} {
+    schema, err := doc.ResolveSchema(schema)
+    if err != nil || schema == nil || len(values) == 0 {
+        return firstValue(values)
+    }
+    if schema.Type.Primary() == "array" {
+        if len(values) == 1 && strings.Contains(values[0], ",") {
+            values = strings.Split(values[0], ",")
+        }
+        items := make([]interface{}, 0, len(values))
+        for _, value := range values {
+            items = append(items, doc.coerce(schema.Items, []string{value}))
+        }
+        return items
+    }
+
+    value := values[0]
+    switch schema.Type.Primary() {
+    case "integer", "number":
+        if number, err := strconv.ParseFloat(value, 64); err == nil {
+            return number
+        }
+    case "boolean":
+        if flag, err := strconv.ParseBool(value); err == nil {
+            return flag
+        }
+    }
+    return value
+}
+
+func firstValue(values []string) interface{} {
+    if len(values) == 0 {
+        return nil
+    }
+    return values[0]
+}
+
+func jsonText(value interface{}) string {
+    encoded, err := json.Marshal(value)
+    if err != nil {
+        return fmt.Sprint(value)
+    }
+    return string(encoded)
+}
@@ -0,0 +1,382 @@
+package openapi
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "sort"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/mapping"
+)
+
+/*
+    The openapi package reads OpenAPI 3 documents (JSON or YAML) into the
+    structures below. Only the parts used to generate and validate mock
+    services are modelled; unknown fields are ignored. References are kept
+    as-is and resolved on demand since schemas may refer to themselves.
+ */
+
+var (
+    ErrInvalidDocument error = errors.New("OpenAPI: Invalid document")
+    ErrUnresolvedRef error = errors.New("OpenAPI: Unable to resolve reference")
+)
+
+type Document struct {
+    OpenAPI    string               `json:"openapi"`
+    Info       Info                 `json:"info"`
+    Servers    []*Server            `json:"servers,omitempty"`
+    Paths      map[string]*PathItem `json:"paths"`
+    Components *Components          `json:"components,omitempty"`
+
+    // pathOrder retains the order the paths were defined in
+    pathOrder []string
+}
+
+type Info struct {
+    Title       string `json:"title"`
+    Description string `json:"description,omitempty"`
+    Version     string `json:"version"`
+}
+
+type Server struct {
+    URL         string                     `json:"url"`
+    Description string                     `json:"description,omitempty"`
+    Variables   map[string]*ServerVariable `json:"variables,omitempty"`
+}
+
+type ServerVariable struct {
+    Default     string   `json:"default"`
+    Enum        []string `json:"enum,omitempty"`
+    Description string   `json:"description,omitempty"`
+}
+
+type Components struct {
+    Schemas       map[string]*Schema      `json:"schemas,omitempty"`
+    Responses     map[string]*Response    `json:"responses,omitempty"`
+    Parameters    map[string]*Parameter   `json:"parameters,omitempty"`
+    Examples      map[string]*Example     `json:"examples,omitempty"`
+    RequestBodies map[string]*RequestBody `json:"requestBodies,omitempty"`
+    Headers       map[string]*Header      `json:"headers,omitempty"`
+}
+
+type PathItem struct {
+    Summary     string       `json:"summary,omitempty"`
+    Description string       `json:"description,omitempty"`
+    Get         *Operation   `json:"get,omitempty"`
+    Put         *Operation   `json:"put,omitempty"`
+    Post        *Operation   `json:"post,omitempty"`
+    Delete      *Operation   `json:"delete,omitempty"`
+    Options     *Operation   `json:"options,omitempty"`
+    Head        *Operation   `json:"head,omitempty"`
+    Patch       *Operation   `json:"patch,omitempty"`
+    Trace       *Operation   `json:"trace,omitempty"`
+    Parameters  []*Parameter `json:"parameters,omitempty"`
+}
+
+type Operation struct {
+    OperationID string               `json:"operationId,omitempty"`
+    Summary     string               `json:"summary,omitempty"`
+    Description string               `json:"description,omitempty"`
+    Tags        []string             `json:"tags,omitempty"`
+    Parameters  []*Parameter         `json:"parameters,omitempty"`
+    RequestBody *RequestBody         `json:"requestBody,omitempty"`
+    Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+    Ref         string              `json:"$ref,omitempty"`
+    Name        string              `json:"name,omitempty"`
+    In          string              `json:"in,omitempty"`
+    Description string              `json:"description,omitempty"`
+    Required    bool                `json:"required,omitempty"`
+    Schema      *Schema             `json:"schema,omitempty"`
+    Example     interface{}         `json:"example,omitempty"`
+    Examples    map[string]*Example `json:"examples,omitempty"`
+}
+
+type RequestBody struct {
+    Ref         string                `json:"$ref,omitempty"`
+    Description string                `json:"description,omitempty"`
+    Required    bool                  `json:"required,omitempty"`
+    Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Response struct {
+    Ref         string                `json:"$ref,omitempty"`
+    Description string                `json:"description"`
+    Headers     map[string]*Header    `json:"headers,omitempty"`
+    Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+    Ref         string      `json:"$ref,omitempty"`
+    Description string      `json:"description,omitempty"`
+    Required    bool        `json:"required,omitempty"`
+    Schema      *Schema     `json:"schema,omitempty"`
+    Example     interface{} `json:"example,omitempty"`
+}
+
+type MediaType struct {
+    Schema   *Schema             `json:"schema,omitempty"`
+    Example  interface{}         `json:"example,omitempty"`
+    Examples map[string]*Example `json:"examples,omitempty"`
+}
+
+type Example struct {
+    Ref           string      `json:"$ref,omitempty"`
+    Summary       string      `json:"summary,omitempty"`
+    Value         interface{} `json:"value,omitempty"`
+    ExternalValue string      `json:"externalValue,omitempty"`
+}
+
+// Operations returns the operations of the path item by method
+func (pi *PathItem) Operations() map[common.HttpVerb]*Operation {
+    operations := make(map[common.HttpVerb]*Operation)
+    for method, operation := range map[string]*Operation{
+        http.MethodGet: pi.Get,
+        http.MethodPut: pi.Put,
+        http.MethodPost: pi.Post,
+        http.MethodDelete: pi.Delete,
+        http.MethodOptions: pi.Options,
+        http.MethodHead: pi.Head,
+        http.MethodPatch: pi.Patch,
+        http.MethodTrace: pi.Trace,
+    } {
+        if operation != nil {
+            operations[common.HttpVerb(method)] = operation
+        }
+    }
+    return operations
+}
+
+// SetOperation sets the operation of the path item for the method
+func (pi *PathItem) SetOperation(method common.HttpVerb, operation *Operation) {
+    switch strings.ToUpper(string(method)) {
+    case http.MethodGet:
+        pi.Get = operation
+    case http.MethodPut:
+        pi.Put = operation
+    case http.MethodPost:
+        pi.Post = operation
+    case http.MethodDelete:
+        pi.Delete = operation
+    case http.MethodOptions:
+        pi.Options = operation
+    case http.MethodHead:
+        pi.Head = operation
+    case http.MethodPatch:
+        pi.Patch = operation
+    case http.MethodTrace:
+        pi.Trace = operation
+    }
+}
+
+// PathOrder returns the paths in the order they were defined in, falling back
+// to name order for paths added in code
+func (doc *Document) PathOrder() []string {
+    order := []string{}
+    seen := make(map[string]bool)
+    for _, path := range doc.pathOrder {
+        if _, ok := doc.Paths[path]; ok && !seen[path] {
+            order = append(order, path)
+            seen[path] = true
+        }
+    }
+    added := []string{}
+    for path := range doc.Paths {
+        if !seen[path] {
+            added = append(added, path)
+        }
+    }
+    sort.Strings(added)
+    return append(order, added...)
+}
+
+// ServerURLs expands the server variables using their defaults; the base URL is used for
+// relative servers and when the document has none
+func (doc *Document) ServerURLs(baseUrl string) (bases []*url.URL, err error) {
+    servers := doc.Servers
+    if len(servers) == 0 {
+        servers = []*Server{{URL: "/"}}
+    }
+
+    seen := make(map[string]bool)
+    for _, server := range servers {
+        rawUrl := server.URL
+        for name, variable := range server.Variables {
+            rawUrl = strings.ReplaceAll(rawUrl, "{"+name+"}", variable.Default)
+        }
+        base, parseErr := url.Parse(rawUrl)
+        if parseErr != nil {
+            err = fmt.Errorf("%w: server %q: %v", ErrInvalidDocument, server.URL, parseErr)
+            return
+        }
+        if len(base.Host) == 0 {
+            if len(baseUrl) == 0 {
+                err = fmt.Errorf("%w: server %q", ErrMissingBaseURL, server.URL)
+                return
+            }
+            if base, err = url.Parse(strings.TrimRight(baseUrl, "/") + "/" + strings.TrimLeft(base.Path, "/")); err != nil {
+                return
+            }
+        }
+        if !seen[base.String()] {
+            seen[base.String()] = true
+            bases = append(bases, base)
+        }
+    }
+    return
+}
+
+// WriteFile writes the document as indented JSON, which YAML tools also accept
+func (doc *Document) WriteFile(file string) (err error) {
+    encoded, err := json.MarshalIndent(doc, "", "  ")
+    if err != nil {
+        return
+    }
+    return ioutil.WriteFile(file, append(encoded, '\n'), 0644)
+}
+
+// Load reads an OpenAPI document from a .json, .yaml or .yml file
+func Load(file string) (doc *Document, err error) {
+    data, err := ioutil.ReadFile(file)
+    if err != nil {
+        return
+    }
+    doc, err = Parse(data)
+    if err != nil {
+        err = fmt.Errorf("%s: %w", file, err)
+    }
+    return
+}
+
+// Parse reads an OpenAPI document in either JSON or YAML
+func Parse(data []byte) (doc *Document, err error) {
+    var root *mapping.Node
+    if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+        root, err = mapping.ParseJSON(data)
+    } else {
+        root, err = mapping.ParseYAML(data)
+    }
+    if err != nil {
+        err = fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+        return
+    }
+
+    // YAML reads `version: 1.0` as a number
+    for _, n := range []*mapping.Node{root.Get("openapi"), root.Get("info").Get("version")} {
+        if n != nil && n.Kind == mapping.KindNumber {
+            n.Kind = mapping.KindString
+        }
+    }
+
+    encoded, err := json.Marshal(root.Interface())
+    if err != nil {
+        err = fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+        return
+    }
+    doc = &Document{}
+    if err = json.Unmarshal(encoded, doc); err != nil {
+        err = fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+        doc = nil
+        return
+    }
+    if !strings.HasPrefix(doc.OpenAPI, "3.") {
+        err = fmt.Errorf("%w: unsupported openapi version %q", ErrInvalidDocument, doc.OpenAPI)
+        doc = nil
+        return
+    }
+    if paths := root.Get("paths"); paths != nil {
+        doc.pathOrder = paths.Keys
+    }
+    return
+}
+
+// refName returns the component name of a local reference such as `#/components/schemas/User`
+func refName(ref string, kind string) (name string, err error) {
+    prefix := "#/components/" + kind + "/"
+    if !strings.HasPrefix(ref, prefix) {
+        err = fmt.Errorf("%w: %q (only %s... references are supported)", ErrUnresolvedRef, ref, prefix)
+        return
+    }
+    name = strings.NewReplacer("~1", "/", "~0", "~").Replace(ref[len(prefix):])
+    return
+}
+
+func (doc *Document) components() *Components {
+    if doc.Components == nil {
+        return &Components{}
+    }
+    return doc.Components
+}
+
+// maxRefDepth bounds how many $ref hops are followed before giving up
+const maxRefDepth = 32
+
+// resolveRef follows $ref through the component definitions until it reaches one
+// without a $ref, failing on unknown names, cycles and overly long chains
+func resolveRef[T any](start *T, ref func(*T) string, kind string, definitions map[string]*T) (resolved *T, err error) {
+    resolved = start
+    seen := map[string]bool{}
+    for resolved != nil && len(ref(resolved)) > 0 {
+        target := ref(resolved)
+        if seen[target] {
+            err = fmt.Errorf("%w: %q refers to itself", ErrUnresolvedRef, target)
+            return
+        }
+        if len(seen) >= maxRefDepth {
+            err = fmt.Errorf("%w: %q is nested more than %d references deep", ErrUnresolvedRef, ref(start), maxRefDepth)
+            return
+        }
+        seen[target] = true
+
+        name, nameErr := refName(target, kind)
+        if nameErr != nil {
+            err = nameErr
+            return
+        }
+        next, ok := definitions[name]
+        if !ok {
+            err = fmt.Errorf("%w: %q", ErrUnresolvedRef, target)
+            return
+        }
+        resolved = next
+    }
+    return
+}
+
+// ResolveSchema follows $ref until it reaches a schema definition
+func (doc *Document) ResolveSchema(schema *Schema) (*Schema, error) {
+    return resolveRef(schema, func(s *Schema) string { return s.Ref }, "schemas", doc.components().Schemas)
+}
+
+// ResolveParameter follows $ref until it reaches a parameter definition
+func (doc *Document) ResolveParameter(parameter *Parameter) (*Parameter, error) {
+    return resolveRef(parameter, func(p *Parameter) string { return p.Ref }, "parameters", doc.components().Parameters)
+}
+
+// ResolveRequestBody follows $ref until it reaches a request body definition
+func (doc *Document) ResolveRequestBody(body *RequestBody) (*RequestBody, error) {
+    return resolveRef(body, func(b *RequestBody) string { return b.Ref }, "requestBodies", doc.components().RequestBodies)
+}
+
+// ResolveResponse follows $ref until it reaches a response definition
+func (doc *Document) ResolveResponse(response *Response) (*Response, error) {
+    return resolveRef(response, func(r *Response) string { return r.Ref }, "responses", doc.components().Responses)
+}
+
+// ResolveHeader follows $ref until it reaches a header definition
+func (doc *Document) ResolveHeader(header *Header) (*Header, error) {
+    return resolveRef(header, func(h *Header) string { return h.Ref }, "headers", doc.components().Headers)
+}
+
+// ResolveExample follows $ref until it reaches an example definition
+func (doc *Document) ResolveExample(example *Example) (*Example, error) {
+    return resolveRef(example, func(e *Example) string { return e.Ref }, "examples", doc.components().Examples)
+}
@@ -0,0 +1,417 @@
+package openapi
+
+import (
+    "encoding/json"
+    "mime"
+    "net/http"
+    "net/url"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/redact"
+    "github.com/TestInABox/gostackinabox/router"
+)
+
+/*
+    Infer builds a skeleton OpenAPI document from the traffic captured by a
+    router.Recorder, as a starting point for documenting a third-party API
+    that the tests mock:
+
+        doc := openapi.Infer("Partner API", recorder.Exchanges())
+        err := doc.WriteFile("partner.openapi.json")
+
+    Path segments that look like identifiers (numbers, UUIDs, long hex strings
+    and redaction placeholders) become path parameters named after the
+    preceding segment, e.g `/users/42` becomes `/users/{userId}`. Each
+    operation collects the query parameters (required when every call sent
+    them), the request body schema and a response per observed status, with
+    schemas inferred from the JSON bodies; values the redactor replaced with
+    timestamp or UUID placeholders keep their format. Exchanges that failed at the
+    transport level or weren't handled (595-597) are skipped.
+ */
+
+// placeholderFormats recovers the format of values the default redactor normalized
+var placeholderFormats = map[string]string{
+    redact.Placeholder("timestamp"): "date-time",
+    redact.Placeholder("uuid"): "uuid",
+}
+
+var identifierPattern = regexp.MustCompile(`^(?:[0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// observedOperation accumulates what was seen for a single method on a path template
+type observedOperation struct {
+    calls       int
+    pathParams  map[string][]string
+    query       map[string][]string
+    queryCalls  map[string]int
+    requestType string
+    request     *Schema
+    hasRequest  bool
+    responses   map[int]*observedResponse
+}
+
+type observedResponse struct {
+    contentType string
+    schema      *Schema
+    hasBody     bool
+}
+
+type templatedPath struct {
+    template string
+    params   []string
+}
+
+// Infer builds an OpenAPI document describing the recorded exchanges
+func Infer(title string, exchanges []router.Exchange) (doc *Document) {
+    doc = &Document{
+        OpenAPI: "3.0.3",
+        Info: Info{Title: title, Version: "0.0.0"},
+        Paths: make(map[string]*PathItem),
+    }
+
+    servers := make(map[string]bool)
+    operations := make(map[string]map[common.HttpVerb]*observedOperation)
+    for _, exchange := range exchanges {
+        if exchange.URL == nil || exchange.Err != nil || exchange.Status == 0 {
+            continue
+        }
+        if exchange.Status >= int(common.HttpStatus_RouteNotHandled) && exchange.Status <= int(common.HttpStatus_ServiceSubRouteError) {
+            continue
+        }
+
+        server := exchange.URL.Scheme + "://" + exchange.URL.Host
+        if !servers[server] {
+            servers[server] = true
+            doc.Servers = append(doc.Servers, &Server{URL: server})
+        }
+
+        path, values := templatePath(exchange.URL.Path)
+        if _, ok := operations[path.template]; !ok {
+            operations[path.template] = make(map[common.HttpVerb]*observedOperation)
+        }
+        method := common.HttpVerb(strings.ToUpper(exchange.Method))
+        observed, ok := operations[path.template][method]
+        if !ok {
+            observed = &observedOperation{
+                pathParams: make(map[string][]string),
+                query: make(map[string][]string),
+                queryCalls: make(map[string]int),
+                responses: make(map[int]*observedResponse),
+            }
+            operations[path.template][method] = observed
+        }
+        observed.add(path, values, exchange)
+    }
+
+    sort.Slice(doc.Servers, func(i, j int) bool { return doc.Servers[i].URL < doc.Servers[j].URL })
+    for template, methods := range operations {
+        item := &PathItem{}
+        for method, observed := range methods {
+            item.SetOperation(method, observed.operation(method, template))
+        }
+        doc.Paths[template] = item
+    }
+    doc.pathOrder = doc.PathOrder()
+    return
+}
+
+// templatePath replaces identifier segments with parameters named after the segment before them
+func templatePath(path string) (result templatedPath, values []string) {
+    segments := strings.Split(path, "/")
+    used := make(map[string]int)
+    for index, segment := range segments {
+        unescaped, err := url.PathUnescape(segment)
+        if err != nil {
+            unescaped = segment
+        }
+        if !identifierPattern.MatchString(unescaped) && !redact.HasPlaceholder(unescaped) {
+            continue
+        }
+
+        name := "id"
+        if index > 0 && !strings.HasPrefix(segments[index-1], "{") && len(segments[index-1]) > 0 {
+            name = singular(segments[index-1]) + "Id"
+        }
+        used[name]++
+        if used[name] > 1 {
+            name += strconv.Itoa(used[name])
+        }
+        segments[index] = "{" + name + "}"
+        result.params = append(result.params, name)
+        values = append(values, unescaped)
+    }
+    result.template = strings.Join(segments, "/")
+    if len(result.template) == 0 {
+        result.template = "/"
+    }
+    return
+}
+
+func singular(word string) string {
+    word = strings.ToLower(strings.Trim(word, "-_."))
+    switch {
+    case strings.HasSuffix(word, "ies") && len(word) > 3:
+        return word[:len(word)-3] + "y"
+    case strings.HasSuffix(word, "ses") || strings.HasSuffix(word, "xes"):
+        return word[:len(word)-2]
+    case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && len(word) > 1:
+        return word[:len(word)-1]
+    }
+    return word
+}
+
+func (observed *observedOperation) add(path templatedPath, values []string, exchange router.Exchange) {
+    observed.calls++
+    for index, name := range path.params {
+        observed.pathParams[name] = append(observed.pathParams[name], values[index])
+    }
+    for name, queryValues := range exchange.URL.Query() {
+        observed.queryCalls[name]++
+        observed.query[name] = append(observed.query[name], queryValues...)
+    }
+
+    if len(exchange.RequestBody) > 0 {
+        contentType, schema := inferBody(exchange.RequestHeaders, exchange.RequestBody)
+        if !observed.hasRequest {
+            observed.requestType = contentType
+        }
+        observed.request = mergeSchema(observed.request, schema, observed.hasRequest)
+        observed.hasRequest = true
+    }
+
+    response, ok := observed.responses[exchange.Status]
+    if !ok {
+        response = &observedResponse{}
+        observed.responses[exchange.Status] = response
+    }
+    if len(exchange.ResponseBody) > 0 {
+        contentType, schema := inferBody(exchange.ResponseHeaders, exchange.ResponseBody)
+        if !response.hasBody {
+            response.contentType = contentType
+        }
+        response.schema = mergeSchema(response.schema, schema, response.hasBody)
+        response.hasBody = true
+    }
+}
+
+func (observed *observedOperation) operation(method common.HttpVerb, template string) *Operation {
+    operation := &Operation{
+        OperationID: operationID(method, template),
+        Responses: make(map[string]*Response),
+    }
+
+    names := make([]string, 0, len(observed.pathParams))
+    for name := range observed.pathParams {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        operation.Parameters = append(
+            operation.Parameters,
+            &Parameter{Name: name, In: "path", Required: true, Schema: inferText(observed.pathParams[name])},
+        )
+    }
+
+    names = names[:0]
+    for name := range observed.query {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        schema := inferText(observed.query[name])
+        if len(observed.query[name]) > observed.queryCalls[name] {
+            schema = &Schema{Type: SchemaType{"array"}, Items: schema}
+        }
+        operation.Parameters = append(
+            operation.Parameters,
+            &Parameter{Name: name, In: "query", Required: observed.queryCalls[name] == observed.calls, Schema: schema},
+        )
+    }
+
+    if observed.hasRequest {
+        operation.RequestBody = &RequestBody{
+            Required: true,
+            Content: map[string]*MediaType{observed.requestType: {Schema: observed.request}},
+        }
+    }
+
+    for status, observedResponse := range observed.responses {
+        response := &Response{Description: http.StatusText(status)}
+        if len(response.Description) == 0 {
+            response.Description = "Observed response"
+        }
+        if observedResponse.hasBody {
+            response.Content = map[string]*MediaType{observedResponse.contentType: {Schema: observedResponse.schema}}
+        }
+        operation.Responses[strconv.Itoa(status)] = response
+    }
+    return operation
+}
+
+// operationID names the operation after its method and path, e.g getUsersByUserId
+func operationID(method common.HttpVerb, template string) string {
+    var id strings.Builder
+    id.WriteString(strings.ToLower(string(method)))
+    for _, segment := range strings.Split(template, "/") {
+        if strings.HasPrefix(segment, "{") {
+            id.WriteString("By")
+            segment = strings.Trim(segment, "{}")
+        }
+        for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+            id.WriteString(strings.ToUpper(word[:1]) + word[1:])
+        }
+    }
+    return id.String()
+}
+
+func inferBody(headers http.Header, body []byte) (contentType string, schema *Schema) {
+    contentType, _, err := mime.ParseMediaType(headers.Get("Content-Type"))
+    if err != nil {
+        contentType = "application/octet-stream"
+    }
+    if isJSON(contentType) {
+        var document interface{}
+        if json.Unmarshal(body, &document) == nil {
+            return contentType, inferSchema(document)
+        }
+    }
+    return contentType, &Schema{Type: SchemaType{"string"}}
+}
+
+// inferText infers the type of parameter values
+func inferText(values []string) (schema *Schema) {
+    for _, value := range values {
+        var next *Schema
+        if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+            next = &Schema{Type: SchemaType{"integer"}}
+        } else if _, err := strconv.ParseFloat(value, 64); err == nil {
+            next = &Schema{Type: SchemaType{"number"}}
+        } else if _, err := strconv.ParseBool(value); err == nil {
+            next = &Schema{Type: SchemaType{"boolean"}}
+        } else {
+            next = inferSchema(value)
+        }
+        schema = mergeSchema(schema, next, schema != nil)
+    }
+    if schema == nil {
+        schema = &Schema{Type: SchemaType{"string"}}
+    }
+    return
+}
+
+// inferSchema describes a decoded JSON value
+func inferSchema(value interface{}) *Schema {
+    switch typed := value.(type) {
+    case nil:
+        return &Schema{Nullable: true}
+    case bool:
+        return &Schema{Type: SchemaType{"boolean"}}
+    case float64:
+        return &Schema{Type: SchemaType{jsonType(typed)}}
+    case string:
+        schema := &Schema{Type: SchemaType{"string"}}
+        if format, ok := placeholderFormats[typed]; ok {
+            schema.Format = format
+        } else if _, err := time.Parse(time.RFC3339, typed); err == nil {
+            schema.Format = "date-time"
+        } else if _, err := time.Parse("2006-01-02", typed); err == nil {
+            schema.Format = "date"
+        } else if uuidPattern.MatchString(typed) {
+            schema.Format = "uuid"
+        }
+        return schema
+    case []interface{}:
+        schema := &Schema{Type: SchemaType{"array"}}
+        for index, item := range typed {
+            schema.Items = mergeSchema(schema.Items, inferSchema(item), index > 0)
+        }
+        if schema.Items == nil {
+            schema.Items = &Schema{}
+        }
+        return schema
+    case map[string]interface{}:
+        schema := &Schema{Type: SchemaType{"object"}, Properties: make(map[string]*Schema, len(typed))}
+        for name, property := range typed {
+            schema.Properties[name] = inferSchema(property)
+            schema.Required = append(schema.Required, name)
+        }
+        sort.Strings(schema.Required)
+        return schema
+    }
+    return &Schema{}
+}
+
+// mergeSchema widens the schema to also describe another observation; seen is false for the first one
+func mergeSchema(schema *Schema, other *Schema, seen bool) *Schema {
+    if !seen || schema == nil {
+        return other
+    }
+    if other == nil {
+        return schema
+    }
+
+    // null only marks the other schema as nullable
+    if len(schema.Type) == 0 && schema.Nullable && len(schema.AnyOf) == 0 {
+        merged := *other
+        merged.Nullable = true
+        return &merged
+    }
+    if len(other.Type) == 0 && other.Nullable && len(other.AnyOf) == 0 {
+        merged := *schema
+        merged.Nullable = true
+        return &merged
+    }
+
+    first, second := schema.Type.Primary(), other.Type.Primary()
+    if (first == "integer" && second == "number") || (first == "number" && second == "integer") {
+        return &Schema{Type: SchemaType{"number"}, Nullable: schema.Nullable || other.Nullable}
+    }
+    if first != second || len(first) == 0 {
+        return &Schema{AnyOf: appendDistinct(schema, other), Nullable: schema.Nullable || other.Nullable}
+    }
+
+    merged := &Schema{Type: schema.Type, Nullable: schema.Nullable || other.Nullable}
+    if schema.Format == other.Format {
+        merged.Format = schema.Format
+    }
+    switch first {
+    case "array":
+        merged.Items = mergeSchema(schema.Items, other.Items, true)
+    case "object":
+        merged.Properties = make(map[string]*Schema)
+        for name, property := range schema.Properties {
+            merged.Properties[name] = property
+        }
+        for name, property := range other.Properties {
+            existing, ok := merged.Properties[name]
+            merged.Properties[name] = mergeSchema(existing, property, ok)
+        }
+        // only properties seen every time are required
+        for _, name := range schema.Required {
+            for _, otherName := range other.Required {
+                if name == otherName {
+                    merged.Required = append(merged.Required, name)
+                    break
+                }
+            }
+        }
+    }
+    return merged
+}
+
+func appendDistinct(schema *Schema, other *Schema) (result []*Schema) {
+    result = []*Schema{schema}
+    if len(schema.AnyOf) > 0 {
+        result = append([]*Schema{}, schema.AnyOf...)
+    }
+    for _, existing := range result {
+        if existing.Type.Primary() == other.Type.Primary() && len(other.Type) > 0 {
+            return
+        }
+    }
+    return append(result, other)
+}
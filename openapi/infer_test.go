@@ -0,0 +1,118 @@
+package openapi_test
+
+import (
+    "io/ioutil"
+    "net/http"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/openapi"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+func Test_OpenAPI_Infer(t *testing.T) {
+    r := router.New()
+    r.Recorder = router.NewRecorder()
+    stubs := stub.New(r)
+    stubs.On("GET", "https://partner.example/v1/users/{id}").
+        Reply(200).
+        JSON(map[string]interface{}{"id": 1, "name": "bob", "created": "2024-01-01T00:00:00Z", "manager": nil})
+    stubs.On("GET", "https://partner.example/v1/users").Reply(200).JSON([]interface{}{map[string]interface{}{"id": 1.5}})
+    stubs.On("POST", "https://partner.example/v1/users").Reply(201).JSON(map[string]interface{}{"id": 2})
+    stubs.On("GET", "https://partner.example/v1/categories/{id}/items/{item}").Reply(204)
+    client := &http.Client{Transport: r}
+
+    calls := []struct {
+        method string
+        target string
+        body   string
+    }{
+        {"GET", "https://partner.example/v1/users/1", ""},
+        {"GET", "https://partner.example/v1/users/2", ""},
+        {"GET", "https://partner.example/v1/users?limit=10&tag=a&tag=b", ""},
+        {"GET", "https://partner.example/v1/users?active=true", ""},
+        {"POST", "https://partner.example/v1/users", `{"name": "bob", "email": "bob@example.com"}`},
+        {"POST", "https://partner.example/v1/users", `{"name": "alice"}`},
+        {"GET", "https://partner.example/v1/categories/6f1c3c0e-8a43-4c4e-9d2e-1f2a3b4c5d6e/items/deadbeefdeadbeef01", ""},
+        {"GET", "https://partner.example/unhandled", ""},
+    }
+    for _, call := range calls {
+        request, _ := http.NewRequest(call.method, call.target, strings.NewReader(call.body))
+        if len(call.body) > 0 {
+            request.Header.Set("Content-Type", "application/json")
+        }
+        response, err := client.Do(request)
+        if err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        // the recorder captures the body as it is read
+        ioutil.ReadAll(response.Body)
+        response.Body.Close()
+    }
+
+    doc := openapi.Infer("Partner", r.Recorder.Exchanges())
+    expectedPaths := []string{"/v1/categories/{categoryId}/items/{itemId}", "/v1/users", "/v1/users/{userId}"}
+    if order := doc.PathOrder(); !reflect.DeepEqual(order, expectedPaths) {
+        t.Fatalf("Unexpected paths: %v", order)
+    }
+    if len(doc.Servers) != 1 || doc.Servers[0].URL != "https://partner.example" {
+        t.Errorf("Unexpected servers: %v", doc.Servers)
+    }
+
+    getUser := doc.Paths["/v1/users/{userId}"].Get
+    if getUser.OperationID != "getV1UsersByUserId" || len(getUser.Parameters) != 1 || getUser.Parameters[0].Schema.Type.Primary() != "integer" {
+        t.Errorf("Unexpected operation: %#v", getUser)
+    }
+    user := getUser.Responses["200"].Content["application/json"].Schema
+    if user.Properties["created"].Format != "date-time" || !user.Properties["manager"].IsNullable() || len(user.Required) != 4 {
+        t.Errorf("Unexpected user schema: %#v", user)
+    }
+
+    listUsers := doc.Paths["/v1/users"].Get
+    parameters := map[string]*openapi.Parameter{}
+    for _, parameter := range listUsers.Parameters {
+        parameters[parameter.Name] = parameter
+    }
+    if parameters["limit"].Required || parameters["limit"].Schema.Type.Primary() != "integer" ||
+        parameters["tag"].Schema.Type.Primary() != "array" || parameters["active"].Schema.Type.Primary() != "boolean" {
+        t.Errorf("Unexpected query parameters: %v", listUsers.Parameters)
+    }
+    if listUsers.Responses["200"].Content["application/json"].Schema.Items.Properties["id"].Type.Primary() != "number" {
+        t.Errorf("Unexpected list schema")
+    }
+
+    createUser := doc.Paths["/v1/users"].Post
+    body := createUser.RequestBody.Content["application/json"].Schema
+    if !reflect.DeepEqual(body.Required, []string{"name"}) || body.Properties["email"] == nil {
+        t.Errorf("Unexpected request schema: %#v", body)
+    }
+    if _, ok := createUser.Responses["201"]; !ok {
+        t.Errorf("Unexpected responses: %v", createUser.Responses)
+    }
+
+    // the inferred document round trips and describes the traffic it came from
+    file := filepath.Join(t.TempDir(), "partner.json")
+    if err := doc.WriteFile(file); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    loaded, err := openapi.Load(file)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    reporter := &recordingReporter{}
+    validator := openapi.NewValidator(loaded, reporter)
+    r.Use(validator.Middleware)
+    for _, call := range calls[:7] {
+        request, _ := http.NewRequest(call.method, call.target, strings.NewReader(call.body))
+        if len(call.body) > 0 {
+            request.Header.Set("Content-Type", "application/json")
+        }
+        client.Do(request)
+    }
+    if len(reporter.messages) > 0 {
+        t.Errorf("Inferred document does not describe the traffic:\n%s", strings.Join(reporter.messages, "\n"))
+    }
+}
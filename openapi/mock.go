@@ -0,0 +1,386 @@
+package openapi
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/util"
+)
+
+/*
+    Mock generates a service tree from an OpenAPI document: a ServerService
+    (matched with a common.BasicServerURI) per server entry, a PathService
+    (matched with a common.TemplateURI) per path and a method handler per
+    operation. Each handler replies with the operation's success response,
+    using the example from the document or a sample generated from the
+    schema along with the declared status and content type.
+
+        mock := openapi.NewMock(doc)
+        mock.Override("getUser", func(request *common.HttpCall) (*common.HttpReply, error) {
+            ...request.PathParams["id"]...
+        })
+        if err := mock.Register(r); err != nil {
+            ...
+        }
+
+    Overrides are keyed by operationId or by "METHOD /path/template" so only
+    the interesting endpoints need custom handlers.
+ */
+
+var (
+    ErrMissingBaseURL error = errors.New("OpenAPI: A BaseURL is required for relative or missing servers")
+    ErrUnknownOperation error = errors.New("OpenAPI: Override does not match any operation")
+)
+
+type Mock struct {
+    Document *Document
+    // BaseURL is used for relative server URLs and when the document has no servers
+    BaseURL string
+
+    lock      sync.Mutex
+    overrides map[string]common.HttpHandler
+}
+
+func NewMock(doc *Document) *Mock {
+    return &Mock{
+        Document: doc,
+        overrides: make(map[string]common.HttpHandler),
+    }
+}
+
+// Override replaces the generated handler of the operation, given by operationId or as "METHOD /path/template"
+func (m *Mock) Override(operation string, handler common.HttpHandler) *Mock {
+    m.lock.Lock()
+    defer m.lock.Unlock()
+    m.overrides[operation] = handler
+    return m
+}
+
+// Register generates the services and registers them with the router
+func (m *Mock) Register(r *router.Router) (err error) {
+    services, err := m.Services()
+    if err != nil {
+        return
+    }
+    for _, serverService := range services {
+        if err = r.RegisterService(serverService.GetName(), serverService); err != nil {
+            return
+        }
+    }
+    return
+}
+
+// Services generates a ServerService for each distinct scheme://host:port of the document's servers
+func (m *Mock) Services() (services []*ServerService, err error) {
+    m.lock.Lock()
+    defer m.lock.Unlock()
+
+    bases, err := m.Document.ServerURLs(m.BaseURL)
+    if err != nil {
+        return
+    }
+
+    used := make(map[string]bool)
+    byName := make(map[string]*ServerService)
+    for _, base := range bases {
+        name := util.GetUrlBaseResource(base)
+        serverService, ok := byName[name]
+        if !ok {
+            serverService = &ServerService{}
+            err = serverService.Init(
+                name,
+                &common.BasicServerURI{
+                    Protocol: base.Scheme,
+                    Host: base.Hostname(),
+                    Port: base.Port(),
+                },
+            )
+            if err != nil {
+                return
+            }
+            serverService.FuncHandler = unmatched
+            byName[name] = serverService
+            services = append(services, serverService)
+        }
+        if err = m.addPaths(serverService, strings.TrimRight(base.Path, "/"), used); err != nil {
+            return
+        }
+    }
+
+    for key := range m.overrides {
+        if !used[key] {
+            err = fmt.Errorf("%w: %q", ErrUnknownOperation, key)
+            return
+        }
+    }
+    return
+}
+
+func (m *Mock) addPaths(serverService *ServerService, prefix string, used map[string]bool) (err error) {
+    for _, path := range m.Document.PathOrder() {
+        item := m.Document.Paths[path]
+        pathService := &PathService{
+            Path: path,
+            Operations: item.Operations(),
+        }
+        if pathService.Template, err = common.NewTemplateURI(prefix + path); err != nil {
+            return
+        }
+        if err = pathService.Init(prefix+path, pathService.Template); err != nil {
+            return
+        }
+
+        methods := make([]string, 0, len(pathService.Operations))
+        for method := range pathService.Operations {
+            methods = append(methods, string(method))
+        }
+        sort.Strings(methods)
+        for _, method := range methods {
+            operation := pathService.Operations[common.HttpVerb(method)]
+            handler, handlerErr := m.handler(method, path, operation, used)
+            if handlerErr != nil {
+                err = fmt.Errorf("%s %s: %w", method, path, handlerErr)
+                return
+            }
+            if err = pathService.RegisterMethodHandler(common.HttpVerb(method), handler); err != nil {
+                return
+            }
+        }
+        if err = serverService.addPath(pathService); err != nil {
+            return
+        }
+    }
+    return
+}
+
+func (m *Mock) handler(method string, path string, operation *Operation, used map[string]bool) (handler common.HttpHandler, err error) {
+    for _, key := range []string{operation.OperationID, method + " " + path} {
+        if override, ok := m.overrides[key]; ok && len(key) > 0 {
+            used[key] = true
+            return override, nil
+        }
+    }
+
+    reply, err := m.Document.exampleReply(operation)
+    if err != nil {
+        return
+    }
+    handler = reply.handle
+    return
+}
+
+// cannedReply is the generated reply of an operation
+type cannedReply struct {
+    status  int
+    headers http.Header
+    body    []byte
+}
+
+func (cr *cannedReply) handle(request *common.HttpCall) (result *common.HttpReply, err error) {
+    result = common.NewReply(common.GetHttpStatus(cr.status)).WithHeaders(cr.headers).Bytes(cr.body)
+    return
+}
+
+// successStatus picks the response the mock replies with: the lowest 2xx, then 2XX or default
+func successStatus(responses map[string]*Response) (key string, status int) {
+    keys := make([]string, 0, len(responses))
+    for key := range responses {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    for _, candidate := range keys {
+        if code, err := strconv.Atoi(candidate); err == nil && code >= 200 && code < 300 {
+            return candidate, code
+        }
+    }
+    for _, candidate := range keys {
+        if strings.EqualFold(candidate, "2XX") || candidate == "default" {
+            return candidate, http.StatusOK
+        }
+    }
+    for _, candidate := range keys {
+        if code, err := strconv.Atoi(candidate); err == nil {
+            return candidate, code
+        }
+    }
+    return "", http.StatusOK
+}
+
+// preferredContentType picks JSON when the response offers it
+func preferredContentType(content map[string]*MediaType) string {
+    types := make([]string, 0, len(content))
+    for contentType := range content {
+        types = append(types, contentType)
+    }
+    sort.Strings(types)
+    for _, contentType := range types {
+        if isJSON(contentType) {
+            return contentType
+        }
+    }
+    if len(types) > 0 {
+        return types[0]
+    }
+    return ""
+}
+
+func isJSON(contentType string) bool {
+    mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+    return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func (doc *Document) exampleReply(operation *Operation) (reply *cannedReply, err error) {
+    key, status := successStatus(operation.Responses)
+    reply = &cannedReply{status: status, headers: make(http.Header)}
+    if len(key) == 0 {
+        return
+    }
+    response, err := doc.ResolveResponse(operation.Responses[key])
+    if err != nil || response == nil {
+        return
+    }
+
+    for name, header := range response.Headers {
+        if header, err = doc.ResolveHeader(header); err != nil {
+            return
+        }
+        value := header.Example
+        if value == nil && header.Required {
+            if value, err = doc.Sample(header.Schema); err != nil {
+                return
+            }
+        }
+        if value != nil {
+            reply.headers.Set(name, fmt.Sprint(value))
+        }
+    }
+
+    contentType := preferredContentType(response.Content)
+    if len(contentType) == 0 {
+        return
+    }
+    media := response.Content[contentType]
+    value, err := doc.mediaExample(media)
+    if err != nil {
+        return
+    }
+    if !strings.Contains(contentType, "*") {
+        reply.headers.Set("Content-Type", contentType)
+    }
+
+    if text, ok := value.(string); ok && !isJSON(contentType) {
+        reply.body = []byte(text)
+        return
+    }
+    if value != nil || isJSON(contentType) {
+        reply.body, err = json.Marshal(value)
+    }
+    return
+}
+
+// mediaExample returns the example, the first named example or a sample of the schema
+func (doc *Document) mediaExample(media *MediaType) (value interface{}, err error) {
+    if media == nil {
+        return
+    }
+    if media.Example != nil {
+        return media.Example, nil
+    }
+    if len(media.Examples) > 0 {
+        names := make([]string, 0, len(media.Examples))
+        for name := range media.Examples {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        example, resolveErr := doc.ResolveExample(media.Examples[names[0]])
+        if resolveErr != nil {
+            return nil, resolveErr
+        }
+        return example.Value, nil
+    }
+    return doc.Sample(media.Schema)
+}
+
+func unmatched(request *common.HttpCall) (result *common.HttpReply, err error) {
+    msg := fmt.Sprintf("gostackinabox: no OpenAPI path matches %s %s", request.Method, request.Url.String())
+    result = common.NewReply(common.HttpStatus_ServiceSubRouteError).Text(msg)
+    return
+}
+
+// ServerService handles a server entry, checking literal paths before templated ones
+type ServerService struct {
+    service.ServiceHandler
+
+    paths []*PathService
+}
+
+func (ss *ServerService) addPath(ps *PathService) (err error) {
+    if err = ss.RegisterHandler(ps); err != nil {
+        return
+    }
+    ss.paths = append(ss.paths, ps)
+    sort.SliceStable(
+        ss.paths,
+        func(i, j int) bool {
+            return !strings.Contains(ss.paths[i].Path, "{") && strings.Contains(ss.paths[j].Path, "{")
+        },
+    )
+    return
+}
+
+// Paths returns the path services in the order they are checked
+func (ss *ServerService) Paths() []*PathService {
+    return append([]*PathService{}, ss.paths...)
+}
+
+func (ss *ServerService) GetHandler(requestUrl url.URL) (result common.HttpHandler, err error) {
+    for _, ps := range ss.paths {
+        matchResult, matchErr := ps.Template.IsMatch(requestUrl)
+        if matchErr != nil {
+            err = matchErr
+            return
+        }
+        if matchResult {
+            if result, err = ps.GetHandler(requestUrl); err == nil {
+                result = ss.Wrap(result)
+            }
+            return
+        }
+    }
+    log.Printf("No OpenAPI path on %s handles %s", ss.GetName(), requestUrl.Path)
+    result = ss.Wrap(ss.FuncHandler)
+    return
+}
+
+// PathService handles a single path template of the document
+type PathService struct {
+    service.ServiceHandler
+
+    // Path is the template as written in the document, without the server's base path
+    Path       string
+    Template   *common.TemplateURI
+    Operations map[common.HttpVerb]*Operation
+}
+
+func (ps *PathService) GetHandler(requestUrl url.URL) (result common.HttpHandler, err error) {
+    result = ps.Wrap(func(request *common.HttpCall) (*common.HttpReply, error) {
+        request.PathParams = ps.Template.Params(*request.Url)
+        return ps.MethodHandler(request)
+    })
+    return
+}
+
+var _ service.Service = &ServerService{}
+var _ service.Service = &PathService{}
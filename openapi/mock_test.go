@@ -0,0 +1,213 @@
+package openapi_test
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/openapi"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/util"
+)
+
+func Test_OpenAPI_Mock(t *testing.T) {
+    doc, err := openapi.Parse([]byte(petstore))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    r := router.New()
+    mock := openapi.NewMock(doc).Override(
+        "getPet",
+        func(request *common.HttpCall) (*common.HttpReply, error) {
+            msg := fmt.Sprintf(`{"id": %s, "name": "custom"}`, request.PathParams["petId"])
+            return &common.HttpReply{
+                Status: 200,
+                ResponseData: util.StringToResponseBody(msg),
+                Length: int64(len(msg)),
+            }, nil
+        },
+    )
+    if err := mock.Register(r); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    client := &http.Client{Transport: r}
+
+    type TestScenario struct {
+        name        string
+        method      string
+        target      string
+        status      int
+        contentType string
+        header      [2]string
+        body        string
+    }
+
+    var TestScenarios = []TestScenario{
+        {
+            name: "schema generated sample",
+            method: "GET",
+            target: "https://eu.pets.example/v1/pets",
+            status: 200,
+            contentType: "application/json",
+            header: [2]string{"X-Total", "2"},
+            body: `[{"born":"2024-01-01","id":0,"name":"string","owner":{"email":"user@example.com","pets":[]},"tag":"string"}]`,
+        },
+        {
+            name: "named example with declared status",
+            method: "POST",
+            target: "https://eu.pets.example/v1/pets",
+            status: 201,
+            contentType: "application/json",
+            body: `{"id":7,"name":"rex"}`,
+        },
+        {
+            name: "literal path before template",
+            method: "GET",
+            target: "https://eu.pets.example/v1/pets/mine",
+            status: 200,
+            contentType: "text/plain",
+            body: "all of them",
+        },
+        {
+            name: "override with path params",
+            method: "GET",
+            target: "https://eu.pets.example/v1/pets/3",
+            status: 200,
+            body: `{"id": 3, "name": "custom"}`,
+        },
+        {
+            name: "no content",
+            method: "DELETE",
+            target: "https://eu.pets.example/v1/pets/3",
+            status: 204,
+        },
+        {
+            name: "undocumented method",
+            method: "PUT",
+            target: "https://eu.pets.example/v1/pets/3",
+            status: int(common.HttpStatus_MethodNotSupport),
+            contentType: "text/plain; charset=utf-8",
+        },
+        {
+            name: "undocumented path",
+            method: "GET",
+            target: "https://eu.pets.example/v1/owners",
+            status: int(common.HttpStatus_ServiceSubRouteError),
+            contentType: "text/plain; charset=utf-8",
+        },
+    }
+
+    for _, scenario := range TestScenarios {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                request, _ := http.NewRequest(scenario.method, scenario.target, nil)
+                response, err := client.Do(request)
+                if err != nil {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+                body, _ := ioutil.ReadAll(response.Body)
+                if response.StatusCode != scenario.status {
+                    t.Errorf("Unexpected status: %d != %d (%s)", response.StatusCode, scenario.status, body)
+                }
+                if contentType := response.Header.Get("Content-Type"); contentType != scenario.contentType {
+                    t.Errorf("Unexpected content type: %q != %q", contentType, scenario.contentType)
+                }
+                if len(scenario.header[0]) > 0 && response.Header.Get(scenario.header[0]) != scenario.header[1] {
+                    t.Errorf("Unexpected %s header: %v", scenario.header[0], response.Header)
+                }
+                if len(scenario.body) > 0 && string(body) != scenario.body {
+                    t.Errorf("Unexpected body:\n%s\n!=\n%s", body, scenario.body)
+                }
+            },
+        )
+    }
+}
+
+func Test_OpenAPI_Mock_Errors(t *testing.T) {
+    doc, err := openapi.Parse([]byte(petstore))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    err = openapi.NewMock(doc).Override("GET /missing", nil).Register(router.New())
+    if !errors.Is(err, openapi.ErrUnknownOperation) {
+        t.Errorf("Unexpected error: %v", err)
+    }
+
+    doc.Servers = nil
+    if _, err = openapi.NewMock(doc).Services(); !errors.Is(err, openapi.ErrMissingBaseURL) {
+        t.Errorf("Unexpected error: %v", err)
+    }
+
+    mock := openapi.NewMock(doc)
+    mock.BaseURL = "http://localhost:8080"
+    services, err := mock.Services()
+    if err != nil || len(services) != 1 || services[0].GetName() != "http://localhost:8080" {
+        t.Errorf("Unexpected services: %v %v", services, err)
+    }
+
+    if _, err = openapi.Parse([]byte(`{"swagger": "2.0"}`)); !errors.Is(err, openapi.ErrInvalidDocument) {
+        t.Errorf("Unexpected error: %v", err)
+    }
+}
+
+func Test_OpenAPI_Parse_JSON(t *testing.T) {
+    doc, err := openapi.Parse([]byte(`{
+  "openapi": "3.1.0",
+  "info": {"title": "t", "version": "1"},
+  "paths": {
+    "/b": {"get": {"responses": {"200": {"description": "ok"}}}},
+    "/a": {"get": {"responses": {"200": {"description": "ok"}}}}
+  },
+  "components": {"schemas": {"Name": {"type": ["string", "null"], "additionalProperties": {"type": "string"}}}}
+}`))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if order := doc.PathOrder(); len(order) != 2 || order[0] != "/b" {
+        t.Errorf("Path order not preserved: %v", order)
+    }
+    schema := doc.Components.Schemas["Name"]
+    if schema.Type.Primary() != "string" || !schema.IsNullable() || schema.AdditionalProperties.Schema == nil {
+        t.Errorf("Unexpected schema: %#v", schema)
+    }
+    encoded, _ := json.Marshal(schema)
+    if string(encoded) != `{"type":["string","null"],"additionalProperties":{"type":"string"}}` {
+        t.Errorf("Unexpected encoding: %s", encoded)
+    }
+}
+
+func Test_OpenAPI_ResolveRefs(t *testing.T) {
+    doc := &openapi.Document{
+        Components: &openapi.Components{
+            Parameters: map[string]*openapi.Parameter{
+                "a": {Ref: "#/components/parameters/b"},
+                "b": {Ref: "#/components/parameters/a"},
+                "limit": {Name: "limit", In: "query"},
+                "alias": {Ref: "#/components/parameters/limit"},
+            },
+            Examples: map[string]*openapi.Example{
+                "self": {Ref: "#/components/examples/self"},
+            },
+        },
+    }
+
+    if parameter, err := doc.ResolveParameter(&openapi.Parameter{Ref: "#/components/parameters/alias"}); err != nil || parameter.Name != "limit" {
+        t.Errorf("Unexpected resolution: %v %v", parameter, err)
+    }
+    if _, err := doc.ResolveParameter(&openapi.Parameter{Ref: "#/components/parameters/a"}); !errors.Is(err, openapi.ErrUnresolvedRef) {
+        t.Errorf("Cycle not detected: %v", err)
+    }
+    if _, err := doc.ResolveExample(&openapi.Example{Ref: "#/components/examples/self"}); !errors.Is(err, openapi.ErrUnresolvedRef) {
+        t.Errorf("Cycle not detected: %v", err)
+    }
+    if _, err := doc.ResolveHeader(&openapi.Header{Ref: "#/components/headers/missing"}); !errors.Is(err, openapi.ErrUnresolvedRef) {
+        t.Errorf("Missing reference not reported: %v", err)
+    }
+}
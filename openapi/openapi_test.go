@@ -0,0 +1,123 @@
+package openapi_test
+
+const petstore = `
+openapi: 3.0.3
+info:
+  title: Pets
+  version: 1.0
+servers:
+  - url: https://{region}.pets.example/v1
+    variables:
+      region:
+        default: eu
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - name: limit
+          in: query
+          schema: {type: integer, minimum: 1, maximum: 100}
+      responses:
+        '200':
+          description: pets
+          headers:
+            X-Total:
+              required: true
+              schema: {type: integer, minimum: 2}
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Pet'}
+    post:
+      operationId: createPet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/NewPet'}
+      responses:
+        '201':
+          description: created
+          content:
+            application/json:
+              examples:
+                rex:
+                  value: {id: 7, name: rex}
+        '400':
+          $ref: '#/components/responses/Error'
+  /pets/mine:
+    get:
+      responses:
+        '200':
+          description: mine
+          content:
+            text/plain:
+              example: all of them
+  /pets/{petId}:
+    parameters:
+      - $ref: '#/components/parameters/PetId'
+    get:
+      operationId: getPet
+      responses:
+        '200':
+          description: a pet
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+        default:
+          $ref: '#/components/responses/Error'
+    delete:
+      responses:
+        '204':
+          description: deleted
+components:
+  parameters:
+    PetId:
+      name: petId
+      in: path
+      required: true
+      schema: {type: integer}
+  responses:
+    Error:
+      description: error
+      content:
+        application/json:
+          schema:
+            type: object
+            required: [message]
+            properties:
+              message: {type: string}
+  schemas:
+    NewPet:
+      type: object
+      required: [name]
+      additionalProperties: false
+      properties:
+        name: {type: string, minLength: 1}
+        tag: {type: string, nullable: true}
+    PetBase:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        tag: {type: string, nullable: true}
+    Pet:
+      allOf:
+        - $ref: '#/components/schemas/PetBase'
+        - type: object
+          required: [id]
+          properties:
+            id: {type: integer, format: int64, readOnly: true}
+            born: {type: string, format: date}
+            owner: {$ref: '#/components/schemas/Owner'}
+    Owner:
+      type: object
+      properties:
+        email: {type: string, format: email}
+        secret: {type: string, writeOnly: true}
+        pets:
+          type: array
+          items: {$ref: '#/components/schemas/Pet'}
+`
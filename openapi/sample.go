@@ -0,0 +1,178 @@
+package openapi
+
+import (
+    "errors"
+    "math"
+    "sort"
+    "strings"
+)
+
+// errRecursive stops a schema that refers to itself from generating forever
+var errRecursive error = errors.New("OpenAPI: Recursive schema")
+
+var sampleFormats = map[string]string{
+    "date-time": "2024-01-01T00:00:00Z",
+    "date": "2024-01-01",
+    "time": "00:00:00Z",
+    "uuid": "00000000-0000-4000-8000-000000000000",
+    "email": "user@example.com",
+    "uri": "https://example.com",
+    "url": "https://example.com",
+    "hostname": "example.com",
+    "ipv4": "192.0.2.1",
+    "ipv6": "2001:db8::1",
+    "byte": "c2FtcGxl",
+    "password": "password",
+}
+
+/*
+    Sample generates a value that satisfies the schema, preferring the
+    schema's own example, default or first enum value. Objects include every
+    property that isn't write-only, arrays hold minItems (at least one) items
+    and numbers respect the minimum and maximum. A schema that refers back to
+    itself ends with an empty array or by leaving out the optional property.
+ */
+func (doc *Document) Sample(schema *Schema) (value interface{}, err error) {
+    value, err = doc.sample(schema, nil)
+    if errors.Is(err, errRecursive) {
+        value, err = nil, nil
+    }
+    return
+}
+
+// sample tracks the references being expanded so recursion can be detected
+func (doc *Document) sample(schema *Schema, refs []string) (value interface{}, err error) {
+    if schema != nil && len(schema.Ref) > 0 {
+        for _, ref := range refs {
+            if ref == schema.Ref {
+                err = errRecursive
+                return
+            }
+        }
+        refs = append(append([]string{}, refs...), schema.Ref)
+    }
+    if schema, err = doc.ResolveSchema(schema); err != nil || schema == nil {
+        return
+    }
+
+    switch {
+    case schema.Example != nil:
+        return schema.Example, nil
+    case schema.Default != nil:
+        return schema.Default, nil
+    case len(schema.Enum) > 0:
+        return schema.Enum[0], nil
+    case len(schema.AllOf) > 0:
+        merged := make(map[string]interface{})
+        for _, part := range schema.AllOf {
+            partValue, partErr := doc.sample(part, refs)
+            if partErr != nil {
+                return nil, partErr
+            }
+            object, ok := partValue.(map[string]interface{})
+            if !ok {
+                return partValue, nil
+            }
+            for key, propertyValue := range object {
+                merged[key] = propertyValue
+            }
+        }
+        return merged, nil
+    case len(schema.OneOf) > 0:
+        return doc.sample(schema.OneOf[0], refs)
+    case len(schema.AnyOf) > 0:
+        return doc.sample(schema.AnyOf[0], refs)
+    }
+
+    switch schema.Type.Primary() {
+    case "object":
+        return doc.sampleObject(schema, refs)
+    case "array":
+        count := 1
+        if schema.MinItems != nil && *schema.MinItems > count {
+            count = *schema.MinItems
+        }
+        items := make([]interface{}, 0, count)
+        for index := 0; index < count; index++ {
+            item, itemErr := doc.sample(schema.Items, refs)
+            if errors.Is(itemErr, errRecursive) && schema.MinItems == nil {
+                return []interface{}{}, nil
+            }
+            if itemErr != nil {
+                return nil, itemErr
+            }
+            items = append(items, item)
+        }
+        return items, nil
+    case "string":
+        text, ok := sampleFormats[schema.Format]
+        if !ok {
+            text = "string"
+        }
+        if schema.MinLength != nil && len(text) < *schema.MinLength {
+            text += strings.Repeat("x", *schema.MinLength-len(text))
+        }
+        if schema.MaxLength != nil && len(text) > *schema.MaxLength {
+            text = text[:*schema.MaxLength]
+        }
+        return text, nil
+    case "integer":
+        return math.Ceil(sampleNumber(schema)), nil
+    case "number":
+        return sampleNumber(schema), nil
+    case "boolean":
+        return true, nil
+    case "":
+        if len(schema.Properties) > 0 {
+            return doc.sampleObject(schema, refs)
+        }
+    }
+    return
+}
+
+func (doc *Document) sampleObject(schema *Schema, refs []string) (value interface{}, err error) {
+    names := make([]string, 0, len(schema.Properties))
+    for name := range schema.Properties {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    object := make(map[string]interface{}, len(names))
+    for _, name := range names {
+        property, resolveErr := doc.ResolveSchema(schema.Properties[name])
+        if resolveErr != nil {
+            return nil, resolveErr
+        }
+        if property.WriteOnly {
+            continue
+        }
+        propertyValue, propertyErr := doc.sample(schema.Properties[name], refs)
+        if errors.Is(propertyErr, errRecursive) && !isRequired(schema, name) {
+            continue
+        }
+        if propertyErr != nil {
+            return nil, propertyErr
+        }
+        object[name] = propertyValue
+    }
+    return object, nil
+}
+
+func sampleNumber(schema *Schema) float64 {
+    switch {
+    case schema.Minimum != nil:
+        return *schema.Minimum
+    case schema.Maximum != nil && *schema.Maximum < 0:
+        return *schema.Maximum
+    }
+    return 0
+}
+
+func isRequired(schema *Schema, name string) bool {
+    for _, required := range schema.Required {
+        if required == name {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,104 @@
+package openapi
+
+import (
+    "bytes"
+    "encoding/json"
+)
+
+type Schema struct {
+    Ref                  string                 `json:"$ref,omitempty"`
+    Type                 SchemaType             `json:"type,omitempty"`
+    Format               string                 `json:"format,omitempty"`
+    Title                string                 `json:"title,omitempty"`
+    Description          string                 `json:"description,omitempty"`
+    Nullable             bool                   `json:"nullable,omitempty"`
+    Enum                 []interface{}          `json:"enum,omitempty"`
+    Default              interface{}            `json:"default,omitempty"`
+    Example              interface{}            `json:"example,omitempty"`
+    Properties           map[string]*Schema     `json:"properties,omitempty"`
+    Required             []string               `json:"required,omitempty"`
+    AdditionalProperties *AdditionalProperties  `json:"additionalProperties,omitempty"`
+    Items                *Schema                `json:"items,omitempty"`
+    AllOf                []*Schema              `json:"allOf,omitempty"`
+    OneOf                []*Schema              `json:"oneOf,omitempty"`
+    AnyOf                []*Schema              `json:"anyOf,omitempty"`
+    Not                  *Schema                `json:"not,omitempty"`
+    Minimum              *float64               `json:"minimum,omitempty"`
+    Maximum              *float64               `json:"maximum,omitempty"`
+    MinLength            *int                   `json:"minLength,omitempty"`
+    MaxLength            *int                   `json:"maxLength,omitempty"`
+    Pattern              string                 `json:"pattern,omitempty"`
+    MinItems             *int                   `json:"minItems,omitempty"`
+    MaxItems             *int                   `json:"maxItems,omitempty"`
+    ReadOnly             bool                   `json:"readOnly,omitempty"`
+    WriteOnly            bool                   `json:"writeOnly,omitempty"`
+}
+
+// IsNullable covers both the 3.0 `nullable` keyword and a 3.1 `null` type
+func (s *Schema) IsNullable() bool {
+    return s.Nullable || s.Type.Is("null")
+}
+
+// SchemaType is a single type in OpenAPI 3.0 and may be a list of types in 3.1
+type SchemaType []string
+
+func (st *SchemaType) UnmarshalJSON(data []byte) (err error) {
+    var single string
+    if err = json.Unmarshal(data, &single); err == nil {
+        *st = SchemaType{single}
+        return
+    }
+    var multiple []string
+    if err = json.Unmarshal(data, &multiple); err == nil {
+        *st = SchemaType(multiple)
+    }
+    return
+}
+
+func (st SchemaType) MarshalJSON() ([]byte, error) {
+    if len(st) == 1 {
+        return json.Marshal(st[0])
+    }
+    return json.Marshal([]string(st))
+}
+
+func (st SchemaType) Is(name string) bool {
+    for _, value := range st {
+        if value == name {
+            return true
+        }
+    }
+    return false
+}
+
+// Primary returns the first type that isn't `null`; empty when the schema has no type
+func (st SchemaType) Primary() string {
+    for _, value := range st {
+        if value != "null" {
+            return value
+        }
+    }
+    return ""
+}
+
+// AdditionalProperties is either a boolean or a schema the extra properties must satisfy
+type AdditionalProperties struct {
+    Allowed bool
+    Schema  *Schema
+}
+
+func (ap *AdditionalProperties) UnmarshalJSON(data []byte) (err error) {
+    if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '{' {
+        return json.Unmarshal(data, &ap.Allowed)
+    }
+    ap.Allowed = true
+    ap.Schema = &Schema{}
+    return json.Unmarshal(data, ap.Schema)
+}
+
+func (ap AdditionalProperties) MarshalJSON() ([]byte, error) {
+    if ap.Schema != nil {
+        return json.Marshal(ap.Schema)
+    }
+    return json.Marshal(ap.Allowed)
+}
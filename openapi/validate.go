@@ -0,0 +1,418 @@
+package openapi
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "mime"
+    "net/http"
+    "net/url"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    Validator checks traffic against an OpenAPI document: the path, query,
+    header and cookie parameters and the JSON body of every request, and the
+    status, headers and JSON body of every reply the handlers produce. This
+    catches clients sending malformed requests and fakes drifting away from
+    the real API. Install it on the router so it wraps every service:
+
+        validator := openapi.NewValidator(doc, t)
+        r.Use(validator.Middleware)
+
+    Requests for hosts that aren't servers of the document pass through
+    unchecked, as do replies using the reserved 595-597 statuses.
+ */
+
+type ValidationMode int
+
+const (
+    // report violations to the Reporter (or the log) and let the traffic through unchanged
+    ValidationReport ValidationMode = iota
+    // reply 400 to invalid requests and 596 in place of invalid replies
+    ValidationReject
+)
+
+type Validator struct {
+    Document *Document
+    // BaseURL is used for relative server URLs and when the document has no servers
+    BaseURL  string
+    Mode     ValidationMode
+    Reporter common.TestReporter
+
+    once   sync.Once
+    routes []*route
+    err    error
+}
+
+func NewValidator(doc *Document, reporter common.TestReporter) *Validator {
+    return &Validator{
+        Document: doc,
+        Reporter: reporter,
+    }
+}
+
+type route struct {
+    server   *common.BasicServerURI
+    template *common.TemplateURI
+    path     string
+    item     *PathItem
+}
+
+func (v *Validator) compile() {
+    bases, err := v.Document.ServerURLs(v.BaseURL)
+    if err != nil {
+        v.err = err
+        return
+    }
+    for _, base := range bases {
+        server := &common.BasicServerURI{Protocol: base.Scheme, Host: base.Hostname(), Port: base.Port()}
+        prefix := strings.TrimRight(base.Path, "/")
+        literal, templated := []*route{}, []*route{}
+        for _, path := range v.Document.PathOrder() {
+            template, templateErr := common.NewTemplateURI(prefix + path)
+            if templateErr != nil {
+                v.err = templateErr
+                return
+            }
+            r := &route{server: server, template: template, path: path, item: v.Document.Paths[path]}
+            if strings.Contains(path, "{") {
+                templated = append(templated, r)
+            } else {
+                literal = append(literal, r)
+            }
+        }
+        v.routes = append(v.routes, literal...)
+        v.routes = append(v.routes, templated...)
+    }
+}
+
+// find returns the route of the URL; documented is false when the host isn't a server of the document
+func (v *Validator) find(u url.URL) (found *route, documented bool, err error) {
+    v.once.Do(v.compile)
+    if err = v.err; err != nil {
+        return
+    }
+    for _, r := range v.routes {
+        if serverMatch, _ := r.server.IsMatch(u); !serverMatch {
+            continue
+        }
+        documented = true
+        if pathMatch, _ := r.template.IsMatch(u); pathMatch {
+            found = r
+            return
+        }
+    }
+    return
+}
+
+// Middleware validates each request before it is handled and each reply afterwards; use it with Router.Use
+func (v *Validator) Middleware(next common.HttpHandler) common.HttpHandler {
+    return func(request *common.HttpCall) (result *common.HttpReply, err error) {
+        violations, err := v.ValidateRequest(request)
+        if err != nil {
+            return
+        }
+        if len(violations) > 0 {
+            if v.Mode == ValidationReject {
+                return rejection(400, "request", violations), nil
+            }
+            v.report(request, violations)
+        }
+
+        result, err = next(request)
+        if err != nil || result == nil {
+            return
+        }
+
+        violations, err = v.ValidateReply(request, result)
+        if err != nil {
+            return
+        }
+        if len(violations) > 0 {
+            if v.Mode == ValidationReject {
+                return rejection(int(common.HttpStatus_ServiceError), "reply", violations), nil
+            }
+            v.report(request, violations)
+        }
+        return
+    }
+}
+
+func (v *Validator) report(request *common.HttpCall, violations []Violation) {
+    for _, violation := range violations {
+        if v.Reporter != nil {
+            v.Reporter.Errorf("openapi: %s %s: %s", request.Method, request.Url.Path, violation)
+        } else {
+            log.Printf("OpenAPI violation: %s %s: %s", request.Method, request.Url.Path, violation)
+        }
+    }
+}
+
+func rejection(status int, kind string, violations []Violation) *common.HttpReply {
+    lines := make([]string, 0, len(violations)+1)
+    lines = append(lines, fmt.Sprintf("gostackinabox: the %s does not match the OpenAPI document:", kind))
+    for _, violation := range violations {
+        lines = append(lines, "  "+violation.String())
+    }
+    msg := strings.Join(lines, "\n")
+    return common.NewReply(common.GetHttpStatus(status)).Text(msg)
+}
+
+// ValidateRequest checks the request against its documented operation
+func (v *Validator) ValidateRequest(request *common.HttpCall) (violations []Violation, err error) {
+    found, documented, err := v.find(*request.Url)
+    if err != nil || !documented {
+        return
+    }
+    c := &checker{doc: v.Document, direction: directionRequest}
+    defer func() { violations = c.violations }()
+
+    if found == nil {
+        c.fail("request.path", "%s is not documented", request.Url.Path)
+        return
+    }
+    operation := found.item.Operations()[common.HttpVerb(strings.ToUpper(string(request.Method)))]
+    if operation == nil {
+        c.fail("request.method", "%s is not documented for %s", request.Method, found.path)
+        return
+    }
+
+    parameters, paramErr := v.parameters(found.item, operation)
+    if paramErr != nil {
+        err = paramErr
+        return
+    }
+    pathParams := found.template.Params(*request.Url)
+    for _, parameter := range parameters {
+        var values []string
+        switch parameter.In {
+        case "path":
+            if value, ok := pathParams[parameter.Name]; ok {
+                values = []string{value}
+            }
+        case "query":
+            values = request.Url.Query()[parameter.Name]
+        case "header":
+            values = request.Headers.Values(parameter.Name)
+        case "cookie":
+            if request.Request != nil {
+                if cookie, cookieErr := request.Request.Cookie(parameter.Name); cookieErr == nil {
+                    values = []string{cookie.Value}
+                }
+            }
+        }
+
+        location := "request." + parameter.In + "." + parameter.Name
+        if len(values) == 0 {
+            if parameter.Required || parameter.In == "path" {
+                c.fail(location, "is required")
+            }
+            continue
+        }
+        c.value(parameter.Schema, v.Document.coerce(parameter.Schema, values), location)
+    }
+
+    body, bodyErr := v.Document.ResolveRequestBody(operation.RequestBody)
+    if bodyErr != nil {
+        err = bodyErr
+        return
+    }
+    data, readErr := request.Bytes()
+    if readErr != nil {
+        c.fail("request.body", "is unreadable: %v", readErr)
+        return
+    }
+    switch {
+    case body == nil && len(data) > 0:
+        c.fail("request.body", "no request body is documented")
+    case body == nil:
+    case len(data) == 0:
+        if body.Required {
+            c.fail("request.body", "is required")
+        }
+    default:
+        c.content(body.Content, request.Headers.Get("Content-Type"), data, "request")
+    }
+    return
+}
+
+// parameters merges the path item's parameters with those of the operation, which take precedence
+func (v *Validator) parameters(item *PathItem, operation *Operation) (parameters []*Parameter, err error) {
+    index := make(map[string]int)
+    for _, parameter := range append(append([]*Parameter{}, item.Parameters...), operation.Parameters...) {
+        if parameter, err = v.Document.ResolveParameter(parameter); err != nil {
+            return
+        }
+        key := parameter.In + ":" + strings.ToLower(parameter.Name)
+        if existing, ok := index[key]; ok {
+            parameters[existing] = parameter
+            continue
+        }
+        index[key] = len(parameters)
+        parameters = append(parameters, parameter)
+    }
+    return
+}
+
+// ValidateReply checks the reply against the responses of the request's documented operation
+func (v *Validator) ValidateReply(request *common.HttpCall, reply *common.HttpReply) (violations []Violation, err error) {
+    status := int(reply.Status)
+    if status >= int(common.HttpStatus_RouteNotHandled) && status <= int(common.HttpStatus_ServiceSubRouteError) {
+        return
+    }
+    // the body of a 101 reply is the upgraded connection, not a documented response
+    if status == http.StatusSwitchingProtocols {
+        return
+    }
+    found, _, err := v.find(*request.Url)
+    if err != nil || found == nil {
+        return
+    }
+    operation := found.item.Operations()[common.HttpVerb(strings.ToUpper(string(request.Method)))]
+    if operation == nil {
+        return
+    }
+
+    c := &checker{doc: v.Document, direction: directionResponse}
+    defer func() { violations = c.violations }()
+
+    response, responseErr := v.Document.ResolveResponse(documentedResponse(operation.Responses, status))
+    if responseErr != nil {
+        err = responseErr
+        return
+    }
+    if response == nil {
+        keys := make([]string, 0, len(operation.Responses))
+        for key := range operation.Responses {
+            keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        c.fail("response.status", "%d is not documented (expected one of %s)", status, strings.Join(keys, ", "))
+        return
+    }
+
+    headers := reply.Headers
+    if headers == nil {
+        headers = make(map[string][]string)
+    }
+    for name, header := range response.Headers {
+        if strings.EqualFold(name, "Content-Type") {
+            continue
+        }
+        if header, err = v.Document.ResolveHeader(header); err != nil {
+            return
+        }
+        values := headers.Values(name)
+        if len(values) == 0 {
+            if header.Required {
+                c.fail("response.headers."+name, "is required")
+            }
+            continue
+        }
+        c.value(header.Schema, v.Document.coerce(header.Schema, values), "response.headers."+name)
+    }
+
+    contentType := headers.Get("Content-Type")
+    if reply.ResponseData == nil || reply.Length == 0 {
+        return
+    }
+    if len(response.Content) == 0 {
+        c.fail("response.body", "no content is documented for %d", status)
+        return
+    }
+    // streams and non-JSON bodies are passed through untouched; only their Content-Type is checked
+    if reply.Length < 0 || !isJSON(contentType) {
+        c.media(response.Content, contentType, "response")
+        return
+    }
+
+    var data []byte
+    if data, err = ioutil.ReadAll(reply.ResponseData); err != nil {
+        return
+    }
+    reply.ResponseData.Close()
+    reply.ResponseData = ioutil.NopCloser(bytes.NewReader(data))
+    if len(data) > 0 {
+        c.content(response.Content, contentType, data, "response")
+    }
+    return
+}
+
+// documentedResponse finds the response for the exact status, then its class (e.g 4XX), then the default
+func documentedResponse(responses map[string]*Response, status int) *Response {
+    code := strconv.Itoa(status)
+    if response, ok := responses[code]; ok {
+        return response
+    }
+    for key, response := range responses {
+        if strings.EqualFold(key, code[:1]+"XX") {
+            return response
+        }
+    }
+    return responses["default"]
+}
+
+// content checks the body is a documented content type and, for JSON, matches the schema
+func (c *checker) content(content map[string]*MediaType, contentType string, data []byte, kind string) {
+    media, ok := c.media(content, contentType, kind)
+    if !ok {
+        return
+    }
+    if media == nil || media.Schema == nil || !isJSON(contentType) {
+        return
+    }
+    var document interface{}
+    if err := json.Unmarshal(data, &document); err != nil {
+        c.fail(kind+".body", "is not valid JSON: %v", err)
+        return
+    }
+    c.value(media.Schema, document, kind+".body")
+}
+
+// media finds the documented media type for the Content-Type, failing the check if there isn't one
+func (c *checker) media(content map[string]*MediaType, contentType string, kind string) (media *MediaType, ok bool) {
+    if media, ok = findMedia(content, contentType); ok {
+        return
+    }
+    types := make([]string, 0, len(content))
+    for documented := range content {
+        types = append(types, documented)
+    }
+    sort.Strings(types)
+    c.fail(kind+".headers.Content-Type", "%q is not documented (expected one of %s)", contentType, strings.Join(types, ", "))
+    return
+}
+
+// findMedia matches the content type exactly, then by its `type/*` range, then `*/*`
+func findMedia(content map[string]*MediaType, contentType string) (media *MediaType, ok bool) {
+    mediaType, _, err := mime.ParseMediaType(contentType)
+    if err != nil {
+        mediaType = strings.ToLower(strings.TrimSpace(contentType))
+    }
+    candidates := []string{mediaType}
+    if slash := strings.Index(mediaType, "/"); slash > 0 {
+        candidates = append(candidates, mediaType[:slash]+"/*")
+    }
+    candidates = append(candidates, "*/*")
+
+    for _, candidate := range candidates {
+        for documented, value := range content {
+            documentedType, _, parseErr := mime.ParseMediaType(documented)
+            if parseErr != nil {
+                documentedType = strings.ToLower(documented)
+            }
+            if documentedType == candidate {
+                return value, true
+            }
+        }
+    }
+    return
+}
@@ -0,0 +1,225 @@
+package openapi_test
+
+import (
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/openapi"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+    "github.com/TestInABox/gostackinabox/util"
+)
+
+type recordingReporter struct {
+    messages []string
+}
+
+func (rr *recordingReporter) Errorf(format string, args ...interface{}) {
+    rr.messages = append(rr.messages, fmt.Sprintf(format, args...))
+}
+
+func newValidatedRouter(t *testing.T, mode openapi.ValidationMode) (*http.Client, *recordingReporter) {
+    doc, err := openapi.Parse([]byte(petstore))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    r := router.New()
+    mock := openapi.NewMock(doc).Override(
+        "getPet",
+        func(request *common.HttpCall) (*common.HttpReply, error) {
+            // drifted from the document: the name is a number and the id is missing
+            msg := `{"name": 5}`
+            return &common.HttpReply{
+                Status: 200,
+                Headers: http.Header{"Content-Type": {"application/json"}},
+                ResponseData: util.StringToResponseBody(msg),
+                Length: int64(len(msg)),
+            }, nil
+        },
+    )
+    if err := mock.Register(r); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    stub.New(r).On("GET", "https://other.example/anything").Reply(200).Body("not checked")
+
+    reporter := &recordingReporter{}
+    validator := openapi.NewValidator(doc, reporter)
+    validator.Mode = mode
+    r.Use(validator.Middleware)
+    return &http.Client{Transport: r}, reporter
+}
+
+func Test_OpenAPI_Validator(t *testing.T) {
+    type TestScenario struct {
+        name        string
+        method      string
+        target      string
+        contentType string
+        body        string
+        violations  []string
+    }
+
+    var TestScenarios = []TestScenario{
+        {
+            name: "valid request and generated reply",
+            method: "GET",
+            target: "https://eu.pets.example/v1/pets?limit=10",
+        },
+        {
+            name: "query parameter out of range",
+            method: "GET",
+            target: "https://eu.pets.example/v1/pets?limit=0",
+            violations: []string{"request.query.limit: 0 is less than the minimum 1"},
+        },
+        {
+            name: "path parameter type",
+            method: "DELETE",
+            target: "https://eu.pets.example/v1/pets/abc",
+            violations: []string{"request.path.petId: expected integer but found string"},
+        },
+        {
+            name: "valid body",
+            method: "POST",
+            target: "https://eu.pets.example/v1/pets",
+            contentType: "application/json; charset=utf-8",
+            body: `{"name": "rex", "tag": null}`,
+        },
+        {
+            name: "body schema",
+            method: "POST",
+            target: "https://eu.pets.example/v1/pets",
+            contentType: "application/json",
+            body: `{"name": "", "color": "red"}`,
+            violations: []string{
+                "request.body.color: is not a documented property",
+                "request.body.name: is 0 characters but needs at least 1",
+            },
+        },
+        {
+            name: "missing body",
+            method: "POST",
+            target: "https://eu.pets.example/v1/pets",
+            violations: []string{"request.body: is required"},
+        },
+        {
+            name: "undocumented content type",
+            method: "POST",
+            target: "https://eu.pets.example/v1/pets",
+            contentType: "text/plain",
+            body: "rex",
+            violations: []string{"request.headers.Content-Type: \"text/plain\" is not documented"},
+        },
+        {
+            name: "undocumented method",
+            method: "PUT",
+            target: "https://eu.pets.example/v1/pets/3",
+            violations: []string{"request.method: PUT is not documented for /pets/{petId}"},
+        },
+        {
+            name: "drifted reply",
+            method: "GET",
+            target: "https://eu.pets.example/v1/pets/3",
+            violations: []string{
+                "response.body.id: is required",
+                "response.body.name: expected string but found integer",
+            },
+        },
+        {
+            name: "other hosts are not checked",
+            method: "GET",
+            target: "https://other.example/anything",
+        },
+    }
+
+    for _, scenario := range TestScenarios {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                client, reporter := newValidatedRouter(t, openapi.ValidationReport)
+                request, _ := http.NewRequest(scenario.method, scenario.target, strings.NewReader(scenario.body))
+                if len(scenario.contentType) > 0 {
+                    request.Header.Set("Content-Type", scenario.contentType)
+                }
+                if _, err := client.Do(request); err != nil {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+
+                if len(reporter.messages) != len(scenario.violations) {
+                    t.Errorf("Unexpected violations:\n%s", strings.Join(reporter.messages, "\n"))
+                }
+                for _, expected := range scenario.violations {
+                    found := false
+                    for _, message := range reporter.messages {
+                        found = found || strings.Contains(message, expected)
+                    }
+                    if !found {
+                        t.Errorf("Missing violation %q in:\n%s", expected, strings.Join(reporter.messages, "\n"))
+                    }
+                }
+            },
+        )
+    }
+}
+
+func Test_OpenAPI_Validator_Reject(t *testing.T) {
+    client, reporter := newValidatedRouter(t, openapi.ValidationReject)
+
+    response, err := client.Get("https://eu.pets.example/v1/pets?limit=500")
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    body, _ := ioutil.ReadAll(response.Body)
+    if response.StatusCode != 400 || !strings.Contains(string(body), "request.query.limit: 500 is greater than the maximum 100") {
+        t.Errorf("Invalid request was not rejected: %d %s", response.StatusCode, body)
+    }
+
+    response, err = client.Get("https://eu.pets.example/v1/pets/3")
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    body, _ = ioutil.ReadAll(response.Body)
+    if response.StatusCode != int(common.HttpStatus_ServiceError) || !strings.Contains(string(body), "response.body.id: is required") {
+        t.Errorf("Invalid reply was not replaced: %d %s", response.StatusCode, body)
+    }
+
+    if len(reporter.messages) != 0 {
+        t.Errorf("Reject mode unexpectedly reported: %v", reporter.messages)
+    }
+}
+
+func Test_OpenAPI_Validator_Streams(t *testing.T) {
+    doc, err := openapi.Parse([]byte(petstore))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    validator := openapi.NewValidator(doc, &recordingReporter{})
+
+    request, _ := http.NewRequest("GET", "https://eu.pets.example/v1/pets/3", nil)
+    call := &common.HttpCall{
+        Method: common.HttpVerb_Get,
+        Url: request.URL,
+        Headers: request.Header,
+        Request: request,
+    }
+
+    // a stream that never ends must not be read
+    reader, writer := io.Pipe()
+    defer writer.Close()
+    reply := common.NewReply(200).WithContentType("application/json").Stream(reader, -1)
+    violations, err := validator.ValidateReply(call, reply)
+    if err != nil || len(violations) != 0 {
+        t.Errorf("Unexpected result: %v %v", violations, err)
+    }
+
+    reply = common.NewReply(200).WithContentType("text/event-stream").Stream(reader, -1)
+    violations, err = validator.ValidateReply(call, reply)
+    if err != nil || len(violations) != 1 || !strings.Contains(violations[0].Location, "Content-Type") {
+        t.Errorf("Undocumented stream Content-Type not reported: %v %v", violations, err)
+    }
+}
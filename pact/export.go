@@ -0,0 +1,262 @@
+package pact
+
+import (
+    "encoding/json"
+    "fmt"
+    "mime"
+    "net/http"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/redact"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+/*
+    A contract can be exported from the traffic a Recorder captured while the
+    consumer's tests ran, or from the stubs the tests registered:
+
+        contract := pact.FromExchanges("web", "users-api", recorder.Exchanges())
+        err := contract.WriteFile("pacts/web-users-api.json")
+
+    Redaction placeholders survive into the examples and each one gets a regex
+    matching rule so the provider is only held to the values the consumer
+    actually relies on. Path parameters of stubs become placeholders as well.
+ */
+
+// ignoredHeaders describe the framing of the recorded message rather than the contract
+var ignoredHeaders = map[string]bool{
+    "Content-Length": true,
+    "Transfer-Encoding": true,
+    "Content-Encoding": true,
+}
+
+// FromExchanges converts the router's recorded traffic into a contract; exchanges that failed at
+// the transport level or weren't handled (595-597) are skipped
+func FromExchanges(consumer string, provider string, exchanges []router.Exchange) *Pact {
+    p := New(consumer, provider)
+    for _, exchange := range exchanges {
+        status := common.HttpStatusCode(exchange.Status)
+        if exchange.Err != nil || exchange.URL == nil || exchange.Status == 0 ||
+            status == common.HttpStatus_RouteNotHandled ||
+            status == common.HttpStatus_ServiceError ||
+            status == common.HttpStatus_ServiceSubRouteError {
+            continue
+        }
+
+        rules := &MatchingRules{}
+        request := Request{
+            Method: exchange.Method,
+            Path: exchange.URL.Path,
+            Headers: contractHeaders(exchange.RequestHeaders, rules),
+            Body: contractBody(exchange.RequestHeaders.Get("Content-Type"), exchange.RequestBody, rules),
+        }
+        if len(request.Path) == 0 {
+            request.Path = "/"
+        }
+        if redact.HasPlaceholder(request.Path) {
+            rules.Path = regexRule(redact.MatchPattern(request.Path))
+        }
+        if query := exchange.URL.Query(); len(query) > 0 {
+            request.Query = query
+            for name, values := range query {
+                for _, value := range values {
+                    if redact.HasPlaceholder(value) {
+                        setRule(&rules.Query, name, regexRule(redact.MatchPattern(value)))
+                    }
+                }
+            }
+        }
+        request.MatchingRules = rules.orNil()
+
+        responseRules := &MatchingRules{}
+        response := Response{
+            Status: exchange.Status,
+            Headers: contractHeaders(exchange.ResponseHeaders, responseRules),
+            Body: contractBody(exchange.ResponseHeaders.Get("Content-Type"), exchange.ResponseBody, responseRules),
+            MatchingRules: responseRules.orNil(),
+        }
+
+        p.add(Interaction{Request: request, Response: response})
+    }
+    return p
+}
+
+// FromStubs converts the stubs' expectations into a contract. Stubs whose path is a pattern or whose
+// reply comes from a handler can't be described; they are reported in the error while the contract
+// holds the remaining interactions. Predicates that can't be described (e.g added with When) are
+// left out of the contract.
+func FromStubs(consumer string, provider string, stubs *stub.Registry) (p *Pact, err error) {
+    p = New(consumer, provider)
+    skipped := []string{}
+    for _, s := range stubs.Stubs() {
+        expected := s.Expectation()
+        if len(expected.Pattern) > 0 || expected.Handler || expected.Method == stub.MethodAny {
+            skipped = append(skipped, fmt.Sprintf("%s %s%s", expected.Method, expected.URL, expected.Pattern))
+            continue
+        }
+        if expected.Opaque > 0 {
+            log.Printf("Exporting %s %s without %d predicate(s) that can't be described", expected.Method, expected.URL, expected.Opaque)
+        }
+
+        rules := &MatchingRules{}
+        request := Request{
+            Method: expected.Method,
+            Path: stubPath(expected.URL, rules),
+            Headers: contractHeaders(expected.Headers, rules),
+        }
+        if len(expected.Query) > 0 {
+            request.Query = expected.Query
+        }
+        switch {
+        case expected.JSONBody != nil:
+            request.Body, _ = json.Marshal(expected.JSONBody)
+        case expected.Body != nil:
+            request.Body = contractBody("", expected.Body, rules)
+        }
+        request.MatchingRules = rules.orNil()
+
+        responseRules := &MatchingRules{}
+        response := Response{
+            Status: expected.Status,
+            Headers: contractHeaders(expected.ReplyHeaders, responseRules),
+            Body: contractBody(expected.ReplyHeaders.Get("Content-Type"), expected.ReplyBody, responseRules),
+            MatchingRules: responseRules.orNil(),
+        }
+
+        p.add(Interaction{Request: request, Response: response})
+    }
+    if len(skipped) > 0 {
+        err = fmt.Errorf("%w: unable to describe stubs: %s", ErrInvalidInteraction, strings.Join(skipped, ", "))
+    }
+    return
+}
+
+// add appends the interaction, giving it a description unique within the contract
+func (p *Pact) add(interaction Interaction) {
+    base := fmt.Sprintf("%s %s returns %d", interaction.Request.Method, interaction.Request.Path, interaction.Response.Status)
+    interaction.Description = base
+    for count := 2; p.hasDescription(interaction.Description); count++ {
+        interaction.Description = fmt.Sprintf("%s (%d)", base, count)
+    }
+    p.Interactions = append(p.Interactions, interaction)
+}
+
+func (p *Pact) hasDescription(description string) bool {
+    for _, interaction := range p.Interactions {
+        if interaction.Description == description {
+            return true
+        }
+    }
+    return false
+}
+
+var templateParam = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// stubPath turns a stub's path template into an example path with a rule matching any parameter value
+func stubPath(stubUrl string, rules *MatchingRules) string {
+    path := "/"
+    if index := strings.Index(stubUrl, "://"); index >= 0 {
+        if slash := strings.Index(stubUrl[index+3:], "/"); slash >= 0 {
+            path = stubUrl[index+3+slash:]
+        }
+    }
+    if !templateParam.MatchString(path) {
+        return path
+    }
+
+    pattern := "^"
+    last := 0
+    for _, loc := range templateParam.FindAllStringIndex(path, -1) {
+        pattern += regexp.QuoteMeta(path[last:loc[0]]) + "[^/]+"
+        last = loc[1]
+    }
+    pattern += regexp.QuoteMeta(path[last:]) + "$"
+    rules.Path = &Rule{Matchers: []Matcher{{Match: MatchRegex, Regex: pattern}}}
+    return templateParam.ReplaceAllStringFunc(
+        path,
+        func(param string) string {
+            return redact.Placeholder(param[1 : len(param)-1])
+        },
+    )
+}
+
+func contractHeaders(headers http.Header, rules *MatchingRules) (result map[string]string) {
+    for name, values := range headers {
+        if ignoredHeaders[http.CanonicalHeaderKey(name)] || len(values) == 0 {
+            continue
+        }
+        if result == nil {
+            result = make(map[string]string)
+        }
+        value := strings.Join(values, ", ")
+        result[name] = value
+        if redact.HasPlaceholder(value) {
+            setRule(&rules.Header, name, regexRule(redact.MatchPattern(value)))
+        }
+    }
+    return
+}
+
+// contractBody keeps JSON bodies as JSON and records anything else as a string
+func contractBody(contentType string, body []byte, rules *MatchingRules) json.RawMessage {
+    if len(body) == 0 {
+        return nil
+    }
+    mediaType, _, _ := mime.ParseMediaType(contentType)
+    isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || len(mediaType) == 0
+    var value interface{}
+    if isJSON && json.Unmarshal(body, &value) == nil {
+        placeholderRules([]string{"$"}, value, rules)
+        return json.RawMessage(body)
+    }
+
+    encoded, _ := json.Marshal(string(body))
+    return json.RawMessage(encoded)
+}
+
+// placeholderRules adds a regex rule for every string in the body that contains a placeholder
+func placeholderRules(path []string, value interface{}, rules *MatchingRules) {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        names := make([]string, 0, len(v))
+        for name := range v {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        for _, name := range names {
+            placeholderRules(append(path[:len(path):len(path)], name), v[name], rules)
+        }
+    case []interface{}:
+        for index, item := range v {
+            placeholderRules(append(path[:len(path):len(path)], fmt.Sprint(index)), item, rules)
+        }
+    case string:
+        if redact.HasPlaceholder(v) {
+            setRule(&rules.Body, formatPath(path), regexRule(redact.MatchPattern(v)))
+        }
+    }
+}
+
+func regexRule(pattern *regexp.Regexp) *Rule {
+    return &Rule{Matchers: []Matcher{{Match: MatchRegex, Regex: pattern.String()}}}
+}
+
+func setRule(rules *map[string]*Rule, name string, rule *Rule) {
+    if *rules == nil {
+        *rules = make(map[string]*Rule)
+    }
+    (*rules)[name] = rule
+}
+
+// orNil drops empty rules so they aren't written to the contract
+func (mr *MatchingRules) orNil() *MatchingRules {
+    if mr.Path == nil && len(mr.Query) == 0 && len(mr.Header) == 0 && len(mr.Body) == 0 {
+        return nil
+    }
+    return mr
+}
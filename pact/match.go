@@ -0,0 +1,410 @@
+package pact
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/redact"
+)
+
+/*
+    Values are compared against the contract's examples exactly unless a
+    matching rule applies; redaction placeholders in the examples act as
+    wildcards just like they do for HAR replay. A `type` rule on an array
+    compares every element against the first example element and a `type`
+    rule on an object compares its members by type.
+ */
+
+// isMatch checks the value against the rule, falling back to the example when there isn't one
+func (r *Rule) isMatch(example string, actual string) bool {
+    if r == nil || len(r.Matchers) == 0 {
+        return redact.Matches(example, actual)
+    }
+    return r.check(
+        func(m Matcher) bool {
+            return m.isMatch(example, actual)
+        },
+    )
+}
+
+// check combines the results of the matchers
+func (r *Rule) check(matches func(Matcher) bool) bool {
+    either := strings.EqualFold(r.Combine, "OR")
+    for _, m := range r.Matchers {
+        ok := matches(m)
+        if either && ok {
+            return true
+        }
+        if !either && !ok {
+            return false
+        }
+    }
+    return !either
+}
+
+// byType reports whether the rule compares values by type rather than example
+func (r *Rule) byType() bool {
+    if r == nil {
+        return false
+    }
+    for _, m := range r.Matchers {
+        if m.Match == MatchType {
+            return true
+        }
+    }
+    return false
+}
+
+func (m Matcher) isMatch(example interface{}, actual interface{}) bool {
+    switch m.Match {
+    case MatchEquality:
+        return reflect.DeepEqual(example, actual)
+    case MatchRegex:
+        pattern, err := regexp.Compile(m.Regex)
+        if err != nil {
+            log.Printf("Pact matcher has an invalid regular expression %q: %v", m.Regex, err)
+            return false
+        }
+        return pattern.MatchString(scalarText(actual))
+    case MatchInclude:
+        return strings.Contains(scalarText(actual), m.Value)
+    case MatchType:
+        return jsonKind(example) == jsonKind(actual)
+    default:
+        log.Printf("Pact matcher %q is not supported; comparing by type", m.Match)
+        return jsonKind(example) == jsonKind(actual)
+    }
+}
+
+func scalarText(value interface{}) string {
+    switch v := value.(type) {
+    case string:
+        return v
+    case nil:
+        return ""
+    default:
+        encoded, _ := json.Marshal(v)
+        return string(encoded)
+    }
+}
+
+func jsonKind(value interface{}) string {
+    switch value.(type) {
+    case nil:
+        return "null"
+    case bool:
+        return "boolean"
+    case float64, json.Number:
+        return "number"
+    case string:
+        return "string"
+    case []interface{}:
+        return "array"
+    case map[string]interface{}:
+        return "object"
+    default:
+        return fmt.Sprintf("%T", value)
+    }
+}
+
+// bodyComparison compares a JSON body against the example; requests may not add members but responses may
+type bodyComparison struct {
+    rules      map[string]*Rule
+    allowExtra bool
+    problems   []string
+}
+
+func compareBody(example json.RawMessage, actual []byte, rules *MatchingRules, allowExtra bool) (problems []string) {
+    if len(example) == 0 {
+        return
+    }
+    var expected interface{}
+    if err := json.Unmarshal(example, &expected); err != nil {
+        problems = append(problems, fmt.Sprintf("the contract body is invalid JSON: %v", err))
+        return
+    }
+
+    // non-JSON bodies are recorded as a JSON string
+    if text, ok := expected.(string); ok && !json.Valid(actual) {
+        if !redact.Matches(text, string(actual)) {
+            problems = append(problems, fmt.Sprintf("body: expected %q but found %q", text, actual))
+        }
+        return
+    }
+
+    var value interface{}
+    if err := json.Unmarshal(actual, &value); err != nil {
+        problems = append(problems, fmt.Sprintf("body: expected JSON but found %q", actual))
+        return
+    }
+    bc := &bodyComparison{allowExtra: allowExtra}
+    if rules != nil {
+        bc.rules = rules.Body
+    }
+    bc.compare([]string{"$"}, expected, value, false)
+    return bc.problems
+}
+
+func (bc *bodyComparison) fail(path []string, format string, args ...interface{}) {
+    bc.problems = append(bc.problems, formatPath(path)+": "+fmt.Sprintf(format, args...))
+}
+
+func (bc *bodyComparison) compare(path []string, expected interface{}, actual interface{}, byType bool) {
+    rule := bc.rule(path)
+    if rule.byType() {
+        byType = true
+    }
+
+    switch example := expected.(type) {
+    case map[string]interface{}:
+        members, ok := actual.(map[string]interface{})
+        if !ok {
+            bc.fail(path, "expected an object but found %s", jsonKind(actual))
+            return
+        }
+        for name, value := range example {
+            memberValue, found := members[name]
+            if !found {
+                bc.fail(path, "missing member %q", name)
+                continue
+            }
+            bc.compare(append(path[:len(path):len(path)], name), value, memberValue, byType)
+        }
+        if !bc.allowExtra {
+            for name := range members {
+                if _, found := example[name]; !found {
+                    bc.fail(path, "unexpected member %q", name)
+                }
+            }
+        }
+
+    case []interface{}:
+        items, ok := actual.([]interface{})
+        if !ok {
+            bc.fail(path, "expected an array but found %s", jsonKind(actual))
+            return
+        }
+        if rule.byType() {
+            bc.compareItems(path, rule, example, items)
+            return
+        }
+        if len(items) != len(example) {
+            bc.fail(path, "expected %d items but found %d", len(example), len(items))
+            return
+        }
+        for index, item := range items {
+            bc.compare(append(path[:len(path):len(path)], strconv.Itoa(index)), example[index], item, byType)
+        }
+
+    default:
+        switch {
+        case rule != nil && len(rule.Matchers) > 0:
+            if !rule.check(func(m Matcher) bool { return m.isMatch(expected, actual) }) {
+                bc.fail(path, "%s does not satisfy the matching rules", scalarText(actual))
+            }
+        case byType:
+            if jsonKind(expected) != jsonKind(actual) {
+                bc.fail(path, "expected a %s but found %s", jsonKind(expected), jsonKind(actual))
+            }
+        default:
+            if text, ok := expected.(string); ok {
+                if value, ok := actual.(string); ok && redact.Matches(text, value) {
+                    return
+                }
+            } else if reflect.DeepEqual(expected, actual) {
+                return
+            }
+            if jsonKind(expected) != jsonKind(actual) {
+                bc.fail(path, "expected a %s but found %s", jsonKind(expected), jsonKind(actual))
+                return
+            }
+            bc.fail(path, "expected %s but found %s", scalarText(expected), scalarText(actual))
+        }
+    }
+}
+
+// compareItems checks every item by type against the first example item and the array length limits
+func (bc *bodyComparison) compareItems(path []string, rule *Rule, example []interface{}, items []interface{}) {
+    for _, m := range rule.Matchers {
+        if m.Min != nil && len(items) < *m.Min {
+            bc.fail(path, "expected at least %d items but found %d", *m.Min, len(items))
+        }
+        if m.Max != nil && len(items) > *m.Max {
+            bc.fail(path, "expected at most %d items but found %d", *m.Max, len(items))
+        }
+    }
+    if len(example) == 0 {
+        return
+    }
+    for index, item := range items {
+        bc.compare(append(path[:len(path):len(path)], strconv.Itoa(index)), example[0], item, true)
+    }
+}
+
+// rule finds the body rule for the path; rule paths may use `*` and `[*]` wildcards
+func (bc *bodyComparison) rule(path []string) *Rule {
+    for key, rule := range bc.rules {
+        segments := parsePath(key)
+        if len(segments) != len(path) {
+            continue
+        }
+        matched := true
+        for index, segment := range segments {
+            if segment != "*" && segment != path[index] {
+                matched = false
+                break
+            }
+        }
+        if matched {
+            return rule
+        }
+    }
+    return nil
+}
+
+// parsePath splits a rule path such as `$.items[*]['first name']` into its segments
+func parsePath(path string) (segments []string) {
+    remaining := path
+    for len(remaining) > 0 {
+        switch {
+        case remaining[0] == '.':
+            remaining = remaining[1:]
+        case strings.HasPrefix(remaining, "['"):
+            end := strings.Index(remaining, "']")
+            if end < 0 {
+                return append(segments, remaining)
+            }
+            segments = append(segments, remaining[2:end])
+            remaining = remaining[end+2:]
+        case remaining[0] == '[':
+            end := strings.IndexByte(remaining, ']')
+            if end < 0 {
+                return append(segments, remaining)
+            }
+            segments = append(segments, remaining[1:end])
+            remaining = remaining[end+1:]
+        default:
+            end := strings.IndexAny(remaining, ".[")
+            if end < 0 {
+                end = len(remaining)
+            }
+            segments = append(segments, remaining[:end])
+            remaining = remaining[end:]
+        }
+    }
+    return
+}
+
+var plainName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// formatPath joins the segments into a JSON path; it's the inverse of parsePath
+func formatPath(segments []string) string {
+    var builder strings.Builder
+    for index, segment := range segments {
+        _, numberErr := strconv.Atoi(segment)
+        switch {
+        case index == 0:
+            builder.WriteString(segment)
+        case numberErr == nil:
+            builder.WriteString("[" + segment + "]")
+        case plainName.MatchString(segment):
+            builder.WriteString("." + segment)
+        default:
+            builder.WriteString("['" + segment + "']")
+        }
+    }
+    return builder.String()
+}
+
+func (mr *MatchingRules) path() *Rule {
+    if mr == nil {
+        return nil
+    }
+    return mr.Path
+}
+
+func (mr *MatchingRules) query(name string) *Rule {
+    if mr == nil {
+        return nil
+    }
+    return mr.Query[name]
+}
+
+// header names are case insensitive
+func (mr *MatchingRules) header(name string) *Rule {
+    if mr == nil {
+        return nil
+    }
+    for key, rule := range mr.Header {
+        if strings.EqualFold(key, name) {
+            return rule
+        }
+    }
+    return nil
+}
+
+// problems describes how the request differs from the interaction's request; an empty result is a match
+func (req *Request) problems(method string, path string, query url.Values, headers http.Header, body []byte) (problems []string) {
+    if !strings.EqualFold(req.Method, method) {
+        problems = append(problems, fmt.Sprintf("method: expected %s but found %s", req.Method, method))
+    }
+    if !req.MatchingRules.path().isMatch(req.Path, path) {
+        problems = append(problems, fmt.Sprintf("path: expected %s but found %s", req.Path, path))
+    }
+
+    for name, values := range req.Query {
+        actual := query[name]
+        if len(actual) != len(values) {
+            problems = append(problems, fmt.Sprintf("query %s: expected %q but found %q", name, values, actual))
+            continue
+        }
+        rule := req.MatchingRules.query(name)
+        for index, value := range values {
+            if !rule.isMatch(value, actual[index]) {
+                problems = append(problems, fmt.Sprintf("query %s: expected %q but found %q", name, value, actual[index]))
+            }
+        }
+    }
+    for name := range query {
+        if _, ok := req.Query[name]; !ok {
+            problems = append(problems, fmt.Sprintf("query %s: unexpected parameter", name))
+        }
+    }
+
+    problems = append(problems, headerProblems(req.Headers, req.MatchingRules, headers)...)
+    problems = append(problems, compareBody(req.Body, body, req.MatchingRules, false)...)
+    return
+}
+
+// problems describes how the reply differs from the interaction's response; an empty result is a match
+func (resp *Response) problems(status int, headers http.Header, body []byte) (problems []string) {
+    if resp.Status != status {
+        problems = append(problems, fmt.Sprintf("status: expected %d but found %d", resp.Status, status))
+    }
+    problems = append(problems, headerProblems(resp.Headers, resp.MatchingRules, headers)...)
+    problems = append(problems, compareBody(resp.Body, body, resp.MatchingRules, true)...)
+    return
+}
+
+// headerProblems checks the expected headers are present; any other headers are allowed
+func headerProblems(expected map[string]string, rules *MatchingRules, actual http.Header) (problems []string) {
+    for name, value := range expected {
+        values := actual.Values(name)
+        if len(values) == 0 {
+            problems = append(problems, fmt.Sprintf("header %s: missing", name))
+            continue
+        }
+        joined := strings.Join(values, ", ")
+        if !rules.header(name).isMatch(value, joined) {
+            problems = append(problems, fmt.Sprintf("header %s: expected %q but found %q", name, value, joined))
+        }
+    }
+    return
+}
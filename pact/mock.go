@@ -0,0 +1,160 @@
+package pact
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "regexp"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/redact"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+/*
+    Mock serves a contract's interactions as stubs so the consumer's tests run
+    against exactly what the provider has agreed to, then checks that the tests
+    exercised every interaction:
+
+        mock := pact.NewMock(contract, "https://users.example")
+        if err := mock.Register(stubs); err != nil {
+            ...
+        }
+        ...run the consumer tests...
+        if err := mock.Verify(); err != nil {
+            t.Error(err)
+        }
+
+    Requests are matched on method, path, query, the interaction's headers and
+    body using its matching rules; when several interactions match (e.g for
+    different provider states) the first one in the contract replies.
+ */
+
+type Mock struct {
+    Pact *Pact
+    // BaseURL is the scheme://host:port the provider is reached on
+    BaseURL string
+
+    stubs []*stub.Stub
+}
+
+func NewMock(p *Pact, baseUrl string) *Mock {
+    return &Mock{
+        Pact: p,
+        BaseURL: baseUrl,
+    }
+}
+
+// Register adds a stub for every interaction to the registry
+func (m *Mock) Register(stubs *stub.Registry) (err error) {
+    if m.Pact == nil {
+        err = fmt.Errorf("%w: missing contract", ErrInvalidPact)
+        return
+    }
+    baseUrl, parseErr := url.Parse(m.BaseURL)
+    if parseErr != nil || len(baseUrl.Host) == 0 {
+        err = fmt.Errorf("%w: BaseURL %q must be an absolute URL", ErrInvalidPact, m.BaseURL)
+        return
+    }
+    base := strings.TrimRight(m.BaseURL, "/")
+
+    m.stubs = nil
+    for index := range m.Pact.Interactions {
+        interaction := &m.Pact.Interactions[index]
+        var reply []byte
+        if reply, err = decodeBody(interaction.Response.Body); err != nil {
+            return
+        }
+
+        s := m.stub(stubs, base, interaction)
+        s.When(
+            func(request *common.HttpCall) bool {
+                body, bodyErr := request.Bytes()
+                if bodyErr != nil {
+                    log.Printf("Interaction %q does not match: unable to read the body: %v", interaction.Description, bodyErr)
+                    return false
+                }
+                problems := interaction.Request.problems(
+                    string(request.Method),
+                    request.Url.Path,
+                    request.Url.Query(),
+                    request.Headers,
+                    body,
+                )
+                if len(problems) > 0 {
+                    log.Printf("Interaction %q does not match: %s", interaction.Description, strings.Join(problems, "; "))
+                }
+                return len(problems) == 0
+            },
+        )
+        s.Reply(interaction.Response.Status).Bytes(reply)
+        contentType := false
+        for name, value := range interaction.Response.Headers {
+            s.Header(name, value)
+            contentType = contentType || strings.EqualFold(name, "Content-Type")
+        }
+        if !contentType && len(reply) > 0 && interaction.Response.Body[0] != '"' {
+            s.Header("Content-Type", "application/json")
+        }
+        m.stubs = append(m.stubs, s)
+    }
+    return stubs.Err()
+}
+
+// stub registers the interaction's path; paths with rules or placeholders become patterns
+func (m *Mock) stub(stubs *stub.Registry, base string, interaction *Interaction) *stub.Stub {
+    method := strings.ToUpper(interaction.Request.Method)
+    path := interaction.Request.Path
+    switch {
+    case interaction.Request.MatchingRules.path() != nil:
+        // the interaction's predicate applies the rule itself
+        return stubs.OnPattern(method, base, regexp.MustCompile(`/.*`))
+    case redact.HasPlaceholder(path):
+        pattern := redact.MatchPattern(path).String()
+        return stubs.OnPattern(method, base, regexp.MustCompile(pattern[1:len(pattern)-1]))
+    default:
+        return stubs.On(method, base+path)
+    }
+}
+
+// decodeBody converts an example body into the bytes sent; JSON string examples are plain text
+func decodeBody(raw json.RawMessage) (result []byte, err error) {
+    if len(raw) == 0 {
+        return
+    }
+    var text string
+    if raw[0] == '"' {
+        if err = json.Unmarshal(raw, &text); err != nil {
+            err = fmt.Errorf("%w: invalid body: %v", ErrInvalidInteraction, err)
+            return
+        }
+        result = []byte(text)
+        return
+    }
+    result = raw
+    return
+}
+
+// Unexercised returns the interactions that haven't replied to any request
+func (m *Mock) Unexercised() (result []Interaction) {
+    for index, s := range m.stubs {
+        if s.Calls() == 0 {
+            result = append(result, m.Pact.Interactions[index])
+        }
+    }
+    return
+}
+
+// Verify fails with ErrNotExercised listing any interactions that haven't replied to a request
+func (m *Mock) Verify() error {
+    missing := []string{}
+    for _, interaction := range m.Unexercised() {
+        missing = append(missing, interaction.Description)
+    }
+    if len(missing) > 0 {
+        return fmt.Errorf("%w: %s", ErrNotExercised, strings.Join(missing, ", "))
+    }
+    return nil
+}
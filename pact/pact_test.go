@@ -0,0 +1,180 @@
+package pact_test
+
+import (
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/pact"
+    "github.com/TestInABox/gostackinabox/redact"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+// provider stubs the users API the consumer relies on
+func provider(t *testing.T, r *router.Router) *stub.Registry {
+    stubs := stub.New(r)
+    stubs.On("GET", "https://users.example/v1/users/{id}").
+        Reply(200).
+        JSON(map[string]interface{}{"id": "42", "name": "bob", "created": "2024-01-02T03:04:05Z"})
+    stubs.On("POST", "https://users.example/v1/users").
+        WithJSONBody(map[string]interface{}{"name": "alice"}).
+        Reply(201).
+        Header("Location", "/v1/users/43")
+    stubs.On("GET", "https://users.example/v1/search?q=bob").Reply(200).Body("bob")
+    if err := stubs.Err(); err != nil {
+        t.Fatalf("Unexpected configuration error: %v", err)
+    }
+    return stubs
+}
+
+func send(t *testing.T, client *http.Client, method string, target string, body string) (status int, reply string) {
+    request, _ := http.NewRequest(method, target, strings.NewReader(body))
+    if len(body) > 0 {
+        request.Header.Set("Content-Type", "application/json")
+    }
+    response, err := client.Do(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    data, _ := ioutil.ReadAll(response.Body)
+    response.Body.Close()
+    return response.StatusCode, string(data)
+}
+
+func consumer(t *testing.T, client *http.Client) {
+    if status, _ := send(t, client, "GET", "https://users.example/v1/users/42", ""); status != 200 {
+        t.Errorf("Unexpected status fetching user: %d", status)
+    }
+    if status, _ := send(t, client, "POST", "https://users.example/v1/users", `{"name":"alice"}`); status != 201 {
+        t.Errorf("Unexpected status creating user: %d", status)
+    }
+}
+
+func Test_Pact_FromExchanges(t *testing.T) {
+    r := router.New()
+    r.Recorder = router.NewRecorder()
+    provider(t, r)
+    consumer(t, &http.Client{Transport: r})
+    send(t, &http.Client{Transport: r}, "GET", "https://users.example/v2/unknown", "")
+
+    contract := pact.FromExchanges("web", "users", r.Recorder.Exchanges())
+    if len(contract.Interactions) != 2 {
+        t.Fatalf("Unexpected interactions: %#v", contract.Interactions)
+    }
+    get := contract.Interactions[0]
+    if get.Description != "GET /v1/users/42 returns 200" || get.Response.Status != 200 {
+        t.Errorf("Unexpected interaction: %#v", get)
+    }
+    rule := get.Response.MatchingRules.Body["$.created"]
+    if rule == nil || rule.Matchers[0].Match != pact.MatchRegex {
+        t.Errorf("Redacted timestamp has no matching rule: %#v", get.Response.MatchingRules)
+    }
+
+    file := filepath.Join(t.TempDir(), "web-users.json")
+    if err := contract.WriteFile(file); err != nil {
+        t.Fatalf("Unexpected error writing: %v", err)
+    }
+    loaded, err := pact.ReadFile(file)
+    if err != nil {
+        t.Fatalf("Unexpected error loading: %v", err)
+    }
+    if loaded.Metadata.PactSpecification.Version != pact.SpecificationVersion || len(loaded.Interactions) != 2 {
+        t.Errorf("Unexpected contract: %#v", loaded)
+    }
+
+    // the real provider must satisfy the contract even though its timestamps differ
+    providerRouter := router.New()
+    stubs := stub.New(providerRouter)
+    stubs.On("GET", "https://users.example/v1/users/42").
+        Reply(200).
+        JSON(map[string]interface{}{"id": "42", "name": "bob", "created": "2025-06-07T08:09:10Z", "admin": false})
+    stubs.On("POST", "https://users.example/v1/users").Reply(201).Header("Location", "/v1/users/43")
+    verifier := &pact.Verifier{BaseURL: "https://users.example", Transport: providerRouter}
+    if err := verifier.Verify(loaded); err != nil {
+        t.Errorf("Unexpected verification failure: %v", err)
+    }
+
+    brokenRouter := router.New()
+    stub.New(brokenRouter).On("GET", "https://users.example/v1/users/42").Reply(200).JSON(map[string]interface{}{"id": 42})
+    verifier = &pact.Verifier{BaseURL: "https://users.example", Transport: brokenRouter}
+    err = verifier.Verify(loaded)
+    if !errors.Is(err, pact.ErrVerificationFailed) {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    for _, expected := range []string{"$.id: expected a string but found number", "missing member \"name\"", "status: expected 201"} {
+        if !strings.Contains(err.Error(), expected) {
+            t.Errorf("Verification failure doesn't mention %q: %v", expected, err)
+        }
+    }
+}
+
+func Test_Pact_FromStubs(t *testing.T) {
+    stubs := provider(t, router.New())
+    stubs.On("GET", "https://users.example/v1/health").Handler(
+        func(request *common.HttpCall) (*common.HttpReply, error) {
+            return &common.HttpReply{Status: 204}, nil
+        },
+    )
+
+    contract, err := pact.FromStubs("web", "users", stubs)
+    if !errors.Is(err, pact.ErrInvalidInteraction) || !strings.Contains(err.Error(), "/v1/health") {
+        t.Errorf("Handler stub was not reported: %v", err)
+    }
+    if len(contract.Interactions) != 3 {
+        t.Fatalf("Unexpected interactions: %#v", contract.Interactions)
+    }
+
+    get := contract.Interactions[0].Request
+    if get.Path != "/v1/users/"+redact.Placeholder("id") || get.MatchingRules == nil || get.MatchingRules.Path == nil {
+        t.Errorf("Path template not exported as a rule: %#v", get)
+    }
+    if post := contract.Interactions[1].Request; string(post.Body) != `{"name":"alice"}` {
+        t.Errorf("Unexpected request body: %s", post.Body)
+    }
+    if search := contract.Interactions[2]; search.Request.Query["q"][0] != "bob" || string(search.Response.Body) != `"bob"` {
+        t.Errorf("Unexpected search interaction: %#v", search)
+    }
+}
+
+func Test_Pact_Mock(t *testing.T) {
+    contract, _ := pact.FromStubs("web", "users", provider(t, router.New()))
+
+    r := router.New()
+    client := &http.Client{Transport: r}
+    mock := pact.NewMock(contract, "https://users.example")
+    if err := mock.Register(stub.New(r)); err != nil {
+        t.Fatalf("Unexpected error registering: %v", err)
+    }
+
+    consumer(t, client)
+    if status, body := send(t, client, "GET", "https://users.example/v1/users/7", ""); status != 200 || !strings.Contains(body, `"name":"bob"`) {
+        t.Errorf("Path rule not applied: %d %s", status, body)
+    }
+    if status, _ := send(t, client, "POST", "https://users.example/v1/users", `{"name":"alice","admin":true}`); status != int(common.HttpStatus_ServiceSubRouteError) {
+        t.Errorf("Unexpected request member was accepted: %d", status)
+    }
+
+    err := mock.Verify()
+    if !errors.Is(err, pact.ErrNotExercised) || !strings.Contains(err.Error(), "GET /v1/search returns 200") {
+        t.Errorf("Unexercised interaction was not reported: %v", err)
+    }
+    if unexercised := mock.Unexercised(); len(unexercised) != 1 {
+        t.Errorf("Unexpected unexercised interactions: %#v", unexercised)
+    }
+
+    if status, body := send(t, client, "GET", "https://users.example/v1/search?q=bob", ""); status != 200 || body != "bob" {
+        t.Errorf("Unexpected text reply: %d %s", status, body)
+    }
+    if err := mock.Verify(); err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+
+    if err := pact.NewMock(contract, "/relative").Register(stub.New(router.New())); !errors.Is(err, pact.ErrInvalidPact) {
+        t.Errorf("Unexpected error: %v", err)
+    }
+}
@@ -0,0 +1,156 @@
+package pact
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+)
+
+/*
+    Types for the Pact specification version 3 contract format as described at
+    https://github.com/pact-foundation/pact-specification/tree/version-3
+
+    A contract records the interactions a consumer relies on so the provider
+    can verify it honours them. Bodies are kept as raw JSON: a JSON document
+    for JSON content and a JSON string for anything else.
+ */
+
+const (
+    SpecificationVersion = "3.0.0"
+
+    // Matcher.Match values understood when serving and verifying interactions
+    MatchEquality = "equality"
+    MatchRegex = "regex"
+    MatchType = "type"
+    MatchInclude = "include"
+)
+
+type Pact struct {
+    Consumer     Pacticipant   `json:"consumer"`
+    Provider     Pacticipant   `json:"provider"`
+    Interactions []Interaction `json:"interactions"`
+    Metadata     Metadata      `json:"metadata"`
+}
+
+type Pacticipant struct {
+    Name string `json:"name"`
+}
+
+type Metadata struct {
+    PactSpecification Specification `json:"pactSpecification"`
+}
+
+type Specification struct {
+    Version string `json:"version"`
+}
+
+type Interaction struct {
+    Description    string          `json:"description"`
+    ProviderStates []ProviderState `json:"providerStates,omitempty"`
+    Request        Request         `json:"request"`
+    Response       Response        `json:"response"`
+}
+
+type ProviderState struct {
+    Name   string                 `json:"name"`
+    Params map[string]interface{} `json:"params,omitempty"`
+}
+
+type Request struct {
+    Method        string              `json:"method"`
+    Path          string              `json:"path"`
+    Query         map[string][]string `json:"query,omitempty"`
+    Headers       map[string]string   `json:"headers,omitempty"`
+    Body          json.RawMessage     `json:"body,omitempty"`
+    MatchingRules *MatchingRules      `json:"matchingRules,omitempty"`
+}
+
+type Response struct {
+    Status        int               `json:"status"`
+    Headers       map[string]string `json:"headers,omitempty"`
+    Body          json.RawMessage   `json:"body,omitempty"`
+    MatchingRules *MatchingRules    `json:"matchingRules,omitempty"`
+}
+
+// MatchingRules relax the exact comparison of the example values; body rules are keyed by JSON path, e.g `$.items[*].id`
+type MatchingRules struct {
+    Path   *Rule            `json:"path,omitempty"`
+    Query  map[string]*Rule `json:"query,omitempty"`
+    Header map[string]*Rule `json:"header,omitempty"`
+    Body   map[string]*Rule `json:"body,omitempty"`
+}
+
+type Rule struct {
+    Matchers []Matcher `json:"matchers"`
+    // Combine is AND (the default) or OR
+    Combine string `json:"combine,omitempty"`
+}
+
+type Matcher struct {
+    Match string `json:"match"`
+    Regex string `json:"regex,omitempty"`
+    Value string `json:"value,omitempty"`
+    Min   *int   `json:"min,omitempty"`
+    Max   *int   `json:"max,omitempty"`
+}
+
+var (
+    ErrInvalidPact error = errors.New("Pact: Invalid contract")
+    ErrInvalidInteraction error = errors.New("Pact: Invalid interaction")
+    ErrNotExercised error = errors.New("Pact: Interactions were not exercised")
+    ErrVerificationFailed error = errors.New("Pact: Provider verification failed")
+)
+
+// New creates an empty contract between the consumer and provider
+func New(consumer string, provider string) *Pact {
+    return &Pact{
+        Consumer: Pacticipant{Name: consumer},
+        Provider: Pacticipant{Name: provider},
+        Interactions: []Interaction{},
+        Metadata: Metadata{
+            PactSpecification: Specification{Version: SpecificationVersion},
+        },
+    }
+}
+
+func Load(r io.Reader) (p *Pact, err error) {
+    p = &Pact{}
+    if decodeErr := json.NewDecoder(r).Decode(p); decodeErr != nil {
+        err = fmt.Errorf("%w: %v", ErrInvalidPact, decodeErr)
+        p = nil
+        return
+    }
+    return
+}
+
+func ReadFile(path string) (p *Pact, err error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return
+    }
+    defer f.Close()
+    return Load(f)
+}
+
+// Export writes the contract to the writer as Pact formatted JSON
+func (p *Pact) Export(w io.Writer) error {
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(p)
+}
+
+func (p *Pact) WriteFile(path string) (err error) {
+    f, err := os.Create(path)
+    if err != nil {
+        return
+    }
+    defer func() {
+        if closeErr := f.Close(); err == nil {
+            err = closeErr
+        }
+    }()
+    err = p.Export(f)
+    return
+}
@@ -0,0 +1,121 @@
+package pact
+
+import (
+    "bytes"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    Verifier is the provider side of the contract: it replays every
+    interaction's request against the provider and checks the reply satisfies
+    the interaction's response. Members the contract doesn't mention may be
+    added to JSON objects in the reply, as can extra headers.
+
+        verifier := &pact.Verifier{
+            BaseURL: "http://localhost:8080",
+            SetUp: func(states []pact.ProviderState) error {
+                ...put the provider into the states...
+            },
+        }
+        if err := verifier.Verify(contract); err != nil {
+            t.Error(err)
+        }
+
+    The examples are sent as-is so any redaction placeholders in the request
+    are sent literally.
+ */
+
+type Verifier struct {
+    // BaseURL is the scheme://host:port (and optional path prefix) the provider is reached on
+    BaseURL string
+    // Transport sends the requests, http.DefaultTransport by default; a router.Router verifies
+    // services defined with gostackinabox
+    Transport http.RoundTripper
+    // SetUp, when set, is called with the interaction's provider states before its request is sent
+    SetUp func(states []ProviderState) error
+}
+
+// Verify replays every interaction, failing with ErrVerificationFailed describing each mismatch
+func (v *Verifier) Verify(p *Pact) (err error) {
+    if p == nil {
+        err = fmt.Errorf("%w: missing contract", ErrInvalidPact)
+        return
+    }
+    baseUrl, parseErr := url.Parse(v.BaseURL)
+    if parseErr != nil || len(baseUrl.Host) == 0 {
+        err = fmt.Errorf("%w: BaseURL %q must be an absolute URL", ErrInvalidPact, v.BaseURL)
+        return
+    }
+
+    failures := []string{}
+    for index := range p.Interactions {
+        interaction := &p.Interactions[index]
+        problems := v.verify(strings.TrimRight(v.BaseURL, "/"), interaction)
+        if len(problems) > 0 {
+            log.Printf("Interaction %q failed verification: %s", interaction.Description, strings.Join(problems, "; "))
+            failures = append(failures, fmt.Sprintf("%s: %s", interaction.Description, strings.Join(problems, "; ")))
+        }
+    }
+    if len(failures) > 0 {
+        err = fmt.Errorf("%w:\n  %s", ErrVerificationFailed, strings.Join(failures, "\n  "))
+    }
+    return
+}
+
+func (v *Verifier) verify(base string, interaction *Interaction) (problems []string) {
+    if v.SetUp != nil {
+        if err := v.SetUp(interaction.ProviderStates); err != nil {
+            return []string{fmt.Sprintf("unable to set up the provider states: %v", err)}
+        }
+    }
+
+    request, err := interaction.Request.build(base)
+    if err != nil {
+        return []string{err.Error()}
+    }
+    transport := v.Transport
+    if transport == nil {
+        transport = http.DefaultTransport
+    }
+    response, err := transport.RoundTrip(request)
+    if err != nil {
+        return []string{fmt.Sprintf("request failed: %v", err)}
+    }
+    defer response.Body.Close()
+    body, err := ioutil.ReadAll(response.Body)
+    if err != nil {
+        return []string{fmt.Sprintf("unable to read the response body: %v", err)}
+    }
+    return interaction.Response.problems(response.StatusCode, response.Header, body)
+}
+
+// build creates the HTTP request described by the interaction
+func (req *Request) build(base string) (request *http.Request, err error) {
+    target := base + req.Path
+    if len(req.Query) > 0 {
+        target += "?" + url.Values(req.Query).Encode()
+    }
+
+    body, err := decodeBody(req.Body)
+    if err != nil {
+        return
+    }
+    request, err = http.NewRequest(strings.ToUpper(req.Method), target, bytes.NewReader(body))
+    if err != nil {
+        err = fmt.Errorf("%w: %v", ErrInvalidInteraction, err)
+        return
+    }
+    for name, value := range req.Headers {
+        request.Header.Set(name, value)
+    }
+    if len(body) > 0 && len(request.Header.Get("Content-Type")) == 0 && req.Body[0] != '"' {
+        request.Header.Set("Content-Type", "application/json")
+    }
+    return
+}
@@ -0,0 +1,173 @@
+package predicate
+
+import (
+    "encoding/json"
+    "fmt"
+    "reflect"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/jsonpath"
+)
+
+// JSONOption relaxes the comparison made by JSONEquals
+type JSONOption int
+
+const (
+    // IgnoreArrayOrder lets array items appear in any order
+    IgnoreArrayOrder JSONOption = 1 << iota
+    // IgnoreExtraFields lets the body have object members and array items the expected value doesn't
+    IgnoreExtraFields
+)
+
+// JSONEquals requires the body to be JSON equal to the value; object member order and whitespace are ignored
+func JSONEquals(v interface{}, options ...JSONOption) (p Predicate, err error) {
+    encoded, err := json.Marshal(v)
+    var expected interface{}
+    if err == nil {
+        err = json.Unmarshal(encoded, &expected)
+    }
+    if err != nil {
+        err = fmt.Errorf("%w: unable to encode the expected JSON: %v", ErrInvalidPredicate, err)
+        return
+    }
+
+    var flags JSONOption
+    for _, option := range options {
+        flags |= option
+    }
+    description := "JSON body equals " + string(encoded)
+    if flags&IgnoreArrayOrder != 0 {
+        description += " ignoring array order"
+    }
+    if flags&IgnoreExtraFields != 0 {
+        description += " ignoring extra fields"
+    }
+
+    p = New(
+        description,
+        func(request *common.HttpCall) string {
+            data, readErr := request.Bytes()
+            if readErr != nil {
+                return unreadable(readErr)
+            }
+            var actual interface{}
+            if err := json.Unmarshal(data, &actual); err != nil {
+                return "body is not JSON"
+            }
+            return jsonDiff("$", expected, actual, flags)
+        },
+    )
+    return
+}
+
+// jsonDiff explains the first difference between the documents, or returns "" when they're equal
+func jsonDiff(path string, expected interface{}, actual interface{}, flags JSONOption) string {
+    ignoreExtra := flags&IgnoreExtraFields != 0
+    switch expectedValue := expected.(type) {
+    case map[string]interface{}:
+        actualValue, ok := actual.(map[string]interface{})
+        if !ok {
+            return fmt.Sprintf("%s: expected an object but found %s", path, encodeJSON(actual))
+        }
+        for key, value := range expectedValue {
+            other, ok := actualValue[key]
+            if !ok {
+                return fmt.Sprintf("%s: missing member %q", path, key)
+            }
+            if reason := jsonDiff(path+"."+key, value, other, flags); len(reason) > 0 {
+                return reason
+            }
+        }
+        if !ignoreExtra {
+            for key := range actualValue {
+                if _, ok := expectedValue[key]; !ok {
+                    return fmt.Sprintf("%s: unexpected member %q", path, key)
+                }
+            }
+        }
+        return ""
+
+    case []interface{}:
+        actualValue, ok := actual.([]interface{})
+        if !ok {
+            return fmt.Sprintf("%s: expected an array but found %s", path, encodeJSON(actual))
+        }
+        if len(actualValue) < len(expectedValue) || (!ignoreExtra && len(actualValue) != len(expectedValue)) {
+            return fmt.Sprintf("%s: expected %d items but found %d", path, len(expectedValue), len(actualValue))
+        }
+        if flags&IgnoreArrayOrder == 0 {
+            for index, value := range expectedValue {
+                if reason := jsonDiff(fmt.Sprintf("%s[%d]", path, index), value, actualValue[index], flags); len(reason) > 0 {
+                    return reason
+                }
+            }
+            return ""
+        }
+        used := make([]bool, len(actualValue))
+        for index, value := range expectedValue {
+            found := false
+            for otherIndex, other := range actualValue {
+                if !used[otherIndex] && len(jsonDiff(path, value, other, flags)) == 0 {
+                    used[otherIndex] = true
+                    found = true
+                    break
+                }
+            }
+            if !found {
+                return fmt.Sprintf("%s: no item matches expected item %d (%s)", path, index, encodeJSON(value))
+            }
+        }
+        return ""
+    }
+
+    if !reflect.DeepEqual(expected, actual) {
+        return fmt.Sprintf("%s: expected %s but found %s", path, encodeJSON(expected), encodeJSON(actual))
+    }
+    return ""
+}
+
+// JSONPath requires a value the expression selects in the JSON body to satisfy the matcher,
+// or just a non-null value when the matcher is nil. Strings are matched as-is and anything
+// else as its JSON encoding.
+func JSONPath(expression string, m Matcher) (p Predicate, err error) {
+    path, err := jsonpath.Compile(expression)
+    if err != nil {
+        return
+    }
+    subject := "JSON body at " + expression
+    p = New(
+        describe(subject, m),
+        func(request *common.HttpCall) string {
+            data, readErr := request.Bytes()
+            if readErr != nil {
+                return unreadable(readErr)
+            }
+            var document interface{}
+            if err := json.Unmarshal(data, &document); err != nil {
+                return "body is not JSON"
+            }
+            values := []string{}
+            for _, result := range path.Evaluate(document) {
+                if result != nil || m != nil {
+                    values = append(values, JSONText(result))
+                }
+            }
+            return matchValues(subject, m, values)
+        },
+    )
+    return
+}
+
+// JSONText renders a decoded JSON value so string matchers can be applied to it
+func JSONText(value interface{}) string {
+    if text, ok := value.(string); ok {
+        return text
+    }
+    encoded, _ := json.Marshal(value)
+    return string(encoded)
+}
+
+func encodeJSON(value interface{}) string {
+    encoded, _ := json.Marshal(value)
+    return string(encoded)
+}
@@ -0,0 +1,90 @@
+package predicate
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// Matcher tests a single value such as a header, a form field or a JSONPath result
+type Matcher interface {
+    Match(value string) bool
+    // String describes the matcher, e.g `contains "json"`
+    String() string
+}
+
+type matcher struct {
+    description string
+    match       func(value string) bool
+}
+
+func (m *matcher) Match(value string) bool {
+    return m.match(value)
+}
+
+func (m *matcher) String() string {
+    return m.description
+}
+
+func Equals(expected string) Matcher {
+    return &matcher{
+        description: fmt.Sprintf("equals %q", expected),
+        match: func(value string) bool {
+            return value == expected
+        },
+    }
+}
+
+// EqualsFold compares ignoring case
+func EqualsFold(expected string) Matcher {
+    return &matcher{
+        description: fmt.Sprintf("equals %q (ignoring case)", expected),
+        match: func(value string) bool {
+            return strings.EqualFold(value, expected)
+        },
+    }
+}
+
+func Contains(expected string) Matcher {
+    return &matcher{
+        description: fmt.Sprintf("contains %q", expected),
+        match: func(value string) bool {
+            return strings.Contains(value, expected)
+        },
+    }
+}
+
+// Regex requires the regular expression to match somewhere in the value; anchor it to match it all
+func Regex(pattern *regexp.Regexp) Matcher {
+    return &matcher{
+        description: fmt.Sprintf("matches /%s/", pattern),
+        match: pattern.MatchString,
+    }
+}
+
+// describe combines the subject with the matcher; a nil matcher only requires the subject to be present
+func describe(subject string, m Matcher) string {
+    if m == nil {
+        return subject + " is present"
+    }
+    return subject + " " + m.String()
+}
+
+// matchValues passes if the matcher accepts any of the values; a nil matcher only needs a value
+func matchValues(subject string, m Matcher, values []string) (reason string) {
+    if len(values) == 0 {
+        return subject + " is missing"
+    }
+    if m == nil {
+        return ""
+    }
+    for _, value := range values {
+        if m.Match(value) {
+            return ""
+        }
+    }
+    if len(values) == 1 {
+        return fmt.Sprintf("found %q", values[0])
+    }
+    return fmt.Sprintf("found %q", values)
+}
@@ -0,0 +1,192 @@
+package predicate
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    The predicate package holds conditions on requests that explain why a
+    request fails them, e.g
+
+        byName, err := predicate.JSONPath("$.user.name", predicate.Equals("bob"))
+        ...
+        if err := predicate.Check(request, predicate.Header("Accept", predicate.Contains("json")), byName); err != nil {
+            // err reads: JSON body at $.user.name equals "bob": found "alice"
+        }
+
+    Predicates work on a common.HttpCall so they can be used by stubs (see
+    stub.Stub.Matching), inside handlers, and through ForURI by anything that
+    needs a common.URI. Predicates read the body through HttpCall.Bytes so
+    later predicates and the handler can read it too.
+ */
+
+var (
+    ErrInvalidPredicate error = errors.New("Predicate: Invalid predicate")
+)
+
+type Predicate interface {
+    // Check returns nil when the request satisfies the predicate, otherwise a *Mismatch explaining why not
+    Check(request *common.HttpCall) error
+    // String describes the predicate, e.g `header "Accept" contains "json"`
+    String() string
+}
+
+// Mismatch explains why a request doesn't satisfy a predicate
+type Mismatch struct {
+    Predicate string
+    Reason    string
+}
+
+func (m *Mismatch) Error() string {
+    return m.Predicate + ": " + m.Reason
+}
+
+// Mismatches is returned by Check when more than one predicate isn't satisfied
+type Mismatches []*Mismatch
+
+func (ms Mismatches) Error() string {
+    messages := make([]string, 0, len(ms))
+    for _, m := range ms {
+        messages = append(messages, m.Error())
+    }
+    return strings.Join(messages, "; ")
+}
+
+type predicate struct {
+    description string
+    check       func(request *common.HttpCall) (reason string)
+}
+
+// New creates a predicate from a check that returns the reason the request doesn't satisfy it, or "" if it does
+func New(description string, check func(request *common.HttpCall) (reason string)) Predicate {
+    return &predicate{description: description, check: check}
+}
+
+func (p *predicate) Check(request *common.HttpCall) error {
+    if reason := p.check(request); len(reason) > 0 {
+        return &Mismatch{Predicate: p.description, Reason: reason}
+    }
+    return nil
+}
+
+func (p *predicate) String() string {
+    return p.description
+}
+
+// Check evaluates every predicate, returning a *Mismatch or Mismatches for those the request doesn't satisfy
+func Check(request *common.HttpCall, predicates ...Predicate) error {
+    var mismatches Mismatches
+    for _, p := range predicates {
+        if err := p.Check(request); err != nil {
+            mismatches = append(mismatches, asMismatch(p, err))
+        }
+    }
+    switch len(mismatches) {
+    case 0:
+        return nil
+    case 1:
+        return mismatches[0]
+    }
+    return mismatches
+}
+
+// IsMatch reports whether the request satisfies every predicate
+func IsMatch(request *common.HttpCall, predicates ...Predicate) bool {
+    for _, p := range predicates {
+        if p.Check(request) != nil {
+            return false
+        }
+    }
+    return true
+}
+
+func asMismatch(p Predicate, err error) *Mismatch {
+    var m *Mismatch
+    if errors.As(err, &m) {
+        return m
+    }
+    return &Mismatch{Predicate: p.String(), Reason: err.Error()}
+}
+
+// All requires every predicate
+func All(predicates ...Predicate) Predicate {
+    return New(
+        describeAll(predicates, " and "),
+        func(request *common.HttpCall) string {
+            if err := Check(request, predicates...); err != nil {
+                return err.Error()
+            }
+            return ""
+        },
+    )
+}
+
+// Any requires at least one of the predicates
+func Any(predicates ...Predicate) Predicate {
+    return New(
+        describeAll(predicates, " or "),
+        func(request *common.HttpCall) string {
+            reasons := []string{}
+            for _, p := range predicates {
+                err := p.Check(request)
+                if err == nil {
+                    return ""
+                }
+                reasons = append(reasons, err.Error())
+            }
+            return "none matched: " + strings.Join(reasons, "; ")
+        },
+    )
+}
+
+// Not requires the request to fail the predicate
+func Not(p Predicate) Predicate {
+    return New(
+        "not "+p.String(),
+        func(request *common.HttpCall) string {
+            if p.Check(request) == nil {
+                return "the request satisfies it"
+            }
+            return ""
+        },
+    )
+}
+
+func describeAll(predicates []Predicate, separator string) string {
+    descriptions := make([]string, 0, len(predicates))
+    for _, p := range predicates {
+        descriptions = append(descriptions, "("+p.String()+")")
+    }
+    return strings.Join(descriptions, separator)
+}
+
+// uriPredicate adapts predicates that only look at the URL (e.g Path and Query) into a common.URI
+type uriPredicate struct {
+    predicate Predicate
+}
+
+// ForURI uses the predicate as a common.URI; the request it checks only has the URL set
+func ForURI(p Predicate) common.URI {
+    return &uriPredicate{predicate: p}
+}
+
+func (up *uriPredicate) IsMatch(u url.URL) (result bool, err error) {
+    checkErr := up.predicate.Check(&common.HttpCall{Url: &u, Headers: http.Header{}})
+    result = checkErr == nil
+    log.Printf("Attempting to match %s against %s... match: %t", u.String(), up.predicate, result)
+    return
+}
+
+// unreadable is the mismatch reason for a body that can't be read or decoded
+func unreadable(err error) string {
+    return fmt.Sprintf("unable to read the body: %v", err)
+}
+
+var _ common.URI = &uriPredicate{}
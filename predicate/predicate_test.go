@@ -0,0 +1,179 @@
+package predicate_test
+
+import (
+    "bytes"
+    "errors"
+    "io/ioutil"
+    "mime/multipart"
+    "net/http"
+    "net/url"
+    "regexp"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/predicate"
+)
+
+func newCall(t *testing.T, method string, rawUrl string, contentType string, body string) *common.HttpCall {
+    request, err := http.NewRequest(method, rawUrl, strings.NewReader(body))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if len(contentType) > 0 {
+        request.Header.Set("Content-Type", contentType)
+    }
+    return &common.HttpCall{
+        Method:  common.HttpVerb(method),
+        Url:     request.URL,
+        Headers: request.Header,
+        Request: request,
+    }
+}
+
+func Test_Predicate_Check(t *testing.T) {
+    call := newCall(t, "GET", "https://api.example/v1/users?active=true", "", "")
+    call.Headers.Set("Accept", "application/json")
+
+    accepts := predicate.Header("Accept", predicate.Contains("json"))
+    active := predicate.Query("active", predicate.Equals("true"))
+    if err := predicate.Check(call, accepts, active); err != nil {
+        t.Errorf("Unexpected mismatch: %v", err)
+    }
+
+    err := predicate.Check(call, accepts, predicate.Query("active", predicate.Equals("false")))
+    var mismatch *predicate.Mismatch
+    if !errors.As(err, &mismatch) {
+        t.Fatalf("Expected a *Mismatch but found %v", err)
+    }
+    if err.Error() != `query parameter "active" equals "false": found "true"` {
+        t.Errorf("Unexpected mismatch: %v", err)
+    }
+
+    err = predicate.Check(call, predicate.Header("X-Missing", nil), predicate.Path(predicate.Equals("/v2/users")))
+    var mismatches predicate.Mismatches
+    if !errors.As(err, &mismatches) || len(mismatches) != 2 {
+        t.Fatalf("Expected two mismatches but found %v", err)
+    }
+    if err.Error() != `header "X-Missing" is present: header "X-Missing" is missing; path equals "/v2/users": found "/v1/users"` {
+        t.Errorf("Unexpected mismatches: %v", err)
+    }
+
+    for _, tc := range []struct {
+        name      string
+        predicate predicate.Predicate
+        expected  bool
+    }{
+        {"all", predicate.All(accepts, active), true},
+        {"all failing", predicate.All(accepts, predicate.Header("X-Missing", nil)), false},
+        {"any", predicate.Any(predicate.Header("X-Missing", nil), active), true},
+        {"not", predicate.Not(predicate.Header("X-Missing", nil)), true},
+        {"not failing", predicate.Not(accepts), false},
+        {"request URI", predicate.RequestURI(predicate.Equals("/v1/users?active=true")), true},
+        {"regex", predicate.Path(predicate.Regex(regexp.MustCompile(`^/v1/`))), true},
+        {"fold", predicate.Header("accept", predicate.EqualsFold("APPLICATION/JSON")), true},
+    } {
+        if result := predicate.IsMatch(call, tc.predicate); result != tc.expected {
+            t.Errorf("%s: %s returned %t", tc.name, tc.predicate, result)
+        }
+    }
+
+    uri := predicate.ForURI(predicate.Query("active", predicate.Equals("true")))
+    u, _ := url.Parse("https://api.example/v1/users?active=true")
+    if match, err := uri.IsMatch(*u); !match || err != nil {
+        t.Errorf("Expected the URI to match: %t %v", match, err)
+    }
+}
+
+func Test_Predicate_Body(t *testing.T) {
+    call := newCall(t, "POST", "https://api.example/v1/users", "text/plain", "hello world")
+    if !predicate.IsMatch(call, predicate.Body(predicate.Contains("hello")), predicate.Body(predicate.Regex(regexp.MustCompile(`world$`)))) {
+        t.Errorf("Expected the body predicates to match")
+    }
+    // the body is put back for the handler
+    body, _ := ioutil.ReadAll(call.Request.Body)
+    if string(body) != "hello world" {
+        t.Errorf("Unexpected body after checking: %q", body)
+    }
+
+    empty := newCall(t, "POST", "https://api.example/v1/users", "", "")
+    if predicate.IsMatch(empty, predicate.Body(nil)) || !predicate.IsMatch(empty, predicate.Body(predicate.Equals(""))) {
+        t.Errorf("Unexpected result for an empty body")
+    }
+}
+
+func Test_Predicate_FormField(t *testing.T) {
+    form := newCall(t, "POST", "https://api.example/login", "application/x-www-form-urlencoded", "user=bob&role=admin&role=ops")
+    if !predicate.IsMatch(form, predicate.FormField("user", predicate.Equals("bob")), predicate.FormField("role", predicate.Equals("ops"))) {
+        t.Errorf("Expected the form fields to match")
+    }
+    if err := predicate.FormField("user", predicate.Equals("alice")).Check(form); err == nil || !strings.HasSuffix(err.Error(), `found "bob"`) {
+        t.Errorf("Unexpected mismatch: %v", err)
+    }
+
+    buffer := &bytes.Buffer{}
+    writer := multipart.NewWriter(buffer)
+    writer.WriteField("title", "report")
+    file, _ := writer.CreateFormFile("upload", "report.txt")
+    file.Write([]byte("file content"))
+    writer.Close()
+    multipartCall := newCall(t, "POST", "https://api.example/upload", writer.FormDataContentType(), buffer.String())
+    if !predicate.IsMatch(multipartCall, predicate.FormField("title", predicate.Equals("report")), predicate.FormField("upload", predicate.Contains("content"))) {
+        t.Errorf("Expected the multipart fields to match")
+    }
+
+    plain := newCall(t, "POST", "https://api.example/login", "text/plain", "user=bob")
+    if err := predicate.FormField("user", nil).Check(plain); err == nil || !strings.Contains(err.Error(), "not a form") {
+        t.Errorf("Unexpected mismatch: %v", err)
+    }
+}
+
+func Test_Predicate_JSON(t *testing.T) {
+    call := newCall(t, "POST", "https://api.example/v1/users", "application/json",
+        `{"user": {"name": "bob", "age": 42, "nickname": null}, "tags": ["b", "a"], "extra": true}`)
+
+    exact, err := predicate.JSONEquals(map[string]interface{}{
+        "user": map[string]interface{}{"name": "bob", "age": 42, "nickname": nil},
+        "tags": []string{"b", "a"},
+        "extra": true,
+    })
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    relaxed, _ := predicate.JSONEquals(
+        map[string]interface{}{"tags": []string{"a", "b"}},
+        predicate.IgnoreArrayOrder, predicate.IgnoreExtraFields,
+    )
+    if !predicate.IsMatch(call, exact, relaxed) {
+        t.Errorf("Expected the JSON predicates to match: %v", predicate.Check(call, exact, relaxed))
+    }
+
+    for _, tc := range []struct {
+        expected interface{}
+        options  []predicate.JSONOption
+        reason   string
+    }{
+        {map[string]interface{}{"tags": []string{"a", "b"}}, []predicate.JSONOption{predicate.IgnoreExtraFields}, `$.tags[0]: expected "a" but found "b"`},
+        {map[string]interface{}{"user": map[string]interface{}{"age": "42"}}, []predicate.JSONOption{predicate.IgnoreExtraFields}, `$.user.age: expected "42" but found 42`},
+        {map[string]interface{}{"tags": []string{"b", "a"}}, nil, `$: unexpected member "`},
+        {map[string]interface{}{"missing": 1}, []predicate.JSONOption{predicate.IgnoreExtraFields}, `$: missing member "missing"`},
+    } {
+        p, _ := predicate.JSONEquals(tc.expected, tc.options...)
+        err := p.Check(call)
+        var mismatch *predicate.Mismatch
+        if !errors.As(err, &mismatch) || !strings.HasPrefix(mismatch.Reason, tc.reason) {
+            t.Errorf("%s: unexpected mismatch: %v", p, err)
+        }
+    }
+
+    name, _ := predicate.JSONPath("$.user.name", predicate.Equals("bob"))
+    age, _ := predicate.JSONPath("$.user.age", predicate.Equals("42"))
+    tag, _ := predicate.JSONPath("$.tags[*]", predicate.Equals("a"))
+    nickname, _ := predicate.JSONPath("$.user.nickname", nil)
+    if !predicate.IsMatch(call, name, age, tag) || predicate.IsMatch(call, nickname) {
+        t.Errorf("Unexpected JSONPath result: %v", predicate.Check(call, name, age, tag, predicate.Not(nickname)))
+    }
+    if _, err := predicate.JSONPath("$..name[?(@.x)]", nil); err == nil {
+        t.Errorf("Expected an unsupported expression to fail")
+    }
+}
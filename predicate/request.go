@@ -0,0 +1,168 @@
+package predicate
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "mime"
+    "mime/multipart"
+    "net/url"
+
+    "github.com/TestInABox/gostackinabox/common"
+)
+
+// Header requires a value of the header to satisfy the matcher, or just the header when the matcher is nil
+func Header(name string, m Matcher) Predicate {
+    subject := fmt.Sprintf("header %q", name)
+    return New(
+        describe(subject, m),
+        func(request *common.HttpCall) string {
+            return matchValues(subject, m, request.Headers.Values(name))
+        },
+    )
+}
+
+// Query requires a value of the query parameter to satisfy the matcher, or just the parameter when the matcher is nil
+func Query(name string, m Matcher) Predicate {
+    subject := fmt.Sprintf("query parameter %q", name)
+    return New(
+        describe(subject, m),
+        func(request *common.HttpCall) string {
+            return matchValues(subject, m, request.Url.Query()[name])
+        },
+    )
+}
+
+// Cookie requires a value of the cookie to satisfy the matcher, or just the cookie when the matcher is nil
+func Cookie(name string, m Matcher) Predicate {
+    subject := fmt.Sprintf("cookie %q", name)
+    return New(
+        describe(subject, m),
+        func(request *common.HttpCall) string {
+            values := []string{}
+            if request.Request != nil {
+                for _, cookie := range request.Request.Cookies() {
+                    if cookie.Name == name {
+                        values = append(values, cookie.Value)
+                    }
+                }
+            }
+            return matchValues(subject, m, values)
+        },
+    )
+}
+
+// Path requires the URL path to satisfy the matcher
+func Path(m Matcher) Predicate {
+    return New(
+        describe("path", m),
+        func(request *common.HttpCall) string {
+            return matchValues("path", m, []string{request.Url.Path})
+        },
+    )
+}
+
+// RequestURI requires the path and query, e.g `/users?active=true`, to satisfy the matcher
+func RequestURI(m Matcher) Predicate {
+    return New(
+        describe("request URI", m),
+        func(request *common.HttpCall) string {
+            return matchValues("request URI", m, []string{request.Url.RequestURI()})
+        },
+    )
+}
+
+// PathParam requires the named segment captured by a templated path to satisfy the matcher
+func PathParam(name string, m Matcher) Predicate {
+    subject := fmt.Sprintf("path parameter %q", name)
+    return New(
+        describe(subject, m),
+        func(request *common.HttpCall) string {
+            values := []string{}
+            if value, ok := request.PathParams[name]; ok {
+                values = append(values, value)
+            }
+            return matchValues(subject, m, values)
+        },
+    )
+}
+
+// Body requires the raw body to satisfy the matcher, or just a non-empty body when the matcher is nil
+func Body(m Matcher) Predicate {
+    return New(
+        describe("body", m),
+        func(request *common.HttpCall) string {
+            data, err := request.Bytes()
+            if err != nil {
+                return unreadable(err)
+            }
+            values := []string{}
+            if len(data) > 0 {
+                values = append(values, string(data))
+            }
+            if len(values) == 0 && m != nil {
+                // an empty body can still satisfy e.g Equals("")
+                values = append(values, "")
+            }
+            return matchValues("body", m, values)
+        },
+    )
+}
+
+// FormField requires a value of the field of a URL encoded or multipart form body to satisfy the matcher;
+// the value of a file part is its content
+func FormField(name string, m Matcher) Predicate {
+    subject := fmt.Sprintf("form field %q", name)
+    return New(
+        describe(subject, m),
+        func(request *common.HttpCall) string {
+            values, err := formValues(request, name)
+            if err != nil {
+                return err.Error()
+            }
+            return matchValues(subject, m, values)
+        },
+    )
+}
+
+func formValues(request *common.HttpCall, name string) (values []string, err error) {
+    contentType := request.Headers.Get("Content-Type")
+    mediaType, params, _ := mime.ParseMediaType(contentType)
+    data, err := request.Bytes()
+    if err != nil {
+        err = errors.New(unreadable(err))
+        return
+    }
+
+    switch mediaType {
+    case "application/x-www-form-urlencoded":
+        form, parseErr := url.ParseQuery(string(data))
+        if parseErr != nil {
+            err = fmt.Errorf("body is not a valid form: %v", parseErr)
+            return
+        }
+        values = form[name]
+    case "multipart/form-data":
+        reader := multipart.NewReader(bytes.NewReader(data), params["boundary"])
+        for {
+            part, partErr := reader.NextPart()
+            if partErr == io.EOF {
+                break
+            }
+            if partErr != nil {
+                err = fmt.Errorf("body is not a valid multipart form: %v", partErr)
+                return
+            }
+            if part.FormName() == name {
+                content, _ := ioutil.ReadAll(part)
+                values = append(values, string(content))
+            }
+            part.Close()
+        }
+    default:
+        err = fmt.Errorf("body is not a form (Content-Type %q)", contentType)
+    }
+    return
+}
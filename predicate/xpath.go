@@ -0,0 +1,382 @@
+package predicate
+
+import (
+    "bytes"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common"
+)
+
+/*
+    XPath supports the location paths XML and SOAP bodies are usually matched
+    with: `/a/b`, `//b`, `*`, `@name`, `@*`, `text()` and the filters `[n]`,
+    `[@name]`, `[@name='value']`, `[child='value']` and `[text()='value']`.
+    Names are compared without their namespace prefix so `/soap:Envelope`
+    and `/Envelope` select the same element whatever prefix the body uses.
+    Functions, axes and operators are reported as unsupported.
+ */
+
+var (
+    ErrUnsupportedXPath error = errors.New("Predicate: Unsupported XPath expression")
+)
+
+// XPath requires the string value of a node the expression selects in the XML body to satisfy
+// the matcher, or just a selected node when the matcher is nil
+func XPath(expression string, m Matcher) (p Predicate, err error) {
+    path, err := compileXPath(expression)
+    if err != nil {
+        return
+    }
+    subject := "XML body at " + expression
+    p = New(
+        describe(subject, m),
+        func(request *common.HttpCall) string {
+            data, readErr := request.Bytes()
+            if readErr != nil {
+                return unreadable(readErr)
+            }
+            document, parseErr := parseXML(data)
+            if parseErr != nil {
+                return fmt.Sprintf("body is not XML: %v", parseErr)
+            }
+            return matchValues(subject, m, path.evaluate(document))
+        },
+    )
+    return
+}
+
+type xmlNode struct {
+    name     string
+    attrs    []xml.Attr
+    children []*xmlNode
+    // text is set for character data nodes, which have no name
+    text     string
+    isText   bool
+}
+
+// value is the XPath string value: the concatenated text of every descendant
+func (n *xmlNode) value() string {
+    if n.isText {
+        return n.text
+    }
+    var builder strings.Builder
+    for _, child := range n.children {
+        builder.WriteString(child.value())
+    }
+    return builder.String()
+}
+
+func (n *xmlNode) elements() (result []*xmlNode) {
+    for _, child := range n.children {
+        if !child.isText {
+            result = append(result, child)
+        }
+    }
+    return
+}
+
+func (n *xmlNode) attr(name string) (value string, ok bool) {
+    for _, attr := range n.attrs {
+        if attr.Name.Local == name {
+            return attr.Value, true
+        }
+    }
+    return
+}
+
+// parseXML builds the document tree; the returned node is the document root holding the top element
+func parseXML(data []byte) (root *xmlNode, err error) {
+    decoder := xml.NewDecoder(bytes.NewReader(data))
+    root = &xmlNode{}
+    stack := []*xmlNode{root}
+    for {
+        token, tokenErr := decoder.Token()
+        if tokenErr == io.EOF {
+            break
+        }
+        if tokenErr != nil {
+            err = tokenErr
+            return
+        }
+        current := stack[len(stack)-1]
+        switch t := token.(type) {
+        case xml.StartElement:
+            element := &xmlNode{name: t.Name.Local, attrs: t.Attr}
+            current.children = append(current.children, element)
+            stack = append(stack, element)
+        case xml.EndElement:
+            stack = stack[:len(stack)-1]
+        case xml.CharData:
+            if len(stack) > 1 {
+                current.children = append(current.children, &xmlNode{text: string(t), isText: true})
+            }
+        }
+    }
+    if len(root.elements()) == 0 {
+        err = errors.New("no root element")
+    }
+    return
+}
+
+type xpathFilter struct {
+    position int
+    // kind is "attr", "child" or "text"; name is the attribute or child element
+    kind     string
+    name     string
+    value    string
+    hasValue bool
+}
+
+type xpathStep struct {
+    descendant bool
+    // test is an element name, `*`, `@name`, `@*` or `text()`
+    test    string
+    filters []xpathFilter
+}
+
+type xpath struct {
+    expression string
+    steps      []xpathStep
+}
+
+func compileXPath(expression string) (xp *xpath, err error) {
+    unsupported := func(detail string) error {
+        return fmt.Errorf("%w: %q %s", ErrUnsupportedXPath, expression, detail)
+    }
+    if !strings.HasPrefix(expression, "/") {
+        err = unsupported("must be an absolute path")
+        return
+    }
+
+    xp = &xpath{expression: expression}
+    rest := expression
+    for len(rest) > 0 {
+        step := xpathStep{}
+        switch {
+        case strings.HasPrefix(rest, "//"):
+            step.descendant = true
+            rest = rest[2:]
+        case strings.HasPrefix(rest, "/"):
+            rest = rest[1:]
+        default:
+            err = unsupported("has an unexpected " + strconv.Quote(rest))
+            return
+        }
+
+        end := strings.IndexAny(rest, "/[")
+        if end < 0 {
+            end = len(rest)
+        }
+        raw := rest[:end]
+        step.test = stripPrefix(raw)
+        rest = rest[end:]
+        if strings.Contains(raw, "::") {
+            err = unsupported("uses the axis " + strconv.Quote(raw))
+            return
+        }
+        if !validTest(step.test) {
+            err = unsupported("uses " + strconv.Quote(step.test) + "; only names, *, @name and text() are supported")
+            return
+        }
+
+        for strings.HasPrefix(rest, "[") {
+            end := closingBracket(rest)
+            if end < 0 {
+                err = unsupported("has an unterminated [")
+                return
+            }
+            filter, ok := parseFilter(strings.TrimSpace(rest[1:end]))
+            if !ok {
+                err = unsupported("uses the filter " + rest[:end+1])
+                return
+            }
+            step.filters = append(step.filters, filter)
+            rest = rest[end+1:]
+        }
+
+        terminal := strings.HasPrefix(step.test, "@") || step.test == "text()"
+        if terminal && len(rest) > 0 {
+            err = unsupported("continues after " + step.test)
+            return
+        }
+        xp.steps = append(xp.steps, step)
+    }
+    return
+}
+
+func stripPrefix(name string) string {
+    if index := strings.Index(name, ":"); index >= 0 {
+        if strings.HasPrefix(name, "@") {
+            return "@" + name[index+1:]
+        }
+        return name[index+1:]
+    }
+    return name
+}
+
+func validTest(test string) bool {
+    switch {
+    case test == "*" || test == "@*" || test == "text()":
+        return true
+    case strings.HasPrefix(test, "@"):
+        test = test[1:]
+    }
+    if len(test) == 0 {
+        return false
+    }
+    return !strings.ContainsAny(test, "()=<>!'\" ")
+}
+
+// closingBracket finds the end of the filter starting the string, skipping quoted text
+func closingBracket(s string) int {
+    var quote byte
+    for index := 1; index < len(s); index++ {
+        switch {
+        case quote != 0:
+            if s[index] == quote {
+                quote = 0
+            }
+        case s[index] == '\'' || s[index] == '"':
+            quote = s[index]
+        case s[index] == ']':
+            return index
+        }
+    }
+    return -1
+}
+
+func parseFilter(content string) (filter xpathFilter, ok bool) {
+    if position, err := strconv.Atoi(content); err == nil {
+        return xpathFilter{position: position}, position > 0
+    }
+
+    subject, value := content, ""
+    if index := strings.Index(content, "="); index >= 0 {
+        subject = strings.TrimSpace(content[:index])
+        quoted := strings.TrimSpace(content[index+1:])
+        if len(quoted) < 2 || (quoted[0] != '\'' && quoted[0] != '"') || quoted[len(quoted)-1] != quoted[0] {
+            return
+        }
+        value = quoted[1 : len(quoted)-1]
+        filter.hasValue = true
+        filter.value = value
+    }
+
+    switch {
+    case subject == "text()":
+        filter.kind = "text"
+    case strings.HasPrefix(subject, "@"):
+        filter.kind = "attr"
+        filter.name = stripPrefix(subject)[1:]
+    default:
+        filter.kind = "child"
+        filter.name = stripPrefix(subject)
+    }
+    ok = validTest(subject) && (filter.kind != "text" || filter.hasValue)
+    return
+}
+
+// evaluate returns the string values of the selected nodes
+func (xp *xpath) evaluate(root *xmlNode) (values []string) {
+    current := []*xmlNode{root}
+    for _, step := range xp.steps {
+        contexts := current
+        if step.descendant {
+            contexts = []*xmlNode{}
+            for _, node := range current {
+                contexts = appendDescendants(contexts, node)
+            }
+        }
+
+        switch {
+        case strings.HasPrefix(step.test, "@"):
+            for _, node := range contexts {
+                for _, attr := range node.attrs {
+                    if step.test == "@*" || attr.Name.Local == step.test[1:] {
+                        values = append(values, attr.Value)
+                    }
+                }
+            }
+            return
+        case step.test == "text()":
+            for _, node := range contexts {
+                for _, child := range node.children {
+                    if child.isText {
+                        values = append(values, child.text)
+                    }
+                }
+            }
+            return
+        }
+
+        next := []*xmlNode{}
+        for _, node := range contexts {
+            candidates := []*xmlNode{}
+            for _, child := range node.elements() {
+                if step.test == "*" || child.name == step.test {
+                    candidates = append(candidates, child)
+                }
+            }
+            for _, filter := range step.filters {
+                candidates = filter.apply(candidates)
+            }
+            next = append(next, candidates...)
+        }
+        current = next
+    }
+
+    for _, node := range current {
+        values = append(values, node.value())
+    }
+    return
+}
+
+func appendDescendants(result []*xmlNode, node *xmlNode) []*xmlNode {
+    result = append(result, node)
+    for _, child := range node.elements() {
+        result = appendDescendants(result, child)
+    }
+    return result
+}
+
+func (filter xpathFilter) apply(candidates []*xmlNode) (result []*xmlNode) {
+    if filter.position > 0 {
+        if filter.position <= len(candidates) {
+            result = append(result, candidates[filter.position-1])
+        }
+        return
+    }
+    for _, node := range candidates {
+        if filter.isMatch(node) {
+            result = append(result, node)
+        }
+    }
+    return
+}
+
+func (filter xpathFilter) isMatch(node *xmlNode) bool {
+    switch filter.kind {
+    case "attr":
+        value, ok := node.attr(filter.name)
+        return ok && (!filter.hasValue || value == filter.value)
+    case "text":
+        for _, child := range node.children {
+            if child.isText && child.text == filter.value {
+                return true
+            }
+        }
+        return false
+    default:
+        for _, child := range node.elements() {
+            if child.name == filter.name && (!filter.hasValue || child.value() == filter.value) {
+                return true
+            }
+        }
+        return false
+    }
+}
@@ -0,0 +1,62 @@
+package predicate_test
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/predicate"
+)
+
+func Test_Predicate_XPath(t *testing.T) {
+    envelope := `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:orders">
+  <soap:Body>
+    <m:GetOrder id="17">
+      <m:Item sku="A1">Widget</m:Item>
+      <m:Item sku="B2">Gadget</m:Item>
+    </m:GetOrder>
+  </soap:Body>
+</soap:Envelope>`
+    call := newCall(t, "POST", "https://api.example/soap", "text/xml", envelope)
+
+    for _, tc := range []struct {
+        expression string
+        value      string
+        expected   bool
+    }{
+        {"/soap:Envelope/soap:Body/m:GetOrder/@id", "17", true},
+        {"/Envelope/Body/GetOrder/@id", "17", true},
+        {"//Item[2]", "Gadget", true},
+        {"//Item[@sku='A1']/text()", "Widget", true},
+        {"//GetOrder[Item='Gadget']/@id", "17", true},
+        {"//Item/@*", "B2", true},
+        {"/Envelope/*/GetOrder/Item[1]", "Gadget", false},
+        {"//Item[@sku='C3']", "Widget", false},
+    } {
+        p, err := predicate.XPath(tc.expression, predicate.Equals(tc.value))
+        if err != nil {
+            t.Errorf("%s: unexpected error: %v", tc.expression, err)
+            continue
+        }
+        if result := predicate.IsMatch(call, p); result != tc.expected {
+            t.Errorf("%s: returned %t: %v", tc.expression, result, p.Check(call))
+        }
+    }
+
+    present, _ := predicate.XPath("//GetOrder", nil)
+    missing, _ := predicate.XPath("//CancelOrder", nil)
+    if !predicate.IsMatch(call, present) || predicate.IsMatch(call, missing) {
+        t.Errorf("Unexpected presence result")
+    }
+
+    for _, expression := range []string{"count(//Item)", "Item", "//Item[last()]", "//@id/text()", "/ancestor::Body"} {
+        if _, err := predicate.XPath(expression, nil); !errors.Is(err, predicate.ErrUnsupportedXPath) {
+            t.Errorf("%s: expected ErrUnsupportedXPath but found %v", expression, err)
+        }
+    }
+
+    notXML := newCall(t, "POST", "https://api.example/soap", "text/plain", "hello")
+    if err := present.Check(notXML); err == nil {
+        t.Errorf("Expected a body that isn't XML to fail")
+    }
+}
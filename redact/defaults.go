@@ -0,0 +1,68 @@
+package redact
+
+import (
+    "regexp"
+)
+
+var (
+    // HTTP dates, e.g the `Date` and `Expires` headers
+    NormalizeHttpDate = Normalizer{
+        Name: "date",
+        Pattern: regexp.MustCompile(`(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun), \d{2} (?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) \d{4} \d{2}:\d{2}:\d{2} GMT`),
+    }
+    // RFC3339/ISO8601 timestamps
+    NormalizeTimestamp = Normalizer{
+        Name: "timestamp",
+        Pattern: regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})`),
+    }
+    // UUIDs as commonly used for request ids
+    NormalizeUUID = Normalizer{
+        Name: "uuid",
+        Pattern: regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`),
+    }
+)
+
+// Default generates a Redactor covering the common credentials and volatile fields
+func Default() *Redactor {
+    return &Redactor{
+        Headers: []Rule{
+            {Name: "Authorization"},
+            {Name: "Proxy-Authorization"},
+            {Name: "Cookie"},
+            {Name: "Set-Cookie"},
+            {Name: "X-Api-Key"},
+            {Name: "X-Auth-Token"},
+            {Name: "X-Amz-Security-Token"},
+            {Pattern: regexp.MustCompile(`(?i)(secret|signature|nonce)`)},
+        },
+        Query: []Rule{
+            {Name: "access_token"},
+            {Name: "api_key"},
+            {Name: "apikey"},
+            {Name: "token"},
+            {Name: "X-Amz-Signature"},
+            {Name: "X-Amz-Credential"},
+            {Name: "X-Amz-Security-Token"},
+            {Pattern: regexp.MustCompile(`(?i)(secret|signature|^sig$|nonce)`)},
+        },
+        JSONFields: []Rule{
+            {Name: "password"},
+            {Name: "token"},
+            {Name: "access_token"},
+            {Name: "refresh_token"},
+            {Name: "id_token"},
+            {Name: "client_secret"},
+            {Name: "api_key"},
+            {Pattern: regexp.MustCompile(`(?i)(secret|nonce)`)},
+        },
+        VolatileHeaders: []Rule{
+            {Name: "X-Request-Id", Placeholder: "request-id"},
+            {Name: "X-Correlation-Id", Placeholder: "request-id"},
+        },
+        Normalizers: []Normalizer{
+            NormalizeHttpDate,
+            NormalizeTimestamp,
+            NormalizeUUID,
+        },
+    }
+}
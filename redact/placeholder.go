@@ -0,0 +1,65 @@
+package redact
+
+import (
+    "regexp"
+    "strings"
+)
+
+/*
+    Placeholders are the markers left behind in place of redacted or
+    normalized values, e.g `[[gsiab:authorization]]`. They are plain text
+    so they survive JSON, HAR and YAML encoding untouched, and replay
+    matching treats each one as a wildcard via `Matches`.
+
+    Names are folded to lower case and any character outside `[a-z0-9_.-]`
+    becomes `_`, so every placeholder is recognisable. The percent-encoded
+    form (as `url.URL` writes a placeholder in the userinfo) is recognised
+    too, so redacted URLs still match after a round trip through `String()`.
+ */
+
+const (
+    placeholderPrefix = "[[gsiab:"
+    placeholderSuffix = "]]"
+)
+
+var (
+    placeholderRegex = regexp.MustCompile(`(?:\[\[|%5[Bb]%5[Bb])gsiab(?::|%3[Aa])[a-z0-9_.\-]*(?:\]\]|%5[Dd]%5[Dd])`)
+    placeholderNameRegex = regexp.MustCompile(`[^a-z0-9_.\-]`)
+)
+
+// Placeholder generates the marker used in place of the named value
+func Placeholder(name string) string {
+    name = placeholderNameRegex.ReplaceAllLiteralString(strings.ToLower(name), "_")
+    return placeholderPrefix + name + placeholderSuffix
+}
+
+// HasPlaceholder reports whether the value contains any placeholder
+func HasPlaceholder(value string) bool {
+    return placeholderRegex.MatchString(value)
+}
+
+// Matches compares a recorded value that may contain placeholders against
+// a live value; each placeholder matches any (possibly empty) run of text
+func Matches(expected string, actual string) bool {
+    if !HasPlaceholder(expected) {
+        return expected == actual
+    }
+
+    pattern := MatchPattern(expected)
+    return pattern.MatchString(actual)
+}
+
+// MatchPattern converts a value containing placeholders into the regular expression Matches uses
+func MatchPattern(expected string) *regexp.Regexp {
+    var builder strings.Builder
+    builder.WriteString("^")
+    last := 0
+    for _, loc := range placeholderRegex.FindAllStringIndex(expected, -1) {
+        builder.WriteString(regexp.QuoteMeta(expected[last:loc[0]]))
+        builder.WriteString("(?s:.*?)")
+        last = loc[1]
+    }
+    builder.WriteString(regexp.QuoteMeta(expected[last:]))
+    builder.WriteString("$")
+    return regexp.MustCompile(builder.String())
+}
@@ -0,0 +1,315 @@
+package redact
+
+import (
+    "encoding/json"
+    "fmt"
+    "mime"
+    "net/http"
+    "net/url"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+/*
+    The Redactor scrubs secrets and volatile values out of traffic before it
+    is logged, recorded or exported. It only works with the standard library
+    request/response types (and the raw bodies) so that the router and any
+    exporter built on top of it can share the same configuration.
+
+    Scrubbing replaces the value with a placeholder (see `Placeholder`) so that
+    replaying the traffic later can still match it using `Matches`.
+ */
+
+// Rule identifies a header, query parameter or JSON field by name
+type Rule struct {
+    // Name is compared case-insensitively against the field name
+    Name string
+    // Pattern is used to match the field name if Name is empty
+    Pattern *regexp.Regexp
+    // Placeholder is the name put in the placeholder; defaults to the field name
+    Placeholder string
+}
+
+func (r Rule) IsMatch(field string) bool {
+    if len(r.Name) > 0 {
+        return strings.EqualFold(r.Name, field)
+    }
+    if r.Pattern != nil {
+        return r.Pattern.MatchString(field)
+    }
+    return false
+}
+
+func (r Rule) placeholder(field string) string {
+    if len(r.Placeholder) > 0 {
+        return Placeholder(r.Placeholder)
+    }
+    return Placeholder(field)
+}
+
+// Normalizer replaces volatile values (dates, request ids, nonces) anywhere in the text
+type Normalizer struct {
+    Name    string
+    Pattern *regexp.Regexp
+}
+
+type Redactor struct {
+    Headers     []Rule
+    Query       []Rule
+    JSONFields  []Rule
+    Normalizers []Normalizer
+    // VolatileHeaders are replaced like Headers but, as with the Normalizers, they aren't
+    // secret so Secrets leaves them out (e.g request ids)
+    VolatileHeaders []Rule
+}
+
+func matchRule(rules []Rule, field string) (rule Rule, ok bool) {
+    for _, rule = range rules {
+        if rule.IsMatch(field) {
+            ok = true
+            return
+        }
+    }
+    return
+}
+
+// Secrets returns a copy that only scrubs the secret rules, leaving volatile
+// values such as request ids and timestamps intact (e.g for log messages)
+func (rd *Redactor) Secrets() *Redactor {
+    if rd == nil {
+        return nil
+    }
+    return &Redactor{
+        Headers: rd.Headers,
+        Query: rd.Query,
+        JSONFields: rd.JSONFields,
+    }
+}
+
+// String applies the normalizers to an arbitrary value
+func (rd *Redactor) String(value string) string {
+    if rd == nil {
+        return value
+    }
+    for _, normalizer := range rd.Normalizers {
+        if normalizer.Pattern == nil {
+            continue
+        }
+        value = normalizer.Pattern.ReplaceAllLiteralString(value, Placeholder(normalizer.Name))
+    }
+    return value
+}
+
+// Header returns a scrubbed copy of the headers
+func (rd *Redactor) Header(header http.Header) (result http.Header) {
+    if header == nil {
+        return
+    }
+    result = make(http.Header, len(header))
+    for name, values := range header {
+        scrubbed := make([]string, len(values))
+        rule, isSecret := Rule{}, false
+        if rd != nil {
+            rule, isSecret = matchRule(rd.Headers, name)
+            if !isSecret {
+                rule, isSecret = matchRule(rd.VolatileHeaders, name)
+            }
+        }
+        for index, value := range values {
+            if isSecret {
+                scrubbed[index] = rule.placeholder(name)
+            } else {
+                scrubbed[index] = rd.String(value)
+            }
+        }
+        result[name] = scrubbed
+    }
+    return
+}
+
+// RawQuery returns a scrubbed copy of the encoded query string
+func (rd *Redactor) RawQuery(rawQuery string) string {
+    if rd == nil || len(rawQuery) == 0 {
+        return rawQuery
+    }
+
+    values, err := url.ParseQuery(rawQuery)
+    if err != nil {
+        // can't safely pick out the parameters so scrub the whole thing
+        return Placeholder("query")
+    }
+
+    changed := false
+    for name, entries := range values {
+        rule, isSecret := matchRule(rd.Query, name)
+        for index, entry := range entries {
+            replacement := rd.String(entry)
+            if isSecret {
+                replacement = rule.placeholder(name)
+            }
+            if replacement != entry {
+                entries[index] = replacement
+                changed = true
+            }
+        }
+    }
+    if !changed {
+        return rawQuery
+    }
+    // keep the placeholders readable instead of percent encoding them
+    return encodeQuery(values)
+}
+
+func encodeQuery(values url.Values) string {
+    keys := make([]string, 0, len(values))
+    for key := range values {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    parts := []string{}
+    for _, key := range keys {
+        for _, value := range values[key] {
+            escaped := url.QueryEscape(value)
+            if HasPlaceholder(value) {
+                escaped = value
+            }
+            parts = append(parts, url.QueryEscape(key)+"="+escaped)
+        }
+    }
+    return strings.Join(parts, "&")
+}
+
+// URL returns a scrubbed copy of the URL
+func (rd *Redactor) URL(u *url.URL) (result *url.URL) {
+    if u == nil {
+        return
+    }
+    copied := *u
+    result = &copied
+    if u.User != nil {
+        if _, hasPassword := u.User.Password(); hasPassword {
+            result.User = url.UserPassword(u.User.Username(), Placeholder("password"))
+        } else {
+            // a bare username is often a token, e.g https://TOKEN@api.example
+            result.User = url.User(Placeholder("username"))
+        }
+    }
+    result.RawQuery = rd.RawQuery(u.RawQuery)
+    if rd != nil {
+        if path := rd.String(u.Path); path != u.Path {
+            // keep the placeholders readable instead of percent encoding them
+            result.Path = path
+            result.RawPath = path
+        }
+    }
+    return
+}
+
+// Body returns a scrubbed copy of the body; JSON bodies have the configured fields
+// replaced and every textual body has the normalizers applied
+func (rd *Redactor) Body(contentType string, body []byte) []byte {
+    if rd == nil || len(body) == 0 {
+        return body
+    }
+
+    mediaType, _, _ := mime.ParseMediaType(contentType)
+    switch {
+    case isJSON(mediaType):
+        var decoded interface{}
+        if err := json.Unmarshal(body, &decoded); err == nil {
+            if rd.scrubJSON(decoded) {
+                if encoded, encodeErr := json.Marshal(decoded); encodeErr == nil {
+                    body = encoded
+                }
+            }
+        }
+        return []byte(rd.String(string(body)))
+    case isText(mediaType):
+        return []byte(rd.String(string(body)))
+    case mediaType == "application/x-www-form-urlencoded":
+        return []byte(rd.RawQuery(string(body)))
+    }
+    return body
+}
+
+func (rd *Redactor) scrubJSON(value interface{}) (changed bool) {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        for key, entry := range v {
+            if rule, isSecret := matchRule(rd.JSONFields, key); isSecret {
+                v[key] = rule.placeholder(key)
+                changed = true
+                continue
+            }
+            if rd.scrubJSON(entry) {
+                changed = true
+            }
+        }
+    case []interface{}:
+        for _, entry := range v {
+            if rd.scrubJSON(entry) {
+                changed = true
+            }
+        }
+    }
+    return
+}
+
+func isJSON(mediaType string) bool {
+    return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func isText(mediaType string) bool {
+    return strings.HasPrefix(mediaType, "text/") ||
+        strings.HasSuffix(mediaType, "+xml") ||
+        mediaType == "application/xml" ||
+        mediaType == "application/javascript"
+}
+
+var urlRegex = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.\-]*://[^\s"'<>]+`)
+
+// Text scrubs any URLs found in free-form text (e.g log messages) and applies the normalizers
+func (rd *Redactor) Text(text string) string {
+    if rd == nil {
+        return text
+    }
+    text = urlRegex.ReplaceAllStringFunc(
+        text,
+        func(match string) string {
+            u, err := url.Parse(match)
+            if err != nil {
+                return match
+            }
+            return rd.URL(u).String()
+        },
+    )
+    return rd.String(text)
+}
+
+// DescribeRequest generates a redacted single line summary of the request suitable for logs
+func (rd *Redactor) DescribeRequest(request *http.Request) string {
+    if request == nil {
+        return "<nil request>"
+    }
+    return fmt.Sprintf(
+        "%s %s Headers: %v",
+        request.Method,
+        rd.URL(request.URL),
+        rd.Header(request.Header),
+    )
+}
+
+// DescribeResponse generates a redacted single line summary of the response suitable for logs
+func (rd *Redactor) DescribeResponse(response *http.Response) string {
+    if response == nil {
+        return "<nil response>"
+    }
+    return fmt.Sprintf(
+        "%d Length: %d Headers: %v",
+        response.StatusCode,
+        response.ContentLength,
+        rd.Header(response.Header),
+    )
+}
@@ -0,0 +1,24 @@
+package router
+
+import (
+    "net/http"
+    "net/url"
+)
+
+// serviceName finds the registered service handling the URL, if any, the same way ServiceRouter does
+func (irt *Router) serviceName(requestUrl *url.URL) string {
+    if requestUrl == nil {
+        return ""
+    }
+    for _, name := range irt.services() {
+        if ok, err := irt.RequestHandlers[name].GetMatcher().IsMatch(*requestUrl); err == nil && ok {
+            return name
+        }
+    }
+    return ""
+}
+
+// withChaos lets Chaos disrupt requests on their way to the services
+func (irt *Router) withChaos(request *http.Request) (*http.Response, error) {
+    return irt.Chaos.Apply(request, irt.serviceName(request.URL), irt.redactor(), irt.BuildResponse, irt.ServiceRouter)
+}
@@ -0,0 +1,115 @@
+package router
+
+import (
+    "context"
+    "io"
+    "sync"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    A real transport gives up on a request as soon as its context is done:
+    RoundTrip returns context.Canceled or context.DeadlineExceeded, and so
+    does reading the body of a response already returned. runHandler and
+    withContext do the same for the router so client timeouts can be tested
+    against slow handlers and slow bodies.
+ */
+
+type handlerOutcome struct {
+    reply *common.HttpReply
+    err   error
+    // panicked is set, with the value in recovered, if the handler panicked
+    panicked  bool
+    recovered interface{}
+}
+
+// runHandler calls the handler, failing at the transport level with the context error instead
+// if the context ends first; a handler still running then is left to finish and its reply is discarded
+func runHandler(handler common.HttpHandler, call *common.HttpCall) (reply *common.HttpReply, err error) {
+    ctx := call.Context()
+    if ctx.Done() == nil {
+        return handler(call)
+    }
+    if ctxErr := ctx.Err(); ctxErr != nil {
+        err = &common.TransportError{Err: ctxErr}
+        return
+    }
+
+    done := make(chan handlerOutcome, 1)
+    go func() {
+        outcome := handlerOutcome{panicked: true}
+        defer func() {
+            if outcome.panicked {
+                outcome.recovered = recover()
+            }
+            done <- outcome
+        }()
+        outcome.reply, outcome.err = handler(call)
+        outcome.panicked = false
+    }()
+
+    select {
+    case outcome := <-done:
+        if outcome.panicked {
+            // panic where the caller can see it, as it would without the context
+            panic(outcome.recovered)
+        }
+        return outcome.reply, outcome.err
+    case <-ctx.Done():
+        log.Printf("Request context ended before the handler replied: %v", ctx.Err())
+        go func() {
+            outcome := <-done
+            if outcome.reply != nil && outcome.reply.ResponseData != nil {
+                outcome.reply.ResponseData.Close()
+            }
+        }()
+        return nil, &common.TransportError{Err: ctx.Err()}
+    }
+}
+
+// contextBody fails reads with the context error once the context ends, closing the
+// underlying body to unblock a read waiting on it
+type contextBody struct {
+    body   io.ReadCloser
+    ctx    context.Context
+    closed chan struct{}
+    once   sync.Once
+}
+
+func withContext(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+    if body == nil || ctx.Done() == nil {
+        return body
+    }
+    cb := &contextBody{body: body, ctx: ctx, closed: make(chan struct{})}
+    go func() {
+        select {
+        case <-ctx.Done():
+            cb.body.Close()
+        case <-cb.closed:
+        }
+    }()
+    return cb
+}
+
+func (cb *contextBody) Read(p []byte) (n int, err error) {
+    if err = cb.ctx.Err(); err != nil {
+        return
+    }
+    n, err = cb.body.Read(p)
+    if err != nil && err != io.EOF {
+        if ctxErr := cb.ctx.Err(); ctxErr != nil {
+            err = ctxErr
+        }
+    }
+    return
+}
+
+func (cb *contextBody) Close() (err error) {
+    cb.once.Do(func() {
+        close(cb.closed)
+        err = cb.body.Close()
+    })
+    return
+}
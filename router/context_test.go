@@ -0,0 +1,101 @@
+package router_test
+
+import (
+    "context"
+    "errors"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+)
+
+func newContextRouter(t *testing.T, handler common.HttpHandler) *http.Client {
+    r := router.New()
+    svc := &service.ServiceHandler{}
+    svc.Init("slow", &common.BasicServerURI{Protocol: "https", Host: "slow.example"})
+    svc.FuncHandler = handler
+    if err := r.RegisterService("slow", svc); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    return &http.Client{Transport: r}
+}
+
+func Test_Router_Context(t *testing.T) {
+    release := make(chan struct{})
+    defer close(release)
+    hasDeadline := make(chan bool, 2)
+    client := newContextRouter(t, func(call *common.HttpCall) (*common.HttpReply, error) {
+        _, ok := call.Context().Deadline()
+        hasDeadline <- ok
+        // ignores the context, like a handler doing slow work would
+        <-release
+        return common.NewReply(200).Text("late"), nil
+    })
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    request, _ := http.NewRequestWithContext(ctx, "GET", "https://slow.example/", nil)
+    started := time.Now()
+    if _, err := client.Do(request); !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("Expected context.DeadlineExceeded but found %v", err)
+    }
+    if elapsed := time.Since(started); elapsed > time.Second {
+        t.Errorf("The router waited for the handler: %v", elapsed)
+    }
+    if !<-hasDeadline {
+        t.Errorf("The handler did not see the request deadline")
+    }
+
+    ctx, cancel = context.WithCancel(context.Background())
+    cancel()
+    request, _ = http.NewRequestWithContext(ctx, "GET", "https://slow.example/", nil)
+    if _, err := client.Do(request); !errors.Is(err, context.Canceled) {
+        t.Errorf("Expected context.Canceled but found %v", err)
+    }
+}
+
+func Test_Router_ContextBody(t *testing.T) {
+    reader, writer := io.Pipe()
+    defer writer.Close()
+    client := newContextRouter(t, func(call *common.HttpCall) (*common.HttpReply, error) {
+        return common.NewReply(200).Stream(reader, -1), nil
+    })
+
+    ctx, cancel := context.WithCancel(context.Background())
+    request, _ := http.NewRequestWithContext(ctx, "GET", "https://slow.example/", nil)
+    response, err := client.Do(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    go func() {
+        writer.Write([]byte("partial"))
+        time.Sleep(20 * time.Millisecond)
+        cancel()
+    }()
+    data, err := ioutil.ReadAll(response.Body)
+    if !errors.Is(err, context.Canceled) || string(data) != "partial" {
+        t.Errorf("Expected the read to fail with context.Canceled: %q %v", data, err)
+    }
+    response.Body.Close()
+}
+
+func Test_Router_ContextPanic(t *testing.T) {
+    client := newContextRouter(t, func(call *common.HttpCall) (*common.HttpReply, error) {
+        panic("handler failure")
+    })
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    request, _ := http.NewRequestWithContext(ctx, "GET", "https://slow.example/", nil)
+
+    defer func() {
+        if recovered := recover(); recovered != "handler failure" {
+            t.Errorf("Expected the handler panic to reach the caller but found %v", recovered)
+        }
+    }()
+    client.Transport.RoundTrip(request)
+}
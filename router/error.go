@@ -8,4 +8,7 @@ var (
     ErrResponseBuildingInternalError error = errors.New("Service Router: Internal Error while building response")
     ErrServiceHandlerAlreadyRegister error = errors.New("Service Router: Already Registered")
     ErrInvalidRequest error = errors.New("Service Router: Invalid Request")
+    ErrRouteNotHandled error = errors.New("Service Router: Route Not Handled")
+    ErrInvalidAllowlistEntry error = errors.New("Service Router: Invalid Allowlist Entry")
+    ErrReplyLengthMismatch error = errors.New("Service Router: Reply Length does not match the body")
 )
@@ -0,0 +1,111 @@
+package router
+
+import (
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    Requests that no service handles normally get the 595 (Route Not Handled)
+    reply. When the Router has a Fallback transport configured those requests
+    may instead be passed through to a real service, but only when the target
+    host is on the Allowlist; the intent being to allow tests to reach genuinely
+    local services (e.g a database's HTTP API on 127.0.0.1) while everything
+    else is still mocked.
+
+    NOTE: The allowlist never performs DNS lookups. CIDR entries only match when
+    the request URL uses an IP address for the host.
+ */
+
+type Allowlist struct {
+    hosts    map[string]bool
+    networks []*net.IPNet
+}
+
+// NewAllowlist builds an allowlist from host names (optionally with a port, e.g `localhost:8080`),
+// IP addresses and CIDRs (e.g `127.0.0.0/8`)
+func NewAllowlist(entries ...string) (al *Allowlist, err error) {
+    al = &Allowlist{
+        hosts: make(map[string]bool),
+    }
+    for _, entry := range entries {
+        if addErr := al.Add(entry); addErr != nil {
+            err = addErr
+            al = nil
+            return
+        }
+    }
+    return
+}
+
+func (al *Allowlist) Add(entry string) (err error) {
+    entry = strings.TrimSpace(strings.ToLower(entry))
+    if len(entry) == 0 {
+        err = fmt.Errorf("%w: empty entry", ErrInvalidAllowlistEntry)
+        return
+    }
+
+    if strings.Contains(entry, "/") {
+        _, network, parseErr := net.ParseCIDR(entry)
+        if parseErr != nil {
+            log.Printf("Unable to parse allowlist CIDR %s: %v", entry, parseErr)
+            err = fmt.Errorf("%w: %s - %v", ErrInvalidAllowlistEntry, entry, parseErr)
+            return
+        }
+        al.networks = append(al.networks, network)
+        return
+    }
+
+    al.hosts[entry] = true
+    return
+}
+
+func (al *Allowlist) IsAllowed(u *url.URL) bool {
+    if al == nil || u == nil {
+        return false
+    }
+
+    host := strings.ToLower(u.Hostname())
+    if al.hosts[host] || al.hosts[strings.ToLower(u.Host)] {
+        return true
+    }
+
+    if ip := net.ParseIP(host); ip != nil {
+        for _, network := range al.networks {
+            if network.Contains(ip) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// unhandled generates the result for a request no service matched
+func (irt *Router) unhandled(request *http.Request) (response *http.Response, err error) {
+    if irt.Fallback != nil && irt.FallbackAllowlist.IsAllowed(request.URL) {
+        log.Printf("Passing request for %s through to the fallback transport", irt.logRedactor().URL(request.URL))
+        // the RequestURI was filled in for the services; real transports require it to be empty
+        passthrough := request.Clone(request.Context())
+        passthrough.RequestURI = ""
+        return irt.Fallback.RoundTrip(passthrough)
+    }
+
+    if irt.Strict {
+        log.Printf("Strict mode: no service handles %s", irt.logRedactor().URL(request.URL))
+        err = fmt.Errorf("%w: %s %s", ErrRouteNotHandled, request.Method, irt.logRedactor().URL(request.URL))
+        return
+    }
+
+    // return 595
+    msg := fmt.Sprintf(
+        "gostackinabox: no service to handle URL '%s'",
+        request.URL.String(),
+    )
+    return irt.BuildResponse(common.NewReply(common.HttpStatus_RouteNotHandled).Text(msg), request)
+}
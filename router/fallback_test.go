@@ -0,0 +1,128 @@
+package router_test
+
+import (
+    "errors"
+    "net/http"
+    "net/url"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/router"
+)
+
+type fakeTransport struct {
+    calls []*http.Request
+}
+
+func (ft *fakeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+    ft.calls = append(ft.calls, request)
+    return &http.Response{StatusCode: 204, Status: "204", Request: request, Body: http.NoBody}, nil
+}
+
+func Test_Router_Allowlist(t *testing.T) {
+    t.Run(
+        "invalid entry",
+        func(t *testing.T) {
+            _, err := router.NewAllowlist("10.0.0.0/33")
+            if !errors.Is(err, router.ErrInvalidAllowlistEntry) {
+                t.Errorf("Unexpected error: %v != %v", err, router.ErrInvalidAllowlistEntry)
+            }
+            _, err = router.NewAllowlist(" ")
+            if !errors.Is(err, router.ErrInvalidAllowlistEntry) {
+                t.Errorf("Unexpected error: %v != %v", err, router.ErrInvalidAllowlistEntry)
+            }
+        },
+    )
+    t.Run(
+        "matching",
+        func(t *testing.T) {
+            al, err := router.NewAllowlist("127.0.0.0/8", "localhost", "db.local:9200")
+            if err != nil {
+                t.Fatalf("Unexpected error: %v", err)
+            }
+            scenarios := map[string]bool{
+                "http://127.0.0.1:8080/": true,
+                "http://localhost/": true,
+                "http://LOCALHOST:1234/": true,
+                "http://db.local:9200/": true,
+                "http://db.local:9300/": false,
+                "http://10.1.1.1/": false,
+                "https://api.example.com/": false,
+            }
+            for rawUrl, expected := range scenarios {
+                u, _ := url.Parse(rawUrl)
+                if result := al.IsAllowed(u); result != expected {
+                    t.Errorf("IsAllowed(%s) = %t != %t", rawUrl, result, expected)
+                }
+            }
+
+            var nilAllowlist *router.Allowlist
+            u, _ := url.Parse("http://127.0.0.1/")
+            if nilAllowlist.IsAllowed(u) {
+                t.Errorf("Nil allowlist unexpectedly allowed a request")
+            }
+        },
+    )
+}
+
+func Test_Router_Fallback(t *testing.T) {
+    newRequest := func(rawUrl string) *http.Request {
+        u, _ := url.Parse(rawUrl)
+        return &http.Request{Method: "GET", URL: u}
+    }
+
+    t.Run(
+        "allowed host uses fallback",
+        func(t *testing.T) {
+            ft := &fakeTransport{}
+            irt := router.New()
+            irt.Fallback = ft
+            irt.FallbackAllowlist, _ = router.NewAllowlist("127.0.0.1")
+
+            response, err := irt.RoundTrip(newRequest("http://127.0.0.1:9200/_health"))
+            if err != nil {
+                t.Fatalf("Unexpected error: %v", err)
+            }
+            validateStatus(t, 204, response)
+            if len(ft.calls) != 1 {
+                t.Fatalf("Fallback transport not called: %d", len(ft.calls))
+            }
+            if ft.calls[0].RequestURI != "" {
+                t.Errorf("Fallback request unexpectedly has a RequestURI: %s", ft.calls[0].RequestURI)
+            }
+        },
+    )
+    t.Run(
+        "disallowed host gets 595",
+        func(t *testing.T) {
+            ft := &fakeTransport{}
+            irt := router.New()
+            irt.Fallback = ft
+            irt.FallbackAllowlist, _ = router.NewAllowlist("127.0.0.1")
+
+            response, err := irt.RoundTrip(newRequest("https://api.example.com/"))
+            if err != nil {
+                t.Fatalf("Unexpected error: %v", err)
+            }
+            validateStatus(t, int(common.HttpStatus_RouteNotHandled), response)
+            if len(ft.calls) != 0 {
+                t.Errorf("Fallback transport unexpectedly called: %d", len(ft.calls))
+            }
+        },
+    )
+    t.Run(
+        "strict mode",
+        func(t *testing.T) {
+            irt := router.New()
+            irt.Strict = true
+
+            response, err := irt.RoundTrip(newRequest("https://api.example.com/"))
+            if !errors.Is(err, router.ErrRouteNotHandled) {
+                t.Errorf("Unexpected error: %v != %v", err, router.ErrRouteNotHandled)
+            }
+            if response != nil {
+                t.Errorf("Unexpectedly received a response: %#v", response)
+            }
+        },
+    )
+}
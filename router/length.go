@@ -0,0 +1,57 @@
+package router
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+
+    "github.com/TestInABox/gostackinabox/common"
+)
+
+/*
+    A reply whose Length disagrees with its body makes the client see a wrong
+    ContentLength or a truncated body, so checkLength rejects it. In-memory
+    bodies (common.BytesBody, util.StringToResponseBody) are checked before
+    the response is built; any other body is checked as the client reads it.
+    A Length of -1 means the length isn't known and is never checked.
+ */
+
+func checkLength(reply *common.HttpReply, request *http.Request) (body io.ReadCloser, err error) {
+    body = reply.ResponseData
+    if reply.Length < 0 || request.Method == http.MethodHead {
+        return
+    }
+    if body == nil {
+        if reply.Length > 0 {
+            err = fmt.Errorf("%w: Length is %d but there is no body", ErrReplyLengthMismatch, reply.Length)
+        }
+        return
+    }
+    if sized, ok := body.(interface{ Len() int }); ok {
+        if int64(sized.Len()) != reply.Length {
+            err = fmt.Errorf("%w: Length is %d but the body has %d bytes", ErrReplyLengthMismatch, reply.Length, sized.Len())
+        }
+        return
+    }
+    body = &lengthChecker{ReadCloser: body, expected: reply.Length}
+    return
+}
+
+// lengthChecker fails the read that finds the body is longer or shorter than the reply Length
+type lengthChecker struct {
+    io.ReadCloser
+    expected int64
+    read     int64
+}
+
+func (lc *lengthChecker) Read(p []byte) (n int, err error) {
+    n, err = lc.ReadCloser.Read(p)
+    lc.read += int64(n)
+    switch {
+    case lc.read > lc.expected:
+        err = fmt.Errorf("%w: Length is %d but the body has more bytes", ErrReplyLengthMismatch, lc.expected)
+    case err == io.EOF && lc.read < lc.expected:
+        err = fmt.Errorf("%w: Length is %d but the body has %d bytes", ErrReplyLengthMismatch, lc.expected, lc.read)
+    }
+    return
+}
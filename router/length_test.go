@@ -0,0 +1,56 @@
+package router_test
+
+import (
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/util"
+)
+
+func Test_Router_ReplyLength(t *testing.T) {
+    for _, scenario := range []struct {
+        name      string
+        reply     *common.HttpReply
+        method    string
+        buildErr  bool
+        readErr   bool
+    }{
+        {"builder", common.NewReply(200).Text("hello"), "GET", false, false},
+        {"unknown length", common.NewReply(200).Stream(strings.NewReader("hello"), -1), "GET", false, false},
+        {"forgotten length", &common.HttpReply{Status: 200, ResponseData: util.StringToResponseBody("hello")}, "GET", true, false},
+        {"length without a body", &common.HttpReply{Status: 200, Length: 5}, "GET", true, false},
+        {"head", &common.HttpReply{Status: 200, Length: 5}, "HEAD", false, false},
+        {"stream too short", common.NewReply(200).Stream(strings.NewReader("hello"), 10), "GET", false, true},
+        {"stream too long", common.NewReply(200).Stream(strings.NewReader("hello"), 2), "GET", false, true},
+        {"stream", common.NewReply(200).Stream(strings.NewReader("hello"), 5), "GET", false, false},
+    } {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                request, _ := http.NewRequest(scenario.method, "https://api.example/", nil)
+                response, err := router.New().BuildResponse(scenario.reply, request)
+                if scenario.buildErr {
+                    if !errors.Is(err, router.ErrReplyLengthMismatch) {
+                        t.Errorf("Expected ErrReplyLengthMismatch but found %v", err)
+                    }
+                    return
+                }
+                if err != nil {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+                if response.Body == nil {
+                    return
+                }
+                _, err = ioutil.ReadAll(response.Body)
+                if scenario.readErr != errors.Is(err, router.ErrReplyLengthMismatch) {
+                    t.Errorf("Unexpected read error: %v", err)
+                }
+            },
+        )
+    }
+}
@@ -0,0 +1,189 @@
+package router
+
+import (
+    "bytes"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "sync"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/redact"
+)
+
+/*
+    The Recorder captures the traffic that passes through the Router so that it
+    can be inspected or exported (e.g as a HAR file) once the test is done.
+    Everything recorded has been passed through the Router's Redactor first.
+
+    An exchange is added when the request is intercepted; a streamed request
+    body is captured as the services read it and the response body as the
+    client reads it, so neither side blocks the exchange and the recorded
+    timings include any time spent delivering the body.
+ */
+
+type Exchange struct {
+    Started time.Time
+
+    Method         string
+    URL            *url.URL
+    Proto          string
+    RequestHeaders http.Header
+    RequestBody    []byte
+
+    Status          int
+    ResponseProto   string
+    ResponseHeaders http.Header
+    ResponseBody    []byte
+    // Err is the transport level error returned to the client, if any
+    Err error
+
+    // Wait is the time taken for the router to produce the response
+    Wait time.Duration
+    // Receive is the time taken for the client to consume the response body
+    Receive time.Duration
+}
+
+type Recorder struct {
+    lock      sync.Mutex
+    exchanges []*Exchange
+}
+
+func NewRecorder() *Recorder {
+    return &Recorder{}
+}
+
+// Exchanges returns a snapshot of the recorded traffic in the order it was intercepted
+func (rec *Recorder) Exchanges() (result []Exchange) {
+    rec.lock.Lock()
+    defer rec.lock.Unlock()
+
+    result = make([]Exchange, 0, len(rec.exchanges))
+    for _, exchange := range rec.exchanges {
+        result = append(result, *exchange)
+    }
+    return
+}
+
+func (rec *Recorder) Reset() {
+    rec.lock.Lock()
+    defer rec.lock.Unlock()
+    rec.exchanges = nil
+}
+
+// record captures the request side; the returned exchange is completed by finish.
+// Replayable request bodies (see `http.Request.GetBody`) are copied up front; any
+// other body is captured as the services read it so streamed uploads aren't blocked.
+// A RoundTripper mustn't modify the caller's request so the services are sent the
+// returned copy instead.
+func (rec *Recorder) record(rd *redact.Redactor, original *http.Request) (exchange *Exchange, request *http.Request) {
+    request = original
+    exchange = &Exchange{
+        Started: time.Now(),
+        Method: request.Method,
+        URL: rd.URL(request.URL),
+        Proto: request.Proto,
+        RequestHeaders: rd.Header(request.Header),
+    }
+
+    contentType := request.Header.Get("Content-Type")
+    if request.Body != nil && request.Body != http.NoBody && request.GetBody != nil {
+        if replay, replayErr := request.GetBody(); replayErr == nil {
+            body, _ := ioutil.ReadAll(replay)
+            replay.Close()
+            exchange.RequestBody = rd.Body(contentType, body)
+        }
+    } else if request.Body != nil && request.Body != http.NoBody {
+        copied := *original
+        request = &copied
+        request.Body = &recordingBody{
+            body: request.Body,
+            recorder: rec,
+            redactor: rd,
+            exchange: exchange,
+            contentType: contentType,
+        }
+    }
+
+    rec.lock.Lock()
+    rec.exchanges = append(rec.exchanges, exchange)
+    rec.lock.Unlock()
+    return
+}
+
+func (rec *Recorder) finish(rd *redact.Redactor, exchange *Exchange, response *http.Response, err error) {
+    rec.lock.Lock()
+    defer rec.lock.Unlock()
+
+    exchange.Wait = time.Since(exchange.Started)
+    exchange.Err = err
+    if response == nil {
+        return
+    }
+
+    exchange.Status = response.StatusCode
+    exchange.ResponseProto = response.Proto
+    exchange.ResponseHeaders = rd.Header(response.Header)
+    // the body of an upgraded connection has to stay writable so it isn't recorded
+    if response.Body != nil && response.StatusCode != http.StatusSwitchingProtocols {
+        response.Body = &recordingBody{
+            body: response.Body,
+            recorder: rec,
+            redactor: rd,
+            exchange: exchange,
+            contentType: response.Header.Get("Content-Type"),
+            response: true,
+        }
+    }
+}
+
+// recordingBody captures the request or response data as it is read; the
+// Recorder and Redactor are the ones in use when the exchange was recorded
+type recordingBody struct {
+    body        io.ReadCloser
+    recorder    *Recorder
+    redactor    *redact.Redactor
+    exchange    *Exchange
+    contentType string
+    response    bool
+    data        bytes.Buffer
+    started     time.Time
+    done        bool
+}
+
+func (rb *recordingBody) Read(p []byte) (n int, err error) {
+    if rb.started.IsZero() {
+        rb.started = time.Now()
+    }
+    n, err = rb.body.Read(p)
+    rb.data.Write(p[:n])
+    if err != nil {
+        rb.complete()
+    }
+    return
+}
+
+func (rb *recordingBody) Close() error {
+    rb.complete()
+    return rb.body.Close()
+}
+
+func (rb *recordingBody) complete() {
+    if rb.done {
+        return
+    }
+    rb.done = true
+
+    body := rb.redactor.Body(rb.contentType, rb.data.Bytes())
+    rb.recorder.lock.Lock()
+    defer rb.recorder.lock.Unlock()
+    if !rb.response {
+        rb.exchange.RequestBody = body
+        return
+    }
+    if !rb.started.IsZero() {
+        rb.exchange.Receive = time.Since(rb.started)
+    }
+    rb.exchange.ResponseBody = body
+}
@@ -0,0 +1,151 @@
+package router_test
+
+import (
+    "io"
+    "io/ioutil"
+    "net/http"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/redact"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/util"
+)
+
+func Test_Router_Recorder(t *testing.T) {
+    irt := router.New()
+    irt.Recorder = router.NewRecorder()
+
+    msg := `{"token":"abc","name":"bob"}`
+    var receivedBody string
+    serviceHandler := &service.ServiceHandler{
+        Matcher: &common.BasicServerURI{
+            Protocol: "http",
+            Host: "example.com",
+        },
+        FuncHandler: func(hc *common.HttpCall) (hr *common.HttpReply, err error) {
+            body, _ := ioutil.ReadAll(hc.Request.Body)
+            receivedBody = string(body)
+            hr = &common.HttpReply{
+                Status: 201,
+                Headers: http.Header{"Content-Type": []string{"application/json"}},
+                ResponseData: util.StringToResponseBody(msg),
+                Length: int64(len(msg)),
+            }
+            return
+        },
+    }
+    if err := irt.RegisterService("http://example.com", serviceHandler); err != nil {
+        t.Fatalf("Unexpected error registering service: %v", err)
+    }
+
+    request, _ := http.NewRequest("POST", "http://example.com/login?api_key=secret", strings.NewReader(`{"password":"hunter2"}`))
+    request.Header.Set("Content-Type", "application/json")
+    request.Header.Set("Authorization", "Bearer xyz")
+
+    response, err := irt.RoundTrip(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if receivedBody != `{"password":"hunter2"}` {
+        t.Errorf("Service did not receive the original request body: %s", receivedBody)
+    }
+
+    exchanges := irt.Recorder.Exchanges()
+    if len(exchanges) != 1 {
+        t.Fatalf("Unexpected number of exchanges: %d", len(exchanges))
+    }
+    if exchanges[0].ResponseBody != nil {
+        t.Errorf("Response body recorded before it was read: %s", exchanges[0].ResponseBody)
+    }
+
+    ioutil.ReadAll(response.Body)
+    response.Body.Close()
+
+    exchange := irt.Recorder.Exchanges()[0]
+    if exchange.Method != "POST" || exchange.Status != 201 {
+        t.Errorf("Unexpected exchange recorded: %s %d", exchange.Method, exchange.Status)
+    }
+    if exchange.RequestHeaders.Get("Authorization") != redact.Placeholder("authorization") {
+        t.Errorf("Request headers not redacted: %v", exchange.RequestHeaders)
+    }
+    if strings.Contains(exchange.URL.String(), "secret") {
+        t.Errorf("Request URL not redacted: %s", exchange.URL)
+    }
+    if strings.Contains(string(exchange.RequestBody), "hunter2") {
+        t.Errorf("Request body not redacted: %s", exchange.RequestBody)
+    }
+    if strings.Contains(string(exchange.ResponseBody), "abc") || !strings.Contains(string(exchange.ResponseBody), "bob") {
+        t.Errorf("Response body not redacted as expected: %s", exchange.ResponseBody)
+    }
+
+    irt.Recorder.Reset()
+    if len(irt.Recorder.Exchanges()) != 0 {
+        t.Errorf("Reset did not clear the exchanges")
+    }
+}
+
+func Test_Router_Recorder_Swapped(t *testing.T) {
+    irt := router.New()
+    rec := router.NewRecorder()
+    irt.Recorder = rec
+
+    msg := "hello"
+    var receivedBody string
+    serviceHandler := &service.ServiceHandler{
+        Matcher: &common.BasicServerURI{
+            Protocol: "http",
+            Host: "example.com",
+        },
+        FuncHandler: func(hc *common.HttpCall) (hr *common.HttpReply, err error) {
+            body, _ := ioutil.ReadAll(hc.Request.Body)
+            receivedBody = string(body)
+            hr = &common.HttpReply{
+                Status: 200,
+                ResponseData: util.StringToResponseBody(msg),
+                Length: int64(len(msg)),
+            }
+            return
+        },
+    }
+    if err := irt.RegisterService("http://example.com", serviceHandler); err != nil {
+        t.Fatalf("Unexpected error registering service: %v", err)
+    }
+
+    // a streamed upload is captured as the service reads it
+    reader, writer := io.Pipe()
+    go func() {
+        writer.Write([]byte("streamed"))
+        writer.Close()
+    }()
+    request, _ := http.NewRequest("PUT", "http://example.com/upload", reader)
+    response, err := irt.RoundTrip(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if receivedBody != "streamed" {
+        t.Errorf("Service did not receive the streamed body: %s", receivedBody)
+    }
+    // the caller's request is left as it was handed in
+    if request.Body != reader {
+        t.Errorf("Recorder replaced the caller's request body")
+    }
+    if response.Request != request {
+        t.Errorf("Response does not refer to the caller's request")
+    }
+
+    // the body completes against the recorder in use when the exchange started
+    irt.Recorder = nil
+    ioutil.ReadAll(response.Body)
+    response.Body.Close()
+
+    exchanges := rec.Exchanges()
+    if len(exchanges) != 1 {
+        t.Fatalf("Unexpected number of exchanges: %d", len(exchanges))
+    }
+    if string(exchanges[0].RequestBody) != "streamed" || string(exchanges[0].ResponseBody) != msg {
+        t.Errorf("Unexpected bodies recorded: %q %q", exchanges[0].RequestBody, exchanges[0].ResponseBody)
+    }
+}
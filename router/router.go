@@ -1,13 +1,17 @@
 package router
 
 import (
+    "errors"
     "fmt"
     "net/http"
+    "sort"
 
+    "github.com/TestInABox/gostackinabox/chaos"
     "github.com/TestInABox/gostackinabox/common"
     "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/fault"
+    "github.com/TestInABox/gostackinabox/redact"
     "github.com/TestInABox/gostackinabox/service"
-    "github.com/TestInABox/gostackinabox/util"
 )
 
 type Router struct {
@@ -15,6 +19,24 @@ type Router struct {
     ProtoMinor int
     RequestHandlers service.ServiceHandlerMap // TODO: Update
     DisableCompression bool
+    // Redactor scrubs secrets from anything the router logs or records
+    Redactor *redact.Redactor
+    // Fallback is used for requests no service handles when the host is on the FallbackAllowlist
+    Fallback http.RoundTripper
+    FallbackAllowlist *Allowlist
+    // Strict causes unhandled requests to fail with ErrRouteNotHandled instead of a 595 reply
+    Strict bool
+    // Recorder, when set, captures all the traffic passing through the router
+    Recorder *Recorder
+    // Middleware wraps the handler of every service; see Use
+    Middleware []common.Middleware
+    // Faults, when set, can fail any request the way a real network would
+    Faults *fault.Injector
+    // Chaos, when set, disrupts a share of the requests sent to the registered services
+    Chaos *chaos.Chaos
+
+    // serviceOrder lists the services in the order they were registered
+    serviceOrder []string
 }
 
 func New() *Router {
@@ -23,9 +45,32 @@ func New() *Router {
         ProtoMinor: 1,
         RequestHandlers: make(service.ServiceHandlerMap),
         DisableCompression: true, // compression isn't handled by this transport router
+        Redactor: redact.Default(),
     }
 }
 
+// the zero value Router still needs to keep secrets out of the logs;
+// use an empty redact.Redactor to disable redaction
+var defaultRedactor = redact.Default()
+
+func (irt *Router) redactor() *redact.Redactor {
+    if irt.Redactor == nil {
+        return defaultRedactor
+    }
+    return irt.Redactor
+}
+
+// logRedactor only scrubs secrets so ids and timestamps in the logs can still be correlated
+func (irt *Router) logRedactor() *redact.Redactor {
+    return irt.redactor().Secrets()
+}
+
+// Use adds middleware that wraps every handled request, in the order given; it runs before any
+// service or method middleware (see service.ServiceHandler)
+func (irt *Router) Use(middleware ...common.Middleware) {
+    irt.Middleware = append(irt.Middleware, middleware...)
+}
+
 // service name is the scheme + host and optionally the port portion of a URL
 func (irt *Router) RegisterService(service string, handler service.Service) (err error) {
     log.Printf("Attempting to register service %s with handler %v", service, handler)
@@ -37,24 +82,60 @@ func (irt *Router) RegisterService(service string, handler service.Service) (err
     log.Printf("Accepting registration of service %s using handler %v", service, handler)
 
     irt.RequestHandlers[service] = handler
+    irt.serviceOrder = append(irt.serviceOrder, service)
     return
 }
 
+// services lists the service names in the order they were registered so that overlapping
+// services are always matched the same way; any added to RequestHandlers directly come last
+func (irt *Router) services() (names []string) {
+    listed := make(map[string]bool, len(irt.serviceOrder))
+    for _, name := range irt.serviceOrder {
+        if _, ok := irt.RequestHandlers[name]; ok && !listed[name] {
+            names = append(names, name)
+            listed[name] = true
+        }
+    }
+    unlisted := []string{}
+    for name := range irt.RequestHandlers {
+        if !listed[name] {
+            unlisted = append(unlisted, name)
+        }
+    }
+    sort.Strings(unlisted)
+    return append(names, unlisted...)
+}
+
 func (irt *Router) BuildResponse(reply *common.HttpReply, request *http.Request) (response *http.Response, err error) {
     if reply == nil || request == nil {
-        log.Printf("Recieved invalid parameter: Reply: %v, Request: %#v", reply, request)
+        log.Printf("Recieved invalid parameter: Reply: %v, Request: %s", reply, irt.logRedactor().DescribeRequest(request))
         err = fmt.Errorf(
-            "%w: Invalid service response or systems error (reply: %#v, request: %#v",
+            "%w: Invalid service response or systems error (reply: %#v, request: %s",
             ErrResponseBuildingInternalError,
             reply,
-            request,
+            irt.logRedactor().DescribeRequest(request),
         )
         return
     }
 
     log.Printf("Building Reply: Status: %d, Data Length: %d", reply.Status, reply.Length)
+    if reply.Status == http.StatusSwitchingProtocols {
+        return irt.switchProtocols(reply, request)
+    }
+    body, err := checkLength(reply, request)
+    if err != nil {
+        log.Printf("Rejecting Reply: %v", err)
+        return
+    }
 
     intStatus := int(reply.Status)
+    contentLength := reply.Length
+    var transferEncoding []string
+    if contentLength < 0 {
+        // the length isn't known so the body is sent as with a real chunked response
+        contentLength = -1
+        transferEncoding = []string{"chunked"}
+    }
     response = &http.Response{
         Status: fmt.Sprintf("%d", intStatus),
         StatusCode: intStatus,
@@ -62,8 +143,9 @@ func (irt *Router) BuildResponse(reply *common.HttpReply, request *http.Request)
         ProtoMajor: irt.ProtoMajor,
         ProtoMinor: irt.ProtoMinor,
         Header: reply.Headers,
-        Body: reply.ResponseData,
-        ContentLength: reply.Length,
+        Body: withContext(request.Context(), body),
+        ContentLength: contentLength,
+        TransferEncoding: transferEncoding,
         Trailer: reply.Trailers,
         Request: request,
         Uncompressed: false, // this doesn't do anything with compression
@@ -87,7 +169,7 @@ func (irt *Router) ServiceRouter(request *http.Request) (response *http.Response
     //      Service handles part of but not the entire route
     requestUrl := request.URL
     if requestUrl == nil {
-        log.Printf("Request is invalid because the URL attribute is nil: %#v", request)
+        log.Printf("Request is invalid because the URL attribute is nil: %s", irt.logRedactor().DescribeRequest(request))
         err = fmt.Errorf("%w: Request has a nil URL", ErrInvalidRequest)
         return
     }
@@ -103,7 +185,8 @@ func (irt *Router) ServiceRouter(request *http.Request) (response *http.Response
 
     log.Printf("Attempting to handle request: Method: %s RequestURI: \"%s\"", request.Method, request.RequestURI)
     // is there a handler for the URI?
-    for serviceName, serviceHandler := range irt.RequestHandlers {
+    for _, serviceName := range irt.services() {
+        serviceHandler := irt.RequestHandlers[serviceName]
         log.Printf("Attempting to match Service %s against URL \"%s\"", serviceName, request.RequestURI)
         // see if this service handles the URL
         matcher := serviceHandler.GetMatcher()
@@ -125,8 +208,10 @@ func (irt *Router) ServiceRouter(request *http.Request) (response *http.Response
             }
 
             log.Printf("Running handler for Service %s on URI %s", serviceName, request.RequestURI)
+            handler = common.Chain(handler, irt.Middleware...)
             // attempt to let the registered service handle it
-            reply, err := handler(
+            reply, err := runHandler(
+                handler,
                 &common.HttpCall{
                     Method: common.HttpVerb(request.Method),
                     Url: request.URL,
@@ -135,6 +220,13 @@ func (irt *Router) ServiceRouter(request *http.Request) (response *http.Response
                 },
             )
 
+            // service wants the request to fail at the transport level
+            var transportErr *common.TransportError
+            if errors.As(err, &transportErr) {
+                log.Printf("Service %s failed the request at the transport level: %v", serviceName, transportErr.Err)
+                return nil, transportErr.Err
+            }
+
             // service had an error
             if err != nil {
                 log.Printf("Service %s generated an error while handling the request: %#v", serviceName, err)
@@ -142,14 +234,7 @@ func (irt *Router) ServiceRouter(request *http.Request) (response *http.Response
                     "gostackinabox: service handling request had an error - %#v",
                     err,
                 )
-                return irt.BuildResponse(
-                    &common.HttpReply{
-                        Status: common.HttpStatus_ServiceError,
-                        ResponseData: util.StringToResponseBody(msg),
-                        Length: int64(len(msg)),
-                    },
-                    request,
-                )
+                return irt.BuildResponse(common.NewReply(common.HttpStatus_ServiceError).Text(msg), request)
             }
 
             log.Printf("Service %s generated a successful response", serviceName)
@@ -158,28 +243,37 @@ func (irt *Router) ServiceRouter(request *http.Request) (response *http.Response
         }
     }
 
-    // return 595
-    msg := fmt.Sprintf(
-        "gostackinabox: no service to handle URL '%s'",
-        request.URL.String(),
-    )
-    return irt.BuildResponse(
-        &common.HttpReply{
-            Status: common.HttpStatus_RouteNotHandled,
-            ResponseData: util.StringToResponseBody(msg),
-            Length: int64(len(msg)),
-        },
-        request,
-    )
+    // nothing handled it; fallback, strict mode failure or 595
+    return irt.unhandled(request)
 }
 
 func (irt *Router) RoundTrip(request *http.Request) (response *http.Response, err error) {
-    log.Printf("Request Intercepted: %#v", request)
-    log.Printf("Request URL: %#v", request.URL)
-    log.Printf("Request URL: %#v", request.RequestURI)
-    response, err = irt.ServiceRouter(request)
-    log.Printf("Response Returned: %#v", response)
-    log.Printf("Error Returned: %#v", err)
+    log.Printf("Request Intercepted: %s", irt.logRedactor().DescribeRequest(request))
+    // hold on to the recorder in use now so swapping it mid-exchange is safe
+    rec, rd := irt.Recorder, irt.redactor()
+    var exchange *Exchange
+    original := request
+    if rec != nil {
+        exchange, request = rec.record(rd, request)
+    }
+    serve := irt.ServiceRouter
+    if irt.Chaos != nil {
+        serve = irt.withChaos
+    }
+    if irt.Faults != nil {
+        response, err = irt.Faults.Apply(request, serve)
+    } else {
+        response, err = serve(request)
+    }
+    if response != nil && response.Request == request {
+        // like http.Transport the response refers to the caller's request
+        response.Request = original
+    }
+    if exchange != nil {
+        rec.finish(rd, exchange, response, err)
+    }
+    log.Printf("Response Returned: %s", irt.logRedactor().DescribeResponse(response))
+    log.Printf("Error Returned: %v", err)
     return
 }
 
@@ -67,6 +67,9 @@ func Test_Router_Router(t *testing.T) {
             if len(irt.RequestHandlers) != 0 {
                 t.Errorf("New did not generate an empty route table: %#v", irt.RequestHandlers)
             }
+            if irt.Redactor == nil {
+                t.Errorf("New did not configure a redactor")
+            }
             expectedProtoMajor := 1
             expectedProtoMinor := 1
             expectedDisableCompression := true
@@ -519,3 +522,73 @@ func Test_Router_Router(t *testing.T) {
         },
     )
 }
+
+func Test_Router_TransportError(t *testing.T) {
+    expectedErr := errors.New("connection reset by peer")
+    irt := router.New()
+    serviceHandler := &service.ServiceHandler{
+        Matcher: &common.BasicServerURI{
+            Protocol: "http",
+            Host: "example.com",
+        },
+        FuncHandler: func(hc *common.HttpCall) (hr *common.HttpReply, err error) {
+            err = &common.TransportError{Err: expectedErr}
+            return
+        },
+    }
+    if err := irt.RegisterService("http://example.com", serviceHandler); err != nil {
+        t.Fatalf("Unexpected error registering service: %v", err)
+    }
+
+    myUrl, _ := url.Parse("http://example.com/")
+    response, err := irt.RoundTrip(&http.Request{URL: myUrl})
+    if err != expectedErr {
+        t.Errorf("Unexpected error: %v != %v", err, expectedErr)
+    }
+    if response != nil {
+        t.Errorf("Unexpectedly received a response: %#v", response)
+    }
+}
+
+func Test_Router_Use(t *testing.T) {
+    irt := router.New()
+    serviceHandler := &service.ServiceHandler{
+        Matcher: &common.BasicServerURI{
+            Protocol: "http",
+            Host: "example.com",
+        },
+        FuncHandler: func(hc *common.HttpCall) (hr *common.HttpReply, err error) {
+            hr = &common.HttpReply{Status: 200, Headers: make(http.Header)}
+            return
+        },
+    }
+    if err := irt.RegisterService("http://example.com", serviceHandler); err != nil {
+        t.Fatalf("Unexpected error registering service: %v", err)
+    }
+
+    irt.Use(
+        func(next common.HttpHandler) common.HttpHandler {
+            return func(hc *common.HttpCall) (*common.HttpReply, error) {
+                if hc.Headers.Get("Authorization") == "" {
+                    return &common.HttpReply{Status: 401}, nil
+                }
+                reply, err := next(hc)
+                if reply != nil {
+                    reply.Headers.Set("X-Checked", "yes")
+                }
+                return reply, err
+            }
+        },
+    )
+
+    myUrl, _ := url.Parse("http://example.com/")
+    response, err := irt.RoundTrip(&http.Request{URL: myUrl, Header: make(http.Header)})
+    if err != nil || response.StatusCode != 401 {
+        t.Errorf("Middleware did not reject the request: %v %v", response, err)
+    }
+
+    response, err = irt.RoundTrip(&http.Request{URL: myUrl, Header: http.Header{"Authorization": {"token"}}})
+    if err != nil || response.StatusCode != 200 || response.Header.Get("X-Checked") != "yes" {
+        t.Errorf("Middleware did not wrap the handler: %v %v", response, err)
+    }
+}
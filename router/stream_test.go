@@ -0,0 +1,110 @@
+package router_test
+
+import (
+    "context"
+    "errors"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+)
+
+func newStreamingClient(t *testing.T, handler common.HttpHandler) *http.Client {
+    r := router.New()
+    events := &service.ServiceHandler{}
+    events.Init("events", &common.BasicServerURI{Protocol: "https", Host: "events.example"})
+    events.RegisterMethodHandler(common.HttpVerb_Get, handler)
+    if err := r.RegisterService("events", events); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    return &http.Client{Transport: r}
+}
+
+func Test_Router_ChunkedReply(t *testing.T) {
+    next := make(chan struct{})
+    client := newStreamingClient(t, func(call *common.HttpCall) (*common.HttpReply, error) {
+        return common.NewReply(200).Chunked(func(w *common.ChunkWriter) error {
+            w.Write([]byte("first"))
+            <-next
+            w.Write([]byte("second"))
+            w.SetTrailer("X-Checksum", "42")
+            return nil
+        }), nil
+    })
+
+    response, err := client.Get("https://events.example/")
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if response.ContentLength != -1 || len(response.TransferEncoding) != 1 || response.TransferEncoding[0] != "chunked" {
+        t.Errorf("Unexpected framing: %d %v", response.ContentLength, response.TransferEncoding)
+    }
+
+    // the first chunk arrives before the second is written
+    buffer := make([]byte, 64)
+    n, err := response.Body.Read(buffer)
+    if err != nil || string(buffer[:n]) != "first" {
+        t.Fatalf("Unexpected first chunk: %q %v", buffer[:n], err)
+    }
+    if trailer := response.Trailer.Get("X-Checksum"); len(trailer) > 0 {
+        t.Errorf("The trailer was set before the body was read: %q", trailer)
+    }
+    close(next)
+    rest, err := ioutil.ReadAll(response.Body)
+    if err != nil || string(rest) != "second" {
+        t.Errorf("Unexpected rest of the body: %q %v", rest, err)
+    }
+    if trailer := response.Trailer.Get("X-Checksum"); trailer != "42" {
+        t.Errorf("Expected the trailer once the body was read but found %q", trailer)
+    }
+}
+
+func Test_Router_ChunkedReplyErrors(t *testing.T) {
+    failure := errors.New("producer failed")
+    client := newStreamingClient(t, func(call *common.HttpCall) (*common.HttpReply, error) {
+        return common.NewReply(200).Chunked(func(w *common.ChunkWriter) error {
+            w.Write([]byte("partial"))
+            return failure
+        }), nil
+    })
+    response, err := client.Get("https://events.example/")
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if data, err := ioutil.ReadAll(response.Body); string(data) != "partial" || !errors.Is(err, failure) {
+        t.Errorf("Expected the producer error after the partial body but found %q %v", data, err)
+    }
+
+    writeErr := make(chan error, 1)
+    client = newStreamingClient(t, func(call *common.HttpCall) (*common.HttpReply, error) {
+        return common.NewReply(200).Chunked(func(w *common.ChunkWriter) error {
+            for {
+                if _, err := w.Write([]byte("tick")); err != nil {
+                    writeErr <- err
+                    return err
+                }
+            }
+        }), nil
+    })
+    ctx, cancel := context.WithCancel(context.Background())
+    request, _ := http.NewRequestWithContext(ctx, "GET", "https://events.example/", nil)
+    response, err = client.Do(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    io.ReadFull(response.Body, make([]byte, 8))
+    cancel()
+    select {
+    case err := <-writeErr:
+        if !errors.Is(err, io.ErrClosedPipe) {
+            t.Errorf("Expected io.ErrClosedPipe but found %v", err)
+        }
+    case <-time.After(time.Second):
+        t.Errorf("The producer kept writing after the request was cancelled")
+    }
+}
@@ -0,0 +1,37 @@
+package router
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    Like http.Transport, a 101 Switching Protocols response has the upgraded
+    connection as its body, which is an io.ReadWriteCloser the client writes
+    to as well as reads from. The body is passed on as-is since wrapping it
+    would hide its Write method; it also outlives the request context as a
+    real upgraded connection does.
+ */
+
+func (irt *Router) switchProtocols(reply *common.HttpReply, request *http.Request) (response *http.Response, err error) {
+    if _, ok := reply.ResponseData.(io.ReadWriteCloser); !ok {
+        err = fmt.Errorf("%w: a 101 reply needs an io.ReadWriteCloser body", ErrResponseBuildingInternalError)
+        return
+    }
+    log.Printf("Switching protocols to %s", reply.Headers.Get("Upgrade"))
+    response = &http.Response{
+        Status: fmt.Sprintf("%d", http.StatusSwitchingProtocols),
+        StatusCode: http.StatusSwitchingProtocols,
+        Proto: fmt.Sprintf("HTTP/%d.%d", irt.ProtoMajor, irt.ProtoMinor),
+        ProtoMajor: irt.ProtoMajor,
+        ProtoMinor: irt.ProtoMinor,
+        Header: reply.Headers,
+        Body: reply.ResponseData,
+        Request: request,
+    }
+    return
+}
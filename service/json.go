@@ -0,0 +1,133 @@
+package service
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "mime"
+    "net/http"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    JSONHandler adapts a typed function into a common.HttpHandler for the
+    usual JSON-in/JSON-out mock, e.g
+
+        handler := service.JSONHandler(
+            func(ctx context.Context, call *common.HttpCall, user User) (User, common.HttpStatusCode, error) {
+                if len(user.Name) == 0 {
+                    return user, 0, ErrNameRequired
+                }
+                user.ID = "42"
+                return user, http.StatusCreated, nil
+            },
+            service.OnError(ErrNameRequired, http.StatusUnprocessableEntity),
+        )
+
+    The adapter replies with:
+
+        415 when the request has a body that isn't JSON by its Content-Type
+        400 when the body can't be decoded into the request type
+        the status given by the options, or by a StatusCoder, for errors
+            returned by the function; other errors are returned to the
+            router as usual, which replies with a 596
+        the status returned by the function (200 if it's 0) and the
+            response encoded as JSON otherwise
+
+    Errors are replied with as `{"error": "<message>"}`. An empty body
+    decodes as the zero value so `struct{}` suits requests without one.
+ */
+
+// JSONFunc is the typed function JSONHandler adapts
+type JSONFunc[Req any, Resp any] func(ctx context.Context, call *common.HttpCall, request Req) (Resp, common.HttpStatusCode, error)
+
+// StatusCoder is implemented by errors that know the status they should be replied with
+type StatusCoder interface {
+    StatusCode() common.HttpStatusCode
+}
+
+// JSONOption maps the errors returned by the function of a JSONHandler to statuses
+type JSONOption func(err error) (status common.HttpStatusCode, ok bool)
+
+// OnError replies with the status when the error is, or wraps, target
+func OnError(target error, status common.HttpStatusCode) JSONOption {
+    return func(err error) (common.HttpStatusCode, bool) {
+        return status, errors.Is(err, target)
+    }
+}
+
+// OnErrorType replies with the status when the error is, or wraps, an error of type E
+func OnErrorType[E error](status common.HttpStatusCode) JSONOption {
+    return func(err error) (common.HttpStatusCode, bool) {
+        var target E
+        return status, errors.As(err, &target)
+    }
+}
+
+// JSONHandler decodes the request body into Req, calls the function and encodes the Resp it returns
+func JSONHandler[Req any, Resp any](fn JSONFunc[Req, Resp], options ...JSONOption) common.HttpHandler {
+    return func(call *common.HttpCall) (result *common.HttpReply, err error) {
+        var request Req
+        data, err := call.Bytes()
+        if err != nil {
+            return jsonError(http.StatusBadRequest, fmt.Errorf("unable to read the body: %w", err))
+        }
+        if len(data) > 0 {
+            if contentType := call.Headers.Get("Content-Type"); !isJSON(contentType) {
+                return jsonError(http.StatusUnsupportedMediaType, fmt.Errorf("expected a JSON body but found %q", contentType))
+            }
+            if decodeErr := json.Unmarshal(data, &request); decodeErr != nil {
+                return jsonError(http.StatusBadRequest, fmt.Errorf("unable to decode the body: %w", decodeErr))
+            }
+        }
+
+        response, status, fnErr := fn(call.Context(), call, request)
+        if fnErr != nil {
+            if mapped, ok := errorStatus(fnErr, options); ok {
+                log.Printf("JSON handler replying %d for: %v", mapped, fnErr)
+                return jsonError(mapped, fnErr)
+            }
+            err = fnErr
+            return
+        }
+
+        if status == 0 {
+            status = http.StatusOK
+        }
+        if status == http.StatusNoContent || status == http.StatusNotModified {
+            result = common.NewReply(status).Empty()
+            return
+        }
+        return common.NewReply(status).JSON(response)
+    }
+}
+
+func errorStatus(err error, options []JSONOption) (status common.HttpStatusCode, ok bool) {
+    for _, option := range options {
+        if status, ok = option(err); ok {
+            return
+        }
+    }
+    var coder StatusCoder
+    if errors.As(err, &coder) {
+        return coder.StatusCode(), true
+    }
+    return
+}
+
+func jsonError(status common.HttpStatusCode, err error) (*common.HttpReply, error) {
+    return common.NewReply(status).JSON(map[string]string{"error": err.Error()})
+}
+
+// isJSON accepts application/json and the structured syntax suffix, e.g application/problem+json
+func isJSON(contentType string) bool {
+    mediaType, _, err := mime.ParseMediaType(contentType)
+    if err != nil {
+        return false
+    }
+    return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
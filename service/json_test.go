@@ -0,0 +1,113 @@
+package service_test
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+)
+
+type user struct {
+    ID   string `json:"id,omitempty"`
+    Name string `json:"name"`
+}
+
+var errNameTaken = errors.New("name taken")
+
+type quotaError struct{}
+
+func (qe *quotaError) Error() string { return "quota exceeded" }
+
+type lockedError struct{}
+
+func (le lockedError) Error() string { return "locked" }
+func (le lockedError) StatusCode() common.HttpStatusCode { return http.StatusLocked }
+
+func Test_Service_JSONHandler(t *testing.T) {
+    handler := service.JSONHandler(
+        func(ctx context.Context, call *common.HttpCall, request user) (response user, status common.HttpStatusCode, err error) {
+            if ctx == nil {
+                t.Errorf("Missing context")
+            }
+            switch request.Name {
+            case "taken":
+                err = fmt.Errorf("creating user: %w", errNameTaken)
+            case "quota":
+                err = &quotaError{}
+            case "locked":
+                err = lockedError{}
+            case "broken":
+                err = errors.New("broken")
+            case "gone":
+                status = http.StatusNoContent
+            default:
+                response = request
+                response.ID = "42"
+                status = http.StatusCreated
+            }
+            return
+        },
+        service.OnError(errNameTaken, http.StatusConflict),
+        service.OnErrorType[*quotaError](http.StatusTooManyRequests),
+    )
+
+    r := router.New()
+    client := &http.Client{Transport: r}
+    svc := &service.ServiceHandler{}
+    svc.Init("users", &common.BasicServerURI{Protocol: "https", Host: "users.example"})
+    svc.FuncHandler = handler
+    if err := r.RegisterService("users", svc); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    for _, scenario := range []struct {
+        name        string
+        contentType string
+        body        string
+        status      int
+        reply       string
+    }{
+        {"created", "application/json", `{"name": "bob"}`, 201, `{"id":"42","name":"bob"}`},
+        {"structured suffix", "application/merge-patch+json", `{"name": "bob"}`, 201, `{"id":"42","name":"bob"}`},
+        {"no body", "", "", 201, `{"id":"42","name":""}`},
+        {"no content", "application/json", `{"name": "gone"}`, 204, ""},
+        {"not JSON", "text/plain", `{"name": "bob"}`, 415, `{"error":"expected a JSON body but found \"text/plain\""}`},
+        {"invalid JSON", "application/json", `{"name": `, 400, ""},
+        {"wrong type", "application/json", `{"name": 7}`, 400, ""},
+        {"mapped error", "application/json", `{"name": "taken"}`, 409, `{"error":"creating user: name taken"}`},
+        {"mapped type", "application/json", `{"name": "quota"}`, 429, `{"error":"quota exceeded"}`},
+        {"status coder", "application/json", `{"name": "locked"}`, 423, `{"error":"locked"}`},
+        {"unmapped error", "application/json", `{"name": "broken"}`, int(common.HttpStatus_ServiceError), ""},
+    } {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                request, _ := http.NewRequest("POST", "https://users.example/", strings.NewReader(scenario.body))
+                if len(scenario.contentType) > 0 {
+                    request.Header.Set("Content-Type", scenario.contentType)
+                }
+                response, err := client.Do(request)
+                if err != nil {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+                body, _ := ioutil.ReadAll(response.Body)
+                if response.StatusCode != scenario.status {
+                    t.Errorf("Unexpected status: %d != %d (%s)", response.StatusCode, scenario.status, body)
+                }
+                if len(scenario.reply) > 0 && string(body) != scenario.reply {
+                    t.Errorf("Unexpected reply: %s != %s", body, scenario.reply)
+                }
+                if response.StatusCode < 500 && len(body) > 0 && response.Header.Get("Content-Type") != "application/json" {
+                    t.Errorf("Unexpected Content-Type: %s", response.Header.Get("Content-Type"))
+                }
+            },
+        )
+    }
+}
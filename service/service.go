@@ -6,7 +6,6 @@ import (
 
     "github.com/TestInABox/gostackinabox/common"
     "github.com/TestInABox/gostackinabox/common/log"
-    "github.com/TestInABox/gostackinabox/util"
 )
 
 /*
@@ -22,6 +21,16 @@ import (
     just the ServiceHandler as a base. More advanced services will
     want to combine it with a series of registered methods and Service
     instances to offload and simplify the handling of complex URI paths.
+
+    Middleware can be added at each level and always runs outermost first:
+
+        1. the router's, see router.Router.Use
+        2. the service's, see Use
+        3. each sub-service's, from the outermost service inwards
+        4. the method's, see UseMethod
+        5. the handler
+
+    Within a level the middleware runs in the order it was added.
 */
 
 // a service must match at the URL Domain Level
@@ -35,6 +44,10 @@ type ServiceHandler struct {
     // handle sub-routes (e.g  GET/POST/OPTION/etc on /<object>)
     //SubServices ServiceMethodHandlerMap
     SubServices ServiceHandlerMap
+    // Middleware wraps every handler of the service and its sub-services; see Use
+    Middleware []common.Middleware
+    // MethodMiddleware wraps the handlers registered for a method; see UseMethod
+    MethodMiddleware map[common.HttpVerb][]common.Middleware
 }
 
 func (sh *ServiceHandler) Init(name string, matcher common.URI) (err error) {
@@ -68,33 +81,45 @@ func (sh *ServiceHandler) GetMatcher() common.URI {
     return sh.Matcher
 }
 
+// Use adds middleware wrapping every handler of the service, including those of its sub-services
+func (sh *ServiceHandler) Use(middleware ...common.Middleware) {
+    sh.Middleware = append(sh.Middleware, middleware...)
+}
+
+// UseMethod adds middleware wrapping the handler registered for the method
+func (sh *ServiceHandler) UseMethod(method common.HttpVerb, middleware ...common.Middleware) {
+    if sh.MethodMiddleware == nil {
+        sh.MethodMiddleware = make(map[common.HttpVerb][]common.Middleware)
+    }
+    sh.MethodMiddleware[method] = append(sh.MethodMiddleware[method], middleware...)
+}
+
+// Wrap applies the service middleware to the handler; services overriding GetHandler should
+// wrap the handlers they return with it
+func (sh *ServiceHandler) Wrap(handler common.HttpHandler) common.HttpHandler {
+    if handler == nil {
+        return nil
+    }
+    return common.Chain(handler, sh.Middleware...)
+}
+
 func (sh *ServiceHandler) MethodHandler(request *common.HttpCall) (result *common.HttpReply, err error) {
     for httpVerb, httpVerbHandler := range sh.MethodMap {
         if request.Method == common.HttpVerb(httpVerb) {
-            result, err = httpVerbHandler(request)
+            handler := common.Chain(httpVerbHandler, sh.MethodMiddleware[httpVerb]...)
+            result, err = handler(request)
             return
         }
     }
 
     msg := fmt.Sprintf("%s on %s is unhandled", request.Method, request.Url.String())
-    expectedLength := int64(len(msg))
-    result = &common.HttpReply{
-        Status: common.GetHttpStatus(405),
-        ResponseData: util.StringToResponseBody(msg),
-        Length: expectedLength,
-    }
+    result = common.NewReply(common.HttpStatus_MethodNotSupport).Text(msg)
     return
 }
 
 func (sh *ServiceHandler) DefaultFuncHandler(request *common.HttpCall) (result *common.HttpReply, err error) {
     log.Printf("Default Handler Called")
-    msg := "Unhandled - Default Handler"
-    expectedLength := int64(len(msg))
-    result = &common.HttpReply{
-        Status: common.GetHttpStatus(500),
-        ResponseData: util.StringToResponseBody(msg),
-        Length: expectedLength,
-    }
+    result = common.NewReply(common.GetHttpStatus(500)).Text("Unhandled - Default Handler")
     return
 }
 
@@ -122,7 +147,7 @@ func (sh *ServiceHandler) GetHandler(requestUrl url.URL) (result common.HttpHand
             }
 
             log.Printf("Service %s supports URI %s using handler %v", serviceName, requestUrl.String(), handler)
-            result = handler
+            result = sh.Wrap(handler)
             return
         }
     }
@@ -132,11 +157,11 @@ func (sh *ServiceHandler) GetHandler(requestUrl url.URL) (result common.HttpHand
     log.Printf("Checking for method handlers (count: %d)", len(sh.MethodMap))
     if len(sh.MethodMap) > 0 {
         log.Printf("Using the Method Handler to handle the URL %s", requestUrl.String())
-        return sh.MethodHandler, nil
+        return sh.Wrap(sh.MethodHandler), nil
     }
 
     log.Printf("Using primary handler to handle the URL %s", requestUrl.String())
-    result = sh.FuncHandler
+    result = sh.Wrap(sh.FuncHandler)
     return
 }
 
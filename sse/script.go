@@ -0,0 +1,71 @@
+package sse
+
+import (
+    "io"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+// Step is one step of a Script
+type Step struct {
+    // Delay is waited before the step
+    Delay time.Duration
+    Event Event
+    // Drop drops the connection instead of sending the event
+    Drop  bool
+}
+
+// Send is a step sending the event after the delay
+func Send(delay time.Duration, e Event) Step {
+    return Step{Delay: delay, Event: e}
+}
+
+// Drop is a step dropping the connection after the delay
+func Drop(delay time.Duration) Step {
+    return Step{Delay: delay, Drop: true}
+}
+
+/*
+    Script replays the steps for every connection and ends the stream after
+    the last one. A client reconnecting with a Last-Event-ID resumes after
+    the step sending that event, so a script such as
+
+        sse.Script(
+            sse.Send(0, sse.Event{ID: "1", Data: "a"}),
+            sse.Drop(10*time.Millisecond),
+            sse.Send(0, sse.Event{ID: "2", Data: "b"}),
+        )
+
+    drops the first connection after event 1 and sends event 2 to a client
+    that reconnects with `Last-Event-ID: 1`. The delays respect the request
+    context.
+ */
+func Script(steps ...Step) common.HttpHandler {
+    return func(request *common.HttpCall) (*common.HttpReply, error) {
+        start := resumeAfter(request, func(index int) string { return steps[index].Event.ID }, len(steps))
+        // a client resuming after an event has already been dropped by the steps following it
+        for start > 0 && start < len(steps) && steps[start].Drop {
+            start++
+        }
+        log.Printf("Replaying the event script to %s from step %d of %d", request.Url, start+1, len(steps))
+        return NewReply(func(w *Writer) error {
+            for _, step := range steps[start:] {
+                if step.Delay > 0 {
+                    if err := common.Sleep(request, step.Delay); err != nil {
+                        return err
+                    }
+                }
+                if step.Drop {
+                    log.Printf("Dropping the event stream to %s", request.Url)
+                    return io.ErrUnexpectedEOF
+                }
+                if err := w.Send(step.Event); err != nil {
+                    return err
+                }
+            }
+            return nil
+        }), nil
+    }
+}
@@ -0,0 +1,112 @@
+package sse
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/service"
+)
+
+/*
+    The sse package mocks Server-Sent Events APIs. Replies have the
+    `text/event-stream` Content-Type and are streamed chunked through the
+    router (see common.ReplyBuilder.Chunked), so events reach the client as
+    they are sent. Events come from either:
+
+        Script  a fixed series of steps, each after a delay, replayed for
+                every connection
+        Stream  events pushed through a channel while the test runs
+
+    Both honor the Last-Event-ID header of a reconnecting client by resuming
+    after the event with that ID, and both can simulate the server dropping
+    the connection, which fails the client's read with io.ErrUnexpectedEOF
+    as a real truncated chunked body does. NewService registers either as
+    the GET handler of a service, e.g
+
+        events := sse.NewStream(feed)
+        svc, err := sse.NewService("events", &common.BasicServerURI{Protocol: "https", Host: "events.example"}, events.Handler())
+ */
+
+type Event struct {
+    ID    string
+    Event string
+    // Data is sent as one `data:` line per line of text
+    Data  string
+    // Retry tells the client how long to wait before reconnecting; it's only sent when positive
+    Retry time.Duration
+}
+
+// Encode returns the event in the text/event-stream format, including the blank line ending it
+func (e Event) Encode() []byte {
+    var builder strings.Builder
+    if len(e.ID) > 0 {
+        fmt.Fprintf(&builder, "id: %s\n", e.ID)
+    }
+    if len(e.Event) > 0 {
+        fmt.Fprintf(&builder, "event: %s\n", e.Event)
+    }
+    if e.Retry > 0 {
+        fmt.Fprintf(&builder, "retry: %d\n", e.Retry.Milliseconds())
+    }
+    for _, line := range strings.Split(e.Data, "\n") {
+        fmt.Fprintf(&builder, "data: %s\n", line)
+    }
+    builder.WriteString("\n")
+    return []byte(builder.String())
+}
+
+// Writer sends the events of an event stream reply
+type Writer struct {
+    chunks *common.ChunkWriter
+}
+
+// Send writes the event; it fails once the client has gone
+func (w *Writer) Send(e Event) error {
+    _, err := w.chunks.Write(e.Encode())
+    return err
+}
+
+// Comment writes a comment line, which clients ignore; servers send them to keep connections alive
+func (w *Writer) Comment(text string) error {
+    _, err := fmt.Fprintf(w.chunks, ": %s\n\n", text)
+    return err
+}
+
+// NewReply replies with an event stream of what the function sends; returning io.ErrUnexpectedEOF
+// simulates the server dropping the connection
+func NewReply(produce func(w *Writer) error) *common.HttpReply {
+    return common.NewReply(http.StatusOK).
+        WithContentType("text/event-stream").
+        WithHeader("Cache-Control", "no-cache").
+        Chunked(func(chunks *common.ChunkWriter) error {
+            return produce(&Writer{chunks: chunks})
+        })
+}
+
+// NewService creates a service replying to GET requests with the handler, e.g a Script or Stream Handler
+func NewService(name string, matcher common.URI, handler common.HttpHandler) (svc *service.ServiceHandler, err error) {
+    svc = &service.ServiceHandler{}
+    if err = svc.Init(name, matcher); err != nil {
+        return
+    }
+    err = svc.RegisterMethodHandler(common.HttpVerb_Get, handler)
+    return
+}
+
+// resumeAfter finds where a client resumes: after the last event with the Last-Event-ID of the
+// request, or from the start
+func resumeAfter(request *common.HttpCall, ids func(index int) string, count int) int {
+    lastID := request.Headers.Get("Last-Event-ID")
+    if len(lastID) == 0 {
+        return 0
+    }
+    for index := count - 1; index >= 0; index-- {
+        if ids(index) == lastID {
+            return index + 1
+        }
+    }
+    return 0
+}
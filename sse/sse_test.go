@@ -0,0 +1,160 @@
+package sse_test
+
+import (
+    "bufio"
+    "errors"
+    "io"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/sse"
+)
+
+func newClient(t *testing.T, handler common.HttpHandler) *http.Client {
+    r := router.New()
+    svc, err := sse.NewService("events", &common.BasicServerURI{Protocol: "https", Host: "events.example"}, handler)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if err := r.RegisterService("events", svc); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    return &http.Client{Transport: r}
+}
+
+// connect returns a function reading the data of the next event, and the id it had
+func connect(t *testing.T, client *http.Client, lastID string) (next func() (id string, data string, err error), response *http.Response) {
+    request, _ := http.NewRequest("GET", "https://events.example/", nil)
+    if len(lastID) > 0 {
+        request.Header.Set("Last-Event-ID", lastID)
+    }
+    response, err := client.Do(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+        t.Errorf("Unexpected Content-Type: %q", contentType)
+    }
+    reader := bufio.NewReader(response.Body)
+    next = func() (id string, data string, err error) {
+        for {
+            line, readErr := reader.ReadString('\n')
+            if readErr != nil {
+                return id, data, readErr
+            }
+            line = strings.TrimSuffix(line, "\n")
+            switch {
+            case len(line) == 0:
+                return
+            case strings.HasPrefix(line, "id: "):
+                id = line[4:]
+            case strings.HasPrefix(line, "data: "):
+                data += line[6:]
+            }
+        }
+    }
+    return
+}
+
+func Test_SSE_Encode(t *testing.T) {
+    e := sse.Event{ID: "7", Event: "update", Data: "line 1\nline 2", Retry: 1500 * time.Millisecond}
+    expected := "id: 7\nevent: update\nretry: 1500\ndata: line 1\ndata: line 2\n\n"
+    if encoded := string(e.Encode()); encoded != expected {
+        t.Errorf("Unexpected encoding: %q", encoded)
+    }
+    if encoded := string((sse.Event{Data: "x"}).Encode()); encoded != "data: x\n\n" {
+        t.Errorf("Unexpected encoding: %q", encoded)
+    }
+}
+
+func Test_SSE_Script(t *testing.T) {
+    client := newClient(t, sse.Script(
+        sse.Send(0, sse.Event{ID: "1", Data: "a"}),
+        sse.Drop(10*time.Millisecond),
+        sse.Send(0, sse.Event{ID: "2", Data: "b"}),
+        sse.Send(10*time.Millisecond, sse.Event{ID: "3", Data: "c"}),
+    ))
+
+    next, response := connect(t, client, "")
+    if id, data, err := next(); id != "1" || data != "a" || err != nil {
+        t.Errorf("Unexpected event: %q %q %v", id, data, err)
+    }
+    if _, _, err := next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+        t.Errorf("Expected the stream to be dropped but found %v", err)
+    }
+    response.Body.Close()
+
+    next, response = connect(t, client, "1")
+    defer response.Body.Close()
+    for _, expected := range []string{"b", "c"} {
+        if _, data, err := next(); data != expected || err != nil {
+            t.Errorf("Unexpected event after reconnecting: %q %v", data, err)
+        }
+    }
+    if _, _, err := next(); err != io.EOF {
+        t.Errorf("Expected the stream to end but found %v", err)
+    }
+}
+
+func Test_SSE_Stream(t *testing.T) {
+    feed := make(chan sse.Event)
+    events := sse.NewStream(feed)
+    client := newClient(t, events.Handler())
+
+    feed <- sse.Event{ID: "1", Data: "a"}
+    next, response := connect(t, client, "")
+    if id, data, err := next(); id != "1" || data != "a" || err != nil {
+        t.Errorf("Unexpected event: %q %q %v", id, data, err)
+    }
+    feed <- sse.Event{ID: "2", Data: "b"}
+    if id, _, err := next(); id != "2" || err != nil {
+        t.Errorf("Unexpected event: %q %v", id, err)
+    }
+
+    events.Drop()
+    if _, _, err := next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+        t.Errorf("Expected the stream to be dropped but found %v", err)
+    }
+    response.Body.Close()
+    feed <- sse.Event{ID: "3", Data: "c"}
+
+    next, response = connect(t, client, "2")
+    defer response.Body.Close()
+    if id, _, err := next(); id != "3" || err != nil {
+        t.Errorf("Expected to resume after event 2 but found %q %v", id, err)
+    }
+    close(feed)
+    if _, _, err := next(); err != io.EOF {
+        t.Errorf("Expected the stream to end but found %v", err)
+    }
+    if count := len(events.Events()); count != 3 {
+        t.Errorf("Unexpected number of events: %d", count)
+    }
+}
+
+func Test_SSE_Stream_DropBeforeRead(t *testing.T) {
+    feed := make(chan sse.Event)
+    defer close(feed)
+    events := sse.NewStream(feed)
+    client := newClient(t, events.Handler())
+
+    next, response := connect(t, client, "")
+    if count := events.Connections(); count != 1 {
+        t.Errorf("Client not connected before reading: %d", count)
+    }
+    events.Drop()
+    if _, _, err := next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+        t.Errorf("Expected the stream to be dropped but found %v", err)
+    }
+    response.Body.Close()
+
+    _, response = connect(t, client, "")
+    response.Body.Close()
+    if count := events.Connections(); count != 0 {
+        t.Errorf("Closing the body without reading left the client connected: %d", count)
+    }
+}
@@ -0,0 +1,168 @@
+package sse
+
+import (
+    "io"
+    "sync"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    Stream sends the events pushed through a channel to every connected
+    client, e.g
+
+        feed := make(chan sse.Event)
+        events := sse.NewStream(feed)
+        ...
+        feed <- sse.Event{ID: "1", Event: "price", Data: `{"amount": 42}`}
+        events.Drop()
+        feed <- sse.Event{ID: "2", Event: "price", Data: `{"amount": 43}`}
+        close(feed)
+
+    Every event is kept so a client connecting without a Last-Event-ID gets
+    all of them from the first, whenever it connects, and a reconnecting
+    client gets those after its Last-Event-ID. Closing the channel ends the
+    streams once the clients have been sent everything.
+ */
+type Stream struct {
+    lock        sync.Mutex
+    history     []Event
+    connections map[*connection]bool
+    done        chan struct{}
+}
+
+type connection struct {
+    // wake is signalled when there are new events
+    wake    chan struct{}
+    dropped chan struct{}
+}
+
+func NewStream(events <-chan Event) *Stream {
+    s := &Stream{
+        connections: make(map[*connection]bool),
+        done: make(chan struct{}),
+    }
+    go s.forward(events)
+    return s
+}
+
+func (s *Stream) forward(events <-chan Event) {
+    for e := range events {
+        s.lock.Lock()
+        s.history = append(s.history, e)
+        for conn := range s.connections {
+            select {
+            case conn.wake <- struct{}{}:
+            default:
+            }
+        }
+        s.lock.Unlock()
+    }
+    close(s.done)
+}
+
+// Events returns the events received so far
+func (s *Stream) Events() []Event {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    return append([]Event{}, s.history...)
+}
+
+// Drop drops the connection of every client connected now
+func (s *Stream) Drop() {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    log.Printf("Dropping %d event stream connections", len(s.connections))
+    for conn := range s.connections {
+        close(conn.dropped)
+        delete(s.connections, conn)
+    }
+}
+
+// Connections returns the number of clients connected now
+func (s *Stream) Connections() int {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    return len(s.connections)
+}
+
+// pending returns the events from the cursor
+func (s *Stream) pending(cursor int) []Event {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    return s.history[cursor:]
+}
+
+func (s *Stream) connect(request *common.HttpCall) (conn *connection, cursor int) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    conn = &connection{
+        wake: make(chan struct{}, 1),
+        dropped: make(chan struct{}),
+    }
+    s.connections[conn] = true
+    cursor = resumeAfter(request, func(index int) string { return s.history[index].ID }, len(s.history))
+    return
+}
+
+func (s *Stream) disconnect(conn *connection) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    delete(s.connections, conn)
+}
+
+// Handler replies to each request with the stream; the client counts as connected, and so can
+// be dropped, as soon as the reply is returned rather than once it starts reading the body
+func (s *Stream) Handler() common.HttpHandler {
+    return func(request *common.HttpCall) (*common.HttpReply, error) {
+        conn, cursor := s.connect(request)
+        reply := NewReply(func(w *Writer) error {
+            defer s.disconnect(conn)
+            log.Printf("Streaming events to %s from event %d", request.Url, cursor+1)
+            for {
+                select {
+                case <-conn.dropped:
+                    return io.ErrUnexpectedEOF
+                default:
+                }
+                events := s.pending(cursor)
+                for _, e := range events {
+                    if err := w.Send(e); err != nil {
+                        return err
+                    }
+                    cursor++
+                }
+                if len(events) > 0 {
+                    continue
+                }
+                select {
+                case <-conn.wake:
+                case <-conn.dropped:
+                    return io.ErrUnexpectedEOF
+                case <-s.done:
+                    if len(s.pending(cursor)) == 0 {
+                        return nil
+                    }
+                case <-request.Context().Done():
+                    return request.Context().Err()
+                }
+            }
+        })
+        // a client that closes the body without reading it never runs the producer
+        reply.ResponseData = &streamBody{ReadCloser: reply.ResponseData, stream: s, conn: conn}
+        return reply, nil
+    }
+}
+
+// streamBody disconnects the client when it closes the body
+type streamBody struct {
+    io.ReadCloser
+    stream *Stream
+    conn   *connection
+}
+
+func (sb *streamBody) Close() error {
+    sb.stream.disconnect(sb.conn)
+    return sb.ReadCloser.Close()
+}
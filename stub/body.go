@@ -0,0 +1,44 @@
+package stub
+
+import (
+    "encoding/json"
+    "fmt"
+    "regexp"
+
+    "github.com/TestInABox/gostackinabox/predicate"
+)
+
+// WithBody requires the request body to be exactly the value
+func (s *Stub) WithBody(body string) *Stub {
+    s.expected.Body = []byte(body)
+    return s.describe(predicate.Body(predicate.Equals(body)))
+}
+
+// WithBodyContaining requires the request body to contain the value
+func (s *Stub) WithBodyContaining(value string) *Stub {
+    return s.Matching(predicate.Body(predicate.Contains(value)))
+}
+
+// WithBodyMatching requires the request body to match the regular expression
+func (s *Stub) WithBodyMatching(pattern *regexp.Regexp) *Stub {
+    return s.Matching(predicate.Body(predicate.Regex(pattern)))
+}
+
+// WithJSONBody requires the request body to be JSON equal to the value;
+// object key order and whitespace are ignored
+func (s *Stub) WithJSONBody(v interface{}) *Stub {
+    p, err := predicate.JSONEquals(v)
+    if err != nil {
+        s.fail(fmt.Errorf("%w: %s JSON body predicate: %v", ErrInvalidStub, s.method, err))
+        return s
+    }
+    encoded, err := json.Marshal(v)
+    if err == nil {
+        err = json.Unmarshal(encoded, &s.expected.JSONBody)
+    }
+    if err != nil {
+        s.fail(fmt.Errorf("%w: %s JSON body expectation: %v", ErrInvalidStub, s.method, err))
+        return s
+    }
+    return s.describe(p)
+}
@@ -0,0 +1,45 @@
+package stub_test
+
+import (
+    "net/http"
+    "regexp"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/predicate"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+func Test_Stub_BodyPredicates(t *testing.T) {
+    r := router.New()
+    client := &http.Client{Transport: r}
+    stubs := stub.New(r)
+
+    stubs.On("POST", "https://api.example/echo").WithBody("exact").Reply(201)
+    stubs.On("POST", "https://api.example/echo").WithJSONBody(map[string]interface{}{"a": 1, "b": []int{2}}).Reply(202)
+    stubs.On("POST", "https://api.example/echo").WithBodyMatching(regexp.MustCompile(`^id=[0-9]+$`)).Reply(203)
+    stubs.On("POST", "https://api.example/echo").WithBodyContaining("needle").Reply(204)
+    name, err := predicate.JSONPath("$.user.name", predicate.Equals("bob"))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    stubs.On("POST", "https://api.example/echo").Matching(name).Reply(205)
+    if err := stubs.Err(); err != nil {
+        t.Fatalf("Unexpected configuration error: %v", err)
+    }
+
+    scenarios := map[string]int{
+        "exact": 201,
+        `{"b": [2], "a": 1}`: 202,
+        "id=42": 203,
+        "hay needle hay": 204,
+        `{"user": {"name": "bob"}}`: 205,
+        "nothing": 597,
+    }
+    for body, status := range scenarios {
+        result, _, _ := doRequestWithBody(t, client, "POST", "https://api.example/echo", body)
+        if result != status {
+            t.Errorf("Body %q: unexpected status: %d != %d", body, result, status)
+        }
+    }
+}
@@ -0,0 +1,54 @@
+package stub
+
+import (
+    "net/http"
+    "net/url"
+)
+
+/*
+    Expectation describes a stub for tools that export it, e.g as a contract.
+    Only the exact-value restrictions (WithHeader, WithQuery, WithBody,
+    WithJSONBody and any query parameters in the URL) can be described; Opaque
+    counts the remaining predicates such as those added with When or by a
+    scenario.
+ */
+type Expectation struct {
+    Method string
+    // URL is the scheme://host:port and path template given to On, or the base URL given to OnPattern
+    URL     string
+    Pattern string
+    Headers http.Header
+    Query   url.Values
+    Body    []byte
+    // JSONBody is the decoded value given to WithJSONBody, if any
+    JSONBody interface{}
+    Opaque   int
+
+    Status       int
+    ReplyHeaders http.Header
+    ReplyBody    []byte
+    // Handler is set when the reply comes from a handler and so can't be described
+    Handler bool
+}
+
+// Expectation returns a description of the requests the stub handles and its reply
+func (s *Stub) Expectation() (result Expectation) {
+    result = s.expected
+    result.Headers = cloneValues(s.expected.Headers)
+    result.Query = url.Values(cloneValues(s.expected.Query))
+    result.Opaque = len(s.predicates) - s.described
+
+    result.Status = int(s.status)
+    result.ReplyHeaders = cloneValues(s.headers)
+    result.ReplyBody = append([]byte{}, s.body...)
+    result.Handler = s.handler != nil
+    return
+}
+
+func cloneValues(values map[string][]string) http.Header {
+    result := make(http.Header, len(values))
+    for name, entries := range values {
+        result[name] = append([]string{}, entries...)
+    }
+    return result
+}
@@ -0,0 +1,393 @@
+package stub
+
+import (
+    "errors"
+    "fmt"
+    "net/url"
+    "regexp"
+    "sort"
+    "sync"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/util"
+)
+
+/*
+    The stub package builds the service tree for simple one-off endpoints so
+    that tests don't need to define ServiceHandler types of their own:
+
+        stubs := stub.New(r)
+        stubs.On("GET", "https://api.example/v1/users/{id}").
+            WithHeader("Accept", "application/json").
+            Reply(200).
+            JSON(user)
+        if err := stubs.Err(); err != nil {
+            ...
+        }
+
+    Each scheme://host:port gets a service registered with the router using a
+    common.BasicServerURI and each path template gets a sub-service using a
+    common.TemplateURI (or a full-match regular expression via OnPattern).
+    Of the stubs on paths that match the request, the first one added whose
+    method and predicates match handles it, even when the stubs are on
+    different paths.
+ */
+
+var (
+    ErrInvalidStub error = errors.New("Stub: Invalid stub")
+    ErrServiceConflict error = errors.New("Stub: Router already has a non-stub service for the host")
+)
+
+// MethodAny registers a stub that handles every request method
+const MethodAny = "ANY"
+
+type Registry struct {
+    router *router.Router
+
+    lock     sync.Mutex
+    services  map[string]*hostService
+    scenarios map[string]*Scenario
+    stubs     []*Stub
+    added     int
+    err       error
+}
+
+func New(r *router.Router) *Registry {
+    return &Registry{
+        router: r,
+        services: make(map[string]*hostService),
+    }
+}
+
+// Err returns the first error encountered while configuring any of the stubs
+func (reg *Registry) Err() error {
+    reg.lock.Lock()
+    defer reg.lock.Unlock()
+    return reg.err
+}
+
+func (reg *Registry) setErr(err error) {
+    reg.lock.Lock()
+    defer reg.lock.Unlock()
+    log.Printf("Stub configuration error: %v", err)
+    if reg.err == nil {
+        reg.err = err
+    }
+}
+
+// On creates and registers a stub for the method on the URL; the path may contain `{name}`
+// parameters and any query parameters in the URL must be present on the request
+func (reg *Registry) On(method string, rawUrl string) (s *Stub) {
+    s = newStub(reg, common.HttpVerb(method))
+
+    stubUrl, err := url.Parse(rawUrl)
+    if err != nil || len(stubUrl.Host) == 0 {
+        s.fail(fmt.Errorf("%w: %s %q must be an absolute URL", ErrInvalidStub, method, rawUrl))
+        return
+    }
+    for name, values := range stubUrl.Query() {
+        for _, value := range values {
+            s.WithQuery(name, value)
+        }
+    }
+
+    path := stubUrl.Path
+    if len(path) == 0 {
+        path = "/"
+    }
+    s.expected.URL = util.GetUrlBaseResource(stubUrl) + path
+    reg.add(
+        s,
+        stubUrl,
+        path,
+        func() (common.URI, error) {
+            return common.NewTemplateURI(path)
+        },
+    )
+    return
+}
+
+// Check reports the error On would record for the URL, e.g an invalid path template or a
+// host the router already has a non-stub service for, without registering anything
+func (reg *Registry) Check(rawUrl string) (err error) {
+    stubUrl, parseErr := url.Parse(rawUrl)
+    if parseErr != nil || len(stubUrl.Host) == 0 {
+        err = fmt.Errorf("%w: %q must be an absolute URL", ErrInvalidStub, rawUrl)
+        return
+    }
+    path := stubUrl.Path
+    if len(path) == 0 {
+        path = "/"
+    }
+    if _, templateErr := common.NewTemplateURI(path); templateErr != nil {
+        err = fmt.Errorf("%w: %v", ErrInvalidStub, templateErr)
+        return
+    }
+
+    reg.lock.Lock()
+    defer reg.lock.Unlock()
+    serviceName := util.GetUrlBaseResource(stubUrl)
+    if _, ok := reg.services[serviceName]; ok {
+        return
+    }
+    if _, ok := reg.router.RequestHandlers[serviceName]; ok {
+        err = fmt.Errorf("%w: %s", ErrServiceConflict, serviceName)
+    }
+    return
+}
+
+// OnPattern creates and registers a stub for the method on any path of the base URL that
+// the regular expression matches in full
+func (reg *Registry) OnPattern(method string, baseUrl string, pattern *regexp.Regexp) (s *Stub) {
+    s = newStub(reg, common.HttpVerb(method))
+
+    stubUrl, err := url.Parse(baseUrl)
+    if err != nil || len(stubUrl.Host) == 0 {
+        s.fail(fmt.Errorf("%w: %s %q must be an absolute URL", ErrInvalidStub, method, baseUrl))
+        return
+    }
+    if pattern == nil {
+        s.fail(fmt.Errorf("%w: %s %s is missing the path pattern", ErrInvalidStub, method, baseUrl))
+        return
+    }
+    anchored, err := regexp.Compile(`^(?:` + pattern.String() + `)$`)
+    if err != nil {
+        s.fail(fmt.Errorf("%w: %s %s has an invalid path pattern: %v", ErrInvalidStub, method, baseUrl, err))
+        return
+    }
+    s.expected.URL = util.GetUrlBaseResource(stubUrl)
+    s.expected.Pattern = pattern.String()
+    reg.add(
+        s,
+        stubUrl,
+        "~"+pattern.String(),
+        func() (common.URI, error) {
+            return &patternURI{pattern: anchored}, nil
+        },
+    )
+    return
+}
+
+func (reg *Registry) add(s *Stub, stubUrl *url.URL, key string, newMatcher func() (common.URI, error)) {
+    reg.lock.Lock()
+    reg.added++
+    s.sequence = reg.added
+    reg.lock.Unlock()
+
+    host, err := reg.hostService(stubUrl)
+    if err != nil {
+        s.fail(err)
+        return
+    }
+    if err = host.addStub(key, newMatcher, s); err != nil {
+        s.fail(err)
+        return
+    }
+
+    reg.lock.Lock()
+    reg.stubs = append(reg.stubs, s)
+    reg.lock.Unlock()
+}
+
+// Stubs returns the registered stubs in the order they were created
+func (reg *Registry) Stubs() []*Stub {
+    reg.lock.Lock()
+    defer reg.lock.Unlock()
+    return append([]*Stub{}, reg.stubs...)
+}
+
+func (reg *Registry) hostService(stubUrl *url.URL) (host *hostService, err error) {
+    reg.lock.Lock()
+    defer reg.lock.Unlock()
+
+    serviceName := util.GetUrlBaseResource(stubUrl)
+    if existing, ok := reg.services[serviceName]; ok {
+        host = existing
+        return
+    }
+    if _, ok := reg.router.RequestHandlers[serviceName]; ok {
+        err = fmt.Errorf("%w: %s", ErrServiceConflict, serviceName)
+        return
+    }
+
+    host = &hostService{
+        paths: make(map[string]*pathService),
+    }
+    err = host.Init(
+        serviceName,
+        &common.BasicServerURI{
+            Protocol: stubUrl.Scheme,
+            Host: stubUrl.Hostname(),
+            Port: stubUrl.Port(),
+        },
+    )
+    if err != nil {
+        return
+    }
+    host.FuncHandler = unmatched
+
+    if err = reg.router.RegisterService(serviceName, host); err != nil {
+        return
+    }
+    reg.services[serviceName] = host
+    return
+}
+
+func unmatched(request *common.HttpCall) (result *common.HttpReply, err error) {
+    msg := fmt.Sprintf("gostackinabox: no stub matches %s %s", request.Method, request.Url.String())
+    result = common.NewReply(common.HttpStatus_ServiceSubRouteError).Text(msg)
+    return
+}
+
+// hostService handles a single scheme://host:port; the stubs on every path that matches a request
+// are checked together in the order they were added
+type hostService struct {
+    service.ServiceHandler
+
+    lock  sync.RWMutex
+    order []*pathService
+    paths map[string]*pathService
+}
+
+func (hs *hostService) addStub(key string, newMatcher func() (common.URI, error), s *Stub) (err error) {
+    hs.lock.Lock()
+    defer hs.lock.Unlock()
+
+    ps, ok := hs.paths[key]
+    if !ok {
+        ps = &pathService{}
+        matcher, matcherErr := newMatcher()
+        if matcherErr != nil {
+            err = fmt.Errorf("%w: %v", ErrInvalidStub, matcherErr)
+            return
+        }
+        ps.matcher = matcher
+        if err = ps.Init(key, matcher); err != nil {
+            return
+        }
+        ps.FuncHandler = unmatched
+        if err = hs.RegisterHandler(ps); err != nil {
+            return
+        }
+        hs.paths[key] = ps
+        hs.order = append(hs.order, ps)
+    }
+    err = ps.addStub(s)
+    return
+}
+
+func (hs *hostService) GetHandler(requestUrl url.URL) (result common.HttpHandler, err error) {
+    hs.lock.RLock()
+    defer hs.lock.RUnlock()
+
+    matched := []*pathService{}
+    for _, ps := range hs.order {
+        matchResult, matchErr := ps.matcher.IsMatch(requestUrl)
+        if matchErr != nil {
+            err = matchErr
+            return
+        }
+        if matchResult {
+            matched = append(matched, ps)
+        }
+    }
+    if len(matched) == 0 {
+        log.Printf("No stubbed path on %s handles %s", hs.GetName(), requestUrl.Path)
+        result = hs.Wrap(hs.FuncHandler)
+        return
+    }
+
+    result = hs.Wrap(func(request *common.HttpCall) (*common.HttpReply, error) {
+        return dispatch(matched, request)
+    })
+    return
+}
+
+// dispatch hands the request to the earliest added stub on the matched paths that accepts it
+func dispatch(matched []*pathService, request *common.HttpCall) (result *common.HttpReply, err error) {
+    type candidate struct {
+        path *pathService
+        stub *Stub
+    }
+    candidates := []candidate{}
+    for _, ps := range matched {
+        ps.lock.RLock()
+        for _, s := range ps.stubs {
+            candidates = append(candidates, candidate{path: ps, stub: s})
+        }
+        ps.lock.RUnlock()
+    }
+    sort.SliceStable(candidates, func(i, j int) bool {
+        return candidates[i].stub.sequence < candidates[j].stub.sequence
+    })
+
+    methodHandled := false
+    for _, c := range candidates {
+        if c.stub.method != request.Method && c.stub.method != MethodAny {
+            continue
+        }
+        methodHandled = true
+        if c.path.accepts(c.stub, request) {
+            return c.stub.handle(request)
+        }
+    }
+    if !methodHandled {
+        return matched[0].MethodHandler(request)
+    }
+    return unmatched(request)
+}
+
+// pathService handles a single path, keeping its stubs in the order they were added
+type pathService struct {
+    service.ServiceHandler
+
+    matcher common.URI
+    lock    sync.RWMutex
+    stubs   []*Stub
+}
+
+func (ps *pathService) addStub(s *Stub) (err error) {
+    ps.lock.Lock()
+    defer ps.lock.Unlock()
+
+    if _, ok := ps.MethodMap[s.method]; !ok && s.method != MethodAny {
+        err = ps.RegisterMethodHandler(
+            s.method,
+            func(request *common.HttpCall) (*common.HttpReply, error) {
+                return dispatch([]*pathService{ps}, request)
+            },
+        )
+        if err != nil {
+            return
+        }
+    }
+    ps.stubs = append(ps.stubs, s)
+    return
+}
+
+// accepts reports whether the stub on this path matches the request, filling in the
+// path parameters from this path's template first
+func (ps *pathService) accepts(s *Stub, request *common.HttpCall) bool {
+    if template, ok := ps.matcher.(*common.TemplateURI); ok {
+        request.PathParams = template.Params(*request.Url)
+    }
+    return s.isMatch(request)
+}
+
+// patternURI matches paths the regular expression matches in full
+type patternURI struct {
+    pattern *regexp.Regexp
+}
+
+func (pu *patternURI) IsMatch(u url.URL) (result bool, err error) {
+    result = pu.pattern.MatchString(u.Path)
+    log.Printf("Attempting to match %s against %s... match: %t", u.String(), pu.pattern.String(), result)
+    return
+}
+
+var _ service.Service = &hostService{}
+var _ service.Service = &pathService{}
+var _ common.URI = &patternURI{}
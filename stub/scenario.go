@@ -0,0 +1,106 @@
+package stub
+
+import (
+    "fmt"
+    "sync"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    Scenario is a named state machine shared by stubs so that the same request
+    can get different replies as a test progresses, e.g a resource that only
+    exists after it has been created:
+
+        stubs.On("GET", "https://api.example/todo/1").InScenario("todo", stub.ScenarioStarted).Reply(404)
+        stubs.On("POST", "https://api.example/todo").InScenario("todo", "").WillSetState("created").Reply(201)
+        stubs.On("GET", "https://api.example/todo/1").InScenario("todo", "created").Reply(200)
+
+    Every scenario begins in ScenarioStarted.
+ */
+
+const ScenarioStarted = "Started"
+
+type Scenario struct {
+    name  string
+    lock  sync.Mutex
+    state string
+}
+
+// Scenario returns the named scenario, creating it if needed
+func (reg *Registry) Scenario(name string) *Scenario {
+    reg.lock.Lock()
+    defer reg.lock.Unlock()
+
+    if reg.scenarios == nil {
+        reg.scenarios = make(map[string]*Scenario)
+    }
+    sc, ok := reg.scenarios[name]
+    if !ok {
+        sc = &Scenario{name: name, state: ScenarioStarted}
+        reg.scenarios[name] = sc
+    }
+    return sc
+}
+
+// ResetScenarios puts every scenario back into ScenarioStarted
+func (reg *Registry) ResetScenarios() {
+    reg.lock.Lock()
+    scenarios := make([]*Scenario, 0, len(reg.scenarios))
+    for _, sc := range reg.scenarios {
+        scenarios = append(scenarios, sc)
+    }
+    reg.lock.Unlock()
+
+    for _, sc := range scenarios {
+        sc.Reset()
+    }
+}
+
+func (sc *Scenario) Name() string {
+    return sc.name
+}
+
+func (sc *Scenario) State() string {
+    sc.lock.Lock()
+    defer sc.lock.Unlock()
+    return sc.state
+}
+
+func (sc *Scenario) SetState(state string) {
+    sc.lock.Lock()
+    defer sc.lock.Unlock()
+    log.Printf("Scenario %s moving from %s to %s", sc.name, sc.state, state)
+    sc.state = state
+}
+
+func (sc *Scenario) Reset() {
+    sc.SetState(ScenarioStarted)
+}
+
+// InScenario ties the stub to the named scenario; if the required state isn't empty
+// the stub only handles requests while the scenario is in that state
+func (s *Stub) InScenario(name string, requiredState string) *Stub {
+    s.scenario = s.registry.Scenario(name)
+    if len(requiredState) == 0 {
+        return s
+    }
+    scenario := s.scenario
+    return s.When(
+        func(request *common.HttpCall) bool {
+            return scenario.State() == requiredState
+        },
+    )
+}
+
+// WillSetState moves the stub's scenario to the state whenever the stub handles a request;
+// InScenario must be called first
+func (s *Stub) WillSetState(state string) *Stub {
+    if s.scenario == nil {
+        s.fail(fmt.Errorf("%w: %s stub sets state %q without a scenario", ErrInvalidStub, s.method, state))
+        return s
+    }
+    s.nextState = state
+    return s
+}
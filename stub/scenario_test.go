@@ -0,0 +1,68 @@
+package stub_test
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+func Test_Stub_Scenario(t *testing.T) {
+    r := router.New()
+    client := &http.Client{Transport: r}
+    stubs := stub.New(r)
+
+    stubs.On("GET", "https://api.example/todo/1").InScenario("todo", stub.ScenarioStarted).Reply(404)
+    stubs.On("POST", "https://api.example/todo").InScenario("todo", "").WillSetState("created").Reply(201)
+    stubs.On("GET", "https://api.example/todo/1").InScenario("todo", "created").Reply(200)
+    if err := stubs.Err(); err != nil {
+        t.Fatalf("Unexpected configuration error: %v", err)
+    }
+
+    steps := []struct {
+        method string
+        target string
+        status int
+    }{
+        {"GET", "https://api.example/todo/1", 404},
+        {"POST", "https://api.example/todo", 201},
+        {"GET", "https://api.example/todo/1", 200},
+    }
+    for _, step := range steps {
+        if status, _, _ := doRequest(t, client, step.method, step.target, nil); status != step.status {
+            t.Errorf("%s %s: unexpected status: %d != %d", step.method, step.target, status, step.status)
+        }
+    }
+
+    stubs.ResetScenarios()
+    if state := stubs.Scenario("todo").State(); state != stub.ScenarioStarted {
+        t.Errorf("Scenario was not reset: %s", state)
+    }
+
+    stubs.On("GET", "https://api.example/other").WillSetState("x")
+    if !errors.Is(stubs.Err(), stub.ErrInvalidStub) {
+        t.Errorf("State without a scenario was not reported: %v", stubs.Err())
+    }
+}
+
+func Test_Stub_Delay(t *testing.T) {
+    r := router.New()
+    client := &http.Client{Transport: r}
+    stub.New(r).On("GET", "https://api.example/slow").Delay(time.Second).Reply(200)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    request, _ := http.NewRequestWithContext(ctx, "GET", "https://api.example/slow", nil)
+    started := time.Now()
+    _, err := client.Do(request)
+    if !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+        t.Errorf("Delay ignored the cancelled request: %v", elapsed)
+    }
+}
@@ -0,0 +1,217 @@
+package stub
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "regexp"
+    "sync"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/predicate"
+)
+
+// Predicate is an additional condition the request must satisfy for the stub to handle it
+type Predicate func(request *common.HttpCall) bool
+
+/*
+    Stub is a single canned endpoint. Methods starting with `With` or `When`
+    restrict which requests the stub handles; the remaining methods describe
+    the reply. All methods return the stub so they can be chained.
+ */
+type Stub struct {
+    registry *Registry
+    method   common.HttpVerb
+    // sequence is the order the stub was added to the registry in
+    sequence int
+
+    predicates []Predicate
+    // expected describes the predicates added by the exact-value With methods
+    expected  Expectation
+    described int
+
+    status  common.HttpStatusCode
+    headers http.Header
+    body    []byte
+    handler common.HttpHandler
+    delay   time.Duration
+
+    scenario  *Scenario
+    nextState string
+
+    lock  sync.Mutex
+    calls int
+    err   error
+}
+
+func newStub(reg *Registry, method common.HttpVerb) *Stub {
+    return &Stub{
+        registry: reg,
+        method: method,
+        status: common.GetHttpStatus(http.StatusOK),
+        headers: make(http.Header),
+        expected: Expectation{
+            Method: string(method),
+            Headers: make(http.Header),
+            Query: make(url.Values),
+        },
+    }
+}
+
+func (s *Stub) fail(err error) {
+    s.lock.Lock()
+    if s.err == nil {
+        s.err = err
+    }
+    s.lock.Unlock()
+    s.registry.setErr(err)
+}
+
+// Err returns the first error encountered while configuring this stub
+func (s *Stub) Err() error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    return s.err
+}
+
+// WithHeader requires the request header to have the value
+func (s *Stub) WithHeader(name string, value string) *Stub {
+    s.expected.Headers.Add(name, value)
+    return s.describe(predicate.Header(name, predicate.Equals(value)))
+}
+
+// WithQuery requires the query parameter to have the value
+func (s *Stub) WithQuery(name string, value string) *Stub {
+    s.expected.Query.Add(name, value)
+    return s.describe(predicate.Query(name, predicate.Equals(value)))
+}
+
+// WithHeaderMatching requires a value of the request header to match the regular expression
+func (s *Stub) WithHeaderMatching(name string, pattern *regexp.Regexp) *Stub {
+    return s.Matching(predicate.Header(name, predicate.Regex(pattern)))
+}
+
+// WithQueryMatching requires a value of the query parameter to match the regular expression
+func (s *Stub) WithQueryMatching(name string, pattern *regexp.Regexp) *Stub {
+    return s.Matching(predicate.Query(name, predicate.Regex(pattern)))
+}
+
+// Matching requires the request to satisfy the predicates; the reason a request doesn't is logged
+func (s *Stub) Matching(predicates ...predicate.Predicate) *Stub {
+    for _, p := range predicates {
+        p := p
+        s.When(
+            func(request *common.HttpCall) bool {
+                if err := p.Check(request); err != nil {
+                    log.Printf("Stub %s %s does not match: %v", s.method, s.expected.URL, err)
+                    return false
+                }
+                return true
+            },
+        )
+    }
+    return s
+}
+
+// When adds an arbitrary predicate on the request
+func (s *Stub) When(predicate Predicate) *Stub {
+    s.predicates = append(s.predicates, predicate)
+    return s
+}
+
+// describe adds a predicate that the stub's Expectation already describes
+func (s *Stub) describe(p predicate.Predicate) *Stub {
+    s.described++
+    return s.Matching(p)
+}
+
+// Reply sets the status code of the reply
+func (s *Stub) Reply(status int) *Stub {
+    s.status = common.GetHttpStatus(status)
+    return s
+}
+
+// Header adds a header to the reply
+func (s *Stub) Header(name string, value string) *Stub {
+    s.headers.Add(name, value)
+    return s
+}
+
+// Body sets the reply body
+func (s *Stub) Body(body string) *Stub {
+    s.body = []byte(body)
+    return s
+}
+
+// Bytes sets the reply body
+func (s *Stub) Bytes(body []byte) *Stub {
+    s.body = body
+    return s
+}
+
+// JSON encodes the value as the reply body and sets the Content-Type
+func (s *Stub) JSON(v interface{}) *Stub {
+    body, err := json.Marshal(v)
+    if err != nil {
+        s.fail(fmt.Errorf("%w: unable to encode JSON reply for %s: %v", ErrInvalidStub, s.method, err))
+        return s
+    }
+    s.body = body
+    if len(s.headers.Get("Content-Type")) == 0 {
+        s.headers.Set("Content-Type", "application/json")
+    }
+    return s
+}
+
+// Delay waits before replying; a cancelled request stops waiting and fails
+func (s *Stub) Delay(d time.Duration) *Stub {
+    s.delay = d
+    return s
+}
+
+// Handler delegates the reply to an arbitrary handler; the request matching still applies
+func (s *Stub) Handler(handler common.HttpHandler) *Stub {
+    s.handler = handler
+    return s
+}
+
+// Calls returns the number of requests the stub has handled
+func (s *Stub) Calls() int {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    return s.calls
+}
+
+func (s *Stub) isMatch(request *common.HttpCall) bool {
+    for _, predicate := range s.predicates {
+        if !predicate(request) {
+            return false
+        }
+    }
+    return true
+}
+
+func (s *Stub) handle(request *common.HttpCall) (result *common.HttpReply, err error) {
+    s.lock.Lock()
+    s.calls++
+    s.lock.Unlock()
+
+    if s.scenario != nil && len(s.nextState) > 0 {
+        s.scenario.SetState(s.nextState)
+    }
+    if s.delay > 0 {
+        if err = common.Sleep(request, s.delay); err != nil {
+            return
+        }
+    }
+
+    if s.handler != nil {
+        return s.handler(request)
+    }
+
+    result = common.NewReply(s.status).WithHeaders(s.headers).Bytes(s.body)
+    return
+}
@@ -0,0 +1,281 @@
+package stub_test
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "regexp"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+func doRequest(t *testing.T, client *http.Client, method string, target string, headers map[string]string) (status int, body string, response *http.Response) {
+    request, _ := http.NewRequest(method, target, nil)
+    for name, value := range headers {
+        request.Header.Set(name, value)
+    }
+    return send(t, client, request)
+}
+
+func doRequestWithBody(t *testing.T, client *http.Client, method string, target string, requestBody string) (status int, body string, response *http.Response) {
+    request, _ := http.NewRequest(method, target, strings.NewReader(requestBody))
+    return send(t, client, request)
+}
+
+func send(t *testing.T, client *http.Client, request *http.Request) (status int, body string, response *http.Response) {
+    response, err := client.Do(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    data, _ := ioutil.ReadAll(response.Body)
+    response.Body.Close()
+    status = response.StatusCode
+    body = string(data)
+    return
+}
+
+func Test_Stub(t *testing.T) {
+    r := router.New()
+    client := &http.Client{Transport: r}
+    stubs := stub.New(r)
+
+    type user struct {
+        Id   string `json:"id"`
+        Name string `json:"name"`
+    }
+
+    me := stubs.On("GET", "https://api.example/v1/users/me").Reply(200).JSON(user{Id: "0", Name: "me"})
+    stubs.On("GET", "https://api.example/v1/users/{id}").
+        WithHeader("Accept", "application/xml").
+        Reply(406)
+    byId := stubs.On("GET", "https://api.example/v1/users/{id}").
+        Handler(
+            func(request *common.HttpCall) (*common.HttpReply, error) {
+                msg := fmt.Sprintf("user %s", request.PathParams["id"])
+                return &common.HttpReply{
+                    Status: 200,
+                    ResponseData: ioutil.NopCloser(strings.NewReader(msg)),
+                    Length: int64(len(msg)),
+                }, nil
+            },
+        )
+    stubs.On("DELETE", "https://api.example/v1/users/{id}?force=true").Reply(204).Header("X-Deleted", "yes")
+    stubs.On("GET", "http://other.example").Reply(200).Body("other")
+
+    if err := stubs.Err(); err != nil {
+        t.Fatalf("Unexpected configuration error: %v", err)
+    }
+
+    type TestScenario struct {
+        name    string
+        method  string
+        target  string
+        headers map[string]string
+        status  int
+        body    string
+    }
+
+    var TestScenarios = []TestScenario{
+        {
+            name: "literal path registered first wins",
+            method: "GET",
+            target: "https://api.example/v1/users/me",
+            status: 200,
+            body: `{"id":"0","name":"me"}`,
+        },
+        {
+            name: "header predicate",
+            method: "GET",
+            target: "https://api.example/v1/users/42",
+            headers: map[string]string{"Accept": "application/xml"},
+            status: 406,
+        },
+        {
+            name: "path params",
+            method: "GET",
+            target: "https://api.example/v1/users/42",
+            status: 200,
+            body: "user 42",
+        },
+        {
+            name: "query predicate",
+            method: "DELETE",
+            target: "https://api.example/v1/users/42?force=true",
+            status: 204,
+        },
+        {
+            name: "query predicate mismatch",
+            method: "DELETE",
+            target: "https://api.example/v1/users/42",
+            status: int(common.HttpStatus_ServiceSubRouteError),
+        },
+        {
+            name: "unknown method",
+            method: "PUT",
+            target: "https://api.example/v1/users/42",
+            status: int(common.HttpStatus_MethodNotSupport),
+        },
+        {
+            name: "unknown path",
+            method: "GET",
+            target: "https://api.example/v2/users",
+            status: int(common.HttpStatus_ServiceSubRouteError),
+        },
+        {
+            name: "second host root",
+            method: "GET",
+            target: "http://other.example/",
+            status: 200,
+            body: "other",
+        },
+    }
+
+    for _, scenario := range TestScenarios {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                status, body, _ := doRequest(t, client, scenario.method, scenario.target, scenario.headers)
+                if status != scenario.status {
+                    t.Errorf("Unexpected status: %d != %d (%s)", status, scenario.status, body)
+                }
+                if len(scenario.body) > 0 && body != scenario.body {
+                    t.Errorf("Unexpected body: %s != %s", body, scenario.body)
+                }
+            },
+        )
+    }
+
+    if me.Calls() != 1 || byId.Calls() != 1 {
+        t.Errorf("Unexpected call counts: me: %d, byId: %d", me.Calls(), byId.Calls())
+    }
+
+    _, _, response := doRequest(t, client, "GET", "https://api.example/v1/users/me", nil)
+    if response.Header.Get("Content-Type") != "application/json" {
+        t.Errorf("JSON reply missing content type: %v", response.Header)
+    }
+}
+
+func Test_Stub_Errors(t *testing.T) {
+    t.Run(
+        "relative URL",
+        func(t *testing.T) {
+            stubs := stub.New(router.New())
+            stubs.On("GET", "/v1/users").Reply(200)
+            if !errors.Is(stubs.Err(), stub.ErrInvalidStub) {
+                t.Errorf("Unexpected error: %v", stubs.Err())
+            }
+        },
+    )
+    t.Run(
+        "invalid JSON",
+        func(t *testing.T) {
+            stubs := stub.New(router.New())
+            stubs.On("GET", "https://api.example/").JSON(make(chan int))
+            if !errors.Is(stubs.Err(), stub.ErrInvalidStub) {
+                t.Errorf("Unexpected error: %v", stubs.Err())
+            }
+        },
+    )
+    t.Run(
+        "invalid JSON body expectation",
+        func(t *testing.T) {
+            stubs := stub.New(router.New())
+            stubs.On("POST", "https://api.example/").WithJSONBody(json.RawMessage(`{"a":`)).Reply(200)
+            if !errors.Is(stubs.Err(), stub.ErrInvalidStub) {
+                t.Errorf("Unexpected error: %v", stubs.Err())
+            }
+        },
+    )
+    t.Run(
+        "conflicting service",
+        func(t *testing.T) {
+            r := router.New()
+            stub.New(r).On("GET", "https://api.example/").Reply(200)
+            stubs := stub.New(r)
+            stubs.On("GET", "https://api.example/other").Reply(200)
+            if !errors.Is(stubs.Err(), stub.ErrServiceConflict) {
+                t.Errorf("Unexpected error: %v", stubs.Err())
+            }
+        },
+    )
+}
+
+func Test_Stub_Patterns(t *testing.T) {
+    r := router.New()
+    client := &http.Client{Transport: r}
+    stubs := stub.New(r)
+
+    stubs.OnPattern("GET", "https://api.example", regexp.MustCompile(`/files/.*\.txt`)).
+        WithHeader("X-Version", "2").
+        Reply(200).
+        Body("text")
+    stubs.On("GET", "https://api.example/files/{name}").Reply(200).Body("any file")
+    // shares the first pattern's path but was added after the template
+    stubs.OnPattern("GET", "https://api.example", regexp.MustCompile(`/files/.*\.txt`)).Reply(200).Body("later")
+    stubs.On(stub.MethodAny, "https://api.example/echo").Reply(202)
+    stubs.OnPattern("GET", "https://api.example", nil)
+    if !errors.Is(stubs.Err(), stub.ErrInvalidStub) {
+        t.Errorf("Missing pattern was not reported: %v", stubs.Err())
+    }
+
+    scenarios := []struct {
+        method  string
+        target  string
+        headers map[string]string
+        status  int
+        body    string
+    }{
+        {"GET", "https://api.example/files/a.txt", map[string]string{"X-Version": "2"}, 200, "text"},
+        // the pattern's predicate fails so the request falls through to the next stub added on any matching path
+        {"GET", "https://api.example/files/a.txt", nil, 200, "any file"},
+        {"GET", "https://api.example/files/dir/a.txt", nil, 200, "later"},
+        {"GET", "https://api.example/files/dir/a.txt.bak", nil, int(common.HttpStatus_ServiceSubRouteError), ""},
+        {"PATCH", "https://api.example/echo", nil, 202, ""},
+        {"POST", "https://api.example/files/a.txt", nil, int(common.HttpStatus_MethodNotSupport), ""},
+    }
+    for _, scenario := range scenarios {
+        status, body, _ := doRequest(t, client, scenario.method, scenario.target, scenario.headers)
+        if status != scenario.status {
+            t.Errorf("%s %s: unexpected status: %d != %d (%s)", scenario.method, scenario.target, status, scenario.status, body)
+        }
+        if len(scenario.body) > 0 && body != scenario.body {
+            t.Errorf("%s %s: unexpected body: %s != %s", scenario.method, scenario.target, body, scenario.body)
+        }
+    }
+}
+
+func Test_Stub_Expectation(t *testing.T) {
+    stubs := stub.New(router.New())
+    s := stubs.On("POST", "https://api.example/v1/users/{id}?verbose=true").
+        WithHeader("Accept", "application/json").
+        WithJSONBody(map[string]string{"name": "bob"}).
+        When(func(request *common.HttpCall) bool { return true }).
+        Reply(201).
+        Body("created")
+    stubs.OnPattern("GET", "https://api.example", regexp.MustCompile(`/files/.*`))
+
+    expected := s.Expectation()
+    if expected.Method != "POST" || expected.URL != "https://api.example/v1/users/{id}" {
+        t.Errorf("Unexpected request: %s %s", expected.Method, expected.URL)
+    }
+    if expected.Query.Get("verbose") != "true" || expected.Headers.Get("Accept") != "application/json" {
+        t.Errorf("Unexpected query or headers: %v %v", expected.Query, expected.Headers)
+    }
+    if body, ok := expected.JSONBody.(map[string]interface{}); !ok || body["name"] != "bob" {
+        t.Errorf("Unexpected JSON body: %#v", expected.JSONBody)
+    }
+    if expected.Opaque != 1 || expected.Status != 201 || string(expected.ReplyBody) != "created" || expected.Handler {
+        t.Errorf("Unexpected expectation: %#v", expected)
+    }
+
+    all := stubs.Stubs()
+    if len(all) != 2 || all[0] != s || all[1].Expectation().Pattern != "/files/.*" {
+        t.Errorf("Unexpected stubs: %v", all)
+    }
+}
@@ -0,0 +1,131 @@
+package templating
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "math/rand"
+    "sync"
+    "text/template"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/jsonpath"
+)
+
+/*
+    Helper functions available to templates:
+
+        uuid                      a random (version 4) UUID
+        now [layout]              the current UTC time, RFC 3339 unless a Go time layout is given
+        base64 value              the standard base64 encoding of the value
+        base64Decode value        the decoded value
+        jsonPath value expr       the first value the JSONPath expression selects, or nil
+        jsonPathAll value expr    every value the JSONPath expression selects
+        toJSON value              the value encoded as JSON
+        random min max            a random integer between min and max inclusive
+        randomString length       a random alphanumeric string
+
+    The random helpers share a source per Template; Template.Seed makes them
+    repeatable between test runs.
+ */
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+type source struct {
+    lock sync.Mutex
+    rnd  *rand.Rand
+}
+
+func newSource() *source {
+    return &source{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (s *source) seed(seed int64) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    s.rnd = rand.New(rand.NewSource(seed))
+}
+
+func (s *source) funcs() template.FuncMap {
+    return template.FuncMap{
+        "uuid": s.uuid,
+        "now": now,
+        "base64": encodeBase64,
+        "base64Decode": decodeBase64,
+        "jsonPath": firstJSONPath,
+        "jsonPathAll": allJSONPath,
+        "toJSON": toJSON,
+        "random": s.random,
+        "randomString": s.randomString,
+    }
+}
+
+func (s *source) uuid() string {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    var id [16]byte
+    s.rnd.Read(id[:])
+    id[6] = (id[6] & 0x0f) | 0x40
+    id[8] = (id[8] & 0x3f) | 0x80
+    return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+func (s *source) random(min int, max int) (int, error) {
+    if max < min {
+        return 0, fmt.Errorf("random: max %d is less than min %d", max, min)
+    }
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    return min + s.rnd.Intn(max-min+1), nil
+}
+
+func (s *source) randomString(length int) string {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    result := make([]byte, length)
+    for index := range result {
+        result[index] = alphanumeric[s.rnd.Intn(len(alphanumeric))]
+    }
+    return string(result)
+}
+
+func now(layout ...string) string {
+    if len(layout) > 0 {
+        return time.Now().UTC().Format(layout[0])
+    }
+    return time.Now().UTC().Format(time.RFC3339)
+}
+
+func encodeBase64(value string) string {
+    return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+func decodeBase64(value string) (string, error) {
+    decoded, err := base64.StdEncoding.DecodeString(value)
+    return string(decoded), err
+}
+
+func allJSONPath(document interface{}, expression string) (result []interface{}, err error) {
+    path, err := jsonpath.Compile(expression)
+    if err != nil {
+        return
+    }
+    result = path.Evaluate(document)
+    return
+}
+
+func firstJSONPath(document interface{}, expression string) (result interface{}, err error) {
+    values, err := allJSONPath(document, expression)
+    if err != nil || len(values) == 0 {
+        return
+    }
+    result = values[0]
+    return
+}
+
+func toJSON(value interface{}) (string, error) {
+    encoded, err := json.Marshal(value)
+    return string(encoded), err
+}
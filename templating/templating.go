@@ -0,0 +1,174 @@
+package templating
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "text/template"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    The templating package renders reply bodies and headers with Go's
+    text/template so that canned replies can echo values from the request:
+
+        reply := templating.MustCompile(
+            201,
+            http.Header{"Location": []string{"/users/{{.PathParams.id}}"}},
+            `{"id": "{{.PathParams.id}}", "name": {{jsonPath .JSON "$.name" | toJSON}}, "created": "{{now}}"}`,
+        )
+        stubs.On("PUT", "https://api.example/users/{id}").Handler(reply.Handler())
+
+    Templates are executed with a Request (see below) and can use the helper
+    functions listed in funcs.go. Handlers that only need a templated reply
+    occasionally can call Reply directly instead of compiling a Template.
+ */
+
+var (
+    ErrInvalidTemplate error = errors.New("Templating: Invalid template")
+    ErrRender error = errors.New("Templating: Unable to render template")
+)
+
+// Request is the data templates are executed with
+type Request struct {
+    Method string
+    URL    *url.URL
+    Path   string
+    // PathParams holds the named segments of a templated path, e.g `{{.PathParams.id}}`
+    PathParams map[string]string
+    Query      url.Values
+    Headers    http.Header
+    Body       string
+    // JSON is the decoded body when it's valid JSON, nil otherwise, e.g `{{.JSON.name}}`
+    JSON interface{}
+}
+
+// NewRequest captures the call's data; the request body is read and put back for later handlers
+func NewRequest(request *common.HttpCall) *Request {
+    data := &Request{
+        Method: string(request.Method),
+        PathParams: request.PathParams,
+        Headers: request.Headers,
+    }
+    if data.PathParams == nil {
+        data.PathParams = map[string]string{}
+    }
+    if data.Headers == nil {
+        data.Headers = http.Header{}
+    }
+    if request.Url != nil {
+        data.URL = request.Url
+        data.Path = request.Url.Path
+        data.Query = request.Url.Query()
+    } else {
+        data.URL = &url.URL{}
+        data.Query = url.Values{}
+    }
+
+    body, err := request.Bytes()
+    if err != nil {
+        log.Printf("Unable to read the request body for templating: %v", err)
+    }
+    data.Body = string(body)
+    if len(body) > 0 {
+        var decoded interface{}
+        if json.Unmarshal(body, &decoded) == nil {
+            data.JSON = decoded
+        }
+    }
+    return data
+}
+
+// Template is a compiled templated reply
+type Template struct {
+    Status  int
+    headers map[string][]*template.Template
+    body    *template.Template
+    random  *source
+}
+
+// Compile parses the header value and body templates of the reply
+func Compile(status int, headers http.Header, body string) (t *Template, err error) {
+    t = &Template{
+        Status: status,
+        headers: make(map[string][]*template.Template, len(headers)),
+        random: newSource(),
+    }
+    funcs := t.random.funcs()
+    for name, values := range headers {
+        for index, value := range values {
+            parsed, parseErr := template.New(fmt.Sprintf("%s[%d]", name, index)).Funcs(funcs).Parse(value)
+            if parseErr != nil {
+                err = fmt.Errorf("%w: header %s: %v", ErrInvalidTemplate, name, parseErr)
+                t = nil
+                return
+            }
+            t.headers[name] = append(t.headers[name], parsed)
+        }
+    }
+    if t.body, err = template.New("body").Funcs(funcs).Parse(body); err != nil {
+        err = fmt.Errorf("%w: body: %v", ErrInvalidTemplate, err)
+        t = nil
+    }
+    return
+}
+
+// MustCompile is Compile for templates known to be valid; it panics otherwise
+func MustCompile(status int, headers http.Header, body string) *Template {
+    t, err := Compile(status, headers, body)
+    if err != nil {
+        panic(err)
+    }
+    return t
+}
+
+// Seed makes the random helpers (random, randomString and uuid) repeatable
+func (t *Template) Seed(seed int64) *Template {
+    t.random.seed(seed)
+    return t
+}
+
+// Render executes the templates for the request
+func (t *Template) Render(request *common.HttpCall) (result *common.HttpReply, err error) {
+    data := NewRequest(request)
+
+    headers := make(http.Header, len(t.headers))
+    for name, values := range t.headers {
+        for _, value := range values {
+            var rendered bytes.Buffer
+            if execErr := value.Execute(&rendered, data); execErr != nil {
+                err = fmt.Errorf("%w: header %s: %v", ErrRender, name, execErr)
+                return
+            }
+            headers.Add(name, rendered.String())
+        }
+    }
+
+    var body bytes.Buffer
+    if execErr := t.body.Execute(&body, data); execErr != nil {
+        err = fmt.Errorf("%w: body: %v", ErrRender, execErr)
+        return
+    }
+
+    result = common.NewReply(common.GetHttpStatus(t.Status)).WithHeaders(headers).Bytes(body.Bytes())
+    return
+}
+
+// Handler returns a handler replying with the rendered template
+func (t *Template) Handler() common.HttpHandler {
+    return t.Render
+}
+
+// Reply compiles and renders a templated reply in one step for use inside handlers
+func Reply(request *common.HttpCall, status int, headers http.Header, body string) (result *common.HttpReply, err error) {
+    t, err := Compile(status, headers, body)
+    if err != nil {
+        return
+    }
+    return t.Render(request)
+}
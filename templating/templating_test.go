@@ -0,0 +1,96 @@
+package templating_test
+
+import (
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "regexp"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/stub"
+    "github.com/TestInABox/gostackinabox/templating"
+)
+
+func Test_Templating_Render(t *testing.T) {
+    r := router.New()
+    client := &http.Client{Transport: r}
+    stubs := stub.New(r)
+    reply := templating.MustCompile(
+        201,
+        http.Header{"Location": []string{"/v1/users/{{.PathParams.id}}"}},
+        `{"id":"{{.PathParams.id}}","name":{{jsonPath .JSON "$.name" | toJSON}},"verbose":"{{.Query.Get "verbose"}}",`+
+            `"agent":"{{.Headers.Get "X-Agent"}}","method":"{{.Method}}","auth":"{{base64 "user:pass"}}"}`,
+    )
+    stubs.On("PUT", "https://api.example/v1/users/{id}").Handler(reply.Handler())
+    if err := stubs.Err(); err != nil {
+        t.Fatalf("Unexpected configuration error: %v", err)
+    }
+
+    request, _ := http.NewRequest("PUT", "https://api.example/v1/users/42?verbose=yes", strings.NewReader(`{"name":"bob"}`))
+    request.Header.Set("X-Agent", "tests")
+    response, err := client.Do(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    body, _ := ioutil.ReadAll(response.Body)
+    expected := `{"id":"42","name":"bob","verbose":"yes","agent":"tests","method":"PUT","auth":"dXNlcjpwYXNz"}`
+    if response.StatusCode != 201 || string(body) != expected {
+        t.Errorf("Unexpected reply: %d %s", response.StatusCode, body)
+    }
+    if location := response.Header.Get("Location"); location != "/v1/users/42" {
+        t.Errorf("Unexpected Location header: %s", location)
+    }
+}
+
+func Test_Templating_Funcs(t *testing.T) {
+    call := &common.HttpCall{Method: "GET"}
+
+    render := func(seed int64, text string) string {
+        reply, err := templating.MustCompile(200, nil, text).Seed(seed).Render(call)
+        if err != nil {
+            t.Fatalf("%s: unexpected error: %v", text, err)
+        }
+        body, _ := ioutil.ReadAll(reply.ResponseData)
+        return string(body)
+    }
+
+    random := `{{random 1 6}} {{randomString 8}} {{uuid}}`
+    first, second := render(7, random), render(7, random)
+    if first != second {
+        t.Errorf("Seeded helpers aren't repeatable: %s != %s", first, second)
+    }
+    if !regexp.MustCompile(`^[1-6] [A-Za-z0-9]{8} [0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(first) {
+        t.Errorf("Unexpected random values: %s", first)
+    }
+
+    if year := render(1, `{{now "2006"}}`); !regexp.MustCompile(`^\d{4}$`).MatchString(year) {
+        t.Errorf("Unexpected formatted time: %s", year)
+    }
+    if decoded := render(1, `{{base64Decode "aGk="}}`); decoded != "hi" {
+        t.Errorf("Unexpected decoded value: %s", decoded)
+    }
+}
+
+func Test_Templating_Errors(t *testing.T) {
+    if _, err := templating.Compile(200, nil, "{{.Path"); !errors.Is(err, templating.ErrInvalidTemplate) {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    if _, err := templating.Compile(200, http.Header{"X-Id": []string{"{{nope}}"}}, ""); !errors.Is(err, templating.ErrInvalidTemplate) {
+        t.Errorf("Unexpected error: %v", err)
+    }
+
+    call := &common.HttpCall{Method: "GET"}
+    if _, err := templating.Reply(call, 200, nil, `{{jsonPath .JSON "name"}}`); !errors.Is(err, templating.ErrRender) {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    reply, err := templating.Reply(call, 200, nil, `{{.Method}} {{jsonPath .JSON "$.name"}}`)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if body, _ := ioutil.ReadAll(reply.ResponseData); string(body) != "GET <no value>" {
+        t.Errorf("Unexpected body: %s", body)
+    }
+}
@@ -0,0 +1,195 @@
+package throttle
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    The throttle package slows down reply bodies to test download progress
+    reporting, read timeouts and stalled streams, e.g
+
+        dialup := throttle.New().Rate(56 * 1024 / 8)
+        users.Use(dialup.Middleware())
+
+        drip := throttle.New().Chunks(16, 100*time.Millisecond).StallAfter(64, time.Minute)
+        return drip.Reply(call, common.NewReply(200).Text(report)), nil
+
+    A Throttle combines any of:
+
+        Rate        delivers at most the bytes per second, in reads of up
+                    to a tenth of a second's worth so progress is smooth
+        Chunks      delivers at most the size per read, waiting the interval
+                    between reads
+        StallAfter  stops delivering for the duration once the bytes have
+                    been delivered
+
+    Its Middleware can be used at the router, service or method level (see
+    service.ServiceHandler) and Reply throttles a single reply. The reply
+    Length is kept. All waits end early, failing the read with the context
+    error, when the request context ends.
+ */
+
+type Throttle struct {
+    rate       int64
+    chunkSize  int
+    interval   time.Duration
+    stallAfter int64
+    stall      time.Duration
+}
+
+// New creates a Throttle that doesn't slow anything down until configured
+func New() *Throttle {
+    return &Throttle{}
+}
+
+// Rate limits the delivery to the bytes per second
+func (th *Throttle) Rate(bytesPerSecond int64) *Throttle {
+    th.rate = bytesPerSecond
+    return th
+}
+
+// Chunks delivers the body in reads of at most size bytes, waiting the interval between them
+func (th *Throttle) Chunks(size int, interval time.Duration) *Throttle {
+    th.chunkSize = size
+    th.interval = interval
+    return th
+}
+
+// StallAfter stops delivering the body for the duration once the bytes have been delivered
+func (th *Throttle) StallAfter(bytes int64, d time.Duration) *Throttle {
+    th.stallAfter = bytes
+    th.stall = d
+    return th
+}
+
+// Body throttles reading the body until the context ends
+func (th *Throttle) Body(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+    return &throttledBody{body: body, ctx: ctx, throttle: *th}
+}
+
+// Reply throttles the body of the reply to the request until the call's context ends, which
+// for a call without an *http.Request is context.Background(); the body of a 101 reply is an
+// upgraded connection that has to stay writable so it is left alone
+func (th *Throttle) Reply(request *common.HttpCall, reply *common.HttpReply) *common.HttpReply {
+    if reply != nil && reply.ResponseData != nil && reply.Status != http.StatusSwitchingProtocols {
+        log.Printf("Throttling the reply to %s %s", request.Method, request.Url)
+        reply.ResponseData = th.Body(request.Context(), reply.ResponseData)
+    }
+    return reply
+}
+
+func (th *Throttle) Middleware() common.Middleware {
+    return func(next common.HttpHandler) common.HttpHandler {
+        return func(request *common.HttpCall) (result *common.HttpReply, err error) {
+            result, err = next(request)
+            if err != nil {
+                return
+            }
+            return th.Reply(request, result), nil
+        }
+    }
+}
+
+type throttledBody struct {
+    body     io.ReadCloser
+    ctx      context.Context
+    // throttle is a copy so later changes don't affect bodies being read
+    throttle Throttle
+    started  time.Time
+    read     int64
+    reads    int
+    stalled  bool
+}
+
+func (tb *throttledBody) Read(p []byte) (n int, err error) {
+    th := tb.throttle
+    if len(p) == 0 {
+        return tb.body.Read(p)
+    }
+    stallPending := th.stall > 0 && !tb.stalled
+    if stallPending && tb.read >= th.stallAfter {
+        log.Printf("Stalling the body for %v after %d bytes", th.stall, tb.read)
+        tb.stalled = true
+        if err = tb.pause(th.stall); err != nil {
+            return
+        }
+        stallPending = false
+    }
+    if th.interval > 0 && tb.reads > 0 {
+        if err = tb.pause(th.interval); err != nil {
+            return
+        }
+    }
+
+    limit := int64(len(p))
+    if th.chunkSize > 0 && limit > int64(th.chunkSize) {
+        limit = int64(th.chunkSize)
+    }
+    if stallPending && th.stallAfter-tb.read < limit {
+        limit = th.stallAfter - tb.read
+    }
+    if th.rate > 0 {
+        step := th.rate / 10
+        if step < 1 {
+            step = 1
+        }
+        if limit > step {
+            limit = step
+        }
+    }
+
+    if tb.started.IsZero() {
+        tb.started = time.Now()
+    }
+    n, err = tb.body.Read(p[:limit])
+    tb.read += int64(n)
+    if n > 0 {
+        tb.reads++
+    }
+    if th.rate > 0 && n > 0 {
+        due := tb.started.Add(time.Duration(float64(tb.read) / float64(th.rate) * float64(time.Second)))
+        if waitErr := tb.wait(time.Until(due)); waitErr != nil {
+            return 0, waitErr
+        }
+    }
+    return
+}
+
+// pause waits without the time counting against the rate
+func (tb *throttledBody) pause(d time.Duration) error {
+    if err := tb.wait(d); err != nil {
+        return err
+    }
+    if !tb.started.IsZero() {
+        tb.started = tb.started.Add(d)
+    }
+    return nil
+}
+
+// wait sleeps for the duration unless the context ends first
+func (tb *throttledBody) wait(d time.Duration) error {
+    if err := tb.ctx.Err(); err != nil {
+        return err
+    }
+    if d <= 0 {
+        return nil
+    }
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        return nil
+    case <-tb.ctx.Done():
+        return tb.ctx.Err()
+    }
+}
+
+func (tb *throttledBody) Close() error {
+    return tb.body.Close()
+}
@@ -0,0 +1,101 @@
+package throttle_test
+
+import (
+    "context"
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/throttle"
+)
+
+// reads records the size of each read and the time it completed
+func reads(t *testing.T, th *throttle.Throttle, data string) (sizes []int, elapsed []time.Duration) {
+    body := th.Body(context.Background(), common.BytesBody([]byte(data)))
+    buffer := make([]byte, 1024)
+    started := time.Now()
+    total := 0
+    for {
+        n, err := body.Read(buffer)
+        if n > 0 {
+            sizes = append(sizes, n)
+            elapsed = append(elapsed, time.Since(started))
+            total += n
+        }
+        if err != nil {
+            break
+        }
+    }
+    if total != len(data) {
+        t.Errorf("Expected %d bytes but read %d", len(data), total)
+    }
+    return
+}
+
+func Test_Throttle_Rate(t *testing.T) {
+    sizes, elapsed := reads(t, throttle.New().Rate(1000), strings.Repeat("x", 200))
+    if len(sizes) != 2 || sizes[0] != 100 {
+        t.Errorf("Expected reads of a tenth of the rate: %v", sizes)
+    }
+    if total := elapsed[len(elapsed)-1]; total < 200*time.Millisecond || total > 400*time.Millisecond {
+        t.Errorf("Expected 200 bytes at 1000 bytes per second to take 200ms: %v", total)
+    }
+}
+
+func Test_Throttle_ChunksAndStall(t *testing.T) {
+    sizes, elapsed := reads(t, throttle.New().Chunks(10, 20*time.Millisecond), strings.Repeat("x", 30))
+    if len(sizes) != 3 || sizes[0] != 10 || elapsed[2] < 40*time.Millisecond {
+        t.Errorf("Unexpected chunks: %v after %v", sizes, elapsed)
+    }
+
+    sizes, elapsed = reads(t, throttle.New().StallAfter(5, 50*time.Millisecond), strings.Repeat("x", 30))
+    if len(sizes) != 2 || sizes[0] != 5 || elapsed[0] > 20*time.Millisecond || elapsed[1] < 50*time.Millisecond {
+        t.Errorf("Unexpected stall: %v after %v", sizes, elapsed)
+    }
+}
+
+func Test_Throttle_Service(t *testing.T) {
+    r := router.New()
+    client := &http.Client{Transport: r}
+    downloads := &service.ServiceHandler{}
+    downloads.Init("downloads", &common.BasicServerURI{Protocol: "https", Host: "downloads.example"})
+    downloads.RegisterMethodHandler(common.HttpVerb_Get, func(call *common.HttpCall) (*common.HttpReply, error) {
+        return common.NewReply(200).Text(strings.Repeat("x", 100)), nil
+    })
+    downloads.Use(throttle.New().StallAfter(10, time.Minute).Middleware())
+    if err := r.RegisterService("downloads", downloads); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+    request, _ := http.NewRequestWithContext(ctx, "GET", "https://downloads.example/file", nil)
+    response, err := client.Do(request)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if response.ContentLength != 100 {
+        t.Errorf("Expected the Length to be kept: %d", response.ContentLength)
+    }
+    data, err := ioutil.ReadAll(response.Body)
+    if len(data) != 10 || !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("Expected the stalled read to time out after 10 bytes but found %d bytes and %v", len(data), err)
+    }
+}
+
+func Test_Throttle_Reply_WithoutRequest(t *testing.T) {
+    // calls built directly, e.g by chaos or tests, have no *http.Request for the context
+    call := &common.HttpCall{Method: "GET", Url: &url.URL{Path: "/file"}, Headers: http.Header{}}
+    reply := throttle.New().Rate(1000).Reply(call, common.NewReply(200).Text("abc"))
+    data, err := ioutil.ReadAll(reply.ResponseData)
+    if err != nil || string(data) != "abc" {
+        t.Errorf("Unexpected throttled body: %q %v", data, err)
+    }
+}
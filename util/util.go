@@ -1,18 +1,17 @@
 package util
 
 import (
-    "bytes"
     "fmt"
     "net/http"
     "net/url"
     "io"
-    "io/ioutil"
+
+    "github.com/TestInABox/gostackinabox/common"
 )
 
+// StringToResponseBody makes the string a reply body; see common.NewReply for building the whole reply
 func StringToResponseBody(s string) io.ReadCloser {
-    b := bytes.NewBufferString(s)
-    r := bytes.NewReader(b.Bytes())
-    return ioutil.NopCloser(r)
+    return common.BytesBody([]byte(s))
 }
 
 func GetUrlBaseResource(url *url.URL) string {
@@ -0,0 +1,328 @@
+package websocket
+
+import (
+    "bufio"
+    "crypto/rand"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "sync"
+    "unicode/utf8"
+
+    "github.com/TestInABox/gostackinabox/common"
+)
+
+type MessageType int
+
+const (
+    TextMessage   MessageType = 1
+    BinaryMessage MessageType = 2
+)
+
+const (
+    opContinuation = 0
+    opText         = 1
+    opBinary       = 2
+    opClose        = 8
+    opPing         = 9
+    opPong         = 10
+)
+
+// Close codes from RFC 6455 section 7.4.1
+const (
+    CloseNormal          = 1000
+    CloseGoingAway       = 1001
+    CloseProtocolError   = 1002
+    CloseUnsupportedData = 1003
+    // CloseNoStatus is reported when the close frame has no code; it's never sent
+    CloseNoStatus        = 1005
+    // CloseAbnormal is reported when the connection ends without a close frame; it's never sent
+    CloseAbnormal        = 1006
+    CloseInvalidPayload  = 1007
+    ClosePolicyViolation = 1008
+    CloseMessageTooBig   = 1009
+    CloseInternalError   = 1011
+)
+
+var (
+    ErrClosed error = errors.New("WebSocket: Connection closed")
+)
+
+// CloseError is returned by ReadMessage once the peer has closed the connection
+type CloseError struct {
+    Code   int
+    Reason string
+}
+
+func (ce *CloseError) Error() string {
+    return fmt.Sprintf("websocket: closed with %d %s", ce.Code, ce.Reason)
+}
+
+// maxMessage is the largest message ReadMessage accepts
+const maxMessage = 32 << 20
+
+/*
+    Conn is one end of a WebSocket connection with a message-level API:
+    ReadMessage returns whole text and binary messages, answering pings
+    and collecting fragmented messages on the way, and the Write methods
+    send messages and control frames. Frames sent by the client are masked
+    as RFC 6455 requires, and ReadMessage rejects frames masked the wrong
+    way with a protocol error.
+
+    Reads and writes can happen in separate goroutines, but only one
+    goroutine may read at a time.
+ */
+type Conn struct {
+    conn     io.ReadWriteCloser
+    reader   *bufio.Reader
+    isClient bool
+    // Subprotocol is the one agreed in the handshake, if any
+    Subprotocol string
+    // Request is the upgrade request, on the service's end of the connection
+    Request *common.HttpCall
+
+    writeLock sync.Mutex
+    closeSent bool
+    onPong    func(data []byte)
+}
+
+func newConn(conn io.ReadWriteCloser, isClient bool) *Conn {
+    return &Conn{
+        conn: conn,
+        reader: bufio.NewReader(conn),
+        isClient: isClient,
+    }
+}
+
+// OnPong sets a function called with the data of every pong received
+func (c *Conn) OnPong(fn func(data []byte)) {
+    c.onPong = fn
+}
+
+// ReadMessage returns the next text or binary message; once the peer closes the connection it
+// replies to the close frame and returns a *CloseError
+func (c *Conn) ReadMessage() (messageType MessageType, data []byte, err error) {
+    for {
+        fin, opcode, payload, frameErr := c.readFrame()
+        if frameErr != nil {
+            return 0, nil, frameErr
+        }
+        switch opcode {
+        case opPing:
+            if err = c.writeFrame(opPong, payload); err != nil {
+                return
+            }
+            continue
+        case opPong:
+            if c.onPong != nil {
+                c.onPong(payload)
+            }
+            continue
+        case opClose:
+            return 0, nil, c.closed(payload)
+        case opText, opBinary:
+            if messageType != 0 {
+                return 0, nil, c.fail(CloseProtocolError, "expected a continuation frame")
+            }
+            messageType = MessageType(opcode)
+        case opContinuation:
+            if messageType == 0 {
+                return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+            }
+        default:
+            return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+        }
+
+        if len(data)+len(payload) > maxMessage {
+            return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+        }
+        data = append(data, payload...)
+        if fin {
+            if messageType == TextMessage && !utf8.Valid(data) {
+                return 0, nil, c.fail(CloseInvalidPayload, "text message is not UTF-8")
+            }
+            return
+        }
+    }
+}
+
+// WriteMessage sends the data as a single frame message
+func (c *Conn) WriteMessage(messageType MessageType, data []byte) error {
+    if messageType != TextMessage && messageType != BinaryMessage {
+        return fmt.Errorf("websocket: unknown message type %d", messageType)
+    }
+    return c.writeFrame(byte(messageType), data)
+}
+
+func (c *Conn) WriteText(text string) error {
+    return c.WriteMessage(TextMessage, []byte(text))
+}
+
+func (c *Conn) WriteBinary(data []byte) error {
+    return c.WriteMessage(BinaryMessage, data)
+}
+
+// Ping sends a ping; the pong comes back through ReadMessage, see OnPong
+func (c *Conn) Ping(data []byte) error {
+    return c.writeFrame(opPing, data)
+}
+
+// Pong sends an unsolicited pong, which peers use as a heartbeat
+func (c *Conn) Pong(data []byte) error {
+    return c.writeFrame(opPong, data)
+}
+
+// Close sends a close frame with the code and reason, then closes the connection
+func (c *Conn) Close(code int, reason string) error {
+    err := c.sendClose(code, reason)
+    c.conn.Close()
+    return err
+}
+
+// Drop closes the connection without a close frame, as a crashed peer would
+func (c *Conn) Drop() error {
+    return c.conn.Close()
+}
+
+func (c *Conn) sendClose(code int, reason string) error {
+    c.writeLock.Lock()
+    defer c.writeLock.Unlock()
+    if c.closeSent {
+        return nil
+    }
+    payload := make([]byte, 2, 2+len(reason))
+    binary.BigEndian.PutUint16(payload, uint16(code))
+    payload = append(payload, reason...)
+    err := c.writeFrameLocked(opClose, payload)
+    c.closeSent = true
+    return err
+}
+
+// closed replies to the peer's close frame and reports it
+func (c *Conn) closed(payload []byte) error {
+    closeErr := &CloseError{Code: CloseNoStatus}
+    if len(payload) >= 2 {
+        closeErr.Code = int(binary.BigEndian.Uint16(payload))
+        closeErr.Reason = string(payload[2:])
+    }
+    code := closeErr.Code
+    if code == CloseNoStatus {
+        code = CloseNormal
+    }
+    c.sendClose(code, "")
+    c.conn.Close()
+    return closeErr
+}
+
+// fail closes the connection after a protocol violation by the peer
+func (c *Conn) fail(code int, reason string) error {
+    c.Close(code, reason)
+    return &CloseError{Code: code, Reason: reason}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+    header := make([]byte, 2)
+    if _, err = io.ReadFull(c.reader, header); err != nil {
+        return false, 0, nil, c.readErr(err)
+    }
+    fin = header[0]&0x80 != 0
+    opcode = header[0] & 0x0f
+    if header[0]&0x70 != 0 {
+        return false, 0, nil, c.fail(CloseProtocolError, "reserved bits are set")
+    }
+    masked := header[1]&0x80 != 0
+    if masked == c.isClient {
+        return false, 0, nil, c.fail(CloseProtocolError, "frame masking is wrong for the direction")
+    }
+
+    length := uint64(header[1] & 0x7f)
+    switch length {
+    case 126:
+        extended := make([]byte, 2)
+        if _, err = io.ReadFull(c.reader, extended); err != nil {
+            return false, 0, nil, c.readErr(err)
+        }
+        length = uint64(binary.BigEndian.Uint16(extended))
+    case 127:
+        extended := make([]byte, 8)
+        if _, err = io.ReadFull(c.reader, extended); err != nil {
+            return false, 0, nil, c.readErr(err)
+        }
+        length = binary.BigEndian.Uint64(extended)
+    }
+    if opcode >= opClose && (length > 125 || !fin) {
+        return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+    }
+    if length > maxMessage {
+        return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+    }
+
+    var mask [4]byte
+    if masked {
+        if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+            return false, 0, nil, c.readErr(err)
+        }
+    }
+    payload = make([]byte, length)
+    if _, err = io.ReadFull(c.reader, payload); err != nil {
+        return false, 0, nil, c.readErr(err)
+    }
+    if masked {
+        for index := range payload {
+            payload[index] ^= mask[index%4]
+        }
+    }
+    return
+}
+
+// readErr reports a connection that ended without a close frame as an abnormal closure
+func (c *Conn) readErr(err error) error {
+    if err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, io.ErrClosedPipe) {
+        return &CloseError{Code: CloseAbnormal, Reason: err.Error()}
+    }
+    return err
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+    c.writeLock.Lock()
+    defer c.writeLock.Unlock()
+    if c.closeSent {
+        return ErrClosed
+    }
+    return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *Conn) writeFrameLocked(opcode byte, payload []byte) error {
+    frame := []byte{0x80 | opcode, 0}
+    length := len(payload)
+    switch {
+    case length < 126:
+        frame[1] = byte(length)
+    case length <= 0xffff:
+        frame[1] = 126
+        frame = append(frame, byte(length>>8), byte(length))
+    default:
+        frame[1] = 127
+        extended := make([]byte, 8)
+        binary.BigEndian.PutUint64(extended, uint64(length))
+        frame = append(frame, extended...)
+    }
+    if c.isClient {
+        frame[1] |= 0x80
+        var mask [4]byte
+        if _, err := rand.Read(mask[:]); err != nil {
+            return err
+        }
+        frame = append(frame, mask[:]...)
+        start := len(frame)
+        frame = append(frame, payload...)
+        for index := start; index < len(frame); index++ {
+            frame[index] ^= mask[(index-start)%4]
+        }
+    } else {
+        frame = append(frame, payload...)
+    }
+    _, err := c.conn.Write(frame)
+    return err
+}
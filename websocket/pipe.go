@@ -0,0 +1,80 @@
+package websocket
+
+import (
+    "io"
+    "sync"
+)
+
+// newPipe returns the two ends of an in-memory connection; unlike net.Pipe writes are buffered as
+// they would be by a socket, so a peer answering a ping while the other writes can't deadlock
+func newPipe() (io.ReadWriteCloser, io.ReadWriteCloser) {
+    forward, backward := newBuffer(), newBuffer()
+    return &pipeEnd{in: backward, out: forward}, &pipeEnd{in: forward, out: backward}
+}
+
+// buffer holds the data written to one direction of the pipe until it's read
+type buffer struct {
+    lock   sync.Mutex
+    ready  *sync.Cond
+    data   []byte
+    closed bool
+}
+
+func newBuffer() *buffer {
+    b := &buffer{}
+    b.ready = sync.NewCond(&b.lock)
+    return b
+}
+
+func (b *buffer) write(p []byte) (int, error) {
+    b.lock.Lock()
+    defer b.lock.Unlock()
+    if b.closed {
+        return 0, io.ErrClosedPipe
+    }
+    b.data = append(b.data, p...)
+    b.ready.Broadcast()
+    return len(p), nil
+}
+
+// read waits for data; once the pipe is closed the remaining data is read before io.EOF
+func (b *buffer) read(p []byte) (int, error) {
+    b.lock.Lock()
+    defer b.lock.Unlock()
+    for len(b.data) == 0 && !b.closed {
+        b.ready.Wait()
+    }
+    if len(b.data) == 0 {
+        return 0, io.EOF
+    }
+    n := copy(p, b.data)
+    b.data = b.data[n:]
+    return n, nil
+}
+
+func (b *buffer) close() {
+    b.lock.Lock()
+    defer b.lock.Unlock()
+    b.closed = true
+    b.ready.Broadcast()
+}
+
+type pipeEnd struct {
+    in  *buffer
+    out *buffer
+}
+
+func (pe *pipeEnd) Read(p []byte) (int, error) {
+    return pe.in.read(p)
+}
+
+func (pe *pipeEnd) Write(p []byte) (int, error) {
+    return pe.out.write(p)
+}
+
+// Close ends both directions, as closing a socket does
+func (pe *pipeEnd) Close() error {
+    pe.in.close()
+    pe.out.close()
+    return nil
+}
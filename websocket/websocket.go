@@ -0,0 +1,218 @@
+package websocket
+
+import (
+    "crypto/rand"
+    "crypto/sha1"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/common/log"
+)
+
+/*
+    The websocket package mocks WebSocket APIs in the same service tree as
+    REST endpoints. Handler accepts upgrade requests, performs the RFC 6455
+    handshake and replies 101 Switching Protocols with one end of an
+    in-memory connection as the body, which the router passes to the client
+    as http.Transport does, e.g
+
+        chat.RegisterMethodHandler(common.HttpVerb_Get, websocket.Handler(func(conn *websocket.Conn) error {
+            for {
+                messageType, data, err := conn.ReadMessage()
+                if err != nil {
+                    return err
+                }
+                if err = conn.WriteMessage(messageType, data); err != nil {
+                    return err
+                }
+            }
+        }))
+
+    The function serves the other end in its own goroutine. When it returns
+    the connection is closed with CloseNormal, or with CloseInternalError
+    and the error as the reason; a *CloseError from the peer closing the
+    connection counts as a normal return. Use Conn.Drop to simulate a
+    server that goes away without a close frame.
+
+    WebSocket client libraries that accept an http.Client work over the
+    router unchanged; Dial is a minimal client for tests without one.
+ */
+
+var (
+    ErrBadHandshake error = errors.New("WebSocket: Bad handshake")
+)
+
+// handshakeGUID is appended to the key to compute the accept value, per RFC 6455 section 1.3
+const handshakeGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// AcceptKey returns the Sec-WebSocket-Accept value for the Sec-WebSocket-Key
+func AcceptKey(key string) string {
+    digest := sha1.Sum([]byte(key + handshakeGUID))
+    return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// hasToken checks a comma separated header for the token, ignoring case
+func hasToken(header http.Header, name string, token string) bool {
+    for _, value := range header.Values(name) {
+        for _, candidate := range strings.Split(value, ",") {
+            if strings.EqualFold(strings.TrimSpace(candidate), token) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// Handler upgrades requests to WebSocket connections served by the function; the subprotocols are
+// those the service supports, the first the client also offers being agreed on
+func Handler(serve func(conn *Conn) error, subprotocols ...string) common.HttpHandler {
+    return func(request *common.HttpCall) (*common.HttpReply, error) {
+        // RFC 6455 only allows upgrading a GET request
+        if request.Method != common.HttpVerb_Get {
+            return common.NewReply(http.StatusMethodNotAllowed).
+                WithHeader("Allow", http.MethodGet).
+                Text(fmt.Sprintf("a WebSocket upgrade must use GET, not %s", request.Method)), nil
+        }
+        if !hasToken(request.Headers, "Connection", "upgrade") || !hasToken(request.Headers, "Upgrade", "websocket") {
+            return common.NewReply(http.StatusUpgradeRequired).
+                WithHeader("Upgrade", "websocket").
+                WithHeader("Connection", "Upgrade").
+                Text("expected a WebSocket upgrade request"), nil
+        }
+        if version := request.Headers.Get("Sec-WebSocket-Version"); version != "13" {
+            return common.NewReply(http.StatusUpgradeRequired).
+                WithHeader("Sec-WebSocket-Version", "13").
+                Text(fmt.Sprintf("unsupported WebSocket version %q", version)), nil
+        }
+        key := request.Headers.Get("Sec-WebSocket-Key")
+        if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+            return common.NewReply(http.StatusBadRequest).Text("invalid Sec-WebSocket-Key"), nil
+        }
+
+        reply := common.NewReply(http.StatusSwitchingProtocols).
+            WithHeader("Upgrade", "websocket").
+            WithHeader("Connection", "Upgrade").
+            WithHeader("Sec-WebSocket-Accept", AcceptKey(key))
+        protocol := agree(request.Headers, subprotocols)
+        if len(protocol) > 0 {
+            reply.WithHeader("Sec-WebSocket-Protocol", protocol)
+        }
+
+        clientEnd, serviceEnd := newPipe()
+        conn := newConn(serviceEnd, false)
+        conn.Subprotocol = protocol
+        conn.Request = request
+        log.Printf("Upgraded %s to a WebSocket connection", request.Url)
+        go run(conn, serve)
+        return reply.Stream(clientEnd, -1), nil
+    }
+}
+
+func agree(header http.Header, supported []string) string {
+    for _, value := range header.Values("Sec-WebSocket-Protocol") {
+        for _, offered := range strings.Split(value, ",") {
+            for _, protocol := range supported {
+                if strings.TrimSpace(offered) == protocol {
+                    return protocol
+                }
+            }
+        }
+    }
+    return ""
+}
+
+func run(conn *Conn, serve func(conn *Conn) error) {
+    defer func() {
+        if recovered := recover(); recovered != nil {
+            log.Printf("WebSocket service panicked: %v", recovered)
+            conn.Close(CloseInternalError, "internal error")
+        }
+    }()
+    err := serve(conn)
+    var closeErr *CloseError
+    switch {
+    case err == nil || errors.As(err, &closeErr):
+        conn.Close(CloseNormal, "")
+    default:
+        log.Printf("WebSocket service failed: %v", err)
+        conn.Close(CloseInternalError, truncateReason(err.Error()))
+    }
+}
+
+// truncateReason shortens the close reason to fit in a control frame with the code, without
+// splitting a UTF-8 character
+func truncateReason(reason string) string {
+    const maxReason = 123
+    if len(reason) <= maxReason {
+        return reason
+    }
+    cut := maxReason
+    for cut > 0 && !utf8.RuneStart(reason[cut]) {
+        cut--
+    }
+    return reason[:cut]
+}
+
+// Dial opens a WebSocket connection with the client, e.g over a router; the target may use the ws,
+// wss, http or https scheme
+func Dial(client *http.Client, target string, header http.Header) (conn *Conn, response *http.Response, err error) {
+    targetUrl, err := url.Parse(target)
+    if err != nil {
+        return
+    }
+    switch targetUrl.Scheme {
+    case "ws":
+        targetUrl.Scheme = "http"
+    case "wss":
+        targetUrl.Scheme = "https"
+    }
+    request, err := http.NewRequest(http.MethodGet, targetUrl.String(), nil)
+    if err != nil {
+        return
+    }
+    for name, values := range header {
+        for _, value := range values {
+            request.Header.Add(name, value)
+        }
+    }
+    nonce := make([]byte, 16)
+    if _, err = rand.Read(nonce); err != nil {
+        return
+    }
+    key := base64.StdEncoding.EncodeToString(nonce)
+    request.Header.Set("Upgrade", "websocket")
+    request.Header.Set("Connection", "Upgrade")
+    request.Header.Set("Sec-WebSocket-Key", key)
+    request.Header.Set("Sec-WebSocket-Version", "13")
+
+    response, err = client.Do(request)
+    if err != nil {
+        return
+    }
+    if response.StatusCode != http.StatusSwitchingProtocols {
+        response.Body.Close()
+        err = fmt.Errorf("%w: status %d", ErrBadHandshake, response.StatusCode)
+        return
+    }
+    if accept := response.Header.Get("Sec-WebSocket-Accept"); accept != AcceptKey(key) {
+        response.Body.Close()
+        err = fmt.Errorf("%w: unexpected Sec-WebSocket-Accept %q", ErrBadHandshake, accept)
+        return
+    }
+    body, ok := response.Body.(io.ReadWriteCloser)
+    if !ok {
+        response.Body.Close()
+        err = fmt.Errorf("%w: the response body isn't writable", ErrBadHandshake)
+        return
+    }
+    conn = newConn(body, true)
+    conn.Subprotocol = response.Header.Get("Sec-WebSocket-Protocol")
+    return
+}
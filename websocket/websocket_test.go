@@ -0,0 +1,223 @@
+package websocket_test
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+    "unicode/utf8"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/fault"
+    "github.com/TestInABox/gostackinabox/latency"
+    "github.com/TestInABox/gostackinabox/openapi"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/throttle"
+    "github.com/TestInABox/gostackinabox/websocket"
+)
+
+// newClient mocks an API with a REST endpoint at / and WebSocket endpoints under /ws
+func newClient(t *testing.T, serve func(conn *websocket.Conn) error, subprotocols ...string) *http.Client {
+    return &http.Client{Transport: newRouter(t, serve, subprotocols...)}
+}
+
+func newRouter(t *testing.T, serve func(conn *websocket.Conn) error, subprotocols ...string) *router.Router {
+    r := router.New()
+    api := &service.ServiceHandler{}
+    api.Init("api", &common.BasicServerURI{Protocol: "https", Host: "api.example"})
+    api.RegisterMethodHandler(common.HttpVerb_Get, func(call *common.HttpCall) (*common.HttpReply, error) {
+        return common.NewReply(200).Text("rest"), nil
+    })
+    ws := &service.ServiceHandler{}
+    ws.Init("ws", &common.PathURI{Path: regexp.MustCompile(`^/ws`)})
+    ws.RegisterMethodHandler(common.HttpVerb_Get, websocket.Handler(serve, subprotocols...))
+    if err := api.RegisterHandler(ws); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if err := r.RegisterService("api", api); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    return r
+}
+
+func echo(conn *websocket.Conn) error {
+    for {
+        messageType, data, err := conn.ReadMessage()
+        if err != nil {
+            return err
+        }
+        if err = conn.WriteMessage(messageType, data); err != nil {
+            return err
+        }
+    }
+}
+
+func Test_WebSocket_AcceptKey(t *testing.T) {
+    // the example from RFC 6455 section 1.3
+    if accept := websocket.AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+        t.Errorf("Unexpected accept key: %s", accept)
+    }
+}
+
+func Test_WebSocket_Echo(t *testing.T) {
+    closed := make(chan error, 1)
+    client := newClient(t, func(conn *websocket.Conn) error {
+        err := echo(conn)
+        closed <- err
+        return err
+    }, "chat.v2", "chat.v1")
+
+    if response, err := client.Get("https://api.example/"); err != nil || response.StatusCode != 200 {
+        t.Fatalf("Unexpected REST result: %v %v", response, err)
+    }
+    if response, err := client.Get("https://api.example/ws"); err != nil || response.StatusCode != http.StatusUpgradeRequired {
+        t.Fatalf("Expected 426 for a plain GET but found %v %v", response, err)
+    }
+
+    conn, response, err := websocket.Dial(client, "wss://api.example/ws", http.Header{"Sec-WebSocket-Protocol": {"chat.v1, chat.v2"}})
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if response.StatusCode != http.StatusSwitchingProtocols || conn.Subprotocol != "chat.v1" {
+        t.Errorf("Unexpected handshake: %d %q", response.StatusCode, conn.Subprotocol)
+    }
+
+    pongs := make(chan string, 1)
+    conn.OnPong(func(data []byte) { pongs <- string(data) })
+    if err := conn.Ping([]byte("hb")); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    large := make([]byte, 70000)
+    for _, scenario := range []struct {
+        messageType websocket.MessageType
+        data        []byte
+    }{
+        {websocket.TextMessage, []byte("hello")},
+        {websocket.BinaryMessage, []byte{0, 1, 2}},
+        {websocket.BinaryMessage, large[:300]},
+        {websocket.BinaryMessage, large},
+    } {
+        if err := conn.WriteMessage(scenario.messageType, scenario.data); err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        messageType, data, err := conn.ReadMessage()
+        if err != nil || messageType != scenario.messageType || string(data) != string(scenario.data) {
+            t.Errorf("Unexpected echo of %d bytes: %d %d bytes %v", len(scenario.data), messageType, len(data), err)
+        }
+    }
+    if pong := <-pongs; pong != "hb" {
+        t.Errorf("Unexpected pong: %q", pong)
+    }
+
+    if err := conn.Close(websocket.CloseGoingAway, "bye"); err != nil {
+        t.Errorf("Unexpected error: %v", err)
+    }
+    var closeErr *websocket.CloseError
+    if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway || closeErr.Reason != "bye" {
+        t.Errorf("Expected the service to see the close but found %v", err)
+    }
+}
+
+func Test_WebSocket_ServiceEnds(t *testing.T) {
+    for _, scenario := range []struct {
+        name   string
+        serve  func(conn *websocket.Conn) error
+        code   int
+        reason string
+    }{
+        {"normal", func(conn *websocket.Conn) error { return conn.WriteText("done") }, websocket.CloseNormal, ""},
+        {"error", func(conn *websocket.Conn) error {
+            conn.WriteText("done")
+            return fmt.Errorf("database unavailable")
+        }, websocket.CloseInternalError, "database unavailable"},
+        {"long multi-byte error", func(conn *websocket.Conn) error {
+            conn.WriteText("done")
+            return fmt.Errorf("%s", strings.Repeat("x", 122)+"éé")
+        }, websocket.CloseInternalError, strings.Repeat("x", 122)},
+        {"drop", func(conn *websocket.Conn) error {
+            conn.WriteText("done")
+            return conn.Drop()
+        }, websocket.CloseAbnormal, ""},
+    } {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                conn, _, err := websocket.Dial(newClient(t, scenario.serve), "wss://api.example/ws", nil)
+                if err != nil {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+                if _, data, err := conn.ReadMessage(); err != nil || string(data) != "done" {
+                    t.Errorf("Unexpected message: %q %v", data, err)
+                }
+                _, _, err = conn.ReadMessage()
+                var closeErr *websocket.CloseError
+                if !errors.As(err, &closeErr) || closeErr.Code != scenario.code || (len(scenario.reason) > 0 && closeErr.Reason != scenario.reason) ||
+                    !utf8.ValidString(closeErr.Reason) {
+                    t.Errorf("Expected close %d %q but found %v", scenario.code, scenario.reason, err)
+                }
+            },
+        )
+    }
+}
+
+func Test_WebSocket_RequiresGet(t *testing.T) {
+    handler := websocket.Handler(echo)
+    request, _ := http.NewRequest(http.MethodPost, "https://api.example/ws", nil)
+    request.Header.Set("Connection", "Upgrade")
+    request.Header.Set("Upgrade", "websocket")
+    request.Header.Set("Sec-WebSocket-Version", "13")
+    request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+    reply, err := handler(&common.HttpCall{
+        Method: common.HttpVerb_Post,
+        Url: request.URL,
+        Headers: request.Header,
+        Request: request,
+    })
+    if err != nil || reply.Status != http.StatusMethodNotAllowed || reply.Headers.Get("Allow") != http.MethodGet {
+        t.Errorf("Expected 405 for a POST upgrade but found %v %v", reply, err)
+    }
+}
+
+func Test_WebSocket_Middleware(t *testing.T) {
+    doc, err := openapi.Parse([]byte(`
+openapi: 3.0.3
+info: {title: ws, version: "1"}
+servers:
+  - url: https://api.example
+paths:
+  /ws:
+    get:
+      responses:
+        '200': {description: ok}
+`))
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    r := newRouter(t, echo)
+    r.Use(
+        latency.NewInjector(1).WithBody(latency.Fixed(time.Millisecond)).Middleware(),
+        throttle.New().Rate(10).Middleware(),
+        openapi.NewValidator(doc, nil).Middleware,
+    )
+    r.Faults = fault.NewInjector(1)
+    r.Faults.Add(fault.UnexpectedEOF(0))
+
+    conn, response, err := websocket.Dial(&http.Client{Transport: r}, "wss://api.example/ws", nil)
+    if err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    defer conn.Close(websocket.CloseNormal, "")
+    if response.StatusCode != http.StatusSwitchingProtocols {
+        t.Fatalf("Unexpected status: %d", response.StatusCode)
+    }
+    if err := conn.WriteText("hello"); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+        t.Errorf("Unexpected echo through the middleware: %q %v", data, err)
+    }
+}
@@ -0,0 +1,558 @@
+package wiremock
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/TestInABox/gostackinabox/mapping"
+    "github.com/TestInABox/gostackinabox/predicate"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+type mappingSpec struct {
+    file     string
+    line     int
+    priority int
+
+    method     string
+    urlField   string
+    path       string
+    pattern    *regexp.Regexp
+    predicates []predicate.Predicate
+
+    scenario      string
+    requiredState string
+    newState      string
+
+    status  int
+    headers [][2]string
+    body    []byte
+    delay   time.Duration
+}
+
+// url is the stub URL, or the base URL for a pattern
+func (spec *mappingSpec) url(base string) string {
+    if spec.pattern != nil {
+        return base
+    }
+    return base + spec.path
+}
+
+func (spec *mappingSpec) register(stubs *stub.Registry, base string) error {
+    var s *stub.Stub
+    if spec.pattern != nil {
+        s = stubs.OnPattern(spec.method, base, spec.pattern)
+    } else {
+        s = stubs.On(spec.method, spec.url(base))
+    }
+    s.Matching(spec.predicates...)
+    if len(spec.scenario) > 0 {
+        s.InScenario(spec.scenario, spec.requiredState)
+        if len(spec.newState) > 0 {
+            s.WillSetState(spec.newState)
+        }
+    }
+
+    s.Reply(spec.status)
+    for _, header := range spec.headers {
+        s.Header(header[0], header[1])
+    }
+    s.Bytes(spec.body)
+    if spec.delay > 0 {
+        s.Delay(spec.delay)
+    }
+    return s.Err()
+}
+
+// converter validates the mappings of a single file
+type converter struct {
+    file     string
+    filesDir string
+
+    problems    mapping.ValidationErrors
+    unsupported mapping.ValidationErrors
+}
+
+func (c *converter) fail(n *mapping.Node, format string, args ...interface{}) {
+    c.problems = append(c.problems, &mapping.ValidationError{File: c.file, Line: n.Line, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *converter) unsupportedFeature(n *mapping.Node, feature string) {
+    c.unsupported = append(
+        c.unsupported,
+        &mapping.ValidationError{File: c.file, Line: n.Line, Msg: fmt.Sprintf("unsupported WireMock feature %q", feature)},
+    )
+}
+
+func (c *converter) kind(n *mapping.Node, field string, kind mapping.Kind) bool {
+    if n.Kind != kind {
+        c.fail(n, "%s must be a %s but found a %s", field, kind, n.Kind)
+        return false
+    }
+    return true
+}
+
+func (c *converter) text(n *mapping.Node, field string) (value string) {
+    value, err := n.Text()
+    if err != nil {
+        c.fail(n, "%s: %v", field, err)
+    }
+    return
+}
+
+func (c *converter) integer(n *mapping.Node, field string) (value int) {
+    value, err := n.Int()
+    if err != nil {
+        c.fail(n, "%s: %v", field, err)
+    }
+    return
+}
+
+func (c *converter) flag(n *mapping.Node, field string) bool {
+    if n.Kind != mapping.KindBool {
+        c.fail(n, "%s must be a boolean but found a %s", field, n.Kind)
+        return false
+    }
+    return n.Value == "true"
+}
+
+// regex compiles a WireMock pattern, which must match the whole value
+func (c *converter) regex(n *mapping.Node, field string) *regexp.Regexp {
+    value := c.text(n, field)
+    pattern, err := regexp.Compile(`^(?:` + value + `)$`)
+    if err != nil {
+        c.unsupported = append(
+            c.unsupported,
+            &mapping.ValidationError{File: c.file, Line: n.Line, Msg: fmt.Sprintf("%s: unsupported regular expression %q: %v", field, value, err)},
+        )
+        return nil
+    }
+    return pattern
+}
+
+func (c *converter) mapping(entry *mapping.Node) (spec *mappingSpec) {
+    if !c.kind(entry, "mapping", mapping.KindMap) {
+        return
+    }
+    problems, unsupported := len(c.problems), len(c.unsupported)
+
+    spec = &mappingSpec{
+        file: c.file,
+        line: entry.Line,
+        priority: defaultPriority,
+        method: stub.MethodAny,
+        status: 200,
+    }
+    for _, key := range entry.Keys {
+        value := entry.Map[key]
+        switch key {
+        case "id", "uuid", "name", "persistent", "metadata", "insertionIndex":
+            // informational only
+        case "priority":
+            spec.priority = c.integer(value, key)
+        case "scenarioName":
+            spec.scenario = c.text(value, key)
+        case "requiredScenarioState":
+            spec.requiredState = c.text(value, key)
+        case "newScenarioState":
+            spec.newState = c.text(value, key)
+        case "request":
+            if c.kind(value, key, mapping.KindMap) {
+                c.request(value, spec)
+            }
+        case "response":
+            if c.kind(value, key, mapping.KindMap) {
+                c.response(value, spec)
+            }
+        default:
+            c.unsupportedFeature(value, key)
+        }
+    }
+    if entry.Get("request") == nil {
+        c.fail(entry, "missing required field \"request\"")
+    }
+    if len(spec.scenario) == 0 && (len(spec.requiredState) > 0 || len(spec.newState) > 0) {
+        c.fail(entry, "scenario states require a \"scenarioName\"")
+    }
+
+    if len(c.problems) > problems || len(c.unsupported) > unsupported {
+        spec = nil
+    }
+    return
+}
+
+var urlFields = []string{"url", "urlPath", "urlPattern", "urlPathPattern", "urlPathTemplate"}
+
+func (c *converter) request(request *mapping.Node, spec *mappingSpec) {
+    for _, key := range request.Keys {
+        value := request.Map[key]
+        field := "request." + key
+        switch key {
+        case "method":
+            spec.method = strings.ToUpper(c.text(value, field))
+        case "url", "urlPath", "urlPattern", "urlPathPattern", "urlPathTemplate":
+            if len(spec.urlField) > 0 {
+                c.fail(value, "only one of %s may be used", strings.Join(urlFields, ", "))
+                continue
+            }
+            spec.urlField = key
+            c.url(value, field, spec)
+        case "queryParameters":
+            c.values(value, field, spec, predicate.Query)
+        case "headers":
+            c.values(value, field, spec, predicate.Header)
+        case "cookies":
+            c.values(value, field, spec, predicate.Cookie)
+        case "pathParameters":
+            if request.Get("urlPathTemplate") == nil {
+                c.fail(value, "pathParameters require a \"urlPathTemplate\"")
+                continue
+            }
+            c.values(value, field, spec, predicate.PathParam)
+        case "basicAuthCredentials":
+            c.basicAuth(value, field, spec)
+        case "bodyPatterns":
+            if c.kind(value, field, mapping.KindSeq) {
+                for _, item := range value.Items {
+                    c.bodyPattern(item, field, spec)
+                }
+            }
+        default:
+            c.unsupportedFeature(value, field)
+        }
+    }
+    if len(spec.urlField) == 0 {
+        spec.pattern = regexp.MustCompile(".*")
+    }
+}
+
+func (c *converter) url(value *mapping.Node, field string, spec *mappingSpec) {
+    switch spec.urlField {
+    case "url":
+        // an exact match on the path and query, so no query means no query parameters
+        expected := c.text(value, field)
+        path := expected
+        if index := strings.Index(path, "?"); index >= 0 {
+            path = path[:index]
+        }
+        spec.predicates = append(spec.predicates, predicate.RequestURI(predicate.Equals(expected)))
+        c.exactPath(path, spec)
+    case "urlPath":
+        c.exactPath(c.text(value, field), spec)
+    case "urlPathTemplate":
+        spec.path = c.text(value, field)
+    case "urlPathPattern":
+        spec.pattern = c.regex(value, field)
+    case "urlPattern":
+        // applies to the path and query so the path itself can be anything
+        spec.pattern = regexp.MustCompile(".*")
+        if pattern := c.regex(value, field); pattern != nil {
+            spec.predicates = append(spec.predicates, predicate.RequestURI(predicate.Regex(pattern)))
+        }
+    }
+}
+
+// exactPath avoids `{...}` in a literal path being treated as a template
+func (c *converter) exactPath(path string, spec *mappingSpec) {
+    if strings.ContainsAny(path, "{}") {
+        spec.pattern = regexp.MustCompile(regexp.QuoteMeta(path))
+        return
+    }
+    spec.path = path
+}
+
+// values converts the matchers of named values; subject is e.g predicate.Header
+func (c *converter) values(n *mapping.Node, field string, spec *mappingSpec, subject func(string, predicate.Matcher) predicate.Predicate) {
+    if !c.kind(n, field, mapping.KindMap) {
+        return
+    }
+    for _, name := range n.Keys {
+        matcher := c.matcher(n.Map[name], field+"."+name)
+        if matcher == nil {
+            continue
+        }
+        name := name
+        spec.predicates = append(spec.predicates, matcher.predicate(func(m predicate.Matcher) predicate.Predicate {
+            return subject(name, m)
+        }))
+    }
+}
+
+var stringMatchers = map[string]bool{
+    "equalTo": true,
+    "contains": true,
+    "doesNotContain": true,
+    "matches": true,
+    "doesNotMatch": true,
+    "absent": true,
+}
+
+// matcher converts a string matcher; nil if it isn't valid or supported
+func (c *converter) matcher(n *mapping.Node, field string) (vm *valueMatcher) {
+    if !c.kind(n, field, mapping.KindMap) {
+        return
+    }
+    problems, unsupported := len(c.problems), len(c.unsupported)
+
+    vm = &valueMatcher{}
+    for _, key := range n.Keys {
+        value := n.Map[key]
+        switch {
+        case key == "caseInsensitive":
+            vm.caseInsensitive = c.flag(value, field+"."+key)
+        case stringMatchers[key] && len(vm.op) > 0:
+            c.fail(value, "%s has more than one matcher (%s and %s)", field, vm.op, key)
+        case key == "absent":
+            vm.op = key
+            if !c.flag(value, field+"."+key) {
+                vm.op = "present"
+            }
+        case stringMatchers[key]:
+            vm.op = key
+            vm.value = c.text(value, field+"."+key)
+            if key == "matches" || key == "doesNotMatch" {
+                vm.pattern = c.regex(value, field+"."+key)
+            }
+        default:
+            c.unsupportedFeature(value, field+"."+key)
+        }
+    }
+    if len(vm.op) == 0 && len(c.problems) == problems && len(c.unsupported) == unsupported {
+        c.fail(n, "%s is missing a matcher (expected one of equalTo, contains, doesNotContain, matches, doesNotMatch or absent)", field)
+    }
+    if len(c.problems) > problems || len(c.unsupported) > unsupported {
+        vm = nil
+    }
+    return
+}
+
+func (c *converter) basicAuth(n *mapping.Node, field string, spec *mappingSpec) {
+    if !c.kind(n, field, mapping.KindMap) {
+        return
+    }
+    username, password := n.Get("username"), n.Get("password")
+    if username == nil || password == nil {
+        c.fail(n, "%s requires \"username\" and \"password\"", field)
+        return
+    }
+    credentials := c.text(username, field+".username") + ":" + c.text(password, field+".password")
+    expected := "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+    spec.predicates = append(spec.predicates, predicate.Header("Authorization", predicate.Equals(expected)))
+}
+
+func (c *converter) bodyPattern(n *mapping.Node, field string, spec *mappingSpec) {
+    if !c.kind(n, field, mapping.KindMap) {
+        return
+    }
+
+    switch {
+    case n.Get("equalToJson") != nil:
+        c.equalToJson(n, field, spec)
+    case n.Get("matchesJsonPath") != nil:
+        c.matchesJsonPath(n.Get("matchesJsonPath"), field+".matchesJsonPath", spec)
+        for _, key := range n.Keys {
+            if key != "matchesJsonPath" {
+                c.unsupportedFeature(n.Map[key], field+"."+key)
+            }
+        }
+    default:
+        for _, key := range n.Keys {
+            if !stringMatchers[key] && key != "caseInsensitive" {
+                c.unsupportedFeature(n.Map[key], field+"."+key)
+                return
+            }
+        }
+        matcher := c.matcher(n, field)
+        if matcher == nil {
+            return
+        }
+        spec.predicates = append(spec.predicates, matcher.predicate(predicate.Body))
+    }
+}
+
+func (c *converter) equalToJson(n *mapping.Node, field string, spec *mappingSpec) {
+    var expected interface{}
+    options := []predicate.JSONOption{}
+    for _, key := range n.Keys {
+        value := n.Map[key]
+        switch key {
+        case "equalToJson":
+            if hasJsonUnitPlaceholder(value) {
+                // e.g `${json-unit.any-string}` would only ever match itself
+                c.unsupportedFeature(value, field+".equalToJson.json-unit")
+                return
+            }
+            if value.Kind != mapping.KindString {
+                expected = value.Interface()
+                continue
+            }
+            // the expected document may also be given as a string of JSON
+            if err := json.Unmarshal([]byte(value.Value), &expected); err != nil {
+                c.fail(value, "%s.equalToJson is not valid JSON: %v", field, err)
+            }
+        case "ignoreArrayOrder":
+            if c.flag(value, field+"."+key) {
+                options = append(options, predicate.IgnoreArrayOrder)
+            }
+        case "ignoreExtraElements":
+            if c.flag(value, field+"."+key) {
+                options = append(options, predicate.IgnoreExtraFields)
+            }
+        default:
+            c.unsupportedFeature(value, field+"."+key)
+        }
+    }
+    p, err := predicate.JSONEquals(expected, options...)
+    if err != nil {
+        c.fail(n, "%s: %v", field, err)
+        return
+    }
+    spec.predicates = append(spec.predicates, p)
+}
+
+// hasJsonUnitPlaceholder reports whether any string in the node uses a JsonUnit placeholder
+func hasJsonUnitPlaceholder(n *mapping.Node) bool {
+    switch n.Kind {
+    case mapping.KindString:
+        return strings.Contains(n.Value, "${json-unit.")
+    case mapping.KindMap:
+        for _, key := range n.Keys {
+            if hasJsonUnitPlaceholder(n.Map[key]) {
+                return true
+            }
+        }
+    case mapping.KindSeq:
+        for _, item := range n.Items {
+            if hasJsonUnitPlaceholder(item) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+func (c *converter) matchesJsonPath(n *mapping.Node, field string, spec *mappingSpec) {
+    var expression *mapping.Node
+    var matcher *valueMatcher
+    switch n.Kind {
+    case mapping.KindString:
+        expression = n
+    case mapping.KindMap:
+        expression = n.Get("expression")
+        if expression == nil {
+            c.fail(n, "%s is missing the \"expression\"", field)
+            return
+        }
+        rest := &mapping.Node{Kind: mapping.KindMap, Line: n.Line, Map: make(map[string]*mapping.Node)}
+        for _, key := range n.Keys {
+            if key != "expression" {
+                rest.Keys = append(rest.Keys, key)
+                rest.Map[key] = n.Map[key]
+            }
+        }
+        if matcher = c.matcher(rest, field); matcher == nil {
+            return
+        }
+    default:
+        c.fail(n, "%s must be a string or a mapping but found a %s", field, n.Kind)
+        return
+    }
+
+    text := c.text(expression, field)
+    if _, err := predicate.JSONPath(text, nil); err != nil {
+        c.unsupported = append(
+            c.unsupported,
+            &mapping.ValidationError{File: c.file, Line: expression.Line, Msg: fmt.Sprintf("%s: %v", field, err)},
+        )
+        return
+    }
+    subject := func(m predicate.Matcher) predicate.Predicate {
+        p, _ := predicate.JSONPath(text, m)
+        return p
+    }
+    if matcher == nil {
+        spec.predicates = append(spec.predicates, subject(nil))
+        return
+    }
+    spec.predicates = append(spec.predicates, matcher.predicate(subject))
+}
+
+func (c *converter) response(response *mapping.Node, spec *mappingSpec) {
+    bodies := []string{}
+    for _, key := range response.Keys {
+        value := response.Map[key]
+        field := "response." + key
+        switch key {
+        case "status":
+            spec.status = c.integer(value, field)
+            if spec.status < 100 || spec.status > 999 {
+                c.fail(value, "%s must be between 100 and 999", field)
+            }
+        case "headers":
+            c.responseHeaders(value, field, spec)
+        case "body":
+            spec.body = []byte(c.text(value, field))
+            bodies = append(bodies, key)
+        case "jsonBody":
+            encoded, err := json.Marshal(value.Interface())
+            if err != nil {
+                c.fail(value, "%s: %v", field, err)
+            }
+            spec.body = encoded
+            bodies = append(bodies, key)
+        case "base64Body":
+            decoded, err := base64.StdEncoding.DecodeString(c.text(value, field))
+            if err != nil {
+                c.fail(value, "%s: %v", field, err)
+            }
+            spec.body = decoded
+            bodies = append(bodies, key)
+        case "bodyFileName":
+            c.bodyFile(value, field, spec)
+            bodies = append(bodies, key)
+        case "fixedDelayMilliseconds":
+            spec.delay = time.Duration(c.integer(value, field)) * time.Millisecond
+        default:
+            c.unsupportedFeature(value, field)
+        }
+    }
+    if len(bodies) > 1 {
+        c.fail(response, "only one of body, jsonBody, base64Body or bodyFileName may be used but found %s", strings.Join(bodies, " and "))
+    }
+}
+
+func (c *converter) responseHeaders(n *mapping.Node, field string, spec *mappingSpec) {
+    if !c.kind(n, field, mapping.KindMap) {
+        return
+    }
+    for _, name := range n.Keys {
+        value := n.Map[name]
+        if value.Kind == mapping.KindSeq {
+            for _, item := range value.Items {
+                spec.headers = append(spec.headers, [2]string{name, c.text(item, field+"."+name)})
+            }
+            continue
+        }
+        spec.headers = append(spec.headers, [2]string{name, c.text(value, field+"."+name)})
+    }
+}
+
+func (c *converter) bodyFile(n *mapping.Node, field string, spec *mappingSpec) {
+    name := c.text(n, field)
+    if len(c.filesDir) == 0 {
+        c.fail(n, "%s %q requires the importer's FilesDir", field, name)
+        return
+    }
+    data, err := ioutil.ReadFile(filepath.Join(c.filesDir, filepath.FromSlash(name)))
+    if err != nil {
+        c.fail(n, "unable to read %s: %v", field, err)
+        return
+    }
+    spec.body = data
+}
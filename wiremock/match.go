@@ -0,0 +1,38 @@
+package wiremock
+
+import (
+    "regexp"
+
+    "github.com/TestInABox/gostackinabox/predicate"
+)
+
+// valueMatcher is a WireMock string matcher such as `{"equalTo": "x", "caseInsensitive": true}`
+type valueMatcher struct {
+    op              string
+    value           string
+    caseInsensitive bool
+    pattern         *regexp.Regexp
+}
+
+// predicate applies the matcher to the value the subject selects, e.g a header via predicate.Header;
+// the subject is given a nil matcher to check the value is present
+func (vm *valueMatcher) predicate(subject func(predicate.Matcher) predicate.Predicate) predicate.Predicate {
+    switch vm.op {
+    case "absent":
+        return predicate.Not(subject(nil))
+    case "present":
+        return subject(nil)
+    case "contains":
+        return subject(predicate.Contains(vm.value))
+    case "doesNotContain":
+        return predicate.Not(subject(predicate.Contains(vm.value)))
+    case "matches":
+        return subject(predicate.Regex(vm.pattern))
+    case "doesNotMatch":
+        return predicate.Not(subject(predicate.Regex(vm.pattern)))
+    }
+    if vm.caseInsensitive {
+        return subject(predicate.EqualsFold(vm.value))
+    }
+    return subject(predicate.Equals(vm.value))
+}
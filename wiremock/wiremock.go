@@ -0,0 +1,196 @@
+package wiremock
+
+import (
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net/url"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/TestInABox/gostackinabox/common/log"
+    "github.com/TestInABox/gostackinabox/mapping"
+    "github.com/TestInABox/gostackinabox/stub"
+)
+
+/*
+    The wiremock package imports WireMock stub mappings, i.e the
+    `mappings/*.json` and `__files/` directories partner teams already
+    maintain, and registers them with a stub.Registry:
+
+        importer := &wiremock.Importer{BaseURL: "https://partner.example"}
+        if err := importer.LoadDir(stubs, "testdata/partner"); err != nil {
+            ...
+        }
+
+    Supported request matching: method (including ANY), url, urlPath,
+    urlPattern, urlPathPattern, urlPathTemplate with pathParameters,
+    queryParameters, headers, cookies and basicAuthCredentials using the
+    equalTo (with caseInsensitive), contains, doesNotContain, matches,
+    doesNotMatch and absent matchers, and bodyPatterns using those matchers
+    plus equalToJson (with ignoreArrayOrder and ignoreExtraElements) and
+    matchesJsonPath.
+
+    Supported responses: status, headers, body, jsonBody, base64Body,
+    bodyFileName and fixedDelayMilliseconds, along with priority and the
+    scenarioName, requiredScenarioState and newScenarioState state machine.
+
+    Anything else (faults, transformers, proxying, XML matchers, JsonUnit
+    placeholders such as `${json-unit.any-string}`, ...) is reported with the
+    file and line it was found on. Regular expressions use Go's RE2 syntax so
+    Java-only constructs such as lookaheads are reported too.
+    Every mapping is checked, including its path and any conflict with services
+    the router already has, before anything is registered.
+
+    WireMock mappings don't name a host so every mapping is served on the
+    importer's BaseURL. Mappings are registered in priority order (lowest
+    first, WireMock's default of 5 when unset) and then in file order.
+ */
+
+var (
+    ErrInvalidImporter error = errors.New("WireMock: Importer requires an absolute BaseURL")
+)
+
+const defaultPriority = 5
+
+type Importer struct {
+    // BaseURL is the scheme://host[:port] the mappings are served on
+    BaseURL string
+    // FilesDir is where bodyFileName is resolved from; LoadDir defaults it to `<dir>/__files`
+    FilesDir string
+    // SkipUnsupported registers the mappings that only use supported features and logs the
+    // others instead of failing the import
+    SkipUnsupported bool
+}
+
+// LoadDir imports every .json file under `<dir>/mappings`, in name order
+func (im *Importer) LoadDir(stubs *stub.Registry, dir string) (err error) {
+    files := []string{}
+    err = filepath.Walk(
+        filepath.Join(dir, "mappings"),
+        func(path string, info os.FileInfo, walkErr error) error {
+            if walkErr != nil {
+                return walkErr
+            }
+            if !info.IsDir() && strings.ToLower(filepath.Ext(path)) == ".json" {
+                files = append(files, path)
+            }
+            return nil
+        },
+    )
+    if err != nil {
+        return
+    }
+    sort.Strings(files)
+
+    importer := *im
+    if len(importer.FilesDir) == 0 {
+        importer.FilesDir = filepath.Join(dir, "__files")
+    }
+    return importer.LoadFiles(stubs, files...)
+}
+
+// LoadFiles imports the mapping files; each holds a single mapping or a `mappings` list
+func (im *Importer) LoadFiles(stubs *stub.Registry, files ...string) (err error) {
+    baseUrl, parseErr := url.Parse(im.BaseURL)
+    if parseErr != nil || len(baseUrl.Host) == 0 {
+        err = fmt.Errorf("%w: %q", ErrInvalidImporter, im.BaseURL)
+        return
+    }
+    base := strings.TrimRight(im.BaseURL, "/")
+
+    var problems, unsupported mapping.ValidationErrors
+    specs := []*mappingSpec{}
+    for _, file := range files {
+        c := &converter{file: file, filesDir: im.FilesDir}
+        specs = append(specs, c.parseFile()...)
+        problems = append(problems, c.problems...)
+        unsupported = append(unsupported, c.unsupported...)
+    }
+
+    if im.SkipUnsupported {
+        for _, problem := range unsupported {
+            log.Printf("Skipping WireMock mapping: %v", problem)
+        }
+    } else {
+        problems = append(problems, unsupported...)
+    }
+    for _, spec := range specs {
+        if checkErr := stubs.Check(spec.url(base)); checkErr != nil {
+            problems = append(problems, &mapping.ValidationError{File: spec.file, Line: spec.line, Msg: checkErr.Error()})
+        }
+    }
+    if len(problems) > 0 {
+        sort.SliceStable(
+            problems,
+            func(i, j int) bool {
+                if problems[i].File != problems[j].File {
+                    return problems[i].File < problems[j].File
+                }
+                return problems[i].Line < problems[j].Line
+            },
+        )
+        err = problems
+        return
+    }
+
+    sort.SliceStable(
+        specs,
+        func(i, j int) bool {
+            return specs[i].priority < specs[j].priority
+        },
+    )
+    for _, spec := range specs {
+        log.Printf("Registering WireMock mapping %s:%d", spec.file, spec.line)
+        if registerErr := spec.register(stubs, base); registerErr != nil {
+            err = fmt.Errorf("%s:%d: %w", spec.file, spec.line, registerErr)
+            return
+        }
+    }
+    return
+}
+
+func (c *converter) parseFile() (specs []*mappingSpec) {
+    data, readErr := ioutil.ReadFile(c.file)
+    if readErr != nil {
+        c.problems = append(c.problems, &mapping.ValidationError{File: c.file, Msg: readErr.Error()})
+        return
+    }
+    root, parseErr := mapping.ParseJSON(data)
+    if parseErr != nil {
+        var ve *mapping.ValidationError
+        if errors.As(parseErr, &ve) {
+            ve.File = c.file
+            c.problems = append(c.problems, ve)
+        } else {
+            c.problems = append(c.problems, &mapping.ValidationError{File: c.file, Msg: parseErr.Error()})
+        }
+        return
+    }
+
+    entries := []*mapping.Node{}
+    switch {
+    case root.Get("mappings") != nil:
+        for _, key := range root.Keys {
+            if key != "mappings" && key != "meta" {
+                c.unsupportedFeature(root.Map[key], key)
+            }
+        }
+        if mappings := root.Get("mappings"); c.kind(mappings, "mappings", mapping.KindSeq) {
+            entries = mappings.Items
+        }
+    case root.Kind == mapping.KindMap:
+        entries = []*mapping.Node{root}
+    default:
+        c.fail(root, "expected a mapping or a `mappings` list but found a %s", root.Kind)
+    }
+
+    for _, entry := range entries {
+        if spec := c.mapping(entry); spec != nil {
+            specs = append(specs, spec)
+        }
+    }
+    return
+}
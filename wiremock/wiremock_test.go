@@ -0,0 +1,416 @@
+package wiremock_test
+
+import (
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/TestInABox/gostackinabox/common"
+    "github.com/TestInABox/gostackinabox/mapping"
+    "github.com/TestInABox/gostackinabox/router"
+    "github.com/TestInABox/gostackinabox/service"
+    "github.com/TestInABox/gostackinabox/stub"
+    "github.com/TestInABox/gostackinabox/wiremock"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+    dir := t.TempDir()
+    for name, content := range files {
+        path := filepath.Join(dir, name)
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatalf("Unable to create directory: %v", err)
+        }
+        if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatalf("Unable to write %s: %v", name, err)
+        }
+    }
+    return dir
+}
+
+func Test_WireMock_LoadDir(t *testing.T) {
+    dir := writeFiles(
+        t,
+        map[string]string{
+            "mappings/users.json": `{
+  "mappings": [
+    {
+      "request": {
+        "method": "GET",
+        "urlPath": "/v1/users",
+        "queryParameters": {"active": {"equalTo": "TRUE", "caseInsensitive": true}},
+        "headers": {"Accept": {"contains": "json"}}
+      },
+      "response": {"status": 200, "jsonBody": [{"id": 1}], "headers": {"Content-Type": "application/json"}}
+    },
+    {
+      "priority": 10,
+      "request": {"method": "ANY", "urlPathPattern": "/v1/.*"},
+      "response": {"status": 404, "body": "fallback"}
+    },
+    {
+      "priority": 1,
+      "request": {"method": "GET", "urlPathPattern": "/v1/users/[0-9]+/avatar"},
+      "response": {"status": 200, "bodyFileName": "avatar.txt", "fixedDelayMilliseconds": 1}
+    },
+    {
+      "request": {
+        "method": "GET",
+        "urlPathTemplate": "/v1/users/{id}",
+        "pathParameters": {"id": {"matches": "[0-9]+"}}
+      },
+      "response": {"status": 200, "body": "user"}
+    }
+  ]
+}`,
+            "mappings/orders.json": `{
+  "request": {
+    "method": "POST",
+    "url": "/v1/orders?dry=true",
+    "basicAuthCredentials": {"username": "bob", "password": "secret"},
+    "bodyPatterns": [
+      {"equalToJson": "{\"item\": \"book\"}", "ignoreExtraElements": true},
+      {"matchesJsonPath": "$.quantity"},
+      {"matchesJsonPath": {"expression": "$.tags[*]", "equalTo": "gift"}}
+    ]
+  },
+  "response": {"status": 201, "base64Body": "b2s="}
+}`,
+            "mappings/todo.json": `{
+  "mappings": [
+    {
+      "scenarioName": "todo",
+      "requiredScenarioState": "Started",
+      "request": {"method": "GET", "url": "/todo/1"},
+      "response": {"status": 404}
+    },
+    {
+      "scenarioName": "todo",
+      "newScenarioState": "created",
+      "request": {"method": "POST", "url": "/todo"},
+      "response": {"status": 201}
+    },
+    {
+      "scenarioName": "todo",
+      "requiredScenarioState": "created",
+      "request": {"method": "GET", "url": "/todo/1"},
+      "response": {"status": 200}
+    }
+  ]
+}`,
+            "__files/avatar.txt": "AVATAR",
+        },
+    )
+
+    r := router.New()
+    stubs := stub.New(r)
+    importer := &wiremock.Importer{BaseURL: "https://partner.example/"}
+    if err := importer.LoadDir(stubs, dir); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    client := &http.Client{Transport: r}
+
+    type TestScenario struct {
+        name    string
+        method  string
+        target  string
+        headers map[string]string
+        body    string
+        status  int
+        reply   string
+    }
+
+    var TestScenarios = []TestScenario{
+        {
+            name: "query and header matchers",
+            method: "GET",
+            target: "https://partner.example/v1/users?active=true",
+            headers: map[string]string{"Accept": "application/json"},
+            status: 200,
+            reply: `[{"id":1}]`,
+        },
+        {
+            name: "lower priority pattern catches the rest",
+            method: "DELETE",
+            target: "https://partner.example/v1/users",
+            status: 404,
+            reply: "fallback",
+        },
+        {
+            name: "higher priority pattern and body file",
+            method: "GET",
+            target: "https://partner.example/v1/users/7/avatar",
+            status: 200,
+            reply: "AVATAR",
+        },
+        {
+            name: "path template with path parameters",
+            method: "GET",
+            target: "https://partner.example/v1/users/7",
+            status: 200,
+            reply: "user",
+        },
+        {
+            name: "path parameter mismatch falls through",
+            method: "GET",
+            target: "https://partner.example/v1/users/bob",
+            status: 404,
+        },
+        {
+            name: "body patterns and basic auth",
+            method: "POST",
+            target: "https://partner.example/v1/orders?dry=true",
+            headers: map[string]string{"Authorization": "Basic Ym9iOnNlY3JldA=="},
+            body: `{"item": "book", "quantity": 2, "tags": ["new", "gift"]}`,
+            status: 201,
+            reply: "ok",
+        },
+        {
+            name: "json path mismatch",
+            method: "POST",
+            target: "https://partner.example/v1/orders?dry=true",
+            headers: map[string]string{"Authorization": "Basic Ym9iOnNlY3JldA=="},
+            body: `{"item": "book", "quantity": 2, "tags": ["new"]}`,
+            status: 404,
+            reply: "fallback",
+        },
+        {
+            name: "exact url requires the query",
+            method: "POST",
+            target: "https://partner.example/v1/orders",
+            headers: map[string]string{"Authorization": "Basic Ym9iOnNlY3JldA=="},
+            body: `{"item": "book", "quantity": 2, "tags": ["gift"]}`,
+            status: 404,
+            reply: "fallback",
+        },
+        {
+            name: "exact url rejects extra query parameters",
+            method: "POST",
+            target: "https://partner.example/todo?extra=1",
+            status: 597,
+        },
+        {
+            name: "scenario starts",
+            method: "GET",
+            target: "https://partner.example/todo/1",
+            status: 404,
+        },
+        {
+            name: "scenario transition",
+            method: "POST",
+            target: "https://partner.example/todo",
+            status: 201,
+        },
+        {
+            name: "scenario new state",
+            method: "GET",
+            target: "https://partner.example/todo/1",
+            status: 200,
+        },
+    }
+
+    for _, scenario := range TestScenarios {
+        t.Run(
+            scenario.name,
+            func(t *testing.T) {
+                request, _ := http.NewRequest(scenario.method, scenario.target, strings.NewReader(scenario.body))
+                for name, value := range scenario.headers {
+                    request.Header.Set(name, value)
+                }
+                response, err := client.Do(request)
+                if err != nil {
+                    t.Fatalf("Unexpected error: %v", err)
+                }
+                body, _ := ioutil.ReadAll(response.Body)
+                if response.StatusCode != scenario.status {
+                    t.Errorf("Unexpected status: %d != %d (%s)", response.StatusCode, scenario.status, body)
+                }
+                if len(scenario.reply) > 0 && string(body) != scenario.reply {
+                    t.Errorf("Unexpected body: %s != %s", body, scenario.reply)
+                }
+            },
+        )
+    }
+
+    if state := stubs.Scenario("todo").State(); state != "created" {
+        t.Errorf("Unexpected scenario state: %s", state)
+    }
+}
+
+func Test_WireMock_Priority_AcrossPaths(t *testing.T) {
+    // the patterns share a path group so the exact url has to be ordered between them
+    dir := writeFiles(
+        t,
+        map[string]string{
+            "mappings/priority.json": `{
+  "mappings": [
+    {
+      "priority": 3,
+      "request": {"method": "GET", "urlPattern": "/.*"},
+      "response": {"status": 200, "body": "catch all"}
+    },
+    {
+      "priority": 2,
+      "request": {"method": "GET", "url": "/y"},
+      "response": {"status": 200, "body": "y"}
+    },
+    {
+      "priority": 1,
+      "request": {"method": "GET", "urlPattern": "/x.*"},
+      "response": {"status": 200, "body": "x"}
+    }
+  ]
+}`,
+        },
+    )
+
+    r := router.New()
+    if err := (&wiremock.Importer{BaseURL: "https://partner.example"}).LoadDir(stub.New(r), dir); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    client := &http.Client{Transport: r}
+    for target, expected := range map[string]string{"/x": "x", "/y": "y", "/z": "catch all"} {
+        response, err := client.Get("https://partner.example" + target)
+        if err != nil {
+            t.Fatalf("Unexpected error: %v", err)
+        }
+        body, _ := ioutil.ReadAll(response.Body)
+        if string(body) != expected {
+            t.Errorf("Unexpected reply for %s: %s != %s", target, body, expected)
+        }
+    }
+}
+
+func Test_WireMock_RegistrationChecks(t *testing.T) {
+    dir := writeFiles(
+        t,
+        map[string]string{
+            "mappings/checks.json": `{
+  "request": {"method": "GET", "urlPath": "/ok"},
+  "response": {"status": 200}
+}`,
+        },
+    )
+
+    r := router.New()
+    taken := &service.ServiceHandler{
+        Matcher: &common.BasicServerURI{
+            Protocol: "https",
+            Host: "taken.example",
+        },
+    }
+    if err := r.RegisterService("https://taken.example", taken); err != nil {
+        t.Fatalf("Unexpected error registering service: %v", err)
+    }
+
+    err := (&wiremock.Importer{BaseURL: "https://taken.example"}).LoadDir(stub.New(r), dir)
+    if !errors.Is(err, mapping.ErrInvalidMapping) {
+        t.Fatalf("Unexpected error: %v", err)
+    }
+    message := err.Error()
+    for _, fragment := range []string{"checks.json:1:", stub.ErrServiceConflict.Error()} {
+        if !strings.Contains(message, fragment) {
+            t.Errorf("Error is missing %q:\n%s", fragment, message)
+        }
+    }
+    if len(r.RequestHandlers) != 1 {
+        t.Errorf("Services were registered despite validation errors: %v", r.RequestHandlers)
+    }
+}
+
+func Test_WireMock_Unsupported(t *testing.T) {
+    dir := writeFiles(
+        t,
+        map[string]string{
+            "mappings/unsupported.json": `{
+  "mappings": [
+    {
+      "request": {"method": "GET", "urlPath": "/fault"},
+      "response": {"fault": "CONNECTION_RESET_BY_PEER"}
+    },
+    {
+      "request": {
+        "method": "POST",
+        "urlPathPattern": "/(?!admin).*",
+        "bodyPatterns": [{"equalToXml": "<a/>"}, {"matchesJsonPath": "$.items[?(@.id == 1)]"}]
+      },
+      "response": {"status": 200, "transformers": ["response-template"]}
+    },
+    {
+      "request": {"method": "GET", "urlPath": "/ok"},
+      "response": {"status": 200, "body": "ok"}
+    },
+    {
+      "request": {
+        "method": "PUT",
+        "urlPath": "/ids",
+        "bodyPatterns": [{"equalToJson": {"id": "${json-unit.any-string}"}}]
+      },
+      "response": {"status": 204}
+    }
+  ]
+}`,
+            "mappings/invalid.json": `{
+  "request": {"method": "GET", "url": "/a", "urlPath": "/b"},
+  "response": {"status": "abc"}
+}`,
+        },
+    )
+
+    t.Run(
+        "reported",
+        func(t *testing.T) {
+            r := router.New()
+            err := (&wiremock.Importer{BaseURL: "https://partner.example"}).LoadDir(stub.New(r), dir)
+            if !errors.Is(err, mapping.ErrInvalidMapping) {
+                t.Fatalf("Unexpected error: %v", err)
+            }
+            expected := []string{
+                "invalid.json:2: only one of url",
+                "invalid.json:3: response.status",
+                "unsupported.json:5: unsupported WireMock feature \"response.fault\"",
+                "unsupported.json:10: request.urlPathPattern: unsupported regular expression",
+                "unsupported.json:11: unsupported WireMock feature \"request.bodyPatterns.equalToXml\"",
+                "unsupported.json:11: request.bodyPatterns.matchesJsonPath:",
+                "unsupported.json:13: unsupported WireMock feature \"response.transformers\"",
+                "unsupported.json:23: unsupported WireMock feature \"request.bodyPatterns.equalToJson.json-unit\"",
+            }
+            message := err.Error()
+            for _, fragment := range expected {
+                if !strings.Contains(message, fragment) {
+                    t.Errorf("Error is missing %q:\n%s", fragment, message)
+                }
+            }
+            if len(r.RequestHandlers) != 0 {
+                t.Errorf("Services were registered despite errors: %v", r.RequestHandlers)
+            }
+        },
+    )
+    t.Run(
+        "skipped",
+        func(t *testing.T) {
+            os.Remove(filepath.Join(dir, "mappings", "invalid.json"))
+            r := router.New()
+            importer := &wiremock.Importer{BaseURL: "https://partner.example", SkipUnsupported: true}
+            if err := importer.LoadDir(stub.New(r), dir); err != nil {
+                t.Fatalf("Unexpected error: %v", err)
+            }
+            response, err := (&http.Client{Transport: r}).Get("https://partner.example/ok")
+            if err != nil || response.StatusCode != 200 {
+                t.Errorf("Supported mapping was not imported: %v %v", response, err)
+            }
+        },
+    )
+    t.Run(
+        "base url",
+        func(t *testing.T) {
+            err := (&wiremock.Importer{BaseURL: "/relative"}).LoadDir(stub.New(router.New()), dir)
+            if !errors.Is(err, wiremock.ErrInvalidImporter) {
+                t.Errorf("Unexpected error: %v", err)
+            }
+        },
+    )
+}